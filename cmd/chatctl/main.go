@@ -0,0 +1,66 @@
+// Команда chatctl — администрирование чата: пользователи и комнаты напрямую через БД,
+// онлайн, кик и объявления — через admin API работающего сервера.
+//
+//	chatctl users list
+//	chatctl users create alice -admin
+//	chatctl rooms list
+//	chatctl ban alice -reason spam
+//	chatctl announce "Перезапуск в 22:00"
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/go-portfolio/websocket-chat/config"
+	"github.com/go-portfolio/websocket-chat/internal/chatctl"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	// .env не обязателен, переменные окружения имеют приоритет
+	_ = godotenv.Load()
+
+	flags := flag.NewFlagSet("chatctl", flag.ExitOnError)
+	c := &chatctl.Ctl{Server: &chatctl.AdminClient{}}
+	flags.StringVar(&c.DatabaseURL, "database-url", os.Getenv("DATABASE_URL"), "строка подключения к PostgreSQL")
+	flags.StringVar(&c.Server.BaseURL, "server", envOr("CHAT_SERVER", "http://localhost:8080"), "адрес работающего сервера для admin API")
+	flags.StringVar(&c.Server.Token, "admin-token", os.Getenv("ADMIN_TOKEN"), "токен admin API (admin-token сервера)")
+	flags.IntVar(&c.Policy.MinLength, "password-min-length", envInt("password-min-length", config.Default().PasswordMinLength), "минимальная длина пароля, как у сервера")
+	flags.StringVar(&c.BreachedFile, "password-breached-file", os.Getenv(config.EnvName("password-breached-file")), "файл утёкших паролей, как у сервера")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), chatctl.Usage)
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(os.Args[1:])
+
+	err := c.Run(flags.Args())
+	c.Close()
+	if errors.Is(err, chatctl.ErrUsage) {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "chatctl:", err)
+		os.Exit(1)
+	}
+}
+
+func envOr(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+// envInt читает число из переменной окружения флага сервера ("password-min-length" ->
+// PASSWORD_MIN_LENGTH); без переменной или при ошибке — def
+func envInt(flagName string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(config.EnvName(flagName))); err == nil {
+		return v
+	}
+	return def
+}
@@ -1,24 +1,44 @@
+// Команда server — HTTP- и WebSocket-сервер чата.
+//
+//	server [flags]                 запуск сервера
+//	server migrate up [flags]      применить все миграции
+//	server migrate down [flags]    откатить последнюю миграцию
+//	server migrate to N [flags]    привести схему к версии N (0 — откатить всё)
+//	server migrate status [flags]  текущая версия и ожидающие миграции
 package main
 
 import (
+	"errors"
+	"flag"
 	"log"
 	"net/http"
+	"os"
 
-	"github.com/joho/godotenv"
+	"github.com/go-portfolio/websocket-chat/config"
 	"github.com/go-portfolio/websocket-chat/internal/app"
 )
 
 func main() {
-	// Загружаем .env
-	_ = godotenv.Load("../../.env")
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "migrate" {
+		os.Exit(runMigrate(args[1:]))
+	}
+
+	// Загружаем и проверяем конфигурацию (.env, файл, окружение, флаги)
+	cfg, err := config.Load(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Создаём приложение
-	a := app.New()
+	a := app.New(cfg)
 
 	// Запуск сервера
-	addr := ":8080"
-	log.Printf("Server listening on %s", addr)
-	if err := http.ListenAndServe(addr, a.Mux); err != nil {
+	log.Printf("Server listening on %s", cfg.ListenAddr)
+	if err := http.ListenAndServe(cfg.ListenAddr, a.Mux); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/go-portfolio/websocket-chat/config"
+	"github.com/go-portfolio/websocket-chat/internal/app"
+	"github.com/go-portfolio/websocket-chat/internal/migrate"
+	"github.com/go-portfolio/websocket-chat/internal/user"
+)
+
+const migrateUsage = "usage: server migrate up|down|status|to <version> [flags]"
+
+// runMigrate выполняет подкоманду migrate и возвращает код выхода.
+// Флаги и переменные окружения те же, что у сервера (нужен database-url).
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	cmd, args := args[0], args[1:]
+
+	target := 0
+	if cmd == "to" {
+		if len(args) == 0 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+		v, err := strconv.Atoi(args[0])
+		if err != nil || v < 0 {
+			fmt.Fprintf(os.Stderr, "invalid version %q\n", args[0])
+			return 2
+		}
+		target, args = v, args[1:]
+	}
+
+	cfg, err := config.Load(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	store, err := user.NewStore(cfg.DatabaseURL)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer store.Close()
+
+	m := app.Migrator(store.Db)
+	ctx := context.Background()
+	switch cmd {
+	case "up":
+		err = m.Up(ctx)
+	case "down":
+		err = m.Down(ctx)
+	case "to":
+		err = m.To(ctx, target)
+	case "status":
+		err = printStatus(ctx, m)
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func printStatus(ctx context.Context, m *migrate.Migrator) error {
+	st, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	state := "clean"
+	if st.Dirty {
+		state = "dirty"
+	}
+	fmt.Printf("version: %d (%s)\n", st.Version, state)
+	for _, mig := range st.Applied {
+		fmt.Printf("  applied  %03d_%s\n", mig.Version, mig.Name)
+	}
+	for _, mig := range st.Pending {
+		fmt.Printf("  pending  %03d_%s\n", mig.Version, mig.Name)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+// Команда slack-import переносит историю из архива экспорта Slack в базу чата:
+//
+//	go run ./cmd/slack-import -database-url postgres://... export.zip
+package main
+
+import (
+	"archive/zip"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/go-portfolio/websocket-chat/internal/message"
+	"github.com/go-portfolio/websocket-chat/internal/room"
+	"github.com/go-portfolio/websocket-chat/internal/slackimport"
+	"github.com/go-portfolio/websocket-chat/internal/user"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	// .env не обязателен, переменные окружения имеют приоритет
+	_ = godotenv.Load()
+
+	flags := flag.NewFlagSet("slack-import", flag.ExitOnError)
+	databaseURL := flags.String("database-url", os.Getenv("DATABASE_URL"), "строка подключения к PostgreSQL")
+	batchSize := flags.Int("batch-size", 500, "сколько сообщений сохранять за один запрос")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: slack-import [flags] export.zip")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(os.Args[1:])
+
+	if flags.NArg() != 1 || *databaseURL == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	archive, err := zip.OpenReader(flags.Arg(0))
+	if err != nil {
+		log.Fatalf("failed to open archive: %v", err)
+	}
+	defer archive.Close()
+
+	store, err := user.NewStore(*databaseURL)
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+	defer store.Close()
+
+	importer := &slackimport.Importer{
+		Users:     store,
+		Rooms:     room.NewStore(store.Db),
+		Messages:  message.NewStore(store.Db),
+		BatchSize: *batchSize,
+	}
+	stats, err := importer.Import(&archive.Reader)
+	log.Printf("slack import: %d room(s), %d new user(s) (%d renamed), %d message(s), %d skipped",
+		stats.Rooms, stats.Users, stats.Renamed, stats.Messages, stats.Skipped)
+	if err != nil {
+		log.Fatalf("slack import failed: %v", err)
+	}
+}
@@ -1,24 +1,620 @@
 package config
 
 import (
-	"log"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/mail"
+	"net/url"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
 )
 
-// Config хранит все переменные окружения для проекта.
+// Config хранит все настраиваемые параметры проекта.
+//
+// Значения собираются из нескольких источников, в порядке возрастания приоритета:
+//  1. значения по умолчанию (Default);
+//  2. необязательный JSON-файл конфигурации (-config / CONFIG_FILE);
+//  3. переменные окружения (имя флага в верхнем регистре, "-" заменён на "_"),
+//     в том числе прочитанные из .env-файла (-env-file / ENV_FILE);
+//  4. флаги командной строки.
 type Config struct {
+	// Файл конфигурации и .env-файл (только флаг или переменная окружения)
+	ConfigFile string
+	EnvFile    string
+
+	// HTTP-сервер; PublicURL — внешний адрес чата для ссылок в письмах
+	ListenAddr string
+	PublicURL  string
+
+	// База данных; AutoMigrate — применять миграции при старте сервера
 	DatabaseURL string
+	AutoMigrate bool
+
+	// JWT и cookie авторизации: короткоживущий access-токен (JWTTTL)
+	// и ротируемый refresh-токен сессии (RefreshTTL)
+	JWTSecret  string
+	JWTTTL     time.Duration
+	RefreshTTL time.Duration
+
+	// Ключи подписи JWT из файлов (PEM RSA/Ed25519 или секрет HS256) вместо JWTSecret;
+	// предыдущий ключ принимается ещё JWTRotationWindow после ротации: момента JWTRotatedAt
+	// (RFC 3339) или, если он не задан, времени изменения JWTKeyFile
+	JWTKeyFile        string
+	JWTKeyID          string
+	JWTPrevKeyFile    string
+	JWTPrevKeyID      string
+	JWTRotationWindow time.Duration
+	JWTRotatedAt      string
+
+	CookieName     string
+	CookieSecure   bool
+	CookieSameSite string
+
+	// Вход через OpenID Connect (пустой OIDCIssuer — отключён)
+	OIDCIssuer       string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string // адрес /api/oidc/callback, зарегистрированный у провайдера
+	OIDCScopes       string // через пробел
+	OIDCName         string // название провайдера на кнопке входа
+
+	// Двухфакторная аутентификация: название сервиса в приложении-аутентификаторе
+	TOTPIssuer string
+
+	// Политика паролей: минимальная длина и файл утёкших паролей (пусто — не проверяются)
+	PasswordMinLength    int
+	PasswordBreachedFile string
+
+	// Защита от перебора: после LoginFreeAttempts неудач в аккаунт (LoginIPFreeAttempts
+	// с одного IP) пауза между попытками растёт до LoginBackoffMax; счётчики забываются
+	// через LoginWindow без попыток. После LockoutThreshold неудач подряд аккаунт
+	// блокируется на LockoutDuration
+	LoginFreeAttempts   int
+	LoginIPFreeAttempts int
+	LoginWindow         time.Duration
+	LoginBackoffMax     time.Duration
+	LockoutThreshold    int
+	LockoutDuration     time.Duration
+	// Регистрации и запросы сброса пароля: SignupPerIP с одного IP за SignupWindow
+	SignupPerIP  int
+	SignupWindow time.Duration
+	// Обратные прокси (CIDR или IP через запятую), которым доверяется X-Forwarded-For
+	TrustedProxies string
+
+	// Загрузка файлов и статика
+	UploadDir     string
+	MaxUploadSize int64
+	StaticDir     string
+	AvatarMaxSize int64
+	AvatarTypes   string // MIME-типы через запятую
+
+	// Вложения в сообщениях
+	AttachmentMaxSize int64
+	AttachmentTypes   string // MIME-типы через запятую
+
+	// Превью ссылок
+	UnfurlEnabled  bool
+	UnfurlTimeout  time.Duration
+	UnfurlMaxBytes int64
+	UnfurlCacheTTL time.Duration
+	UnfurlAllow    string // CIDR или IP через запятую, к которым разрешено обращаться
+
+	// Почта и e-mail дайджесты: log (только в лог), smtp или none
+	MailBackend     string
+	MailFrom        string
+	SMTPAddr        string
+	SMTPUsername    string
+	SMTPPassword    string
+	DigestThreshold time.Duration // сколько пользователь должен быть не в сети
+	DigestInterval  time.Duration // как часто проверять, кому отправить дайджест
+
+	// Сброс пароля по ссылке из письма: срок действия ссылки
+	PasswordResetTTL time.Duration
+
+	// Токен admin API для chatctl; пусто — admin API только для администраторов по cookie
+	AdminToken string
+
+	// Срок хранения истории: глобальные ограничения (0 — без ограничения) и правила комнат
+	RetentionMaxAge    time.Duration
+	RetentionMaxCount  int
+	RetentionRooms     string // "комната=возраст/количество" через запятую, напр. "legal=720h,flood=/1000"
+	RetentionInterval  time.Duration
+	RetentionBatchSize int
+	RetentionUnsent    time.Duration // сколько хранить загруженные, но не отправленные вложения
+
+	// Хранилище файлов: local, memory или s3
+	StorageBackend string
+	S3Endpoint     string
+	S3Bucket       string
+	S3Region       string
+	S3AccessKey    string
+	S3SecretKey    string
+
+	// WebSocket
+	ReadLimit       int64
+	PongWait        time.Duration
+	PingPeriod      time.Duration
+	WriteWait       time.Duration
+	TicketTTL       time.Duration // срок действия одноразового билета для /ws
+	ReadBufferSize  int
+	WriteBufferSize int
+	SendBufferSize  int
+	HistorySize     int
+}
+
+// Default возвращает конфигурацию со значениями по умолчанию.
+func Default() *Config {
+	return &Config{
+		EnvFile:             ".env",
+		ListenAddr:          ":8080",
+		PublicURL:           "http://localhost:8080",
+		JWTTTL:              15 * time.Minute,
+		RefreshTTL:          30 * 24 * time.Hour,
+		JWTRotationWindow:   24 * time.Hour,
+		CookieName:          "auth",
+		CookieSecure:        false,
+		CookieSameSite:      "lax",
+		OIDCScopes:          "openid profile email",
+		OIDCName:            "OIDC",
+		TOTPIssuer:          "GoChat",
+		PasswordMinLength:   8,
+		LoginFreeAttempts:   3,
+		LoginIPFreeAttempts: 10,
+		LoginWindow:         15 * time.Minute,
+		LoginBackoffMax:     5 * time.Minute,
+		LockoutThreshold:    10,
+		LockoutDuration:     15 * time.Minute,
+		SignupPerIP:         5,
+		SignupWindow:        time.Hour,
+		UploadDir:           "uploads",
+		MaxUploadSize:       10 << 20,
+		AvatarMaxSize:       2 << 20,
+		AvatarTypes:         "image/png,image/jpeg,image/gif,image/webp",
+		AttachmentMaxSize:   10 << 20,
+		AttachmentTypes:     "image/png,image/jpeg,image/gif,image/webp,application/pdf,text/plain,application/zip",
+		UnfurlEnabled:       true,
+		UnfurlTimeout:       5 * time.Second,
+		UnfurlMaxBytes:      512 << 10,
+		UnfurlCacheTTL:      time.Hour,
+		MailBackend:         "log",
+		MailFrom:            "chat@localhost",
+		DigestThreshold:     time.Hour,
+		DigestInterval:      15 * time.Minute,
+		PasswordResetTTL:    time.Hour,
+		RetentionInterval:   time.Hour,
+		RetentionBatchSize:  500,
+		RetentionUnsent:     24 * time.Hour,
+		StorageBackend:      "local",
+		S3Region:            "us-east-1",
+		ReadLimit:           512,
+		PongWait:            60 * time.Second,
+		PingPeriod:          45 * time.Second,
+		WriteWait:           10 * time.Second,
+		TicketTTL:           30 * time.Second,
+		ReadBufferSize:      1024,
+		WriteBufferSize:     1024,
+		SendBufferSize:      16,
+		HistorySize:         50,
+	}
+}
+
+// flagSet регистрирует все параметры как флаги, привязанные к полям cfg.
+// Имя флага также определяет ключ в файле конфигурации и имя переменной окружения.
+func (c *Config) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+
+	fs.StringVar(&c.ConfigFile, "config", c.ConfigFile, "путь к JSON-файлу конфигурации")
+	fs.StringVar(&c.EnvFile, "env-file", c.EnvFile, "путь к .env-файлу (отсутствующий файл по умолчанию игнорируется)")
+
+	fs.StringVar(&c.ListenAddr, "listen-addr", c.ListenAddr, "адрес HTTP-сервера")
+	fs.StringVar(&c.PublicURL, "public-url", c.PublicURL, "внешний адрес чата для ссылок в письмах")
+	fs.StringVar(&c.DatabaseURL, "database-url", c.DatabaseURL, "строка подключения к PostgreSQL")
+	fs.BoolVar(&c.AutoMigrate, "auto-migrate", c.AutoMigrate, "применять миграции при запуске сервера")
+
+	fs.StringVar(&c.JWTSecret, "jwt-secret", c.JWTSecret, "секрет для подписи JWT")
+	fs.DurationVar(&c.JWTTTL, "jwt-ttl", c.JWTTTL, "время жизни access-токена (JWT)")
+	fs.DurationVar(&c.RefreshTTL, "refresh-ttl", c.RefreshTTL, "время жизни сессии без обновления refresh-токена")
+	fs.StringVar(&c.JWTKeyFile, "jwt-key-file", c.JWTKeyFile, "файл ключа подписи JWT: PEM RSA/Ed25519 или секрет HS256")
+	fs.StringVar(&c.JWTKeyID, "jwt-key-id", c.JWTKeyID, "kid ключа подписи (по умолчанию — отпечаток ключа)")
+	fs.StringVar(&c.JWTPrevKeyFile, "jwt-prev-key-file", c.JWTPrevKeyFile, "файл предыдущего ключа, токены которого ещё принимаются")
+	fs.StringVar(&c.JWTPrevKeyID, "jwt-prev-key-id", c.JWTPrevKeyID, "kid предыдущего ключа (по умолчанию — отпечаток ключа)")
+	fs.DurationVar(&c.JWTRotationWindow, "jwt-rotation-window", c.JWTRotationWindow, "сколько после ротации принимать токены предыдущего ключа")
+	fs.StringVar(&c.JWTRotatedAt, "jwt-rotated-at", c.JWTRotatedAt, "момент ротации ключа JWT в RFC 3339 (по умолчанию — время изменения jwt-key-file)")
+	fs.StringVar(&c.CookieName, "cookie-name", c.CookieName, "имя cookie авторизации")
+	fs.BoolVar(&c.CookieSecure, "cookie-secure", c.CookieSecure, "выставлять флаг Secure у cookie")
+	fs.StringVar(&c.CookieSameSite, "cookie-samesite", c.CookieSameSite, "SameSite для cookie: lax, strict или none")
+
+	fs.StringVar(&c.OIDCIssuer, "oidc-issuer", c.OIDCIssuer, "издатель OpenID Connect (пусто — вход через OIDC отключён)")
+	fs.StringVar(&c.OIDCClientID, "oidc-client-id", c.OIDCClientID, "client_id приложения у провайдера OIDC")
+	fs.StringVar(&c.OIDCClientSecret, "oidc-client-secret", c.OIDCClientSecret, "client_secret (пусто — публичный клиент, только PKCE)")
+	fs.StringVar(&c.OIDCRedirectURL, "oidc-redirect-url", c.OIDCRedirectURL, "полный адрес /api/oidc/callback, зарегистрированный у провайдера")
+	fs.StringVar(&c.OIDCScopes, "oidc-scopes", c.OIDCScopes, "запрашиваемые scope через пробел")
+	fs.StringVar(&c.OIDCName, "oidc-name", c.OIDCName, "название провайдера на кнопке входа")
+	fs.StringVar(&c.TOTPIssuer, "totp-issuer", c.TOTPIssuer, "название сервиса в приложении-аутентификаторе (2FA)")
+	fs.IntVar(&c.PasswordMinLength, "password-min-length", c.PasswordMinLength, "минимальная длина пароля в символах")
+	fs.StringVar(&c.PasswordBreachedFile, "password-breached-file", c.PasswordBreachedFile, "файл утёкших паролей: пароль или SHA-1 в строке (пусто — без проверки)")
+	fs.IntVar(&c.LoginFreeAttempts, "login-free-attempts", c.LoginFreeAttempts, "сколько неудачных входов в аккаунт допускается без паузы")
+	fs.IntVar(&c.LoginIPFreeAttempts, "login-ip-free-attempts", c.LoginIPFreeAttempts, "сколько неудачных входов с одного IP допускается без паузы")
+	fs.DurationVar(&c.LoginWindow, "login-window", c.LoginWindow, "через сколько без попыток забываются неудачные входы")
+	fs.DurationVar(&c.LoginBackoffMax, "login-backoff-max", c.LoginBackoffMax, "предельная пауза между неудачными попытками входа")
+	fs.IntVar(&c.LockoutThreshold, "lockout-threshold", c.LockoutThreshold, "после скольких неудачных входов подряд аккаунт блокируется")
+	fs.DurationVar(&c.LockoutDuration, "lockout-duration", c.LockoutDuration, "на сколько блокируется аккаунт")
+	fs.IntVar(&c.SignupPerIP, "signup-per-ip", c.SignupPerIP, "сколько регистраций и запросов сброса пароля допускается с одного IP за signup-window")
+	fs.DurationVar(&c.SignupWindow, "signup-window", c.SignupWindow, "окно для signup-per-ip")
+	fs.StringVar(&c.TrustedProxies, "trusted-proxies", c.TrustedProxies, "обратные прокси (CIDR/IP через запятую), которым доверяется X-Forwarded-For")
+
+	fs.StringVar(&c.UploadDir, "upload-dir", c.UploadDir, "каталог для загруженных файлов")
+	fs.Int64Var(&c.MaxUploadSize, "max-upload-size", c.MaxUploadSize, "максимальный размер загрузки в байтах")
+	fs.StringVar(&c.StaticDir, "static-dir", c.StaticDir, "каталог с UI вместо встроенного (для разработки)")
+	fs.Int64Var(&c.AvatarMaxSize, "avatar-max-size", c.AvatarMaxSize, "максимальный размер аватара в байтах")
+	fs.StringVar(&c.AvatarTypes, "avatar-types", c.AvatarTypes, "разрешённые MIME-типы аватаров через запятую")
+
+	fs.Int64Var(&c.AttachmentMaxSize, "attachment-max-size", c.AttachmentMaxSize, "максимальный размер вложения в байтах")
+	fs.StringVar(&c.AttachmentTypes, "attachment-types", c.AttachmentTypes, "разрешённые MIME-типы вложений через запятую")
+
+	fs.BoolVar(&c.UnfurlEnabled, "unfurl", c.UnfurlEnabled, "получать превью ссылок из сообщений")
+	fs.DurationVar(&c.UnfurlTimeout, "unfurl-timeout", c.UnfurlTimeout, "таймаут загрузки страницы для превью")
+	fs.Int64Var(&c.UnfurlMaxBytes, "unfurl-max-bytes", c.UnfurlMaxBytes, "сколько байт страницы читать для превью")
+	fs.DurationVar(&c.UnfurlCacheTTL, "unfurl-cache-ttl", c.UnfurlCacheTTL, "время жизни превью в кэше")
+	fs.StringVar(&c.UnfurlAllow, "unfurl-allow", c.UnfurlAllow, "приватные сети (CIDR/IP через запятую), для которых разрешены превью")
+
+	fs.StringVar(&c.MailBackend, "mail-backend", c.MailBackend, "отправка писем: log, smtp или none (без дайджестов)")
+	fs.StringVar(&c.MailFrom, "mail-from", c.MailFrom, "адрес отправителя писем")
+	fs.StringVar(&c.SMTPAddr, "smtp-addr", c.SMTPAddr, "адрес SMTP-сервера host:port")
+	fs.StringVar(&c.SMTPUsername, "smtp-username", c.SMTPUsername, "логин SMTP (пусто — без авторизации)")
+	fs.StringVar(&c.SMTPPassword, "smtp-password", c.SMTPPassword, "пароль SMTP")
+	fs.DurationVar(&c.DigestThreshold, "digest-threshold", c.DigestThreshold, "через сколько после ухода из сети отправлять e-mail дайджест")
+	fs.DurationVar(&c.DigestInterval, "digest-interval", c.DigestInterval, "период проверки e-mail дайджестов")
+	fs.DurationVar(&c.PasswordResetTTL, "password-reset-ttl", c.PasswordResetTTL, "срок действия ссылки сброса пароля")
+
+	fs.StringVar(&c.AdminToken, "admin-token", c.AdminToken, "токен admin API (Authorization: Bearer) для chatctl")
+
+	fs.DurationVar(&c.RetentionMaxAge, "retention-max-age", c.RetentionMaxAge, "сколько хранить сообщения (0 — бессрочно)")
+	fs.IntVar(&c.RetentionMaxCount, "retention-max-count", c.RetentionMaxCount, "сколько последних сообщений хранить в каждой комнате (0 — все)")
+	fs.StringVar(&c.RetentionRooms, "retention-rooms", c.RetentionRooms, "правила хранения комнат: комната=возраст/количество через запятую")
+	fs.DurationVar(&c.RetentionInterval, "retention-interval", c.RetentionInterval, "период очистки устаревших сообщений")
+	fs.IntVar(&c.RetentionBatchSize, "retention-batch-size", c.RetentionBatchSize, "сколько сообщений удалять за один запрос")
+	fs.DurationVar(&c.RetentionUnsent, "retention-unsent-attachments", c.RetentionUnsent, "сколько хранить загруженные, но не отправленные вложения (0 — бессрочно)")
+
+	fs.StringVar(&c.StorageBackend, "storage-backend", c.StorageBackend, "хранилище файлов: local, memory или s3")
+	fs.StringVar(&c.S3Endpoint, "s3-endpoint", c.S3Endpoint, "адрес S3-совместимого хранилища")
+	fs.StringVar(&c.S3Bucket, "s3-bucket", c.S3Bucket, "имя бакета S3")
+	fs.StringVar(&c.S3Region, "s3-region", c.S3Region, "регион S3")
+	fs.StringVar(&c.S3AccessKey, "s3-access-key", c.S3AccessKey, "ключ доступа S3")
+	fs.StringVar(&c.S3SecretKey, "s3-secret-key", c.S3SecretKey, "секретный ключ S3")
+
+	fs.Int64Var(&c.ReadLimit, "ws-read-limit", c.ReadLimit, "максимальный размер входящего WebSocket-сообщения в байтах")
+	fs.DurationVar(&c.PongWait, "ws-pong-wait", c.PongWait, "сколько ждать PONG от клиента")
+	fs.DurationVar(&c.PingPeriod, "ws-ping-period", c.PingPeriod, "период отправки PING")
+	fs.DurationVar(&c.WriteWait, "ws-write-wait", c.WriteWait, "таймаут записи в сокет")
+	fs.DurationVar(&c.TicketTTL, "ws-ticket-ttl", c.TicketTTL, "срок действия одноразового билета для подключения к /ws")
+	fs.IntVar(&c.ReadBufferSize, "ws-read-buffer", c.ReadBufferSize, "размер буфера чтения WebSocket")
+	fs.IntVar(&c.WriteBufferSize, "ws-write-buffer", c.WriteBufferSize, "размер буфера записи WebSocket")
+	fs.IntVar(&c.SendBufferSize, "ws-send-buffer", c.SendBufferSize, "размер очереди исходящих сообщений клиента")
+	fs.IntVar(&c.HistorySize, "history-size", c.HistorySize, "сколько последних сообщений комнаты хранить в памяти")
+
+	return fs
 }
 
-// Load загружает конфигурацию из .env или переменных окружения.
-func Load() *Config {
+// EnvName возвращает имя переменной окружения для флага: "ws-read-limit" -> "WS_READ_LIMIT".
+func EnvName(flagName string) string {
+	return strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
 
-	dsn := os.Getenv("DATABASE_URL")
-	if dsn == "" {
-		log.Fatal("DATABASE_URL is not set")
+// SplitList разбивает значение вида "a, b,c" на непустые элементы
+func SplitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
 	}
+	return out
+}
 
-	return &Config{
-		DatabaseURL: dsn,
+// ParseNetworks разбирает список CIDR или IP через запятую; отдельный IP — сеть из одного адреса
+func ParseNetworks(v string) ([]*net.IPNet, error) {
+	var out []*net.IPNet
+	for _, item := range SplitList(v) {
+		if ip := net.ParseIP(item); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			out = append(out, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a CIDR or IP", item)
+		}
+		out = append(out, network)
+	}
+	return out, nil
+}
+
+// Load собирает конфигурацию из файла, окружения и аргументов командной строки
+// (без имени программы) и проверяет её.
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+	flags := cfg.flagSet("server")
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// Флаги, явно указанные в командной строке, не перекрываются другими источниками
+	explicit := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	// .env дополняет окружение, но не перекрывает уже заданные переменные
+	if !explicit["env-file"] {
+		if v, ok := os.LookupEnv("ENV_FILE"); ok {
+			cfg.EnvFile = v
+			explicit["env-file"] = true
+		}
+	}
+	if cfg.EnvFile != "" {
+		err := godotenv.Load(cfg.EnvFile)
+		if err != nil && (explicit["env-file"] || !errors.Is(err, fs.ErrNotExist)) {
+			return nil, fmt.Errorf("failed to load env file %s: %w", cfg.EnvFile, err)
+		}
+	}
+
+	if !explicit["config"] {
+		if v, ok := os.LookupEnv("CONFIG_FILE"); ok {
+			cfg.ConfigFile = v
+		}
+	}
+
+	if cfg.ConfigFile != "" {
+		values, err := readFile(cfg.ConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range values {
+			if explicit[name] {
+				continue
+			}
+			if flags.Lookup(name) == nil || name == "config" || name == "env-file" {
+				return nil, fmt.Errorf("config file %s: unknown key %q", cfg.ConfigFile, name)
+			}
+			if err := flags.Set(name, value); err != nil {
+				return nil, fmt.Errorf("config file %s: invalid value for %q: %w", cfg.ConfigFile, name, err)
+			}
+		}
+	}
+
+	var envErr error
+	flags.VisitAll(func(f *flag.Flag) {
+		if explicit[f.Name] || f.Name == "config" || f.Name == "env-file" {
+			return
+		}
+		v, ok := os.LookupEnv(EnvName(f.Name))
+		if !ok || v == "" {
+			return
+		}
+		if err := flags.Set(f.Name, v); err != nil {
+			envErr = errors.Join(envErr, fmt.Errorf("env %s: %w", EnvName(f.Name), err))
+		}
+	})
+	if envErr != nil {
+		return nil, envErr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// readFile читает плоский JSON-объект {"имя-флага": значение}.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for k, v := range raw {
+		switch v := v.(type) {
+		case string:
+			values[k] = v
+		case float64:
+			values[k] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			values[k] = strconv.FormatBool(v)
+		default:
+			return nil, fmt.Errorf("config file %s: key %q must be a string, number or bool", path, k)
+		}
+	}
+	return values, nil
+}
+
+// Validate проверяет согласованность значений и возвращает все найденные ошибки сразу.
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.DatabaseURL == "" {
+		add("database-url (DATABASE_URL) is required")
+	}
+	if _, _, err := net.SplitHostPort(c.ListenAddr); err != nil {
+		add("listen-addr %q is invalid: %v", c.ListenAddr, err)
+	}
+	if u, err := url.Parse(c.PublicURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		add("public-url %q must be an http(s) URL", c.PublicURL)
+	}
+
+	if c.JWTTTL <= 0 {
+		add("jwt-ttl must be positive")
+	}
+	if c.RefreshTTL < c.JWTTTL {
+		add("refresh-ttl must not be shorter than jwt-ttl (%s)", c.JWTTTL)
+	}
+	if c.JWTSecret != "" && c.JWTKeyFile != "" {
+		add("jwt-secret and jwt-key-file are mutually exclusive")
+	}
+	if c.JWTPrevKeyFile != "" && c.JWTRotationWindow < c.JWTTTL {
+		add("jwt-rotation-window must be at least jwt-ttl (%s), otherwise tokens of the previous key are rejected before they expire", c.JWTTTL)
+	}
+	if c.JWTRotatedAt != "" {
+		if _, err := time.Parse(time.RFC3339, c.JWTRotatedAt); err != nil {
+			add("jwt-rotated-at must be an RFC 3339 time, e.g. 2024-05-01T12:00:00Z (got %q)", c.JWTRotatedAt)
+		}
+	}
+	if c.CookieName == "" {
+		add("cookie-name must not be empty")
+	}
+	switch strings.ToLower(c.CookieSameSite) {
+	case "lax", "strict":
+	case "none":
+		if !c.CookieSecure {
+			add("cookie-samesite=none requires cookie-secure=true")
+		}
+	default:
+		add("cookie-samesite must be one of lax, strict, none (got %q)", c.CookieSameSite)
+	}
+	if strings.TrimSpace(c.TOTPIssuer) == "" || strings.Contains(c.TOTPIssuer, ":") {
+		add("totp-issuer must be non-empty and must not contain ':'")
+	}
+	if c.PasswordMinLength < 1 || c.PasswordMinLength > 72 {
+		add("password-min-length must be between 1 and 72")
+	}
+	if c.LoginBackoffMax < time.Second || c.LockoutThreshold <= 0 || c.LockoutDuration <= 0 {
+		add("login-backoff-max must be at least 1s, lockout-threshold and lockout-duration must be positive")
+	}
+	if c.LoginFreeAttempts < 0 || c.LoginIPFreeAttempts < 0 || c.LoginWindow <= 0 {
+		add("login-free-attempts and login-ip-free-attempts must not be negative, login-window must be positive")
+	}
+	if c.SignupPerIP <= 0 || c.SignupWindow <= 0 {
+		add("signup-per-ip and signup-window must be positive")
+	}
+	if _, err := ParseNetworks(c.TrustedProxies); err != nil {
+		add("trusted-proxies: %v", err)
+	}
+	if c.OIDCIssuer != "" {
+		if u, err := url.Parse(c.OIDCIssuer); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			add("oidc-issuer %q must be an http(s) URL", c.OIDCIssuer)
+		}
+		if c.OIDCClientID == "" || c.OIDCRedirectURL == "" {
+			add("oidc-issuer requires oidc-client-id and oidc-redirect-url")
+		}
+		if !slices.Contains(strings.Fields(c.OIDCScopes), "openid") {
+			add("oidc-scopes must include openid")
+		}
+	}
+
+	if c.UploadDir == "" {
+		add("upload-dir must not be empty")
+	}
+	if c.MaxUploadSize <= 0 {
+		add("max-upload-size must be positive")
+	}
+	if c.AvatarMaxSize <= 0 || c.AvatarMaxSize > c.MaxUploadSize {
+		add("avatar-max-size must be positive and not exceed max-upload-size")
+	}
+	if len(SplitList(c.AvatarTypes)) == 0 {
+		add("avatar-types must list at least one MIME type")
+	}
+
+	if c.AttachmentMaxSize <= 0 || c.AttachmentMaxSize > c.MaxUploadSize {
+		add("attachment-max-size must be positive and not exceed max-upload-size")
+	}
+	if len(SplitList(c.AttachmentTypes)) == 0 {
+		add("attachment-types must list at least one MIME type")
+	}
+
+	if c.UnfurlTimeout <= 0 || c.UnfurlMaxBytes <= 0 || c.UnfurlCacheTTL <= 0 {
+		add("unfurl-timeout, unfurl-max-bytes and unfurl-cache-ttl must be positive")
+	}
+	for _, item := range SplitList(c.UnfurlAllow) {
+		if _, _, err := net.ParseCIDR(item); err != nil && net.ParseIP(item) == nil {
+			add("unfurl-allow: %q is not a CIDR or IP", item)
+		}
+	}
+
+	switch c.MailBackend {
+	case "none":
+	case "log", "smtp":
+		if c.MailBackend == "smtp" {
+			if _, _, err := net.SplitHostPort(c.SMTPAddr); err != nil {
+				add("mail-backend=smtp requires a valid smtp-addr (host:port)")
+			}
+		}
+		if _, err := mail.ParseAddress(c.MailFrom); err != nil {
+			add("mail-from %q is not a valid address", c.MailFrom)
+		}
+		if c.DigestThreshold <= 0 || c.DigestInterval <= 0 {
+			add("digest-threshold and digest-interval must be positive")
+		}
+		if c.PasswordResetTTL <= 0 {
+			add("password-reset-ttl must be positive")
+		}
+	default:
+		add("mail-backend must be one of log, smtp, none (got %q)", c.MailBackend)
+	}
+
+	if c.RetentionMaxAge < 0 || c.RetentionMaxCount < 0 || c.RetentionUnsent < 0 {
+		add("retention-max-age, retention-max-count and retention-unsent-attachments must not be negative")
+	}
+	if c.RetentionInterval <= 0 || c.RetentionBatchSize <= 0 {
+		add("retention-interval and retention-batch-size must be positive")
+	}
+
+	switch c.StorageBackend {
+	case "local", "memory":
+	case "s3":
+		if c.S3Endpoint == "" || c.S3Bucket == "" || c.S3AccessKey == "" || c.S3SecretKey == "" {
+			add("storage-backend=s3 requires s3-endpoint, s3-bucket, s3-access-key and s3-secret-key")
+		}
+	default:
+		add("storage-backend must be one of local, memory, s3 (got %q)", c.StorageBackend)
+	}
+
+	if c.ReadLimit <= 0 {
+		add("ws-read-limit must be positive")
+	}
+	if c.PongWait <= 0 {
+		add("ws-pong-wait must be positive")
+	}
+	if c.PingPeriod <= 0 || c.PingPeriod >= c.PongWait {
+		add("ws-ping-period must be positive and less than ws-pong-wait (%s)", c.PongWait)
+	}
+	if c.WriteWait <= 0 {
+		add("ws-write-wait must be positive")
+	}
+	if c.TicketTTL <= 0 {
+		add("ws-ticket-ttl must be positive")
+	}
+	if c.ReadBufferSize <= 0 || c.WriteBufferSize <= 0 {
+		add("ws-read-buffer and ws-write-buffer must be positive")
+	}
+	if c.SendBufferSize <= 0 {
+		add("ws-send-buffer must be positive")
+	}
+	if c.HistorySize < 0 {
+		add("history-size must not be negative")
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
 	}
+	return nil
 }
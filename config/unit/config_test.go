@@ -0,0 +1,208 @@
+package config_test
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeConfigFile создаёт временный JSON-файл конфигурации
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return path
+}
+
+// Значения по умолчанию применяются, если задан только DATABASE_URL
+func TestLoad_Defaults(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://localhost/chat")
+
+	cfg, err := config.Load(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, ":8080", cfg.ListenAddr)
+	assert.Equal(t, int64(512), cfg.ReadLimit)
+	assert.Equal(t, 50, cfg.HistorySize)
+	assert.Equal(t, 15*time.Minute, cfg.JWTTTL)
+	assert.Equal(t, 30*24*time.Hour, cfg.RefreshTTL)
+}
+
+// Приоритет: флаги > окружение > файл > значения по умолчанию
+func TestLoad_Precedence(t *testing.T) {
+	path := writeConfigFile(t, `{
+		"database-url": "postgres://file/chat",
+		"listen-addr": ":9000",
+		"history-size": 10,
+		"ws-read-limit": 1024,
+		"cookie-secure": true
+	}`)
+
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("HISTORY_SIZE", "20")
+	t.Setenv("WS_READ_LIMIT", "2048")
+
+	cfg, err := config.Load([]string{"-ws-read-limit", "4096"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "postgres://file/chat", cfg.DatabaseURL) // только в файле
+	assert.Equal(t, ":9000", cfg.ListenAddr)                 // только в файле
+	assert.True(t, cfg.CookieSecure)                         // только в файле
+	assert.Equal(t, 20, cfg.HistorySize)                     // окружение перекрывает файл
+	assert.Equal(t, int64(4096), cfg.ReadLimit)              // флаг перекрывает всё
+}
+
+// Неизвестный ключ в файле — ошибка, а не молчаливое игнорирование
+func TestLoad_UnknownFileKey(t *testing.T) {
+	path := writeConfigFile(t, `{"database-url": "postgres://x", "listen-adr": ":1"}`)
+
+	_, err := config.Load([]string{"-config", path})
+	assert.ErrorContains(t, err, `unknown key "listen-adr"`)
+}
+
+// Некорректное значение в окружении
+func TestLoad_InvalidEnvValue(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://localhost/chat")
+	t.Setenv("WS_PONG_WAIT", "soon")
+
+	_, err := config.Load(nil)
+	assert.ErrorContains(t, err, "WS_PONG_WAIT")
+}
+
+// Validate собирает все ошибки сразу
+func TestValidate_ReportsAllErrors(t *testing.T) {
+	cfg := config.Default()
+	cfg.ListenAddr = "8080"
+	cfg.PingPeriod = 2 * time.Minute
+	cfg.CookieSameSite = "none"
+
+	err := cfg.Validate()
+	assert.Error(t, err)
+	assert.ErrorContains(t, err, "database-url (DATABASE_URL) is required")
+	assert.ErrorContains(t, err, "listen-addr")
+	assert.ErrorContains(t, err, "ws-ping-period")
+	assert.ErrorContains(t, err, "cookie-samesite=none requires cookie-secure=true")
+}
+
+func TestValidate_Mail(t *testing.T) {
+	cfg := config.Default()
+	cfg.DatabaseURL = "postgres://localhost/chat"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.MailBackend = "smtp"
+	cfg.MailFrom = "not an address"
+	err := cfg.Validate()
+	assert.ErrorContains(t, err, "mail-backend=smtp requires a valid smtp-addr")
+	assert.ErrorContains(t, err, "mail-from")
+
+	cfg.MailBackend = "pigeon"
+	assert.ErrorContains(t, cfg.Validate(), "mail-backend must be one of")
+}
+
+func TestValidate_JWTKeys(t *testing.T) {
+	cfg := config.Default()
+	cfg.DatabaseURL = "postgres://localhost/chat"
+	cfg.JWTSecret = "secret"
+	cfg.JWTKeyFile = "jwt.pem"
+	assert.ErrorContains(t, cfg.Validate(), "jwt-secret and jwt-key-file are mutually exclusive")
+
+	cfg.JWTSecret = ""
+	cfg.JWTPrevKeyFile = "old.pem"
+	cfg.JWTRotationWindow = time.Minute
+	assert.ErrorContains(t, cfg.Validate(), "jwt-rotation-window must be at least jwt-ttl")
+
+	cfg.JWTRotationWindow = time.Hour
+	assert.NoError(t, cfg.Validate())
+
+	cfg.JWTRotatedAt = "2024-05-01"
+	assert.ErrorContains(t, cfg.Validate(), "jwt-rotated-at must be an RFC 3339 time")
+	cfg.JWTRotatedAt = "2024-05-01T12:00:00Z"
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestValidate_OIDC(t *testing.T) {
+	cfg := config.Default()
+	cfg.DatabaseURL = "postgres://localhost/chat"
+	cfg.OIDCIssuer = "https://accounts.example.com"
+	assert.ErrorContains(t, cfg.Validate(), "oidc-issuer requires oidc-client-id and oidc-redirect-url")
+
+	cfg.OIDCClientID = "chat"
+	cfg.OIDCRedirectURL = "https://chat.example.com/api/oidc/callback"
+	cfg.OIDCScopes = "profile email"
+	assert.ErrorContains(t, cfg.Validate(), "oidc-scopes must include openid")
+
+	cfg.OIDCScopes = "openid email"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.OIDCIssuer = "accounts.example.com"
+	assert.ErrorContains(t, cfg.Validate(), "must be an http(s) URL")
+}
+
+func TestValidate_TOTPIssuer(t *testing.T) {
+	cfg := config.Default()
+	cfg.DatabaseURL = "postgres://localhost/chat"
+	cfg.TOTPIssuer = "Go:Chat"
+	assert.ErrorContains(t, cfg.Validate(), "totp-issuer")
+
+	cfg.TOTPIssuer = ""
+	assert.ErrorContains(t, cfg.Validate(), "totp-issuer")
+}
+
+func TestValidate_PasswordReset(t *testing.T) {
+	cfg := config.Default()
+	cfg.DatabaseURL = "postgres://localhost/chat"
+	cfg.PublicURL = "chat.example.com"
+	assert.ErrorContains(t, cfg.Validate(), "public-url")
+
+	cfg.PublicURL = "https://chat.example.com"
+	cfg.PasswordResetTTL = 0
+	assert.ErrorContains(t, cfg.Validate(), "password-reset-ttl must be positive")
+
+	cfg.MailBackend = "none"
+	assert.NoError(t, cfg.Validate(), "без почты сброс пароля отключён, срок не проверяется")
+}
+
+func TestValidate_LoginProtection(t *testing.T) {
+	cfg := config.Default()
+	cfg.DatabaseURL = "postgres://localhost/chat"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.PasswordMinLength = 0
+	assert.ErrorContains(t, cfg.Validate(), "password-min-length")
+	cfg.PasswordMinLength = 73
+	assert.ErrorContains(t, cfg.Validate(), "password-min-length")
+
+	cfg = config.Default()
+	cfg.DatabaseURL = "postgres://localhost/chat"
+	cfg.LockoutThreshold = 0
+	assert.ErrorContains(t, cfg.Validate(), "lockout-threshold")
+}
+
+func TestValidate_TrustedProxies(t *testing.T) {
+	cfg := config.Default()
+	cfg.DatabaseURL = "postgres://localhost/chat"
+	cfg.TrustedProxies = "10.0.0.0/8, 192.0.2.1, ::1"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.TrustedProxies = "10.0.0.0/8,proxy.local"
+	assert.ErrorContains(t, cfg.Validate(), "trusted-proxies")
+
+	cfg.TrustedProxies = ""
+	cfg.SignupPerIP = 0
+	assert.ErrorContains(t, cfg.Validate(), "signup-per-ip")
+}
+
+func TestParseNetworks(t *testing.T) {
+	networks, err := config.ParseNetworks("10.0.0.0/8, 192.0.2.1")
+	assert.NoError(t, err)
+	if assert.Len(t, networks, 2) {
+		assert.True(t, networks[0].Contains(net.ParseIP("10.1.2.3")))
+		assert.True(t, networks[1].Contains(net.ParseIP("192.0.2.1")))
+		assert.False(t, networks[1].Contains(net.ParseIP("192.0.2.2")))
+	}
+}
@@ -10,6 +10,8 @@ require (
 	github.com/lib/pq v1.10.9
 	github.com/stretchr/testify v1.9.0
 	golang.org/x/crypto v0.41.0
+	golang.org/x/image v0.24.0
+	golang.org/x/net v0.43.0
 )
 
 require (
@@ -1,13 +1,29 @@
 package app
 
 import (
+	"context"
 	"log"
 	"net/http"
-	"os"
+	"strings"
+	"time"
 
 	"github.com/go-portfolio/websocket-chat/config"
+	"github.com/go-portfolio/websocket-chat/internal/attachment"
 	"github.com/go-portfolio/websocket-chat/internal/auth"
 	"github.com/go-portfolio/websocket-chat/internal/chat"
+	"github.com/go-portfolio/websocket-chat/internal/mail"
+	"github.com/go-portfolio/websocket-chat/internal/markdown"
+	"github.com/go-portfolio/websocket-chat/internal/mention"
+	"github.com/go-portfolio/websocket-chat/internal/message"
+	"github.com/go-portfolio/websocket-chat/internal/notify"
+	"github.com/go-portfolio/websocket-chat/internal/oidc"
+	"github.com/go-portfolio/websocket-chat/internal/reset"
+	"github.com/go-portfolio/websocket-chat/internal/retention"
+	"github.com/go-portfolio/websocket-chat/internal/room"
+	"github.com/go-portfolio/websocket-chat/internal/session"
+	"github.com/go-portfolio/websocket-chat/internal/settings"
+	"github.com/go-portfolio/websocket-chat/internal/storage"
+	"github.com/go-portfolio/websocket-chat/internal/unfurl"
 	"github.com/go-portfolio/websocket-chat/internal/user"
 	"github.com/go-portfolio/websocket-chat/internal/web"
 )
@@ -16,39 +32,244 @@ type App struct {
 	Mux *http.ServeMux
 }
 
-func New() *App {
-	// Загружаем конфиг
-	cfg := config.Load()
-
+func New(cfg *config.Config) *App {
 	// User store
 	store, err := user.NewStore(cfg.DatabaseURL)
 	if err != nil {
 		log.Fatalf("failed to init user store: %v", err)
 	}
+	store.Policy = user.PasswordPolicy{MinLength: cfg.PasswordMinLength}
+	if cfg.PasswordBreachedFile != "" {
+		if store.Policy.Breached, err = user.LoadBreachedList(cfg.PasswordBreachedFile); err != nil {
+			log.Fatalf("failed to load breached passwords: %v", err)
+		}
+		log.Printf("password policy: %d breached passwords loaded", len(store.Policy.Breached))
+	}
+
+	// Миграции схемы (несколько экземпляров ждут друг друга на advisory-блокировке)
+	if cfg.AutoMigrate {
+		if err := Migrator(store.Db).Up(context.Background()); err != nil {
+			log.Fatalf("failed to migrate database: %v", err)
+		}
+	}
 
-	// JWT secret
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "dev-secret"
-		log.Printf("[dev] JWT_SECRET not set, using default secret")
+	// Ключи подписи JWT
+	keys, err := newKeyring(cfg)
+	if err != nil {
+		log.Fatalf("failed to init JWT keys: %v", err)
+	}
+	auth.Keys = keys
+	auth.TokenTTL = cfg.JWTTTL
+
+	// Хранилище файлов
+	blobs, err := newBlobStore(cfg)
+	if err != nil {
+		log.Fatalf("failed to init blob store: %v", err)
 	}
-	auth.InitSecret([]byte(secret))
+
+	// Комнаты и вложения (используют то же подключение к БД)
+	rooms := room.NewStore(store.Db)
+	attachments := attachment.NewStore(store.Db)
+	mentions := mention.NewStore(store.Db)
+	notifications := notify.NewStore(store.Db)
+	messages := message.NewStore(store.Db)
+	sessions := session.NewStore(store.Db)
 
 	// ChatHub
 	hub := chat.NewHub()
+	hub.Settings = chat.Settings{
+		ReadLimit:      cfg.ReadLimit,
+		PongWait:       cfg.PongWait,
+		PingPeriod:     cfg.PingPeriod,
+		WriteWait:      cfg.WriteWait,
+		SendBufferSize: cfg.SendBufferSize,
+		HistorySize:    cfg.HistorySize,
+	}
+	hub.Processors = append(hub.Processors, &attachment.Processor{Store: attachments}, markdown.Processor{})
+	hub.Observers = append(hub.Observers, &message.Recorder{Store: messages})
+
+	// Входящие уведомления: упоминания и личные сообщения для тех, кто не в сети
+	inbox := &notify.Inbox{Store: notifications, Hub: hub}
+	hub.Observers = append(hub.Observers, inbox)
+
+	// Упоминания: проверяются до рассылки, уведомления уходят после
+	mentioner := &mention.Notifier{Hub: hub, Users: store, Rooms: rooms, Store: mentions, Inbox: inbox, MaxMentions: 20}
+	hub.Processors = append(hub.Processors, mentioner)
+	hub.Observers = append(hub.Observers, mentioner)
+
+	// Превью ссылок
+	if cfg.UnfurlEnabled {
+		opts := unfurl.DefaultOptions()
+		opts.Timeout = cfg.UnfurlTimeout
+		opts.MaxBytes = cfg.UnfurlMaxBytes
+		opts.CacheTTL = cfg.UnfurlCacheTTL
+		opts.Allow = config.SplitList(cfg.UnfurlAllow)
+		unfurler, err := unfurl.New(hub, opts)
+		if err != nil {
+			log.Fatalf("failed to init unfurler: %v", err)
+		}
+		hub.Observers = append(hub.Observers, unfurler)
+	}
 	go hub.Run()
 
+	// E-mail дайджесты для тех, кто давно не заходил, и ссылки сброса пароля
+	mailer := newMailer(cfg)
+	if mailer != nil {
+		digester := &notify.Digester{Store: notifications, Mailer: mailer, Hub: hub, Threshold: cfg.DigestThreshold}
+		go digester.Run(context.Background(), cfg.DigestInterval)
+	}
+
+	// Очистка истории по срокам хранения
+	rules, err := retention.ParseRules(cfg.RetentionRooms)
+	if err != nil {
+		log.Fatalf("failed to parse retention rules: %v", err)
+	}
+	purger := &retention.Purger{
+		Messages:    messages,
+		Attachments: attachments,
+		Blobs:       blobs,
+		History:     hub,
+		Global:      retention.Policy{MaxAge: cfg.RetentionMaxAge, MaxCount: cfg.RetentionMaxCount},
+		Rooms:       rules,
+		Unsent:      cfg.RetentionUnsent,
+		BatchSize:   cfg.RetentionBatchSize,
+	}
+	go purger.Run(context.Background(), cfg.RetentionInterval)
+
 	// Внутренние глобальные сервисы
 	web.ChatHub = hub
 	web.Users = store
+	web.Blobs = blobs
+	web.Rooms = rooms
+	web.Attachments = attachments
+	web.Mentions = mentions
+	web.Notifications = notifications
+	web.Inbox = inbox
+	web.Messages = messages
+	web.Sessions = sessions
+	web.TwoFactor = store
+	web.Settings = settings.NewStore(store.Db)
+	web.Passwords = store
+	if mailer != nil {
+		web.ResetSender = &reset.MailSender{Mailer: mailer}
+	}
+
+	// Параметры HTTP-слоя
+	web.CookieName = cfg.CookieName
+	web.CookieSecure = cfg.CookieSecure
+	web.CookieSameSite = sameSite(cfg.CookieSameSite)
+	web.RefreshTTL = cfg.RefreshTTL
+	web.AvatarPolicy = storage.Policy{MaxSize: cfg.AvatarMaxSize, AllowedTypes: config.SplitList(cfg.AvatarTypes)}
+	web.AttachmentPolicy = storage.Policy{MaxSize: cfg.AttachmentMaxSize, AllowedTypes: config.SplitList(cfg.AttachmentTypes)}
+	web.MaxUploadSize = cfg.MaxUploadSize
+	web.AdminToken = cfg.AdminToken
+	web.StaticDir = cfg.StaticDir
+	web.SetBufferSizes(cfg.ReadBufferSize, cfg.WriteBufferSize)
+	web.Tickets = auth.NewTicketStore(cfg.TicketTTL)
+	web.TOTPIssuer = cfg.TOTPIssuer
+	web.PublicURL = cfg.PublicURL
+	web.ResetTTL = cfg.PasswordResetTTL
+	web.LoginIPLimiter = auth.NewLimiter(cfg.LoginIPFreeAttempts, time.Second, cfg.LoginBackoffMax, cfg.LoginWindow)
+	web.LoginUserLimiter = auth.NewLimiter(cfg.LoginFreeAttempts, time.Second, cfg.LoginBackoffMax, cfg.LoginWindow)
+	web.Lockouts = auth.NewLimiter(cfg.LockoutThreshold-1, cfg.LockoutDuration, cfg.LockoutDuration, cfg.LockoutDuration)
+	web.SignupLimiter = auth.NewLimiter(cfg.SignupPerIP, time.Minute, cfg.SignupWindow, cfg.SignupWindow)
+	// Список уже проверен в Validate
+	web.TrustedProxies, _ = config.ParseNetworks(cfg.TrustedProxies)
+	if cfg.OIDCIssuer != "" {
+		web.OIDC = oidc.New(oidc.Config{
+			Issuer:       cfg.OIDCIssuer,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+			Scopes:       strings.Fields(cfg.OIDCScopes),
+		})
+		web.OIDCName = cfg.OIDCName
+		web.Identities = store
+	}
 
 	// Роуты
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", web.IndexHandler)
 	mux.HandleFunc("/api/register", web.RegisterHandler)
 	mux.HandleFunc("/api/login", web.LoginHandler)
-	mux.Handle("/ws", web.AuthMiddleware(http.HandlerFunc(web.ChatConnectionHandler)))
-	mux.Handle("/uploads/", http.StripPrefix("/uploads/", http.FileServer(http.Dir("../../uploads"))))
+	mux.HandleFunc("GET /.well-known/jwks.json", web.JWKSHandler)
+	mux.HandleFunc("POST /api/refresh", web.RefreshHandler)
+	mux.HandleFunc("POST /api/logout", web.LogoutHandler)
+	mux.HandleFunc("POST /api/password/forgot", web.ForgotPasswordHandler)
+	mux.HandleFunc("POST /api/password/reset", web.ResetPasswordHandler)
+	mux.HandleFunc("POST /api/email/verify", web.VerifyEmailHandler)
+	mux.Handle("POST /api/password", web.AuthMiddleware(http.HandlerFunc(web.ChangePasswordHandler)))
+	mux.HandleFunc("GET /api/oidc", web.OIDCInfoHandler)
+	mux.HandleFunc("GET /api/oidc/login", web.OIDCLoginHandler)
+	mux.HandleFunc("GET /api/oidc/callback", web.OIDCCallbackHandler)
+	mux.Handle("GET /api/oidc/link", web.AuthMiddleware(http.HandlerFunc(web.OIDCLinkHandler)))
+	mux.Handle("GET /api/2fa", web.AuthMiddleware(http.HandlerFunc(web.TwoFactorStatusHandler)))
+	mux.Handle("POST /api/2fa/setup", web.TwoFactorSetupMiddleware(http.HandlerFunc(web.TwoFactorSetupHandler)))
+	mux.Handle("POST /api/2fa/enable", web.TwoFactorSetupMiddleware(http.HandlerFunc(web.TwoFactorEnableHandler)))
+	mux.Handle("POST /api/2fa/disable", web.AuthMiddleware(http.HandlerFunc(web.TwoFactorDisableHandler)))
+	mux.Handle("POST /api/2fa/recovery-codes", web.AuthMiddleware(http.HandlerFunc(web.TwoFactorRecoveryCodesHandler)))
+	mux.Handle("GET /api/sessions", web.AuthMiddleware(http.HandlerFunc(web.SessionsHandler)))
+	mux.Handle("DELETE /api/sessions", web.AuthMiddleware(http.HandlerFunc(web.RevokeOtherSessionsHandler)))
+	mux.Handle("DELETE /api/sessions/{id}", web.AuthMiddleware(http.HandlerFunc(web.RevokeSessionHandler)))
+	mux.Handle("GET /api/profile", web.AuthMiddleware(http.HandlerFunc(web.ProfileHandler)))
+	mux.Handle("PUT /api/profile/email", web.AuthMiddleware(http.HandlerFunc(web.EmailSettingsHandler)))
+	mux.Handle("PUT /api/profile/avatar", web.AuthMiddleware(http.HandlerFunc(web.AvatarUploadHandler)))
+	mux.Handle("POST /api/attachments", web.AuthMiddleware(http.HandlerFunc(web.UploadAttachmentHandler)))
+	mux.Handle("GET /api/attachments/{id}", web.AuthMiddleware(http.HandlerFunc(web.AttachmentHandler)))
+	mux.Handle("GET /api/attachments/{id}/thumbnail", web.AuthMiddleware(http.HandlerFunc(web.AttachmentThumbnailHandler)))
+	mux.Handle("GET /api/mentions", web.AuthMiddleware(http.HandlerFunc(web.MentionsHandler)))
+	mux.Handle("GET /api/notifications", web.AuthMiddleware(http.HandlerFunc(web.NotificationsHandler)))
+	mux.Handle("POST /api/notifications/{id}/read", web.AuthMiddleware(http.HandlerFunc(web.MarkNotificationReadHandler)))
+	mux.Handle("POST /api/notifications/read", web.AuthMiddleware(http.HandlerFunc(web.MarkAllNotificationsReadHandler)))
+	mux.Handle("GET /api/rooms/{room}/export", web.AuthMiddleware(http.HandlerFunc(web.ExportRoomHandler)))
+	mux.Handle("PUT /api/rooms/{room}/members/{username}/role", web.AuthMiddleware(http.HandlerFunc(web.RoomRoleHandler)))
+	mux.Handle("GET /api/search", web.AuthMiddleware(http.HandlerFunc(web.SearchHandler)))
+	mux.Handle("GET /api/admin/rooms", web.AdminMiddleware(http.HandlerFunc(web.AdminRoomsHandler)))
+	mux.Handle("POST /api/admin/users/{username}/kick", web.AdminMiddleware(http.HandlerFunc(web.AdminKickHandler)))
+	mux.Handle("POST /api/admin/announcements", web.AdminMiddleware(http.HandlerFunc(web.AdminAnnounceHandler)))
+	mux.Handle("GET /api/admin/settings", web.AdminMiddleware(http.HandlerFunc(web.AdminSettingsHandler)))
+	mux.Handle("PUT /api/admin/settings", web.AdminMiddleware(http.HandlerFunc(web.AdminUpdateSettingsHandler)))
+	mux.Handle("DELETE /api/admin/users/{username}/2fa", web.AdminMiddleware(http.HandlerFunc(web.AdminResetTwoFactorHandler)))
+	mux.Handle("POST /api/ws-ticket", web.AuthMiddleware(http.HandlerFunc(web.WSTicketHandler)))
+	mux.Handle("/ws", web.WebSocketAuthMiddleware(http.HandlerFunc(web.ChatConnectionHandler)))
+	mux.HandleFunc("GET /uploads/", web.UploadsHandler)
+	mux.HandleFunc("GET /avatars/{name}", web.DefaultAvatarHandler)
 
 	return &App{Mux: mux}
 }
+
+// newBlobStore создаёт хранилище файлов согласно storage-backend
+func newBlobStore(cfg *config.Config) (storage.BlobStore, error) {
+	switch cfg.StorageBackend {
+	case "memory":
+		return storage.NewMemoryStore(), nil
+	case "s3":
+		return storage.NewS3Store(cfg.S3Endpoint, cfg.S3Bucket, cfg.S3Region, cfg.S3AccessKey, cfg.S3SecretKey), nil
+	default:
+		return storage.NewLocalStore(cfg.UploadDir)
+	}
+}
+
+// newMailer создаёт отправщик писем согласно mail-backend; nil — письма отключены
+func newMailer(cfg *config.Config) mail.Mailer {
+	switch cfg.MailBackend {
+	case "smtp":
+		return &mail.SMTPMailer{Addr: cfg.SMTPAddr, From: cfg.MailFrom, Username: cfg.SMTPUsername, Password: cfg.SMTPPassword}
+	case "log":
+		return &mail.LogMailer{}
+	default:
+		return nil
+	}
+}
+
+// sameSite переводит строковое значение из конфига в http.SameSite
+func sameSite(v string) http.SameSite {
+	switch strings.ToLower(v) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
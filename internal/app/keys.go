@@ -0,0 +1,90 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/config"
+	"github.com/go-portfolio/websocket-chat/internal/auth"
+)
+
+// newKeyring собирает ключи подписи JWT: из jwt-key-file, из jwt-secret или,
+// если не задано ничего, случайный ключ (токены не переживут перезапуск,
+// но сессии продолжатся через refresh-токены)
+func newKeyring(cfg *config.Config) (*auth.Keyring, error) {
+	var (
+		current *auth.Key
+		err     error
+	)
+	switch {
+	case cfg.JWTKeyFile != "":
+		current, err = auth.LoadKey(cfg.JWTKeyID, cfg.JWTKeyFile)
+	case cfg.JWTSecret != "":
+		if len(cfg.JWTSecret) < 32 {
+			log.Printf("JWT_SECRET is shorter than 32 bytes, consider a longer secret or jwt-key-file")
+		}
+		current = auth.NewHMACKey(cfg.JWTKeyID, []byte(cfg.JWTSecret))
+	default:
+		log.Printf("[dev] no JWT key configured, using an ephemeral key")
+		current, err = auth.GenerateKey()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := auth.NewKeyring(current)
+	if err != nil {
+		return nil, err
+	}
+
+	// Токены без kid выпускались до появления связки ключей и подписаны jwt-secret:
+	// после ротации это предыдущий ключ, а без ротации — тот же секрет, что сейчас
+	var legacy *auth.Key
+	if cfg.JWTSecret != "" {
+		legacy = current
+	}
+	if cfg.JWTPrevKeyFile != "" {
+		legacy, err = auth.LoadKey(cfg.JWTPrevKeyID, cfg.JWTPrevKeyFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if legacy != nil {
+		rotatedAt, err := rotationTime(cfg)
+		if err != nil {
+			return nil, err
+		}
+		until := rotatedAt.Add(cfg.JWTRotationWindow)
+		if legacy != current {
+			if err := keys.AddPrevious(legacy, until); err != nil {
+				return nil, err
+			}
+			log.Printf("JWT key rotation: accepting key %q until %s", legacy.ID, until.Format(time.RFC3339))
+		}
+		keys.AddLegacy(legacy, until)
+	}
+	log.Printf("JWT signing key %q (%s)", current.ID, current.Method.Alg())
+	return keys, nil
+}
+
+// rotationTime возвращает момент ротации ключа, от которого отсчитывается окно:
+// jwt-rotated-at или время изменения jwt-key-file. Без них окно отсчитывается от
+// запуска и продлевается каждым перезапуском.
+func rotationTime(cfg *config.Config) (time.Time, error) {
+	if cfg.JWTRotatedAt != "" {
+		return time.Parse(time.RFC3339, cfg.JWTRotatedAt)
+	}
+	if cfg.JWTKeyFile != "" {
+		info, err := os.Stat(cfg.JWTKeyFile)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to stat key: %w", err)
+		}
+		return info.ModTime(), nil
+	}
+	if cfg.JWTPrevKeyFile != "" {
+		log.Printf("JWT key rotation: jwt-rotated-at is not set, the rotation window starts now")
+	}
+	return time.Now(), nil
+}
@@ -0,0 +1,19 @@
+package app
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/go-portfolio/websocket-chat/internal/migrate"
+	"github.com/go-portfolio/websocket-chat/migrations"
+)
+
+// Migrator возвращает мигратор со встроенными в бинарник миграциями
+func Migrator(db *sql.DB) *migrate.Migrator {
+	list, err := migrate.Load(migrations.FS)
+	if err != nil {
+		// Встроенные файлы проверяются тестами, ошибка здесь — ошибка сборки
+		log.Fatalf("failed to load embedded migrations: %v", err)
+	}
+	return &migrate.Migrator{Db: db, Migrations: list}
+}
@@ -0,0 +1,48 @@
+package attachment
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-portfolio/websocket-chat/internal/chat"
+)
+
+// Processor превращает ссылку на вложение (attachment_id) в сообщении типа "attachment"
+// в полное описание файла и привязывает вложение к комнате или личной переписке
+type Processor struct {
+	Store AttachmentStore
+}
+
+var _ chat.MessageProcessor = (*Processor)(nil)
+
+func (p *Processor) ProcessMessage(msg *chat.ChatMessage) error {
+	if msg.Type != "attachment" {
+		return nil
+	}
+	if msg.Attachment == nil || msg.Attachment.ID == "" {
+		return fmt.Errorf("attachment_id is required")
+	}
+
+	a, err := p.Store.Get(msg.Attachment.ID)
+	if err != nil {
+		return err
+	}
+	// Отправить можно только собственное вложение; чужое выглядит как несуществующее
+	if a.Owner != msg.From {
+		return ErrNotFound
+	}
+
+	room, peer := msg.Room, ""
+	if msg.To != "" {
+		room, peer = "", msg.To
+	}
+	if err := p.Store.Bind(a.ID, room, peer); err != nil {
+		if errors.Is(err, ErrAlreadyShared) {
+			return err
+		}
+		return fmt.Errorf("failed to send attachment")
+	}
+
+	msg.Attachment = a.Info()
+	return nil
+}
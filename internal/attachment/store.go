@@ -0,0 +1,303 @@
+package attachment
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-portfolio/websocket-chat/internal/chat"
+)
+
+var (
+	// ErrNotFound — вложения нет (или оно недоступно пользователю)
+	ErrNotFound = errors.New("attachment not found")
+	// ErrAlreadyShared — вложение уже отправлено в другую комнату или другому пользователю
+	ErrAlreadyShared = errors.New("attachment already shared elsewhere")
+)
+
+// Attachment — метаданные загруженного файла.
+// Пока вложение не отправлено в чат, Room и Peer пусты и доступ есть только у владельца.
+type Attachment struct {
+	ID        string
+	Owner     string
+	Room      string // комната, в которую отправлено вложение
+	Peer      string // получатель личного сообщения с вложением
+	Name      string
+	Size      int64
+	MimeType  string
+	BlobKey   string
+	ThumbKey  string // пусто, если миниатюры нет
+	CreatedAt time.Time
+}
+
+// IsImage сообщает, является ли вложение изображением
+func (a *Attachment) IsImage() bool {
+	return strings.HasPrefix(a.MimeType, "image/")
+}
+
+// Info возвращает описание вложения для ChatMessage
+func (a *Attachment) Info() *chat.Attachment {
+	info := &chat.Attachment{
+		ID:       a.ID,
+		Name:     a.Name,
+		Size:     a.Size,
+		MimeType: a.MimeType,
+		URL:      URL(a.ID),
+	}
+	if a.ThumbKey != "" {
+		info.ThumbnailURL = ThumbnailURL(a.ID)
+	}
+	return info
+}
+
+// URL возвращает адрес скачивания вложения
+func URL(id string) string {
+	return "/api/attachments/" + id
+}
+
+// ThumbnailURL возвращает адрес миниатюры вложения
+func ThumbnailURL(id string) string {
+	return "/api/attachments/" + id + "/thumbnail"
+}
+
+// NewID генерирует случайный идентификатор вложения
+func NewID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// CleanName убирает из имени файла путь и управляющие символы и ограничивает длину.
+// Имя используется только для отображения и Content-Disposition.
+func CleanName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == "/" {
+		name = "file"
+	}
+	for len(name) > 255 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+// AttachmentStore хранит метаданные вложений
+type AttachmentStore interface {
+	Create(a *Attachment) error
+	Get(id string) (*Attachment, error)
+	// Bind привязывает вложение к комнате или личной переписке при первой отправке
+	Bind(id, room, peer string) error
+}
+
+type Store struct {
+	Db *sql.DB
+}
+
+var _ AttachmentStore = (*Store)(nil)
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{Db: db}
+}
+
+// Create сохраняет метаданные под блокировкой ключей файлов (см. lockKeys).
+// Сами файлы кладутся в хранилище после Create: удаление, начатое раньше, к этому
+// моменту уже закончено, а начатое позже увидит новое вложение и файл не тронет.
+func (s *Store) Create(a *Attachment) error {
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockKeys(tx, a.BlobKey, a.ThumbKey); err != nil {
+		return err
+	}
+	thumb := sql.NullString{String: a.ThumbKey, Valid: a.ThumbKey != ""}
+	query := `INSERT INTO attachments (id, owner, name, size, mime_type, blob_key, thumb_key, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	if _, err := tx.Exec(query, a.ID, a.Owner, a.Name, a.Size, a.MimeType, a.BlobKey, thumb, a.CreatedAt); err != nil {
+		return fmt.Errorf("failed to insert attachment: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) Get(id string) (*Attachment, error) {
+	var (
+		a                 Attachment
+		room, peer, thumb sql.NullString
+	)
+	query := `SELECT id, owner, room, peer, name, size, mime_type, blob_key, thumb_key, created_at
+		FROM attachments WHERE id=$1`
+	err := s.Db.QueryRow(query, id).Scan(
+		&a.ID, &a.Owner, &room, &peer, &a.Name, &a.Size, &a.MimeType, &a.BlobKey, &thumb, &a.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachment: %w", err)
+	}
+	a.Room, a.Peer, a.ThumbKey = room.String, peer.String, thumb.String
+	return &a, nil
+}
+
+// Bind выполняет привязку атомарно: повторная отправка туда же допустима,
+// отправка в другое место — ErrAlreadyShared
+func (s *Store) Bind(id, room, peer string) error {
+	query := `UPDATE attachments SET room=NULLIF($2, ''), peer=NULLIF($3, '')
+		WHERE id=$1 AND ((room IS NULL AND peer IS NULL) OR (COALESCE(room, '')=$2 AND COALESCE(peer, '')=$3))`
+	res, err := s.Db.Exec(query, id, room, peer)
+	if err != nil {
+		return fmt.Errorf("failed to bind attachment: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrAlreadyShared
+	}
+	return nil
+}
+
+// DeleteUnused удаляет метаданные вложения, если на него больше не ссылается ни одно
+// сообщение истории, и через remove — его файлы.
+// Ключи вычисляются из содержимого, и одинаковые файлы разных вложений лежат под одним
+// ключом: файл, который ещё нужен другим вложениям, не удаляется, а его ключ в
+// результате пустой. Если remove вернул ошибку, вложение остаётся на месте.
+// ErrNotFound — вложения нет или оно ещё используется.
+func (s *Store) DeleteUnused(id string, remove func(key string) error) (*Attachment, error) {
+	query := `DELETE FROM attachments WHERE id=$1
+		AND NOT EXISTS (SELECT 1 FROM messages WHERE attachment_id=$1)
+		RETURNING id, blob_key, thumb_key`
+	deleted, err := s.deleteWith(remove, query, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(deleted) == 0 {
+		return nil, ErrNotFound
+	}
+	return deleted[0], nil
+}
+
+// DeleteUnsent удаляет до limit вложений, загруженных раньше before и так и не
+// отправленных в чат, вместе с их файлами — по тем же правилам, что DeleteUnused.
+// Вложение, которое успели отправить, пока шло удаление, остаётся.
+func (s *Store) DeleteUnsent(before time.Time, limit int, remove func(key string) error) ([]*Attachment, error) {
+	query := `DELETE FROM attachments WHERE id IN (
+			SELECT a.id FROM attachments a
+			WHERE a.room IS NULL AND a.peer IS NULL AND a.created_at < $1
+			AND NOT EXISTS (SELECT 1 FROM messages m WHERE m.attachment_id=a.id)
+			ORDER BY a.created_at LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		) AND room IS NULL AND peer IS NULL
+		RETURNING id, blob_key, thumb_key`
+	return s.deleteWith(remove, query, before, limit)
+}
+
+// deleteWith выполняет удаляющий запрос (RETURNING id, blob_key, thumb_key) и в той же
+// транзакции удаляет файлы, которые больше не нужны ни одному вложению
+func (s *Store) deleteWith(remove func(key string) error, query string, args ...any) ([]*Attachment, error) {
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete attachment: %w", err)
+	}
+	var (
+		deleted []*Attachment
+		keys    []string
+	)
+	for rows.Next() {
+		var (
+			a     Attachment
+			thumb sql.NullString
+		)
+		if err := rows.Scan(&a.ID, &a.BlobKey, &thumb); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan attachment: %w", err)
+		}
+		a.ThumbKey = thumb.String
+		deleted = append(deleted, &a)
+		keys = append(keys, a.BlobKey, a.ThumbKey)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, fmt.Errorf("failed to delete attachment: %w", err)
+	}
+	rows.Close()
+	if len(deleted) == 0 {
+		return nil, nil
+	}
+
+	// Под блокировкой ключей новая загрузка того же файла не может начаться, а уже
+	// сохранённая видна следующим запросам транзакции
+	if err := lockKeys(tx, keys...); err != nil {
+		return nil, err
+	}
+	unused := make(map[string]bool)
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		if _, seen := unused[key]; seen {
+			continue
+		}
+		var used bool
+		err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM attachments WHERE blob_key=$1 OR thumb_key=$1)`, key).Scan(&used)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check attachment file: %w", err)
+		}
+		unused[key] = !used
+		if used || remove == nil {
+			continue
+		}
+		if err := remove(key); err != nil {
+			return nil, fmt.Errorf("failed to delete %s: %w", key, err)
+		}
+	}
+	for _, a := range deleted {
+		if !unused[a.BlobKey] {
+			a.BlobKey = ""
+		}
+		if !unused[a.ThumbKey] {
+			a.ThumbKey = ""
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return deleted, nil
+}
+
+// lockKeys берёт транзакционные advisory-блокировки на ключи файлов: сохранение вложения
+// и удаление его файла с тем же ключом не пересекаются. Ключи блокируются по порядку,
+// чтобы две транзакции не ждали друг друга.
+func lockKeys(tx *sql.Tx, keys ...string) error {
+	keys = slices.DeleteFunc(slices.Clone(keys), func(k string) bool { return k == "" })
+	slices.Sort(keys)
+	for _, key := range slices.Compact(keys) {
+		if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, key); err != nil {
+			return fmt.Errorf("failed to lock %s: %w", key, err)
+		}
+	}
+	return nil
+}
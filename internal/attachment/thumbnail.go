@@ -0,0 +1,27 @@
+package attachment
+
+import (
+	"github.com/go-portfolio/websocket-chat/internal/imageutil"
+)
+
+// ThumbnailSize — максимальная сторона миниатюры в пикселях
+const ThumbnailSize = 256
+
+// Thumbnail уменьшает изображение так, чтобы большая сторона не превышала side,
+// сохраняя пропорции. Результат — PNG без метаданных исходника.
+func Thumbnail(data []byte, side int) ([]byte, error) {
+	src, err := imageutil.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	if w > side || h > side {
+		if w >= h {
+			w, h = side, max(1, h*side/w)
+		} else {
+			w, h = max(1, w*side/h), side
+		}
+	}
+	return imageutil.ScalePNG(src, src.Bounds(), w, h)
+}
@@ -0,0 +1,295 @@
+package attachment_test
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"image"
+	"image/png"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-portfolio/websocket-chat/internal/attachment"
+	"github.com/go-portfolio/websocket-chat/internal/chat"
+	"github.com/stretchr/testify/assert"
+)
+
+// memStore — in-memory AttachmentStore с той же семантикой Bind, что и Store
+type memStore struct {
+	items map[string]*attachment.Attachment
+}
+
+func (m *memStore) Create(a *attachment.Attachment) error {
+	m.items[a.ID] = a
+	return nil
+}
+
+func (m *memStore) Get(id string) (*attachment.Attachment, error) {
+	a, ok := m.items[id]
+	if !ok {
+		return nil, attachment.ErrNotFound
+	}
+	cp := *a
+	return &cp, nil
+}
+
+func (m *memStore) Bind(id, room, peer string) error {
+	a := m.items[id]
+	if (a.Room != "" || a.Peer != "") && (a.Room != room || a.Peer != peer) {
+		return attachment.ErrAlreadyShared
+	}
+	a.Room, a.Peer = room, peer
+	return nil
+}
+
+func newMemStore(items ...*attachment.Attachment) *memStore {
+	m := &memStore{items: map[string]*attachment.Attachment{}}
+	for _, a := range items {
+		m.items[a.ID] = a
+	}
+	return m
+}
+
+/* ==========================
+   Processor
+   ========================== */
+
+// Отправка в комнату: вложение привязывается к комнате, сообщение получает описание файла
+func TestProcessor_BindsToRoom(t *testing.T) {
+	store := newMemStore(&attachment.Attachment{
+		ID: "a1", Owner: "alice", Name: "cat.png", Size: 10, MimeType: "image/png", ThumbKey: "thumbnails/x.png",
+	})
+	p := &attachment.Processor{Store: store}
+
+	msg := &chat.ChatMessage{Type: "attachment", From: "alice", Room: "tech", Attachment: &chat.Attachment{ID: "a1"}}
+	assert.NoError(t, p.ProcessMessage(msg))
+
+	assert.Equal(t, "tech", store.items["a1"].Room)
+	assert.Equal(t, "cat.png", msg.Attachment.Name)
+	assert.Equal(t, "/api/attachments/a1", msg.Attachment.URL)
+	assert.Equal(t, "/api/attachments/a1/thumbnail", msg.Attachment.ThumbnailURL)
+}
+
+// Личное сообщение: вложение привязывается к адресату, а не к комнате
+func TestProcessor_BindsToPeer(t *testing.T) {
+	store := newMemStore(&attachment.Attachment{ID: "a1", Owner: "alice", MimeType: "application/pdf"})
+	p := &attachment.Processor{Store: store}
+
+	msg := &chat.ChatMessage{Type: "attachment", From: "alice", To: "bob", Room: "tech", Attachment: &chat.Attachment{ID: "a1"}}
+	assert.NoError(t, p.ProcessMessage(msg))
+
+	assert.Equal(t, "", store.items["a1"].Room)
+	assert.Equal(t, "bob", store.items["a1"].Peer)
+	assert.Empty(t, msg.Attachment.ThumbnailURL)
+}
+
+// Чужое вложение отправить нельзя
+func TestProcessor_ForeignAttachment(t *testing.T) {
+	p := &attachment.Processor{Store: newMemStore(&attachment.Attachment{ID: "a1", Owner: "alice"})}
+
+	msg := &chat.ChatMessage{Type: "attachment", From: "mallory", Room: "tech", Attachment: &chat.Attachment{ID: "a1"}}
+	assert.ErrorIs(t, p.ProcessMessage(msg), attachment.ErrNotFound)
+}
+
+// Уже отправленное в одну комнату вложение нельзя переслать в другую
+func TestProcessor_AlreadyShared(t *testing.T) {
+	p := &attachment.Processor{Store: newMemStore(&attachment.Attachment{ID: "a1", Owner: "alice", Room: "tech"})}
+
+	msg := &chat.ChatMessage{Type: "attachment", From: "alice", Room: "games", Attachment: &chat.Attachment{ID: "a1"}}
+	assert.ErrorIs(t, p.ProcessMessage(msg), attachment.ErrAlreadyShared)
+}
+
+// Обычные сообщения не затрагиваются
+func TestProcessor_IgnoresOtherTypes(t *testing.T) {
+	p := &attachment.Processor{Store: newMemStore()}
+	msg := &chat.ChatMessage{Type: "message", Text: "hi"}
+	assert.NoError(t, p.ProcessMessage(msg))
+	assert.Nil(t, msg.Attachment)
+}
+
+/* ==========================
+   Store
+   ========================== */
+
+func TestStore_Get(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := attachment.NewStore(db)
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT id, owner, room, peer, name, size, mime_type, blob_key, thumb_key, created_at`).
+		WithArgs("a1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "owner", "room", "peer", "name", "size", "mime_type", "blob_key", "thumb_key", "created_at"}).
+			AddRow("a1", "alice", "tech", nil, "cat.png", 10, "image/png", "attachments/x.png", nil, now))
+
+	a, err := store.Get("a1")
+	assert.NoError(t, err)
+	assert.Equal(t, "tech", a.Room)
+	assert.Equal(t, "", a.Peer)
+	assert.Equal(t, "", a.ThumbKey)
+}
+
+func TestStore_GetNotFound(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := attachment.NewStore(db)
+
+	mock.ExpectQuery(`SELECT id, owner`).WillReturnError(sql.ErrNoRows)
+
+	_, err := store.Get("missing")
+	assert.ErrorIs(t, err, attachment.ErrNotFound)
+}
+
+// Bind, не затронувший строк, означает отправку в другое место
+func TestStore_BindAlreadyShared(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := attachment.NewStore(db)
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE attachments SET room=NULLIF($2, ''), peer=NULLIF($3, '')`)).
+		WithArgs("a1", "games", "").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.ErrorIs(t, store.Bind("a1", "games", ""), attachment.ErrAlreadyShared)
+}
+
+// Метаданные сохраняются под блокировкой ключей файлов
+func TestStore_CreateLocksKeys(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := attachment.NewStore(db)
+
+	at := time.Unix(100, 0)
+	mock.ExpectBegin()
+	lock := regexp.QuoteMeta(`SELECT pg_advisory_xact_lock(hashtext($1))`)
+	mock.ExpectExec(lock).WithArgs("attachments/x.png").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(lock).WithArgs("thumbnails/x.png").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO attachments`)).
+		WithArgs("a1", "alice", "x.png", int64(4), "image/png", "attachments/x.png", "thumbnails/x.png", at).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err := store.Create(&attachment.Attachment{
+		ID: "a1", Owner: "alice", Name: "x.png", Size: 4, MimeType: "image/png",
+		BlobKey: "attachments/x.png", ThumbKey: "thumbnails/x.png", CreatedAt: at,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Файл, общий с другим вложением (тот же ключ содержимого), не удаляется
+func TestStore_DeleteUnusedSharedBlob(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := attachment.NewStore(db)
+
+	var removed []string
+	remove := func(key string) error {
+		removed = append(removed, key)
+		return nil
+	}
+	lock := regexp.QuoteMeta(`SELECT pg_advisory_xact_lock(hashtext($1))`)
+	used := regexp.QuoteMeta(`SELECT EXISTS (SELECT 1 FROM attachments WHERE blob_key=$1 OR thumb_key=$1)`)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`DELETE FROM attachments WHERE id=$1`)).WithArgs("a1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "blob_key", "thumb_key"}).AddRow("a1", "attachments/x.png", "thumbnails/x.png"))
+	mock.ExpectExec(lock).WithArgs("attachments/x.png").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(lock).WithArgs("thumbnails/x.png").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(used).WithArgs("attachments/x.png").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(used).WithArgs("thumbnails/x.png").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectCommit()
+	a, err := store.DeleteUnused("a1", remove)
+	assert.NoError(t, err)
+	assert.Empty(t, a.BlobKey, "файл нужен другому вложению")
+	assert.Equal(t, "thumbnails/x.png", a.ThumbKey)
+	assert.Equal(t, []string{"thumbnails/x.png"}, removed)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`DELETE FROM attachments WHERE id=$1`)).WithArgs("a2").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "blob_key", "thumb_key"}))
+	mock.ExpectRollback()
+	_, err = store.DeleteUnused("a2", remove)
+	assert.ErrorIs(t, err, attachment.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Если файл не удалился, вложение остаётся, чтобы не потерять ссылку на файл
+func TestStore_DeleteUnusedRemoveFails(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := attachment.NewStore(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`DELETE FROM attachments WHERE id=$1`)).WithArgs("a1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "blob_key", "thumb_key"}).AddRow("a1", "attachments/x.txt", nil))
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_xact_lock`)).WithArgs("attachments/x.txt").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS`)).WithArgs("attachments/x.txt").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectRollback()
+
+	_, err := store.DeleteUnused("a1", func(string) error { return errors.New("disk failure") })
+	assert.ErrorContains(t, err, "disk failure")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Неотправленные вложения удаляются пачкой; общий ключ блокируется и проверяется один раз
+func TestStore_DeleteUnsent(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := attachment.NewStore(db)
+
+	before := time.Unix(1000, 0)
+	mock.ExpectBegin()
+	query := regexp.QuoteMeta(`DELETE FROM attachments WHERE id IN (`) + `.*a\.created_at < \$1.*FOR UPDATE SKIP LOCKED \) AND room IS NULL AND peer IS NULL`
+	mock.ExpectQuery(query).
+		WithArgs(before, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "blob_key", "thumb_key"}).
+			AddRow("a1", "attachments/x.txt", nil).
+			AddRow("a2", "attachments/x.txt", nil))
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_xact_lock`)).WithArgs("attachments/x.txt").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS`)).WithArgs("attachments/x.txt").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectCommit()
+
+	var removed []string
+	deleted, err := store.DeleteUnsent(before, 10, func(key string) error {
+		removed = append(removed, key)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Len(t, deleted, 2)
+	assert.Equal(t, []string{"attachments/x.txt"}, removed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+/* ==========================
+   Вспомогательные функции
+   ========================== */
+
+func TestCleanName(t *testing.T) {
+	assert.Equal(t, "passwd", attachment.CleanName("../../etc/passwd"))
+	assert.Equal(t, "report.pdf", attachment.CleanName(`C:\Users\me\report.pdf`))
+	assert.Equal(t, "ab.txt", attachment.CleanName("a\"b\x00.txt"))
+	assert.Equal(t, "file", attachment.CleanName(""))
+}
+
+// Миниатюра сохраняет пропорции и не превышает заданную сторону
+func TestThumbnail_KeepsAspectRatio(t *testing.T) {
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1000, 500)))
+
+	thumb, err := attachment.Thumbnail(buf.Bytes(), 256)
+	assert.NoError(t, err)
+
+	cfg, err := png.DecodeConfig(bytes.NewReader(thumb))
+	assert.NoError(t, err)
+	assert.Equal(t, 256, cfg.Width)
+	assert.Equal(t, 128, cfg.Height)
+}
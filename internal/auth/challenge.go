@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrChallenge — вызова нет, он истёк или исчерпал попытки: нужно войти заново
+	ErrChallenge = errors.New("invalid or expired login challenge")
+	// ErrChallengeFailed — проверка второго фактора не прошла, попытка израсходована
+	ErrChallengeFailed = errors.New("invalid code")
+)
+
+// ChallengeStore хранит незавершённые входы: пароль уже проверен, ждём второй фактор.
+// Вызов живёт TTL и выдерживает не больше MaxAttempts неверных кодов.
+// Вызовы хранятся в памяти процесса.
+type ChallengeStore struct {
+	TTL         time.Duration
+	MaxAttempts int
+
+	mu         sync.Mutex
+	challenges map[string]*challenge
+}
+
+type challenge struct {
+	username string
+	expires  time.Time
+	attempts int
+}
+
+func NewChallengeStore(ttl time.Duration, maxAttempts int) *ChallengeStore {
+	return &ChallengeStore{TTL: ttl, MaxAttempts: maxAttempts, challenges: make(map[string]*challenge)}
+}
+
+// Issue выдаёт вызов для пользователя, прошедшего проверку пароля
+func (s *ChallengeStore) Issue(username string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate challenge: %w", err)
+	}
+	value := base64.RawURLEncoding.EncodeToString(b)
+
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, c := range s.challenges {
+		if now.After(c.expires) {
+			delete(s.challenges, k)
+		}
+	}
+	s.challenges[value] = &challenge{username: username, expires: now.Add(s.TTL)}
+	return value, nil
+}
+
+// Peek возвращает пользователя вызова, не расходуя попыток
+func (s *ChallengeStore) Peek(value string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.challenges[value]
+	if !ok || time.Now().After(c.expires) {
+		return "", false
+	}
+	return c.username, true
+}
+
+// Attempt проверяет второй фактор через check. Успех погашает вызов, неудача
+// расходует попытку; check вызывается без блокировки, попытка резервируется заранее,
+// поэтому параллельные запросы не превысят MaxAttempts.
+func (s *ChallengeStore) Attempt(value string, check func(username string) (bool, error)) (string, error) {
+	s.mu.Lock()
+	c, ok := s.challenges[value]
+	if !ok || time.Now().After(c.expires) || c.attempts >= s.MaxAttempts {
+		delete(s.challenges, value)
+		s.mu.Unlock()
+		return "", ErrChallenge
+	}
+	c.attempts++
+	username := c.username
+	s.mu.Unlock()
+
+	passed, err := check(username)
+	if err != nil {
+		return "", err
+	}
+	if !passed {
+		return "", ErrChallengeFailed
+	}
+
+	s.mu.Lock()
+	delete(s.challenges, value)
+	s.mu.Unlock()
+	return username, nil
+}
+
+// Discard удаляет вызов, например после завершения входа другим путём
+func (s *ChallengeStore) Discard(value string) {
+	s.mu.Lock()
+	delete(s.challenges, value)
+	s.mu.Unlock()
+}
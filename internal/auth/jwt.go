@@ -7,37 +7,59 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// TokenTTL — время жизни выдаваемых токенов
+var TokenTTL = 24 * time.Hour
+
+// Claims — данные, извлечённые из проверенного токена
+type Claims struct {
+	Username  string
+	SessionID string // пусто — токен не привязан к сессии
+}
+
 // IssueJWT создаёт JWT-токен
 func IssueJWT(username string) (string, error) {
+	return IssueSessionJWT(username, "")
+}
+
+// IssueSessionJWT создаёт access-токен, привязанный к сессии (claim "sid")
+func IssueSessionJWT(username, sessionID string) (string, error) {
+	now := time.Now()
 	claims := jwt.MapClaims{
 		"sub": username,
-		"iat": time.Now().Unix(),
-		"exp": time.Now().Add(24 * time.Hour).Unix(),
+		"iat": now.Unix(),
+		"exp": now.Add(TokenTTL).Unix(),
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(Secret)
+	if sessionID != "" {
+		claims["sid"] = sessionID
+	}
+	return Keys.Sign(claims)
 }
 
 // ParseJWT парсит и валидирует токен
 func ParseJWT(tokenStr string) (string, error) {
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method")
-		}
-		return Secret, nil
-	})
+	c, err := ParseClaims(tokenStr)
+	if err != nil {
+		return "", err
+	}
+	return c.Username, nil
+}
+
+// ParseClaims парсит и валидирует токен, возвращая пользователя и сессию
+func ParseClaims(tokenStr string) (Claims, error) {
+	token, err := jwt.Parse(tokenStr, Keys.Keyfunc)
 	if err != nil || !token.Valid {
-		return "", fmt.Errorf("invalid token: %w", err)
+		return Claims{}, fmt.Errorf("invalid token: %w", err)
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		return "", fmt.Errorf("invalid claims")
+		return Claims{}, fmt.Errorf("invalid claims")
 	}
 
 	sub, _ := claims["sub"].(string)
 	if sub == "" {
-		return "", fmt.Errorf("missing subject")
+		return Claims{}, fmt.Errorf("missing subject")
 	}
-	return sub, nil
+	sid, _ := claims["sid"].(string)
+	return Claims{Username: sub, SessionID: sid}, nil
 }
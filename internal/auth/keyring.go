@@ -0,0 +1,282 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key — ключ подписи JWT. Для HS256 подпись и проверка используют общий секрет,
+// для RS256 и EdDSA проверке достаточно открытого ключа.
+type Key struct {
+	ID     string // kid в заголовке токена
+	Method jwt.SigningMethod
+
+	sign   any // nil — ключ только для проверки
+	verify any
+}
+
+// NewHMACKey создаёт ключ HS256; пустой id вычисляется из секрета
+func NewHMACKey(id string, secret []byte) *Key {
+	if id == "" {
+		id = keyID(secret)
+	}
+	return &Key{ID: id, Method: jwt.SigningMethodHS256, sign: secret, verify: secret}
+}
+
+// NewKey создаёт ключ RS256 (*rsa.PrivateKey) или EdDSA (ed25519.PrivateKey)
+func NewKey(id string, private crypto.Signer) (*Key, error) {
+	key, err := NewPublicKey(id, private.Public())
+	if err != nil {
+		return nil, err
+	}
+	key.sign = private
+	return key, nil
+}
+
+// NewPublicKey создаёт ключ только для проверки подписи (например, предыдущий ключ ротации)
+func NewPublicKey(id string, public crypto.PublicKey) (*Key, error) {
+	key := &Key{ID: id, verify: public}
+	switch public.(type) {
+	case *rsa.PublicKey:
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", public)
+	}
+	if key.ID == "" {
+		der, err := x509.MarshalPKIXPublicKey(public)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode public key: %w", err)
+		}
+		key.ID = keyID(der)
+	}
+	return key, nil
+}
+
+// GenerateKey создаёт случайный ключ EdDSA
+func GenerateKey() (*Key, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return NewKey("", private)
+}
+
+// ParseKey разбирает ключ: PEM с закрытым ключом RSA или Ed25519 (PKCS#1/PKCS#8),
+// PEM с открытым ключом (только проверка) или, если это не PEM, секрет HS256
+func ParseKey(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		secret := bytes.TrimSpace(data)
+		if len(secret) == 0 {
+			return nil, errors.New("empty key")
+		}
+		return NewHMACKey(id, secret), nil
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse RSA key: %w", err)
+		}
+		return NewKey(id, private)
+	case "PRIVATE KEY":
+		private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+		signer, ok := private.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported key type %T", private)
+		}
+		return NewKey(id, signer)
+	case "PUBLIC KEY":
+		public, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key: %w", err)
+		}
+		return NewPublicKey(id, public)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
+
+// LoadKey читает ключ из файла (см. ParseKey)
+func LoadKey(id, path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+	key, err := ParseKey(id, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// keyID — короткий отпечаток ключевого материала для kid
+func keyID(material []byte) string {
+	sum := sha256.Sum256(material)
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+// Keyring подписывает токены текущим ключом и проверяет их по kid:
+// текущим ключом или предыдущими, пока не закончилось их окно ротации.
+// Токены без kid (выпущенные до появления связки) проверяются ключом AddLegacy.
+type Keyring struct {
+	mu       sync.RWMutex
+	current  *Key
+	previous map[string]previousKey
+	legacy   *previousKey
+}
+
+type previousKey struct {
+	key   *Key
+	until time.Time
+}
+
+// NewKeyring создаёт связку с ключом подписи current
+func NewKeyring(current *Key) (*Keyring, error) {
+	if current.sign == nil {
+		return nil, errors.New("signing key must include a private key or secret")
+	}
+	return &Keyring{current: current, previous: make(map[string]previousKey)}, nil
+}
+
+// AddPrevious принимает токены, подписанные key, до момента until
+func (k *Keyring) AddPrevious(key *Key, until time.Time) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if key.ID == k.current.ID {
+		return fmt.Errorf("previous key has the same kid %q as the current key", key.ID)
+	}
+	k.previous[key.ID] = previousKey{key: key, until: until}
+	return nil
+}
+
+// AddLegacy принимает токены без kid, подписанные key, до момента until
+func (k *Keyring) AddLegacy(key *Key, until time.Time) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.legacy = &previousKey{key: key, until: until}
+}
+
+// Current возвращает ключ подписи
+func (k *Keyring) Current() *Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.current
+}
+
+// Sign подписывает claims текущим ключом и проставляет kid
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	key := k.Current()
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.sign)
+}
+
+// Keyfunc выбирает ключ проверки по kid; алгоритм токена должен совпадать с алгоритмом ключа
+func (k *Keyring) Keyfunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	key := k.lookup(kid, time.Now())
+	if key == nil {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+	return key.verify, nil
+}
+
+func (k *Keyring) lookup(kid string, now time.Time) *Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if kid == "" {
+		if k.legacy != nil && now.Before(k.legacy.until) {
+			return k.legacy.key
+		}
+		return nil
+	}
+	if kid == k.current.ID {
+		return k.current
+	}
+	if p, ok := k.previous[kid]; ok && now.Before(p.until) {
+		return p.key
+	}
+	return nil
+}
+
+// JWK — открытый ключ в формате RFC 7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS возвращает открытые ключи (текущий и действующие предыдущие).
+// Ключи HS256 не публикуются.
+func (k *Keyring) JWKS() []JWK {
+	k.mu.RLock()
+	keys := []*Key{k.current}
+	now := time.Now()
+	for _, p := range k.previous {
+		if now.Before(p.until) {
+			keys = append(keys, p.key)
+		}
+	}
+	k.mu.RUnlock()
+
+	set := []JWK{}
+	for _, key := range keys {
+		switch pub := key.verify.(type) {
+		case *rsa.PublicKey:
+			set = append(set, JWK{
+				Kty: "RSA", Kid: key.ID, Use: "sig", Alg: key.Method.Alg(),
+				N: base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set = append(set, JWK{
+				Kty: "OKP", Kid: key.ID, Use: "sig", Alg: key.Method.Alg(),
+				Crv: "Ed25519", X: base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+	return set
+}
+
+// Keys — связка ключей для выдачи и проверки токенов. По умолчанию — случайный
+// ключ EdDSA: токены не переживают перезапуск; app.New заменяет его ключами из конфига.
+var Keys = mustGenerateKeyring()
+
+func mustGenerateKeyring() *Keyring {
+	key, err := GenerateKey()
+	if err != nil {
+		panic(err)
+	}
+	ring, _ := NewKeyring(key)
+	return ring
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter замедляет перебор: после Free попыток по ключу (IP, имени пользователя)
+// каждая следующая откладывает новые попытки на паузу, которая удваивается от Base
+// до Max. Счётчик забывается, если попыток не было дольше Window.
+// С Base == Max это временная блокировка после Free+1 неудач.
+// Счётчики хранятся в памяти процесса.
+type Limiter struct {
+	Free   int
+	Base   time.Duration
+	Max    time.Duration
+	Window time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*limit
+	swept   time.Time
+}
+
+type limit struct {
+	hits  int
+	last  time.Time // последняя попытка
+	until time.Time // до этого момента попытки отклоняются
+}
+
+func NewLimiter(free int, base, max, window time.Duration) *Limiter {
+	return &Limiter{Free: free, Base: base, Max: max, Window: window, entries: make(map[string]*limit)}
+}
+
+// Wait возвращает, сколько ещё ждать до следующей попытки; 0 — можно пробовать
+func (l *Limiter) Wait(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.entries[key]
+	if !ok {
+		return 0
+	}
+	return max(time.Until(e.until), 0)
+}
+
+// Hit учитывает попытку и возвращает паузу до следующей; 0 — паузы нет
+func (l *Limiter) Hit(key string) time.Duration {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.hit(key, now)
+}
+
+// Reserve проверяет и учитывает попытку под одной блокировкой: если попытки по ключу
+// пока запрещены, возвращает оставшуюся паузу и ничего не учитывает, иначе учитывает
+// попытку и возвращает 0. Так параллельные запросы не проходят проверку все разом,
+// пока не учтена первая неудача. Попытку, которая не должна считаться, снимает Release
+func (l *Limiter) Reserve(key string) time.Duration {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.entries[key]; ok && now.Before(e.until) {
+		return e.until.Sub(now)
+	}
+	l.hit(key, now)
+	return 0
+}
+
+// Release снимает одну попытку, учтённую Reserve (например, пароль оказался верным)
+func (l *Limiter) Release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.entries[key]
+	if !ok || e.hits == 0 {
+		return
+	}
+	e.hits--
+	if e.hits <= l.Free {
+		e.until = time.Time{}
+	}
+}
+
+func (l *Limiter) hit(key string, now time.Time) time.Duration {
+	l.sweep(now)
+
+	e, ok := l.entries[key]
+	if !ok || now.Sub(e.last) > l.Window {
+		e = &limit{}
+		l.entries[key] = e
+	}
+	e.hits++
+	e.last = now
+
+	over := e.hits - l.Free
+	if over <= 0 {
+		return 0
+	}
+	delay := l.Base
+	for i := 1; i < over && delay < l.Max; i++ {
+		delay *= 2
+	}
+	delay = min(delay, l.Max)
+	e.until = now.Add(delay)
+	return delay
+}
+
+// Reset забывает попытки по ключу (например, после успешного входа)
+func (l *Limiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.entries, key)
+}
+
+// sweep раз в Window выбрасывает забытые счётчики, чтобы перебор с множества
+// адресов не раздувал память
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < l.Window {
+		return
+	}
+	l.swept = now
+	for k, e := range l.entries {
+		if now.Sub(e.last) > l.Window && now.After(e.until) {
+			delete(l.entries, k)
+		}
+	}
+}
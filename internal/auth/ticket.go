@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TicketStore выдаёт одноразовые короткоживущие билеты для подключения к WebSocket
+// там, где нельзя передать cookie или заголовок Authorization (браузерный WebSocket
+// с другого origin, встраиваемые виджеты). Билеты хранятся в памяти процесса.
+type TicketStore struct {
+	TTL time.Duration
+
+	mu      sync.Mutex
+	tickets map[string]ticket
+}
+
+type ticket struct {
+	claims  Claims
+	expires time.Time
+}
+
+func NewTicketStore(ttl time.Duration) *TicketStore {
+	return &TicketStore{TTL: ttl, tickets: make(map[string]ticket)}
+}
+
+// Issue выдаёт билет на пользователя и сессию claims
+func (s *TicketStore) Issue(claims Claims) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate ticket: %w", err)
+	}
+	value := base64.RawURLEncoding.EncodeToString(b)
+
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Заодно выбрасываем просроченные, чтобы неиспользованные билеты не копились
+	for k, t := range s.tickets {
+		if now.After(t.expires) {
+			delete(s.tickets, k)
+		}
+	}
+	s.tickets[value] = ticket{claims: claims, expires: now.Add(s.TTL)}
+	return value, nil
+}
+
+// Redeem погашает билет: второй раз тот же билет не принимается
+func (s *TicketStore) Redeem(value string) (Claims, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tickets[value]
+	if !ok {
+		return Claims{}, false
+	}
+	delete(s.tickets, value)
+	if time.Now().After(t.expires) {
+		return Claims{}, false
+	}
+	return t.claims, true
+}
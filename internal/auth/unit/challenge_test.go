@@ -0,0 +1,68 @@
+package unit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChallengeStore(t *testing.T) {
+	store := auth.NewChallengeStore(time.Minute, 3)
+	challenge, err := store.Issue("alice")
+	require.NoError(t, err)
+
+	username, ok := store.Peek(challenge)
+	assert.True(t, ok)
+	assert.Equal(t, "alice", username)
+
+	_, err = store.Attempt(challenge, func(string) (bool, error) { return false, nil })
+	assert.ErrorIs(t, err, auth.ErrChallengeFailed)
+
+	username, err = store.Attempt(challenge, func(u string) (bool, error) { return u == "alice", nil })
+	require.NoError(t, err)
+	assert.Equal(t, "alice", username)
+
+	_, err = store.Attempt(challenge, func(string) (bool, error) { return true, nil })
+	assert.ErrorIs(t, err, auth.ErrChallenge, "успешный вызов погашен")
+}
+
+func TestChallengeStore_MaxAttempts(t *testing.T) {
+	store := auth.NewChallengeStore(time.Minute, 2)
+	challenge, err := store.Issue("alice")
+	require.NoError(t, err)
+
+	wrong := func(string) (bool, error) { return false, nil }
+	for i := 0; i < 2; i++ {
+		_, err = store.Attempt(challenge, wrong)
+		assert.ErrorIs(t, err, auth.ErrChallengeFailed)
+	}
+	_, err = store.Attempt(challenge, func(string) (bool, error) { return true, nil })
+	assert.ErrorIs(t, err, auth.ErrChallenge, "попытки исчерпаны, верный код уже не помогает")
+	_, ok := store.Peek(challenge)
+	assert.False(t, ok)
+}
+
+func TestChallengeStore_ExpiredAndErrors(t *testing.T) {
+	store := auth.NewChallengeStore(-time.Second, 5)
+	challenge, err := store.Issue("alice")
+	require.NoError(t, err)
+	_, ok := store.Peek(challenge)
+	assert.False(t, ok)
+	_, err = store.Attempt(challenge, func(string) (bool, error) { return true, nil })
+	assert.ErrorIs(t, err, auth.ErrChallenge)
+
+	store = auth.NewChallengeStore(time.Minute, 5)
+	challenge, err = store.Issue("alice")
+	require.NoError(t, err)
+	dbErr := errors.New("db down")
+	_, err = store.Attempt(challenge, func(string) (bool, error) { return false, dbErr })
+	assert.ErrorIs(t, err, dbErr)
+
+	store.Discard(challenge)
+	_, ok = store.Peek(challenge)
+	assert.False(t, ok)
+}
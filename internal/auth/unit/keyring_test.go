@@ -0,0 +1,188 @@
+package unit
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/auth"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useKeys подменяет глобальную связку ключей на время теста
+func useKeys(t *testing.T, current *auth.Key) *auth.Keyring {
+	ring, err := auth.NewKeyring(current)
+	require.NoError(t, err)
+	prev := auth.Keys
+	auth.Keys = ring
+	t.Cleanup(func() { auth.Keys = prev })
+	return ring
+}
+
+func pemFile(t *testing.T, blockType string, der []byte) string {
+	path := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+	return path
+}
+
+func rsaKeyFile(t *testing.T) (string, *rsa.PrivateKey) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return pemFile(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(private)), private
+}
+
+func TestLoadKey_Formats(t *testing.T) {
+	rsaPath, rsaKey := rsaKeyFile(t)
+	key, err := auth.LoadKey("", rsaPath)
+	require.NoError(t, err)
+	assert.Equal(t, "RS256", key.Method.Alg())
+	assert.NotEmpty(t, key.ID)
+
+	_, edPrivate, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(edPrivate)
+	key, err = auth.LoadKey("ed-1", pemFile(t, "PRIVATE KEY", der))
+	require.NoError(t, err)
+	assert.Equal(t, "EdDSA", key.Method.Alg())
+	assert.Equal(t, "ed-1", key.ID)
+
+	// Открытый ключ годится только для проверки
+	der, _ = x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	public, err := auth.LoadKey("", pemFile(t, "PUBLIC KEY", der))
+	require.NoError(t, err)
+	_, err = auth.NewKeyring(public)
+	assert.Error(t, err)
+
+	// Не-PEM — секрет HS256
+	secretPath := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(secretPath, []byte("0123456789abcdef0123456789abcdef\n"), 0o600))
+	key, err = auth.LoadKey("", secretPath)
+	require.NoError(t, err)
+	assert.Equal(t, "HS256", key.Method.Alg())
+
+	_, err = auth.LoadKey("", filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func TestIssueAndParse_AllAlgorithms(t *testing.T) {
+	rsaPath, _ := rsaKeyFile(t)
+	rsaKey, err := auth.LoadKey("", rsaPath)
+	require.NoError(t, err)
+	edKey, err := auth.GenerateKey()
+	require.NoError(t, err)
+
+	for _, key := range []*auth.Key{auth.NewHMACKey("", []byte("secret")), rsaKey, edKey} {
+		useKeys(t, key)
+		token, err := auth.IssueSessionJWT("alice", "s1")
+		require.NoError(t, err)
+
+		parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+		require.NoError(t, err)
+		assert.Equal(t, key.ID, parsed.Header["kid"])
+		assert.Equal(t, key.Method.Alg(), parsed.Header["alg"])
+
+		claims, err := auth.ParseClaims(token)
+		require.NoError(t, err, key.Method.Alg())
+		assert.Equal(t, auth.Claims{Username: "alice", SessionID: "s1"}, claims)
+	}
+}
+
+// Токены старого ключа принимаются только в окне ротации
+func TestKeyring_RotationWindow(t *testing.T) {
+	old := auth.NewHMACKey("old", []byte("old-secret"))
+	useKeys(t, old)
+	oldToken, err := auth.IssueJWT("alice")
+	require.NoError(t, err)
+
+	fresh, err := auth.GenerateKey()
+	require.NoError(t, err)
+	ring := useKeys(t, fresh)
+	_, err = auth.ParseJWT(oldToken)
+	assert.Error(t, err, "без предыдущего ключа старый токен отклоняется")
+
+	require.NoError(t, ring.AddPrevious(old, time.Now().Add(time.Hour)))
+	user, err := auth.ParseJWT(oldToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", user)
+
+	require.NoError(t, ring.AddPrevious(old, time.Now().Add(-time.Second)))
+	_, err = auth.ParseJWT(oldToken)
+	assert.Error(t, err, "окно ротации закончилось")
+
+	assert.Error(t, ring.AddPrevious(fresh, time.Now().Add(time.Hour)), "тот же kid, что у текущего")
+}
+
+// Токены без kid, выпущенные до появления связки, принимает только ключ AddLegacy в своём окне
+func TestKeyring_LegacyTokens(t *testing.T) {
+	legacy := auth.NewHMACKey("old", []byte("old-secret"))
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()})
+	oldToken, err := token.SignedString([]byte("old-secret"))
+	require.NoError(t, err)
+
+	fresh, err := auth.GenerateKey()
+	require.NoError(t, err)
+	ring := useKeys(t, fresh)
+	require.NoError(t, ring.AddPrevious(legacy, time.Now().Add(time.Hour)))
+	_, err = auth.ParseJWT(oldToken)
+	assert.Error(t, err, "токен без kid не ищется среди предыдущих ключей")
+
+	ring.AddLegacy(legacy, time.Now().Add(time.Hour))
+	user, err := auth.ParseJWT(oldToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", user)
+
+	ring.AddLegacy(legacy, time.Now().Add(-time.Second))
+	_, err = auth.ParseJWT(oldToken)
+	assert.Error(t, err, "окно ротации закончилось")
+}
+
+// Алгоритм токена должен совпадать с алгоритмом ключа, найденного по kid
+func TestKeyring_RejectsAlgorithmMismatch(t *testing.T) {
+	key, err := auth.GenerateKey()
+	require.NoError(t, err)
+	useKeys(t, key)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "mallory", "exp": time.Now().Add(time.Hour).Unix()})
+	token.Header["kid"] = key.ID
+	forged, err := token.SignedString([]byte(key.ID))
+	require.NoError(t, err)
+
+	_, err = auth.ParseJWT(forged)
+	assert.Error(t, err)
+}
+
+func TestKeyring_JWKS(t *testing.T) {
+	rsaPath, rsaPrivate := rsaKeyFile(t)
+	rsaKey, err := auth.LoadKey("rsa-1", rsaPath)
+	require.NoError(t, err)
+	ring := useKeys(t, rsaKey)
+
+	edKey, err := auth.GenerateKey()
+	require.NoError(t, err)
+	require.NoError(t, ring.AddPrevious(edKey, time.Now().Add(time.Hour)))
+	require.NoError(t, ring.AddPrevious(auth.NewHMACKey("hmac", []byte("secret")), time.Now().Add(time.Hour)))
+
+	set := ring.JWKS()
+	require.Len(t, set, 2, "секреты HS256 не публикуются")
+	assert.Equal(t, "rsa-1", set[0].Kid)
+	assert.Equal(t, "RSA", set[0].Kty)
+	assert.Equal(t, "AQAB", set[0].E)
+	assert.Equal(t, rsaPrivate.N.Bytes(), mustDecode(t, set[0].N))
+	assert.Equal(t, "OKP", set[1].Kty)
+	assert.Equal(t, "Ed25519", set[1].Crv)
+	assert.Equal(t, edKey.ID, set[1].Kid)
+}
+
+func mustDecode(t *testing.T, s string) []byte {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
@@ -0,0 +1,76 @@
+package unit
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter_Backoff(t *testing.T) {
+	l := auth.NewLimiter(2, time.Minute, 5*time.Minute, time.Hour)
+
+	assert.Zero(t, l.Hit("alice"))
+	assert.Zero(t, l.Hit("alice"))
+	assert.Zero(t, l.Wait("alice"), "первые Free попыток без паузы")
+
+	assert.Equal(t, time.Minute, l.Hit("alice"))
+	assert.Equal(t, 2*time.Minute, l.Hit("alice"))
+	assert.Equal(t, 4*time.Minute, l.Hit("alice"))
+	assert.Equal(t, 5*time.Minute, l.Hit("alice"), "пауза не больше Max")
+	assert.Equal(t, 5*time.Minute, l.Hit("alice"))
+
+	wait := l.Wait("alice")
+	assert.True(t, wait > 4*time.Minute && wait <= 5*time.Minute, wait)
+	assert.Zero(t, l.Wait("bob"), "ключи независимы")
+
+	l.Reset("alice")
+	assert.Zero(t, l.Wait("alice"))
+	assert.Zero(t, l.Hit("alice"))
+}
+
+func TestLimiter_Window(t *testing.T) {
+	l := auth.NewLimiter(1, time.Millisecond, time.Millisecond, 20*time.Millisecond)
+
+	l.Hit("10.0.0.1")
+	assert.Equal(t, time.Millisecond, l.Hit("10.0.0.1"))
+
+	time.Sleep(30 * time.Millisecond)
+	assert.Zero(t, l.Wait("10.0.0.1"))
+	assert.Zero(t, l.Hit("10.0.0.1"), "счётчик забыт после Window без попыток")
+}
+
+func TestLimiter_ReserveConcurrent(t *testing.T) {
+	l := auth.NewLimiter(3, time.Minute, time.Hour, time.Hour)
+
+	var wg sync.WaitGroup
+	var passed atomic.Int32
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if l.Reserve("alice") == 0 {
+				passed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(4), passed.Load(), "проходят Free попыток и ещё одна, назначившая паузу")
+	assert.NotZero(t, l.Wait("alice"))
+}
+
+func TestLimiter_Release(t *testing.T) {
+	l := auth.NewLimiter(1, time.Minute, time.Hour, time.Hour)
+
+	assert.Zero(t, l.Reserve("alice"))
+	assert.Zero(t, l.Reserve("alice"))
+	assert.NotZero(t, l.Wait("alice"))
+
+	l.Release("alice")
+	assert.Zero(t, l.Wait("alice"), "снятая попытка не держит паузу")
+	l.Release("bob")
+	assert.Zero(t, l.Wait("bob"))
+}
@@ -0,0 +1,39 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTicketStore(t *testing.T) {
+	store := auth.NewTicketStore(time.Minute)
+	claims := auth.Claims{Username: "alice", SessionID: "s1"}
+
+	ticket, err := store.Issue(claims)
+	require.NoError(t, err)
+	other, err := store.Issue(claims)
+	require.NoError(t, err)
+	assert.NotEqual(t, ticket, other)
+
+	got, ok := store.Redeem(ticket)
+	assert.True(t, ok)
+	assert.Equal(t, claims, got)
+
+	_, ok = store.Redeem(ticket)
+	assert.False(t, ok, "повторно билет не принимается")
+	_, ok = store.Redeem("unknown")
+	assert.False(t, ok)
+}
+
+func TestTicketStore_Expired(t *testing.T) {
+	store := auth.NewTicketStore(-time.Second)
+	ticket, err := store.Issue(auth.Claims{Username: "alice"})
+	require.NoError(t, err)
+
+	_, ok := store.Redeem(ticket)
+	assert.False(t, ok)
+}
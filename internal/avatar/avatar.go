@@ -0,0 +1,104 @@
+package avatar
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"regexp"
+	"strconv"
+
+	"github.com/go-portfolio/websocket-chat/internal/imageutil"
+	"github.com/go-portfolio/websocket-chat/internal/storage"
+)
+
+var (
+	// ErrNotImage — данные не удалось декодировать как изображение
+	ErrNotImage = imageutil.ErrNotImage
+	// ErrTooManyPixels — изображение слишком большое для обработки
+	ErrTooManyPixels = imageutil.ErrTooManyPixels
+)
+
+// Sizes — размеры (в пикселях) квадратных вариантов аватара, от меньшего к большему
+var Sizes = []int{32, 64, 256}
+
+// Variant — один вариант аватара в формате PNG
+type Variant struct {
+	Size int
+	Data []byte
+}
+
+// Process декодирует изображение, обрезает его до квадрата по центру и строит
+// варианты заданных размеров. Результат перекодируется в PNG, поэтому EXIF и прочие
+// метаданные исходного файла не сохраняются.
+func Process(data []byte, sizes []int) ([]Variant, error) {
+	src, err := imageutil.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	crop := squareCrop(src.Bounds())
+	variants := make([]Variant, 0, len(sizes))
+	for _, size := range sizes {
+		scaled, err := imageutil.ScalePNG(src, crop, size, size)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode avatar: %w", err)
+		}
+		variants = append(variants, Variant{Size: size, Data: scaled})
+	}
+	return variants, nil
+}
+
+// squareCrop возвращает наибольший квадрат в центре прямоугольника
+func squareCrop(b image.Rectangle) image.Rectangle {
+	side := min(b.Dx(), b.Dy())
+	x := b.Min.X + (b.Dx()-side)/2
+	y := b.Min.Y + (b.Dy()-side)/2
+	return image.Rect(x, y, x+side, y+side)
+}
+
+// Save обрабатывает изображение и сохраняет все варианты в хранилище.
+// Возвращает ключ самого крупного варианта; ключи остальных получаются через Key.
+func Save(blobs storage.BlobStore, data []byte) (string, error) {
+	variants, err := Process(data, Sizes)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	id := hex.EncodeToString(sum[:])
+
+	var primary string
+	for _, v := range variants {
+		primary = Key(id, v.Size)
+		if err := blobs.Put(primary, v.Data, "image/png"); err != nil {
+			return "", fmt.Errorf("failed to store avatar: %w", err)
+		}
+	}
+	return primary, nil
+}
+
+// Key возвращает ключ варианта размера size для набора id
+func Key(id string, size int) string {
+	return fmt.Sprintf("avatars/%s/%d.png", id, size)
+}
+
+var keyRe = regexp.MustCompile(`^avatars/([0-9a-f]{64})/(\d+)\.png$`)
+
+// Variants возвращает ключи всех вариантов по ключу любого из них.
+// ok == false, если ключ не относится к обработанному аватару (например, загружен до появления вариантов).
+func Variants(key string) (map[int]string, bool) {
+	m := keyRe.FindStringSubmatch(key)
+	if m == nil {
+		return nil, false
+	}
+	if _, err := strconv.Atoi(m[2]); err != nil {
+		return nil, false
+	}
+
+	keys := make(map[int]string, len(Sizes))
+	for _, size := range Sizes {
+		keys[size] = Key(m[1], size)
+	}
+	return keys, true
+}
@@ -0,0 +1,91 @@
+package avatar
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Identicon строит детерминированный SVG-аватар для имени пользователя:
+// симметричный узор 5×5 и цвет вычисляются из SHA-256 имени, поэтому
+// у одного и того же пользователя картинка всегда одинаковая.
+func Identicon(username string) []byte {
+	sum := sha256.Sum256([]byte(strings.ToLower(username)))
+
+	hue := (int(sum[0])<<8 | int(sum[1])) % 360
+	fg := fmt.Sprintf("hsl(%d,55%%,50%%)", hue)
+
+	var b strings.Builder
+	b.WriteString(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="-0.5 -0.5 6 6" shape-rendering="crispEdges">`)
+	b.WriteString(`<rect x="-0.5" y="-0.5" width="6" height="6" fill="#f0f0f0"/>`)
+
+	// Левые три столбца задаются битами хэша, правые два — зеркальное отражение
+	bit := 0
+	for x := 0; x < 3; x++ {
+		for y := 0; y < 5; y++ {
+			on := sum[2+bit/8]>>(bit%8)&1 == 1
+			bit++
+			if !on {
+				continue
+			}
+			fmt.Fprintf(&b, `<rect x="%d" y="%d" width="1" height="1" fill="%s"/>`, x, y, fg)
+			if x != 2 {
+				fmt.Fprintf(&b, `<rect x="%d" y="%d" width="1" height="1" fill="%s"/>`, 4-x, y, fg)
+			}
+		}
+	}
+
+	b.WriteString(`</svg>`)
+	return []byte(b.String())
+}
+
+// Cache — LRU-кэш сгенерированных аватаров, чтобы не строить их на каждый запрос
+type Cache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List               // от недавно использованных к давним
+	items map[string]*list.Element // username -> элемент order
+}
+
+type cacheEntry struct {
+	username string
+	data     []byte
+}
+
+// NewCache создаёт кэш на size записей
+func NewCache(size int) *Cache {
+	return &Cache{
+		size:  size,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// Get возвращает аватар пользователя, генерируя его при промахе
+func (c *Cache) Get(username string) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[username]; ok {
+		c.order.MoveToFront(el)
+		return el.Value.(cacheEntry).data
+	}
+
+	data := Identicon(username)
+	c.items[username] = c.order.PushFront(cacheEntry{username: username, data: data})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(cacheEntry).username)
+	}
+	return data
+}
+
+// Len возвращает число закэшированных аватаров
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,111 @@
+package avatar_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/go-portfolio/websocket-chat/internal/avatar"
+	"github.com/go-portfolio/websocket-chat/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// testImage создаёт изображение w×h: левая половина красная, правая синяя
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= w/2 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// JPEG любого соотношения сторон превращается в квадратные PNG нужных размеров
+func TestProcess_SquareVariants(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&buf, testImage(400, 200), nil))
+
+	variants, err := avatar.Process(buf.Bytes(), []int{32, 64, 256})
+	assert.NoError(t, err)
+	assert.Len(t, variants, 3)
+
+	for _, v := range variants {
+		img, format, err := image.Decode(bytes.NewReader(v.Data))
+		assert.NoError(t, err)
+		assert.Equal(t, "png", format, "варианты всегда перекодируются в PNG")
+		assert.Equal(t, v.Size, img.Bounds().Dx())
+		assert.Equal(t, v.Size, img.Bounds().Dy())
+	}
+}
+
+// Обрезка идёт по центру: в квадрат попадают обе половины исходника
+func TestProcess_CenterCrop(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, testImage(300, 100)))
+
+	variants, err := avatar.Process(buf.Bytes(), []int{64})
+	assert.NoError(t, err)
+
+	img, _ := png.Decode(bytes.NewReader(variants[0].Data))
+	r, _, _, _ := img.At(2, 32).RGBA()
+	_, _, b, _ := img.At(61, 32).RGBA()
+	assert.Greater(t, r, uint32(0x8000), "левый край — красный")
+	assert.Greater(t, b, uint32(0x8000), "правый край — синий")
+}
+
+// Не-изображения отклоняются
+func TestProcess_NotImage(t *testing.T) {
+	_, err := avatar.Process([]byte("definitely not an image"), avatar.Sizes)
+	assert.ErrorIs(t, err, avatar.ErrNotImage)
+}
+
+// Save кладёт все варианты в хранилище, Variants восстанавливает их ключи
+func TestSave_AndVariants(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, testImage(50, 50)))
+	blobs := storage.NewMemoryStore()
+
+	key, err := avatar.Save(blobs, buf.Bytes())
+	assert.NoError(t, err)
+	assert.Len(t, blobs.Keys(), len(avatar.Sizes))
+
+	keys, ok := avatar.Variants(key)
+	assert.True(t, ok)
+	for _, size := range avatar.Sizes {
+		assert.Contains(t, blobs.Keys(), keys[size])
+	}
+
+	_, ok = avatar.Variants("1700000000_me.png")
+	assert.False(t, ok, "старые аватары без вариантов не распознаются")
+}
+
+// Identicon детерминирован и различается у разных пользователей
+func TestIdenticon_Deterministic(t *testing.T) {
+	a1 := avatar.Identicon("alice")
+	a2 := avatar.Identicon("alice")
+	b := avatar.Identicon("bob")
+
+	assert.Equal(t, a1, a2)
+	assert.NotEqual(t, a1, b)
+	assert.True(t, bytes.HasPrefix(a1, []byte("<svg")))
+}
+
+// Cache хранит не больше size записей, вытесняя давно не использованные
+func TestCache_Eviction(t *testing.T) {
+	cache := avatar.NewCache(2)
+
+	assert.Equal(t, avatar.Identicon("alice"), cache.Get("alice"))
+	cache.Get("bob")
+	cache.Get("alice") // alice снова «свежая»
+	cache.Get("carol") // вытесняет bob
+
+	assert.Equal(t, 2, cache.Len())
+}
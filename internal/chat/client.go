@@ -16,17 +16,24 @@ type Client struct {
 	privateChan chan ChatMessage
 	CloseCh     chan struct{}
 	Username    string
+	SessionID   string // сессия входа; при её отзыве клиент отключается
+	kickCh      chan string
 }
 
 // NewClient создаёт нового клиента
 func NewClient(hub *Hub, room RoomManager, conn WebSocketConn, username string) *Client {
+	bufSize := DefaultSettings().SendBufferSize
+	if hub != nil {
+		bufSize = hub.Settings.SendBufferSize
+	}
 	return &Client{
 		Hub:         hub,
 		Room:        room,
 		Conn:        conn,
-		privateChan: make(chan ChatMessage, 16),
+		privateChan: make(chan ChatMessage, bufSize),
 		CloseCh:     make(chan struct{}),
 		Username:    username,
+		kickCh:      make(chan string, 1),
 	}
 }
 
@@ -35,6 +42,11 @@ func (c *Client) GetUsername() string {
 	return c.Username
 }
 
+// GetSessionID возвращает ID сессии, в рамках которой открыто подключение
+func (c *Client) GetSessionID() string {
+	return c.SessionID
+}
+
 // GetRoomName возвращает имя комнаты через интерфейс RoomManager
 func (c *Client) GetRoomName() string {
 	if c.Room != nil {
@@ -69,6 +81,15 @@ func (c *Client) Close() error {
 	return c.Conn.Close()
 }
 
+// Kick принудительно отключает клиента: WriteSocket отправляет событие "kicked"
+// с причиной и закрывает соединение, после чего клиент отключается как обычно
+func (c *Client) Kick(reason string) {
+	select {
+	case c.kickCh <- reason:
+	default: // клиента уже отключают
+	}
+}
+
 // ReadSocket читает сообщения из WebSocket
 func (c *Client) ReadSocket() {
 	defer func() {
@@ -76,18 +97,20 @@ func (c *Client) ReadSocket() {
 		c.Conn.Close()
 	}()
 
-	c.Conn.SetReadLimit(512)
-	c.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	settings := c.Hub.Settings
+	c.Conn.SetReadLimit(settings.ReadLimit)
+	c.Conn.SetReadDeadline(time.Now().Add(settings.PongWait))
 	c.Conn.SetPongHandler(func(string) error {
-		c.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		c.Conn.SetReadDeadline(time.Now().Add(settings.PongWait))
 		return nil
 	})
 
 	for {
 		var incoming struct {
-			Text string `json:"text"`
-			To   string `json:"to"`
-			Type string `json:"type"`
+			Text         string `json:"text"`
+			To           string `json:"to"`
+			Type         string `json:"type"`
+			AttachmentID string `json:"attachment_id"`
 		}
 
 		if err := c.Conn.ReadJSON(&incoming); err != nil {
@@ -98,6 +121,7 @@ func (c *Client) ReadSocket() {
 		}
 
 		msg := ChatMessage{
+			ID:        NewMessageID(),
 			Type:      strings.TrimSpace(incoming.Type),
 			From:      c.Username,
 			Text:      strings.TrimSpace(incoming.Text),
@@ -106,28 +130,38 @@ func (c *Client) ReadSocket() {
 			Timestamp: time.Now().Unix(),
 		}
 
-		if msg.Text == "" {
+		if msg.Type == "attachment" {
+			msg.Attachment = &Attachment{ID: strings.TrimSpace(incoming.AttachmentID)}
+		} else if msg.Text == "" {
+			continue
+		}
+
+		if err := c.Hub.Process(&msg); err != nil {
+			_ = c.SendMessage(ChatMessage{
+				Type:      "error",
+				Text:      err.Error(),
+				Room:      msg.Room,
+				Timestamp: time.Now().Unix(),
+			})
 			continue
 		}
 
 		if msg.To != "" {
-			msg.Type = "private"
-			c.Hub.mu.RLock()
-			for client := range c.Hub.Clients {
-				if client.GetUsername() == msg.To || client.GetUsername() == msg.From {
-					_ = client.SendMessage(msg)
-				}
+			if msg.Type != "attachment" {
+				msg.Type = "private"
 			}
-			c.Hub.mu.RUnlock()
+			c.Hub.Deliver(msg)
 		} else {
 			c.Room.BroadcastMessage(msg)
 		}
+		c.Hub.notifyObservers(msg)
 	}
 }
 
 // WriteSocket пишет сообщения из канала клиенту и отправляет PING
 func (c *Client) WriteSocket() {
-	ticker := time.NewTicker(45 * time.Second)
+	settings := c.Hub.Settings
+	ticker := time.NewTicker(settings.PingPeriod)
 	defer func() {
 		ticker.Stop()
 		c.Conn.Close()
@@ -136,17 +170,23 @@ func (c *Client) WriteSocket() {
 	for {
 		select {
 		case msg := <-c.privateChan:
-			c.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			c.Conn.SetWriteDeadline(time.Now().Add(settings.WriteWait))
 			if err := c.Conn.WriteJSON(msg); err != nil {
 				return
 			}
 
 		case <-ticker.C:
-			c.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			c.Conn.SetWriteDeadline(time.Now().Add(settings.WriteWait))
 			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
 
+		case reason := <-c.kickCh:
+			c.Conn.SetWriteDeadline(time.Now().Add(settings.WriteWait))
+			_ = c.Conn.WriteJSON(ChatMessage{Type: "kicked", Text: reason, Timestamp: time.Now().Unix()})
+			_ = c.Conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason))
+			return
+
 		case <-c.CloseCh:
 			return
 		}
@@ -1,6 +1,8 @@
 package chat
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"sync"
 	"time"
@@ -13,6 +15,11 @@ type Hub struct {
 	Rooms        map[string]RoomManager
 	mu           sync.RWMutex
 	BroadcastCh  chan ChatMessage
+	Settings     Settings
+	// Processors вызываются по порядку для каждого сообщения клиента до рассылки
+	Processors []MessageProcessor
+	// Observers уведомляются о каждом сообщении клиента после рассылки
+	Observers []MessageObserver
 }
 
 func NewHub() *Hub {
@@ -22,6 +29,7 @@ func NewHub() *Hub {
 		BroadcastCh:  make(chan ChatMessage, 128),
 		RegisterCh:   make(chan UserClient),
 		unregisterCh: make(chan UserClient),
+		Settings:     DefaultSettings(),
 	}
 }
 
@@ -105,6 +113,170 @@ func (h *Hub) Broadcast(msg ChatMessage) {
 	}
 }
 
+// Process прогоняет сообщение через все Processors; первая ошибка прерывает обработку
+func (h *Hub) Process(msg *ChatMessage) error {
+	for _, p := range h.Processors {
+		if err := p.ProcessMessage(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notifyObservers сообщает наблюдателям о разосланном сообщении
+func (h *Hub) notifyObservers(msg ChatMessage) {
+	for _, o := range h.Observers {
+		o.MessageSent(msg)
+	}
+}
+
+// Deliver доставляет сообщение адресатам: личное — получателю и отправителю,
+// остальные — всем клиентам комнаты msg.Room
+func (h *Hub) Deliver(msg ChatMessage) {
+	if msg.To != "" {
+		h.mu.RLock()
+		for client := range h.Clients {
+			if client.GetUsername() == msg.To || client.GetUsername() == msg.From {
+				_ = client.SendMessage(msg)
+			}
+		}
+		h.mu.RUnlock()
+		return
+	}
+	h.GetRoom(msg.Room).BroadcastMessage(msg)
+}
+
+// SendToUser отправляет сообщение всем подключениям пользователя, в какой бы комнате
+// они ни находились. Возвращает false, если пользователь не в сети.
+func (h *Hub) SendToUser(username string, msg ChatMessage) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	sent := false
+	for client := range h.Clients {
+		if client.GetUsername() == username {
+			_ = client.SendMessage(msg)
+			sent = true
+		}
+	}
+	return sent
+}
+
+// IsOnline сообщает, есть ли у пользователя хотя бы одно подключение
+func (h *Hub) IsOnline(username string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for client := range h.Clients {
+		if client.GetUsername() == username {
+			return true
+		}
+	}
+	return false
+}
+
+// Disconnect принудительно отключает все подключения пользователя
+// и возвращает их число
+func (h *Hub) Disconnect(username, reason string) int {
+	return h.kick(func(client UserClient) bool { return client.GetUsername() == username }, reason)
+}
+
+// DisconnectSession принудительно отключает подключения, открытые в рамках
+// сессии (например, после её отзыва), и возвращает их число
+func (h *Hub) DisconnectSession(sessionID, reason string) int {
+	if sessionID == "" {
+		return 0
+	}
+	return h.kick(func(client UserClient) bool {
+		s, ok := client.(SessionBound)
+		return ok && s.GetSessionID() == sessionID
+	}, reason)
+}
+
+// SessionConnections возвращает число открытых подключений пользователя по ID сессий
+func (h *Hub) SessionConnections(username string) map[string]int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	counts := make(map[string]int)
+	for client := range h.Clients {
+		s, ok := client.(SessionBound)
+		if !ok || client.GetUsername() != username || s.GetSessionID() == "" {
+			continue
+		}
+		counts[s.GetSessionID()]++
+	}
+	return counts
+}
+
+// kick отключает клиентов, подходящих под match
+func (h *Hub) kick(match func(UserClient) bool, reason string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	n := 0
+	for client := range h.Clients {
+		if !match(client) {
+			continue
+		}
+		if k, ok := client.(Kicker); ok {
+			k.Kick(reason)
+			n++
+		}
+	}
+	return n
+}
+
+// Announce рассылает системное сообщение во все комнаты, где кто-то есть,
+// и возвращает число таких комнат
+func (h *Hub) Announce(text string) int {
+	counts := h.OnlineCounts()
+	for name := range counts {
+		h.GetRoom(name).BroadcastMessage(ChatMessage{
+			ID:        NewMessageID(),
+			Type:      "system",
+			Room:      name,
+			Text:      text,
+			Timestamp: time.Now().Unix(),
+		})
+	}
+	return len(counts)
+}
+
+// OnlineCounts возвращает число пользователей в сети по комнатам (без пустых комнат)
+func (h *Hub) OnlineCounts() map[string]int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	users := make(map[string]map[string]bool)
+	for client := range h.Clients {
+		room := client.GetRoomName()
+		if users[room] == nil {
+			users[room] = make(map[string]bool)
+		}
+		users[room][client.GetUsername()] = true
+	}
+	counts := make(map[string]int, len(users))
+	for room, set := range users {
+		counts[room] = len(set)
+	}
+	return counts
+}
+
+// PruneHistory убирает сообщения из истории комнаты в памяти, которую получают
+// вошедшие в комнату (см. Room.PruneHistory); комнату не создаёт
+func (h *Hub) PruneHistory(room string, ids []string, before time.Time) int {
+	h.mu.RLock()
+	rm, ok := h.Rooms[room].(*Room)
+	h.mu.RUnlock()
+	if !ok {
+		return 0
+	}
+	return rm.PruneHistory(ids, before)
+}
+
+// NewMessageID генерирует случайный идентификатор сообщения
+func NewMessageID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
 func (h *Hub) GetRoom(name string) RoomManager {
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -112,6 +284,7 @@ func (h *Hub) GetRoom(name string) RoomManager {
 		return room
 	}
 	room := NewRoom(name)
+	room.HistorySize = h.Settings.HistorySize
 	h.Rooms[name] = room
 	go room.Run()
 	return room
@@ -1,6 +1,10 @@
 package chat
 
-import "sync"
+import (
+	"slices"
+	"sync"
+	"time"
+)
 
 // Room реализует RoomManager
 type Room struct {
@@ -8,15 +12,18 @@ type Room struct {
 	Clients   map[UserClient]bool
 	Broadcast chan ChatMessage
 	History   []ChatMessage
-	Mu        sync.RWMutex
+	// HistorySize — сколько последних сообщений хранить в History
+	HistorySize int
+	Mu          sync.RWMutex
 }
 
 func NewRoom(name string) *Room {
 	return &Room{
-		Name:      name,
-		Clients:   make(map[UserClient]bool),
-		Broadcast: make(chan ChatMessage, 128),
-		History:   make([]ChatMessage, 0, 50),
+		Name:        name,
+		Clients:     make(map[UserClient]bool),
+		Broadcast:   make(chan ChatMessage, 128),
+		History:     make([]ChatMessage, 0, 50),
+		HistorySize: 50,
 	}
 }
 
@@ -31,13 +38,33 @@ func (r *Room) Run() {
 		}
 		r.Mu.RUnlock()
 
+		r.Mu.Lock()
 		r.History = append(r.History, msg)
-		if len(r.History) > 50 {
-			r.History = r.History[len(r.History)-50:]
+		if len(r.History) > r.HistorySize {
+			r.History = r.History[len(r.History)-r.HistorySize:]
 		}
+		r.Mu.Unlock()
 	}
 }
 
+// PruneHistory убирает из History сообщения с ID из ids и отправленные раньше before
+// (нулевое before — только по ID) и возвращает число убранных
+func (r *Room) PruneHistory(ids []string, before time.Time) int {
+	r.Mu.Lock()
+	defer r.Mu.Unlock()
+	kept := r.History[:0]
+	for _, msg := range r.History {
+		if (msg.ID != "" && slices.Contains(ids, msg.ID)) || (!before.IsZero() && msg.Timestamp < before.Unix()) {
+			continue
+		}
+		kept = append(kept, msg)
+	}
+	removed := len(r.History) - len(kept)
+	clear(r.History[len(kept):])
+	r.History = kept
+	return removed
+}
+
 func (r *Room) OnlineUsers() []string {
 	r.Mu.RLock()
 	defer r.Mu.RUnlock()
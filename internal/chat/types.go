@@ -4,13 +4,94 @@ import "time"
 
 // ChatMessage представляет одно сообщение
 type ChatMessage struct {
-	Type      string              `json:"type"`
-	From      string              `json:"from"`
-	To        string              `json:"to,omitempty"`
-	Text      string              `json:"text"`
-	Timestamp int64               `json:"timestamp"`
-	Room      string              `json:"room"`
-	Users     map[string]UserClient
+	ID           string        `json:"id,omitempty"`
+	Type         string        `json:"type"`
+	From         string        `json:"from"`
+	To           string        `json:"to,omitempty"`
+	Text         string        `json:"text"`
+	HTML         string        `json:"html,omitempty"` // безопасный HTML из Markdown, строится сервером
+	Timestamp    int64         `json:"timestamp"`
+	Room         string        `json:"room"`
+	Attachment   *Attachment   `json:"attachment,omitempty"`
+	Previews     []LinkPreview `json:"previews,omitempty"`
+	Mentions     []Mention     `json:"mentions,omitempty"`
+	Notification *Notification `json:"notification,omitempty"` // у событий "notification"
+	Users        map[string]UserClient
+}
+
+// Attachment описывает файл, приложенный к сообщению типа "attachment"
+type Attachment struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Size         int64  `json:"size"`
+	MimeType     string `json:"mime_type"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+}
+
+// LinkPreview — карточка ссылки из сообщения (OpenGraph), приходит событием "message_unfurled"
+type LinkPreview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+}
+
+// Mention — упоминание в тексте сообщения: @username, @here (все в комнате онлайн)
+// или @room (все участники комнаты). Позиции считаются в символах, а не в байтах.
+type Mention struct {
+	Kind     string `json:"kind"` // "user", "here" или "room"
+	Username string `json:"username,omitempty"`
+	Offset   int    `json:"offset"` // позиция "@" в тексте
+	Length   int    `json:"length"` // длина упоминания вместе с "@"
+}
+
+// Notification — запись во входящих пользователя: упоминание, личное сообщение,
+// пришедшее пока он был не в сети, приглашение или действие модератора
+type Notification struct {
+	ID        int64  `json:"id"`
+	Kind      string `json:"kind"`
+	From      string `json:"from,omitempty"`
+	Room      string `json:"room,omitempty"`
+	MessageID string `json:"message_id,omitempty"`
+	Text      string `json:"text"`
+	Read      bool   `json:"read"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// MessageProcessor обрабатывает входящее сообщение до рассылки:
+// может дополнить его или отклонить, вернув ошибку (она уходит отправителю)
+type MessageProcessor interface {
+	ProcessMessage(msg *ChatMessage) error
+}
+
+// MessageObserver получает сообщения клиентов после рассылки.
+// Вызывается синхронно из цикла чтения клиента, поэтому долгую работу нужно выносить в горутину.
+type MessageObserver interface {
+	MessageSent(msg ChatMessage)
+}
+
+// Settings — настраиваемые параметры соединений и комнат
+type Settings struct {
+	ReadLimit      int64         // максимальный размер входящего сообщения
+	PongWait       time.Duration // сколько ждать PONG от клиента
+	PingPeriod     time.Duration // период отправки PING (меньше PongWait)
+	WriteWait      time.Duration // таймаут записи в сокет
+	SendBufferSize int           // размер очереди исходящих сообщений клиента
+	HistorySize    int           // сколько сообщений комнаты хранить в памяти
+}
+
+// DefaultSettings возвращает параметры по умолчанию
+func DefaultSettings() Settings {
+	return Settings{
+		ReadLimit:      512,
+		PongWait:       60 * time.Second,
+		PingPeriod:     45 * time.Second,
+		WriteWait:      10 * time.Second,
+		SendBufferSize: 16,
+		HistorySize:    50,
+	}
 }
 
 // Интерфейс для клиента чата
@@ -23,6 +104,16 @@ type UserClient interface {
 	PrivateChan() chan ChatMessage
 }
 
+// Kicker — клиент, которого можно принудительно отключить (реализуется Client)
+type Kicker interface {
+	Kick(reason string)
+}
+
+// SessionBound — клиент, подключившийся в рамках сессии входа (реализуется Client)
+type SessionBound interface {
+	GetSessionID() string
+}
+
 // Минимальные методы websocket.Conn, которые нужны клиенту
 type WebSocketConn interface {
 	ReadJSON(v interface{}) error
@@ -274,3 +274,154 @@ func TestClient_Close(t *testing.T) {
 	assert.NoError(t, err, "Close() клиента не должен возвращать ошибку")
 	assert.True(t, conn.closed, "Close() клиента должен закрывать и соединение (WebSocketConn.Close)")
 }
+
+// --- Тесты MessageProcessor --------------------------------------------------
+
+// processorFunc — адаптер функции к интерфейсу chat.MessageProcessor
+type processorFunc func(msg *chat.ChatMessage) error
+
+func (f processorFunc) ProcessMessage(msg *chat.ChatMessage) error { return f(msg) }
+
+// TestHub_Process
+// Цель: процессоры вызываются по порядку и могут дополнять сообщение;
+// первая ошибка прерывает цепочку.
+func TestHub_Process(t *testing.T) {
+	hub := chat.NewHub()
+	var calls []string
+
+	hub.Processors = []chat.MessageProcessor{
+		processorFunc(func(msg *chat.ChatMessage) error {
+			calls = append(calls, "first")
+			msg.Text += "!"
+			return nil
+		}),
+		processorFunc(func(msg *chat.ChatMessage) error {
+			calls = append(calls, "second")
+			if msg.Type == "attachment" {
+				return errors.New("rejected")
+			}
+			return nil
+		}),
+		processorFunc(func(msg *chat.ChatMessage) error {
+			calls = append(calls, "third")
+			return nil
+		}),
+	}
+
+	msg := chat.ChatMessage{Text: "hi"}
+	assert.NoError(t, hub.Process(&msg))
+	assert.Equal(t, "hi!", msg.Text, "процессор может изменить сообщение")
+	assert.Equal(t, []string{"first", "second", "third"}, calls)
+
+	calls = nil
+	err := hub.Process(&chat.ChatMessage{Type: "attachment"})
+	assert.EqualError(t, err, "rejected")
+	assert.Equal(t, []string{"first", "second"}, calls, "после ошибки цепочка прерывается")
+}
+
+// --- Тесты принудительного отключения ----------------------------------------
+
+// TestClient_Kick
+// Цель: после Kick WriteSocket отправляет событие "kicked" с причиной,
+// кадр закрытия и завершается, закрывая соединение.
+func TestClient_Kick(t *testing.T) {
+	hub := chat.NewHub()
+	conn := &mockConn{}
+	client := chat.NewClient(hub, hub.GetRoom("room1"), conn, "alice")
+
+	client.Kick("banned")
+	client.Kick("повторный вызов не блокирует")
+
+	done := make(chan struct{})
+	go func() {
+		client.WriteSocket()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("WriteSocket не завершился после Kick")
+	}
+
+	assert.Equal(t, []chat.ChatMessage{{Type: "kicked", Text: "banned", Timestamp: conn.writeJSONCalls[0].Timestamp}}, conn.writeJSONCalls)
+	assert.Equal(t, 1, conn.writeMsgCalls, "должен уйти кадр закрытия")
+	assert.True(t, conn.closed)
+}
+
+// TestHub_DisconnectAndOnlineCounts
+// Цель: Disconnect отключает все подключения пользователя (и только его),
+// OnlineCounts считает уникальных пользователей по комнатам.
+func TestHub_DisconnectAndOnlineCounts(t *testing.T) {
+	hub := chat.NewHub()
+	alice1 := chat.NewClient(hub, hub.GetRoom("tech"), &mockConn{}, "alice")
+	alice2 := chat.NewClient(hub, hub.GetRoom("tech"), &mockConn{}, "alice")
+	bob := chat.NewClient(hub, hub.GetRoom("random"), &mockConn{}, "bob")
+	for _, c := range []*chat.Client{alice1, alice2, bob} {
+		hub.Clients[c] = true
+	}
+	// Клиенты без Kick (например, моки) пропускаются
+	hub.Clients[newMockClient("alice", "tech")] = true
+
+	assert.Equal(t, map[string]int{"tech": 1, "random": 1}, hub.OnlineCounts())
+	assert.Equal(t, 2, hub.Disconnect("alice", "kicked by admin"))
+	assert.Equal(t, 0, hub.Disconnect("carol", "kicked by admin"))
+}
+
+// TestHub_DisconnectSession
+// Цель: DisconnectSession отключает только подключения отозванной сессии.
+func TestHub_DisconnectSession(t *testing.T) {
+	hub := chat.NewHub()
+	phone := chat.NewClient(hub, hub.GetRoom("tech"), &mockConn{}, "alice")
+	phone.SessionID = "s1"
+	laptop := chat.NewClient(hub, hub.GetRoom("tech"), &mockConn{}, "alice")
+	laptop.SessionID = "s2"
+	hub.Clients[phone] = true
+	hub.Clients[laptop] = true
+	hub.Clients[newMockClient("alice", "tech")] = true
+
+	assert.Equal(t, 1, hub.DisconnectSession("s1", "logged out"))
+	assert.Equal(t, 0, hub.DisconnectSession("unknown", "logged out"))
+	assert.Equal(t, 0, hub.DisconnectSession("", "logged out"), "клиенты без сессии не совпадают с пустым ID")
+}
+
+// TestHub_SessionConnections
+// Цель: подключения считаются по сессиям только для указанного пользователя.
+func TestHub_SessionConnections(t *testing.T) {
+	hub := chat.NewHub()
+	for _, c := range []struct{ user, sid string }{
+		{"alice", "s1"}, {"alice", "s1"}, {"alice", "s2"}, {"alice", ""}, {"bob", "s3"},
+	} {
+		client := chat.NewClient(hub, hub.GetRoom("tech"), &mockConn{}, c.user)
+		client.SessionID = c.sid
+		hub.Clients[client] = true
+	}
+
+	assert.Equal(t, map[string]int{"s1": 2, "s2": 1}, hub.SessionConnections("alice"))
+	assert.Empty(t, hub.SessionConnections("carol"))
+}
+
+// TestHub_PruneHistory
+// Цель: история в памяти очищается так же, как история в базе — по ID удалённых
+// сообщений и по сроку; несуществующие комнаты не создаются.
+func TestHub_PruneHistory(t *testing.T) {
+	hub := chat.NewHub()
+	room := hub.GetRoom("legal").(*chat.Room)
+	now := time.Now()
+	room.Mu.Lock()
+	room.History = []chat.ChatMessage{
+		{ID: "m1", Text: "old", Timestamp: now.Add(-40 * 24 * time.Hour).Unix()},
+		{Type: "system", Text: "joined", Timestamp: now.Add(-35 * 24 * time.Hour).Unix()},
+		{ID: "m2", Text: "deleted by count", Timestamp: now.Unix()},
+		{ID: "m3", Text: "fresh", Timestamp: now.Unix()},
+	}
+	room.Mu.Unlock()
+
+	assert.Equal(t, 3, hub.PruneHistory("legal", []string{"m2"}, now.Add(-30*24*time.Hour)))
+	room.Mu.RLock()
+	assert.Equal(t, []chat.ChatMessage{{ID: "m3", Text: "fresh", Timestamp: now.Unix()}}, room.History)
+	room.Mu.RUnlock()
+
+	assert.Equal(t, 0, hub.PruneHistory("other", []string{"m3"}, time.Time{}))
+	_, created := hub.Rooms["other"]
+	assert.False(t, created)
+}
@@ -0,0 +1,150 @@
+// Package chatctl — команды администрирования чата (cmd/chatctl): пользователи и комнаты
+// напрямую через БД, онлайн, кик и объявления — через admin API работающего сервера.
+package chatctl
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/go-portfolio/websocket-chat/internal/room"
+	"github.com/go-portfolio/websocket-chat/internal/user"
+)
+
+// Usage — справка по командам
+const Usage = `usage: chatctl [flags] <command> [args]
+
+commands:
+  users list
+  users create <username> [-password p] [-admin]
+  users delete <username>
+  users reset-password <username> [-password p]
+  users admin <username> on|off
+  users reset-2fa <username>
+  require-2fa on|off
+  rooms list
+  kick <username> [-reason r]
+  ban <username> [-reason r]
+  unban <username>
+  announce <text>
+
+flags:
+`
+
+// ErrUsage — неверные аргументы команды
+var ErrUsage = errors.New("invalid arguments")
+
+// Ctl — подключения, общие для всех команд
+type Ctl struct {
+	DatabaseURL string
+	Server      *AdminClient
+	// Политика паролей — та же, что у сервера (-password-min-length, -password-breached-file)
+	Policy       user.PasswordPolicy
+	BreachedFile string
+
+	Out io.Writer // nil — os.Stdout
+	Err io.Writer // nil — os.Stderr
+
+	// Хранилища; если не заданы, создаются по DatabaseURL при первом обращении
+	Users *user.Store
+	Rooms *room.Store
+}
+
+// Run выполняет команду; ErrUsage — неверные аргументы
+func (c *Ctl) Run(args []string) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+	switch cmd, rest := args[0], args[1:]; cmd {
+	case "users":
+		if len(rest) == 0 {
+			return ErrUsage
+		}
+		return c.usersCommand(rest[0], rest[1:])
+	case "rooms":
+		if len(rest) != 1 || rest[0] != "list" {
+			return ErrUsage
+		}
+		return c.listRooms()
+	case "kick":
+		return c.kick(rest)
+	case "ban":
+		return c.ban(rest, true)
+	case "unban":
+		return c.ban(rest, false)
+	case "announce":
+		return c.announce(rest)
+	case "require-2fa":
+		return c.require2FA(rest)
+	default:
+		return ErrUsage
+	}
+}
+
+// Close закрывает подключение к базе
+func (c *Ctl) Close() {
+	if c.Users != nil {
+		c.Users.Close()
+	}
+}
+
+// db подключается к базе при первом обращении
+func (c *Ctl) db() error {
+	if c.Users != nil {
+		if c.Rooms == nil {
+			c.Rooms = room.NewStore(c.Users.Db)
+		}
+		return nil
+	}
+	if c.DatabaseURL == "" {
+		return errors.New("database-url (DATABASE_URL) is required")
+	}
+	if c.BreachedFile != "" && c.Policy.Breached == nil {
+		breached, err := user.LoadBreachedList(c.BreachedFile)
+		if err != nil {
+			return fmt.Errorf("password-breached-file: %w", err)
+		}
+		c.Policy.Breached = breached
+	}
+	store, err := user.NewStore(c.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	store.Policy = c.Policy
+	c.Users = store
+	c.Rooms = room.NewStore(store.Db)
+	return nil
+}
+
+func (c *Ctl) out() io.Writer {
+	if c.Out == nil {
+		return os.Stdout
+	}
+	return c.Out
+}
+
+func (c *Ctl) errOut() io.Writer {
+	if c.Err == nil {
+		return os.Stderr
+	}
+	return c.Err
+}
+
+// parseFlags разбирает флаги подкоманды, допуская их после позиционных аргументов
+// ("users create alice -admin")
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	fs.SetOutput(io.Discard)
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, ErrUsage
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
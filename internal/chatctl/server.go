@@ -0,0 +1,103 @@
+package chatctl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// AdminClient обращается к admin API работающего сервера
+type AdminClient struct {
+	BaseURL string
+	Token   string
+	HTTP    *http.Client
+}
+
+// OnlineCounts возвращает число пользователей в сети по комнатам
+func (a *AdminClient) OnlineCounts() (map[string]int, error) {
+	var resp struct {
+		Rooms []struct {
+			Room   string `json:"room"`
+			Online int    `json:"online"`
+		} `json:"rooms"`
+	}
+	if err := a.do(http.MethodGet, "/api/admin/rooms", nil, &resp); err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int, len(resp.Rooms))
+	for _, r := range resp.Rooms {
+		counts[r.Room] = r.Online
+	}
+	return counts, nil
+}
+
+// Kick отключает все подключения пользователя и возвращает их число;
+// silent — не оставлять пользователю уведомление об отключении
+func (a *AdminClient) Kick(username, reason string, silent bool) (int, error) {
+	var resp struct {
+		Disconnected int `json:"disconnected"`
+	}
+	path := "/api/admin/users/" + url.PathEscape(username) + "/kick"
+	err := a.do(http.MethodPost, path, map[string]any{"reason": reason, "silent": silent}, &resp)
+	return resp.Disconnected, err
+}
+
+// Announce рассылает системное сообщение и возвращает число комнат
+func (a *AdminClient) Announce(text string) (int, error) {
+	var resp struct {
+		Rooms int `json:"rooms"`
+	}
+	err := a.do(http.MethodPost, "/api/admin/announcements", map[string]string{"text": text}, &resp)
+	return resp.Rooms, err
+}
+
+func (a *AdminClient) do(method, path string, body, out any) error {
+	if a.Token == "" {
+		return errors.New("admin-token (ADMIN_TOKEN) is required for server commands")
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, strings.TrimRight(a.BaseURL, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := a.HTTP
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&e)
+		if e.Error == "" {
+			e.Error = resp.Status
+		}
+		return fmt.Errorf("server: %s", e.Error)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,179 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-portfolio/websocket-chat/internal/chatctl"
+	"github.com/go-portfolio/websocket-chat/internal/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// kickRequest — запрос к admin API, который получил сервер
+type kickRequest struct {
+	Path   string
+	Reason string `json:"reason"`
+	Silent bool   `json:"silent"`
+}
+
+// adminServer поднимает admin API, отвечающий на кик disconnected подключениями
+func adminServer(t *testing.T, disconnected int) (*chatctl.AdminClient, *[]kickRequest) {
+	var kicks []kickRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer admin-secret", r.Header.Get("Authorization"))
+		req := kickRequest{Path: r.URL.Path}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		kicks = append(kicks, req)
+		_ = json.NewEncoder(w).Encode(map[string]int{"disconnected": disconnected})
+	}))
+	t.Cleanup(srv.Close)
+	return &chatctl.AdminClient{BaseURL: srv.URL, Token: "admin-secret"}, &kicks
+}
+
+// newCtl создаёт Ctl поверх sqlmock; вывод команд собирается в out и errOut
+func newCtl(t *testing.T, server *chatctl.AdminClient) (*chatctl.Ctl, sqlmock.Sqlmock, *bytes.Buffer, *bytes.Buffer) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	var out, errOut bytes.Buffer
+	c := &chatctl.Ctl{
+		Server: server,
+		Users:  &user.Store{Db: db, Policy: user.PasswordPolicy{MinLength: 8}},
+		Out:    &out,
+		Err:    &errOut,
+	}
+	return c, mock, &out, &errOut
+}
+
+func expectRevoke(mock sqlmock.Sqlmock, username string, ids ...string) {
+	rows := sqlmock.NewRows([]string{"id"})
+	for _, id := range ids {
+		rows.AddRow(id)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE sessions SET revoked_at = NOW()`)).
+		WithArgs(username, "").WillReturnRows(rows)
+}
+
+func TestRun_Usage(t *testing.T) {
+	c, _, _, _ := newCtl(t, &chatctl.AdminClient{})
+	assert.ErrorIs(t, c.Run(nil), chatctl.ErrUsage)
+	assert.ErrorIs(t, c.Run([]string{"users", "create"}), chatctl.ErrUsage)
+	assert.ErrorIs(t, c.Run([]string{"ban"}), chatctl.ErrUsage)
+	assert.ErrorIs(t, c.Run([]string{"rooms"}), chatctl.ErrUsage)
+}
+
+// Блокировка: отметка в БД, уведомление, отзыв сессий и отключение от сервера
+func TestBan_RevokesSessionsAndKicks(t *testing.T) {
+	server, kicks := adminServer(t, 2)
+	c, mock, out, _ := newCtl(t, server)
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET banned_at=`)).
+		WithArgs("mallory", true).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO notifications`)).
+		WithArgs("mallory", "moderation", "", "", "", "Администратор заблокировал ваш аккаунт: spam").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+	expectRevoke(mock, "mallory", "s1", "s2")
+
+	require.NoError(t, c.Run([]string{"ban", "mallory", "-reason", "spam"}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []kickRequest{{Path: "/api/admin/users/mallory/kick", Reason: "spam", Silent: true}}, *kicks)
+	assert.Equal(t, "banned mallory\nrevoked 2 session(s)\ndisconnected 2 connection(s)\n", out.String())
+}
+
+// Разблокировка не трогает сессии и не обращается к серверу
+func TestUnban(t *testing.T) {
+	server, kicks := adminServer(t, 0)
+	c, mock, out, _ := newCtl(t, server)
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET banned_at=`)).
+		WithArgs("mallory", false).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, c.Run([]string{"unban", "mallory"}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Empty(t, *kicks)
+	assert.Equal(t, "unbanned mallory\n", out.String())
+}
+
+func TestUsersCreate(t *testing.T) {
+	c, mock, out, _ := newCtl(t, &chatctl.AdminClient{})
+
+	// Политика паролей сервера действует и здесь: до базы слабый пароль не доходит
+	assert.ErrorIs(t, c.Run([]string{"users", "create", "alice", "-password", "short"}), user.ErrWeakPassword)
+
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO users`)).
+		WithArgs("alice", sqlmock.AnyArg(), sqlmock.AnyArg(), nil).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET is_admin=$2 WHERE username=$1`)).
+		WithArgs("alice", true).WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, c.Run([]string{"users", "create", "alice", "-admin", "-password", "correct horse"}))
+	assert.Equal(t, "created alice\n", out.String(), "заданный пароль не выводится")
+
+	// Без -password пароль генерируется и выводится
+	out.Reset()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO users`)).
+		WithArgs("bob", sqlmock.AnyArg(), sqlmock.AnyArg(), nil).WillReturnResult(sqlmock.NewResult(1, 1))
+	require.NoError(t, c.Run([]string{"users", "create", "bob"}))
+	assert.Regexp(t, `^created bob\npassword: [A-Za-z0-9_-]{16}\n$`, out.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Сброс пароля меняет его вместе с удалением ссылок сброса, завершает все сессии
+// и отключает пользователя
+func TestUsersResetPassword(t *testing.T) {
+	server, kicks := adminServer(t, 1)
+	c, mock, out, _ := newCtl(t, server)
+
+	assert.ErrorIs(t, c.Run([]string{"users", "reset-password", "alice", "-password", "short"}), user.ErrWeakPassword)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET password_hash=$2 WHERE username=$1`)).
+		WithArgs("alice", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM password_resets WHERE username=$1`)).
+		WithArgs("alice").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectRevoke(mock, "alice", "s1")
+
+	require.NoError(t, c.Run([]string{"users", "reset-password", "alice", "-password", "correct horse"}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []kickRequest{{Path: "/api/admin/users/alice/kick", Reason: "password reset", Silent: true}}, *kicks)
+	assert.Equal(t, "password reset for alice\nrevoked 1 session(s)\ndisconnected 1 connection(s)\n", out.String())
+}
+
+// Недоступный сервер не мешает сбросу: сессии отозваны, выводится предупреждение
+func TestUsersResetPassword_ServerUnavailable(t *testing.T) {
+	c, mock, _, errOut := newCtl(t, &chatctl.AdminClient{BaseURL: "http://127.0.0.1:0", Token: "admin-secret"})
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET password_hash=$2`)).
+		WithArgs("alice", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM password_resets`)).
+		WithArgs("alice").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	expectRevoke(mock, "alice")
+
+	require.NoError(t, c.Run([]string{"users", "reset-password", "alice", "-password", "correct horse"}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Contains(t, errOut.String(), "could not disconnect alice")
+}
+
+// Несуществующему пользователю пароль не сбрасывается, сессии не трогаются
+func TestUsersResetPassword_UnknownUser(t *testing.T) {
+	server, kicks := adminServer(t, 0)
+	c, mock, _, _ := newCtl(t, server)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET password_hash=$2`)).
+		WithArgs("ghost", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	assert.ErrorIs(t, c.Run([]string{"users", "reset-password", "ghost", "-password", "correct horse"}), user.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Empty(t, *kicks)
+}
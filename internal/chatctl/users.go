@@ -0,0 +1,261 @@
+package chatctl
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/chat"
+	"github.com/go-portfolio/websocket-chat/internal/notify"
+	"github.com/go-portfolio/websocket-chat/internal/session"
+	"github.com/go-portfolio/websocket-chat/internal/settings"
+)
+
+func (c *Ctl) usersCommand(cmd string, args []string) error {
+	fs := flag.NewFlagSet("users "+cmd, flag.ContinueOnError)
+	password := fs.String("password", "", "пароль (по умолчанию генерируется и выводится)")
+	admin := fs.Bool("admin", false, "сразу выдать права администратора")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := c.db(); err != nil {
+		return err
+	}
+
+	switch {
+	case cmd == "list" && len(args) == 0:
+		return c.listUsers()
+
+	case cmd == "create" && len(args) == 1:
+		pw := passwordOrRandom(*password)
+		if err := c.Users.Register(args[0], pw, ""); err != nil {
+			return err
+		}
+		if *admin {
+			if err := c.Users.SetAdmin(args[0], true); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(c.out(), "created %s\n", args[0])
+		c.printGenerated(*password, pw)
+		return nil
+
+	case cmd == "delete" && len(args) == 1:
+		if err := c.Users.Delete(args[0]); err != nil {
+			return err
+		}
+		// Открытые подключения удалённого пользователя закрываются, если сервер доступен
+		c.tryKick(args[0], "account deleted")
+		fmt.Fprintf(c.out(), "deleted %s\n", args[0])
+		return nil
+
+	case cmd == "reset-password" && len(args) == 1:
+		pw := passwordOrRandom(*password)
+		if err := c.Users.SetPassword(args[0], pw); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out(), "password reset for %s\n", args[0])
+		c.printGenerated(*password, pw)
+		// Старый пароль мог утечь: входы по нему завершаются, а ссылки сброса
+		// SetPassword удалил вместе со сменой пароля
+		ids, err := session.NewStore(c.Users.Db).RevokeAll(args[0], "")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out(), "revoked %d session(s)\n", len(ids))
+		c.tryKick(args[0], "password reset")
+		return nil
+
+	case cmd == "admin" && len(args) == 2 && (args[1] == "on" || args[1] == "off"):
+		if err := c.Users.SetAdmin(args[0], args[1] == "on"); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out(), "admin %s for %s\n", args[1], args[0])
+		return nil
+
+	case cmd == "reset-2fa" && len(args) == 1:
+		if err := c.Users.DisableTOTP(args[0]); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out(), "two-factor authentication reset for %s\n", args[0])
+		return nil
+	}
+	return ErrUsage
+}
+
+// require2FA включает или выключает обязательную 2FA для всех пользователей
+func (c *Ctl) require2FA(args []string) error {
+	if len(args) != 1 || (args[0] != "on" && args[0] != "off") {
+		return ErrUsage
+	}
+	if err := c.db(); err != nil {
+		return err
+	}
+	if err := settings.SetBool(settings.NewStore(c.Users.Db), settings.RequireTwoFactor, args[0] == "on"); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out(), "required two-factor authentication %s\n", args[0])
+	return nil
+}
+
+func (c *Ctl) listUsers() error {
+	users, err := c.Users.List()
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(c.out(), 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "USERNAME\tADMIN\tBANNED\tEMAIL\tLAST SEEN\tCREATED")
+	for _, u := range users {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			u.Username, yesNo(u.Admin), yesNo(u.BannedAt != nil), dash(u.Email),
+			formatTime(u.LastSeenAt), u.CreatedAt.Format(time.DateOnly))
+	}
+	return tw.Flush()
+}
+
+func (c *Ctl) listRooms() error {
+	if err := c.db(); err != nil {
+		return err
+	}
+	rooms, err := c.Rooms.List()
+	if err != nil {
+		return err
+	}
+
+	// Онлайн знает только сервер; без него выводятся только данные из БД
+	online, err := c.Server.OnlineCounts()
+	if err != nil {
+		fmt.Fprintf(c.errOut(), "chatctl: online counts unavailable: %v\n", err)
+	}
+
+	tw := tabwriter.NewWriter(c.out(), 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ROOM\tMEMBERS\tONLINE\tCREATED")
+	for _, r := range rooms {
+		count := "-"
+		if online != nil {
+			count = fmt.Sprint(online[r.Name])
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", r.Name, r.Members, count, r.CreatedAt.Format(time.DateOnly))
+	}
+	return tw.Flush()
+}
+
+func (c *Ctl) kick(args []string) error {
+	fs := flag.NewFlagSet("kick", flag.ContinueOnError)
+	reason := fs.String("reason", "", "причина, которую увидит пользователь")
+	args, err := parseFlags(fs, args)
+	if err != nil || len(args) != 1 {
+		return ErrUsage
+	}
+	n, err := c.Server.Kick(args[0], *reason, false)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out(), "disconnected %d connection(s) of %s\n", n, args[0])
+	return nil
+}
+
+// ban блокирует пользователя в БД и отключает его от сервера; unban снимает блокировку
+func (c *Ctl) ban(args []string, banned bool) error {
+	fs := flag.NewFlagSet("ban", flag.ContinueOnError)
+	reason := fs.String("reason", "banned", "причина, которую увидит пользователь")
+	args, err := parseFlags(fs, args)
+	if err != nil || len(args) != 1 {
+		return ErrUsage
+	}
+	if err := c.db(); err != nil {
+		return err
+	}
+	if err := c.Users.SetBanned(args[0], banned); err != nil {
+		return err
+	}
+	if !banned {
+		fmt.Fprintf(c.out(), "unbanned %s\n", args[0])
+		return nil
+	}
+	fmt.Fprintf(c.out(), "banned %s\n", args[0])
+	// Уведомление сохраняется сразу в базу: сервер может быть недоступен,
+	// а увидит его пользователь после разблокировки
+	err = notify.NewStore(c.Users.Db).Add(args[0], &chat.Notification{
+		Kind: notify.KindModeration,
+		Text: "Администратор заблокировал ваш аккаунт: " + *reason,
+	})
+	if err != nil {
+		return err
+	}
+	// Выданные токены перестают действовать, обновить их тоже нельзя
+	ids, err := session.NewStore(c.Users.Db).RevokeAll(args[0], "")
+	if err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		fmt.Fprintf(c.out(), "revoked %d session(s)\n", len(ids))
+	}
+	c.tryKick(args[0], *reason)
+	return nil
+}
+
+func (c *Ctl) announce(args []string) error {
+	if len(args) != 1 {
+		return ErrUsage
+	}
+	n, err := c.Server.Announce(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out(), "announcement sent to %d room(s)\n", n)
+	return nil
+}
+
+// tryKick отключает пользователя, если сервер доступен; иначе только предупреждает.
+// Уведомление об отключении не создаётся: о причине сообщает вызывающий
+func (c *Ctl) tryKick(username, reason string) {
+	n, err := c.Server.Kick(username, reason, true)
+	if err != nil {
+		fmt.Fprintf(c.errOut(), "chatctl: could not disconnect %s: %v\n", username, err)
+		return
+	}
+	if n > 0 {
+		fmt.Fprintf(c.out(), "disconnected %d connection(s)\n", n)
+	}
+}
+
+func passwordOrRandom(pw string) string {
+	if pw != "" {
+		return pw
+	}
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (c *Ctl) printGenerated(given, pw string) {
+	if given == "" {
+		fmt.Fprintf(c.out(), "password: %s\n", pw)
+	}
+}
+
+func yesNo(v bool) string {
+	if v {
+		return "yes"
+	}
+	return "no"
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format(time.DateTime)
+}
@@ -0,0 +1,59 @@
+// Package imageutil декодирует загруженные изображения и строит их уменьшенные копии
+// для аватаров и миниатюр вложений
+package imageutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+
+	// Декодеры поддерживаемых форматов регистрируются в image.Decode
+	_ "image/gif"
+	_ "image/jpeg"
+
+	_ "golang.org/x/image/webp"
+
+	"golang.org/x/image/draw"
+)
+
+var (
+	// ErrNotImage — данные не удалось декодировать как изображение
+	ErrNotImage = errors.New("file is not a supported image")
+	// ErrTooManyPixels — изображение слишком большое для обработки
+	ErrTooManyPixels = errors.New("image dimensions too large")
+)
+
+// MaxPixels — предел площади исходного изображения (защита от «бомб» распаковки)
+const MaxPixels = 40_000_000
+
+// Decode декодирует изображение; размеры сначала проверяются по заголовку,
+// чтобы не распаковывать в память огромные картинки
+func Decode(data []byte) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, ErrNotImage
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrTooManyPixels
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrNotImage
+	}
+	return src, nil
+}
+
+// ScalePNG масштабирует область area изображения src до w×h и кодирует результат
+// в PNG, поэтому EXIF и прочие метаданные исходного файла не сохраняются
+func ScalePNG(src image.Image, area image.Rectangle, w, h int) ([]byte, error) {
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, area, draw.Src, nil)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, fmt.Errorf("failed to encode png: %w", err)
+	}
+	return buf.Bytes(), nil
+}
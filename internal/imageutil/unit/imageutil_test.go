@@ -0,0 +1,50 @@
+package imageutil_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/go-portfolio/websocket-chat/internal/imageutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 30, 20))))
+
+	img, err := imageutil.Decode(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 30, 20), img.Bounds())
+
+	_, err = imageutil.Decode([]byte("not an image"))
+	assert.ErrorIs(t, err, imageutil.ErrNotImage)
+}
+
+// Размеры проверяются по заголовку: огромная картинка не распаковывается
+func TestDecode_TooManyPixels(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))))
+	data := buf.Bytes()
+	// Ширина и высота в IHDR: 10000×10000, контрольная сумма чанка пересчитывается
+	copy(data[16:24], []byte{0, 0, 0x27, 0x10, 0, 0, 0x27, 0x10})
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+
+	_, err := imageutil.Decode(data)
+	assert.ErrorIs(t, err, imageutil.ErrTooManyPixels)
+}
+
+func TestScalePNG(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	data, err := imageutil.ScalePNG(src, image.Rect(100, 0, 300, 200), 64, 64)
+	require.NoError(t, err)
+
+	cfg, err := png.DecodeConfig(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 64, cfg.Width)
+	assert.Equal(t, 64, cfg.Height)
+}
@@ -0,0 +1,106 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// ErrInvalidHeader — в адресе или теме есть перевод строки (попытка внедрить заголовок)
+var ErrInvalidHeader = errors.New("invalid mail header")
+
+// Message — письмо в виде простого текста
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer отправляет письма
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// SMTPMailer отправляет письма через SMTP-сервер.
+// STARTTLS используется автоматически, если сервер его поддерживает.
+type SMTPMailer struct {
+	Addr     string // host:port
+	From     string
+	Username string // пусто — без авторизации
+	Password string
+}
+
+var _ Mailer = (*SMTPMailer)(nil)
+
+func (m *SMTPMailer) Send(msg Message) error {
+	data, err := Build(m.From, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, err := net.SplitHostPort(m.Addr)
+		if err != nil {
+			return fmt.Errorf("invalid smtp address: %w", err)
+		}
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+
+	if err := smtp.SendMail(m.Addr, auth, m.From, []string{msg.To}, data); err != nil {
+		return fmt.Errorf("smtp send: %w", err)
+	}
+	return nil
+}
+
+// LogMailer не отправляет письма, а пишет их в лог (для разработки)
+type LogMailer struct {
+	Logger *log.Logger // nil — стандартный логгер
+}
+
+var _ Mailer = (*LogMailer)(nil)
+
+func (m *LogMailer) Send(msg Message) error {
+	logf := log.Printf
+	if m.Logger != nil {
+		logf = m.Logger.Printf
+	}
+	logf("mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// Build формирует письмо в формате RFC 5322: тема кодируется по RFC 2047,
+// тело — quoted-printable в UTF-8
+func Build(from string, msg Message, date time.Time) ([]byte, error) {
+	for _, v := range []string{from, msg.To, msg.Subject} {
+		if strings.ContainsAny(v, "\r\n") {
+			return nil, ErrInvalidHeader
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	qp := quotedprintable.NewWriter(&buf)
+	body := strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n")
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
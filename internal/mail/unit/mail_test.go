@@ -0,0 +1,162 @@
+package mail_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"io"
+	"log"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	stdmail "net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/mail"
+	"github.com/stretchr/testify/assert"
+)
+
+// received — письмо, принятое фейковым SMTP-сервером
+type received struct {
+	From, To, Auth string
+	Data           string
+}
+
+// fakeSMTP — минимальный SMTP-сервер: принимает одно письмо на соединение.
+// С withAuth объявляет AUTH PLAIN и запоминает переданные учётные данные.
+func fakeSMTP(t *testing.T, withAuth bool) (string, <-chan received) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	out := make(chan received, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r, w := bufio.NewReader(conn), conn
+		reply := func(s string) { _, _ = io.WriteString(w, s+"\r\n") }
+
+		var msg received
+		reply("220 fake ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			cmd := strings.ToUpper(line)
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"):
+				if withAuth {
+					reply("250-fake")
+					reply("250 AUTH PLAIN")
+				} else {
+					reply("250 fake")
+				}
+			case strings.HasPrefix(cmd, "AUTH PLAIN"):
+				creds, _ := base64.StdEncoding.DecodeString(strings.TrimSpace(line[len("AUTH PLAIN"):]))
+				msg.Auth = string(creds)
+				reply("235 ok")
+			case strings.HasPrefix(cmd, "MAIL FROM:"):
+				msg.From = strings.Trim(line[len("MAIL FROM:"):], "<> ")
+				reply("250 ok")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				msg.To = strings.Trim(line[len("RCPT TO:"):], "<> ")
+				reply("250 ok")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				msg.Data = data.String()
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				out <- msg
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().String(), out
+}
+
+func wait(t *testing.T, ch <-chan received) received {
+	select {
+	case m := <-ch:
+		return m
+	case <-time.After(2 * time.Second):
+		t.Fatal("письмо не дошло до SMTP-сервера")
+		return received{}
+	}
+}
+
+// decodeBody разбирает письмо и возвращает тему и тело в UTF-8
+func decodeBody(t *testing.T, data string) (string, string) {
+	m, err := stdmail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+	body, _ := io.ReadAll(quotedprintable.NewReader(m.Body))
+	return subject, string(body)
+}
+
+func TestSMTPMailer_Send(t *testing.T) {
+	addr, ch := fakeSMTP(t, false)
+	m := &mail.SMTPMailer{Addr: addr, From: "chat@example.com"}
+
+	err := m.Send(mail.Message{To: "bob@example.com", Subject: "Непрочитанное: 2", Body: "Привет, bob!\nУ вас 2 упоминания."})
+	assert.NoError(t, err)
+
+	got := wait(t, ch)
+	assert.Equal(t, "chat@example.com", got.From)
+	assert.Equal(t, "bob@example.com", got.To)
+	assert.Empty(t, got.Auth)
+
+	subject, body := decodeBody(t, got.Data)
+	assert.Equal(t, "Непрочитанное: 2", subject)
+	assert.Equal(t, "Привет, bob!\r\nУ вас 2 упоминания.", strings.TrimRight(body, "\r\n"))
+}
+
+func TestSMTPMailer_Auth(t *testing.T) {
+	addr, ch := fakeSMTP(t, true)
+	m := &mail.SMTPMailer{Addr: addr, From: "chat@example.com", Username: "chat", Password: "s3cret"}
+
+	assert.NoError(t, m.Send(mail.Message{To: "bob@example.com", Subject: "hi", Body: "hi"}))
+	assert.Equal(t, "\x00chat\x00s3cret", wait(t, ch).Auth)
+}
+
+// Перевод строки в теме или адресе — попытка внедрить заголовок
+func TestBuild_RejectsHeaderInjection(t *testing.T) {
+	_, err := mail.Build("chat@example.com", mail.Message{To: "bob@example.com", Subject: "hi\r\nBcc: eve@example.com"}, time.Now())
+	assert.ErrorIs(t, err, mail.ErrInvalidHeader)
+
+	_, err = mail.Build("chat@example.com", mail.Message{To: "bob@example.com\nBcc: eve@example.com"}, time.Now())
+	assert.ErrorIs(t, err, mail.ErrInvalidHeader)
+}
+
+func TestLogMailer(t *testing.T) {
+	var buf bytes.Buffer
+	m := &mail.LogMailer{Logger: log.New(&buf, "", 0)}
+	assert.NoError(t, m.Send(mail.Message{To: "bob@example.com", Subject: "Тема", Body: "Текст"}))
+	assert.Contains(t, buf.String(), "bob@example.com")
+	assert.Contains(t, buf.String(), "Тема")
+	assert.Contains(t, buf.String(), "Текст")
+}
@@ -0,0 +1,271 @@
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-portfolio/websocket-chat/internal/chat"
+)
+
+// Поддерживается безопасное подмножество Markdown:
+//
+//	**жирный**, *курсив* или _курсив_, `код`, ```блок кода```,
+//	[текст](https://ссылка), автоссылки http(s):// и цитаты "> ".
+//
+// HTML строится только из фиксированного набора тегов, весь текст экранируется,
+// а ссылки допускаются лишь со схемами http, https и mailto. Сырой HTML из
+// сообщения никогда не попадает в результат, поэтому санитайзер не нужен.
+
+// maxQuoteDepth ограничивает вложенность цитат
+const maxQuoteDepth = 4
+
+// Render превращает текст сообщения в безопасный HTML
+func Render(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(text, "\n"), 0)
+	return b.String()
+}
+
+// Processor заполняет поле HTML у пользовательских сообщений перед рассылкой
+type Processor struct{}
+
+var _ chat.MessageProcessor = (*Processor)(nil)
+
+func (Processor) ProcessMessage(msg *chat.ChatMessage) error {
+	// HTML всегда строится сервером — значение от клиента не принимается
+	msg.HTML = ""
+	switch msg.Type {
+	case "", "message", "private", "attachment":
+	default:
+		return nil
+	}
+	if msg.Text != "" {
+		msg.HTML = Render(msg.Text)
+	}
+	return nil
+}
+
+// langRe — допустимое имя языка у блока кода (идёт в атрибут class)
+var langRe = regexp.MustCompile(`^[A-Za-z0-9_+#-]{1,20}$`)
+
+// renderBlocks разбирает строки на блоки: код, цитаты и абзацы
+func renderBlocks(b *strings.Builder, lines []string, depth int) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			i++
+
+		case strings.HasPrefix(trimmed, "```"):
+			lang := strings.TrimSpace(strings.TrimPrefix(trimmed, "```"))
+			var code []string
+			for i++; i < len(lines) && strings.TrimSpace(lines[i]) != "```"; i++ {
+				code = append(code, lines[i])
+			}
+			i++ // закрывающий ``` (или конец текста)
+
+			b.WriteString("<pre><code")
+			if langRe.MatchString(lang) {
+				b.WriteString(` class="language-` + lang + `"`)
+			}
+			b.WriteString(">")
+			b.WriteString(html.EscapeString(strings.Join(code, "\n")))
+			b.WriteString("</code></pre>")
+
+		case strings.HasPrefix(trimmed, ">") && depth < maxQuoteDepth:
+			var quoted []string
+			for ; i < len(lines); i++ {
+				t := strings.TrimSpace(lines[i])
+				if !strings.HasPrefix(t, ">") {
+					break
+				}
+				t = strings.TrimPrefix(t, ">")
+				quoted = append(quoted, strings.TrimPrefix(t, " "))
+			}
+			b.WriteString("<blockquote>")
+			renderBlocks(b, quoted, depth+1)
+			b.WriteString("</blockquote>")
+
+		default:
+			b.WriteString("<p>")
+			for first := true; i < len(lines); i++ {
+				t := strings.TrimSpace(lines[i])
+				if t == "" || strings.HasPrefix(t, "```") || (strings.HasPrefix(t, ">") && depth < maxQuoteDepth) {
+					break
+				}
+				if !first {
+					b.WriteString("<br>")
+				}
+				first = false
+				renderInline(b, t, true)
+			}
+			b.WriteString("</p>")
+		}
+	}
+}
+
+// autolinkRe находит голые ссылки (как в unfurl.ExtractURLs)
+var autolinkRe = regexp.MustCompile(`^https?://[^\s<>"'` + "`" + `]+`)
+
+// renderInline обрабатывает строчную разметку; links=false внутри текста ссылки
+func renderInline(b *strings.Builder, s string, links bool) {
+	for i := 0; i < len(s); {
+		c := s[i]
+		rest := s[i:]
+
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte("\\`*_[]()>#", s[i+1]) >= 0:
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '`':
+			if end := strings.IndexByte(s[i+1:], '`'); end > 0 {
+				b.WriteString("<code>")
+				b.WriteString(html.EscapeString(s[i+1 : i+1+end]))
+				b.WriteString("</code>")
+				i += end + 2
+				continue
+			}
+
+		case strings.HasPrefix(rest, "**"):
+			if end := closing(s, i+2, "**"); end > 0 {
+				b.WriteString("<strong>")
+				renderInline(b, s[i+2:end], links)
+				b.WriteString("</strong>")
+				i = end + 2
+				continue
+			}
+
+		case c == '*' || (c == '_' && !wordBefore(s, i)):
+			if end := closing(s, i+1, string(c)); end > 0 && (c == '*' || !wordAfter(s, end+1)) {
+				b.WriteString("<em>")
+				renderInline(b, s[i+1:end], links)
+				b.WriteString("</em>")
+				i = end + 1
+				continue
+			}
+
+		case c == '[' && links:
+			if label, target, n := parseLink(rest); n > 0 {
+				if href, ok := safeURL(target); ok {
+					writeLink(b, href, func() { renderInline(b, label, false) })
+					i += n
+					continue
+				}
+			}
+
+		case c == 'h' && links && !wordBefore(s, i):
+			if m := autolinkRe.FindString(rest); m != "" {
+				m = strings.TrimRight(m, ".,;:!?)]}")
+				if href, ok := safeURL(m); ok {
+					writeLink(b, href, func() { b.WriteString(html.EscapeString(m)) })
+					i += len(m)
+					continue
+				}
+			}
+		}
+
+		// Обычный символ: копируем руну целиком, экранируя спецсимволы HTML
+		_, size := utf8.DecodeRuneInString(rest)
+		b.WriteString(html.EscapeString(s[i : i+size]))
+		i += size
+	}
+}
+
+// closing ищет закрывающий разделитель delim начиная с from;
+// выделение не может быть пустым и не может начинаться или заканчиваться пробелом
+func closing(s string, from int, delim string) int {
+	if from >= len(s) || s[from] == ' ' {
+		return -1
+	}
+	for j := from + 1; j+len(delim) <= len(s); j++ {
+		if s[j] == '\\' {
+			j++
+			continue
+		}
+		if s[j] == '`' {
+			// код внутри выделения не разрывается
+			if end := strings.IndexByte(s[j+1:], '`'); end >= 0 {
+				j += end + 1
+				continue
+			}
+		}
+		if strings.HasPrefix(s[j:], delim) && s[j-1] != ' ' {
+			// "**" не должен закрывать одиночную "*"
+			if delim == "*" && strings.HasPrefix(s[j:], "**") {
+				j++
+				continue
+			}
+			return j
+		}
+	}
+	return -1
+}
+
+// parseLink разбирает [label](target) в начале s; n — длина конструкции
+func parseLink(s string) (label, target string, n int) {
+	end := strings.Index(s, "](")
+	if end <= 1 {
+		return "", "", 0
+	}
+	paren := strings.IndexByte(s[end+2:], ')')
+	if paren <= 0 {
+		return "", "", 0
+	}
+	return s[1:end], strings.TrimSpace(s[end+2 : end+2+paren]), end + 3 + paren
+}
+
+// safeURL пропускает только абсолютные http(s)- и mailto-ссылки
+func safeURL(raw string) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		if u.Host == "" {
+			return "", false
+		}
+	case "mailto":
+		if u.Opaque == "" {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+	return u.String(), true
+}
+
+func writeLink(b *strings.Builder, href string, label func()) {
+	b.WriteString(`<a href="`)
+	b.WriteString(html.EscapeString(href))
+	b.WriteString(`" rel="nofollow noopener noreferrer" target="_blank">`)
+	label()
+	b.WriteString("</a>")
+}
+
+// wordBefore сообщает, стоит ли перед позицией i буква или цифра (snake_case не выделяется)
+func wordBefore(s string, i int) bool {
+	if i == 0 {
+		return false
+	}
+	r, _ := utf8.DecodeLastRuneInString(s[:i])
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// wordAfter сообщает, стоит ли на позиции i буква или цифра
+func wordAfter(s string, i int) bool {
+	if i >= len(s) {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(s[i:])
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
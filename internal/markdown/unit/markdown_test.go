@@ -0,0 +1,110 @@
+package markdown_test
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-portfolio/websocket-chat/internal/chat"
+	"github.com/go-portfolio/websocket-chat/internal/markdown"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/html"
+)
+
+func TestRender_Subset(t *testing.T) {
+	cases := map[string]string{
+		"hello":                   "<p>hello</p>",
+		"**bold** and *it*":       "<p><strong>bold</strong> and <em>it</em></p>",
+		"_it_ but snake_case_var": "<p><em>it</em> but snake_case_var</p>",
+		"**a *b* c**":             "<p><strong>a <em>b</em> c</strong></p>",
+		"use `a < b` here":        "<p>use <code>a &lt; b</code> here</p>",
+		"2 * 3 * 4":               "<p>2 * 3 * 4</p>",
+		`\*not em\*`:              "<p>*not em*</p>",
+		"line1\nline2":            "<p>line1<br>line2</p>",
+		"p1\n\np2":                "<p>p1</p><p>p2</p>",
+		"> quote\n> more\ntext":   "<blockquote><p>quote<br>more</p></blockquote><p>text</p>",
+		"```go\nif a < b {}\n```": `<pre><code class="language-go">if a &lt; b {}</code></pre>`,
+		"```\n**raw**":            "<pre><code>**raw**</code></pre>",
+		"[Go](https://go.dev)":    `<p><a href="https://go.dev" rel="nofollow noopener noreferrer" target="_blank">Go</a></p>`,
+		"see https://go.dev/doc.": `<p>see <a href="https://go.dev/doc" rel="nofollow noopener noreferrer" target="_blank">https://go.dev/doc</a>.</p>`,
+		"привет **мир**":          "<p>привет <strong>мир</strong></p>",
+	}
+	for in, want := range cases {
+		assert.Equal(t, want, markdown.Render(in), in)
+	}
+}
+
+// allowedTags — теги и атрибуты, которые может породить Render
+var allowedTags = map[string][]string{
+	"p": nil, "br": nil, "strong": nil, "em": nil, "code": {"class"},
+	"pre": nil, "blockquote": nil, "a": {"href", "rel", "target"},
+}
+
+// assertSafe разбирает результат как HTML и проверяет, что в нём только разрешённые теги,
+// атрибуты и схемы ссылок
+func assertSafe(t *testing.T, in, out string) {
+	z := html.NewTokenizer(strings.NewReader(out))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		tok := z.Token()
+		attrs, ok := allowedTags[tok.Data]
+		if !assert.True(t, ok, "tag <%s> from %q", tok.Data, in) {
+			continue
+		}
+		for _, a := range tok.Attr {
+			assert.Contains(t, attrs, a.Key, "attribute %s on <%s> from %q", a.Key, tok.Data, in)
+			if a.Key == "href" {
+				u, err := url.Parse(a.Val)
+				assert.NoError(t, err)
+				assert.Contains(t, []string{"http", "https", "mailto"}, u.Scheme, in)
+			}
+			if a.Key == "class" {
+				assert.Regexp(t, `^language-[A-Za-z0-9_+#-]+$`, a.Val, in)
+			}
+		}
+	}
+}
+
+// Никакой пользовательский ввод не должен порождать активный HTML
+func TestRender_NoInjection(t *testing.T) {
+	inputs := []string{
+		`<script>alert(1)</script>`,
+		`<img src=x onerror=alert(1)>`,
+		`[click](javascript:alert(1))`,
+		`[click](JaVaScRiPt:alert(1))`,
+		`[click](data:text/html,<script>alert(1)</script>)`,
+		`[x](https://a.b/"onmouseover="alert(1))`,
+		"```\"><script>alert(1)</script>\n</code>\n```",
+		"```go onclick=alert(1)\ncode\n```",
+		`**<b onclick=alert(1)>x</b>**`,
+		`[**<svg onload=alert(1)>**](https://ok.example)`,
+		`https://x.example/<script>`,
+		`> <iframe src="https://evil.example">`,
+		"`<style>`*<a href=x>*",
+	}
+	for _, in := range inputs {
+		assertSafe(t, in, markdown.Render(in))
+	}
+}
+
+// Ссылка с недопустимой схемой остаётся текстом
+func TestRender_UnsafeLinkIsText(t *testing.T) {
+	assert.Equal(t, "<p>[click](javascript:alert(1))</p>", markdown.Render("[click](javascript:alert(1))"))
+}
+
+func TestProcessor(t *testing.T) {
+	msg := &chat.ChatMessage{Type: "message", Text: "**hi**", HTML: "<script>from client</script>"}
+	assert.NoError(t, markdown.Processor{}.ProcessMessage(msg))
+	assert.Equal(t, "<p><strong>hi</strong></p>", msg.HTML)
+
+	// Пустой текст (например, вложение без подписи) — без HTML
+	msg = &chat.ChatMessage{Type: "attachment", HTML: "<b>x</b>"}
+	assert.NoError(t, markdown.Processor{}.ProcessMessage(msg))
+	assert.Empty(t, msg.HTML)
+}
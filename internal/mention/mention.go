@@ -0,0 +1,264 @@
+package mention
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-portfolio/websocket-chat/internal/chat"
+	"github.com/go-portfolio/websocket-chat/internal/room"
+	"github.com/go-portfolio/websocket-chat/internal/user"
+)
+
+// Виды упоминаний
+const (
+	KindUser = "user"
+	KindHere = "here"
+	KindRoom = "room"
+)
+
+// maxUsernameLen совпадает с ограничением длины имени при регистрации
+const maxUsernameLen = 24
+
+// Parse находит в тексте кандидатов в упоминания: @username, @here и @room.
+// "@" внутри слова (как в e-mail) и внутри `кода` не считается упоминанием.
+func Parse(text string) []chat.Mention {
+	var (
+		mentions []chat.Mention
+		inCode   bool
+		prev     rune
+		pos      int // позиция в символах
+	)
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case r == '`':
+			inCode = !inCode
+		case r == '@' && !inCode && !isNameRune(prev) && prev != '@':
+			name := scanName(text[i+size:])
+			if name != "" {
+				m := chat.Mention{Kind: KindUser, Username: name, Offset: pos, Length: 1 + utf8.RuneCountInString(name)}
+				switch strings.ToLower(name) {
+				case "here":
+					m.Kind, m.Username = KindHere, ""
+				case "room":
+					m.Kind, m.Username = KindRoom, ""
+				}
+				mentions = append(mentions, m)
+				i += size + len(name)
+				pos += m.Length
+				prev = 'a'
+				continue
+			}
+		}
+		prev = r
+		i += size
+		pos++
+	}
+	return mentions
+}
+
+// scanName читает имя пользователя после "@"; завершающие точки и дефисы
+// считаются пунктуацией ("спроси @bob.")
+func scanName(s string) string {
+	end := 0
+	for end < len(s) {
+		r, size := utf8.DecodeRuneInString(s[end:])
+		if !isNameRune(r) && r != '.' && r != '-' {
+			break
+		}
+		end += size
+	}
+	name := strings.TrimRight(s[:end], ".-")
+	if utf8.RuneCountInString(name) > maxUsernameLen {
+		return ""
+	}
+	return name
+}
+
+func isNameRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Notifier находит упоминания в сообщениях, сохраняет их и уведомляет упомянутых.
+// До рассылки (ProcessMessage) он проверяет упоминания и записывает их в сообщение,
+// после рассылки (MessageSent) отправляет упомянутым событие "mention" — во все их
+// подключения, даже если они сейчас в другой комнате.
+type Notifier struct {
+	Hub   *chat.Hub
+	Users user.UserStore
+	// Rooms нужен для @room; без него @room работает как @here
+	Rooms room.RoomStore
+	// Store сохраняет упоминания для GET /api/mentions; nil — не сохранять
+	Store MentionStore
+	// Inbox получает уведомление "mention" для каждого упомянутого; nil — не создавать
+	Inbox Inbox
+	// MaxMentions ограничивает число упоминаний в одном сообщении
+	MaxMentions int
+}
+
+// Inbox — входящие уведомления пользователей (реализуется notify.Inbox)
+type Inbox interface {
+	Notify(username string, n chat.Notification) error
+}
+
+var (
+	_ chat.MessageProcessor = (*Notifier)(nil)
+	_ chat.MessageObserver  = (*Notifier)(nil)
+)
+
+// ProcessMessage оставляет в сообщении только упоминания существующих пользователей.
+// В личных сообщениях @here и @room не действуют.
+func (n *Notifier) ProcessMessage(msg *chat.ChatMessage) error {
+	msg.Mentions = nil
+	if !mentionable(msg) {
+		return nil
+	}
+
+	known := make(map[string]bool)
+	for _, m := range Parse(msg.Text) {
+		if n.MaxMentions > 0 && len(msg.Mentions) >= n.MaxMentions {
+			break
+		}
+		switch m.Kind {
+		case KindHere, KindRoom:
+			if msg.To != "" {
+				continue
+			}
+		case KindUser:
+			exists, checked := known[m.Username]
+			if !checked {
+				var err error
+				if exists, err = n.Users.Exists(m.Username); err != nil {
+					log.Printf("mention: %v", err)
+				}
+				known[m.Username] = exists
+			}
+			if !exists {
+				continue
+			}
+		}
+		msg.Mentions = append(msg.Mentions, m)
+	}
+	return nil
+}
+
+// MessageSent сохраняет упоминания и отправляет уведомления.
+// Упоминания в личных сообщениях не рассылаются: адресат и так получает сообщение.
+func (n *Notifier) MessageSent(msg chat.ChatMessage) {
+	if len(msg.Mentions) == 0 || msg.To != "" || !mentionable(&msg) {
+		return
+	}
+
+	targets := n.targets(msg)
+	if len(targets) == 0 {
+		return
+	}
+
+	if n.Store != nil {
+		records := make([]Record, 0, len(targets))
+		for _, username := range targets {
+			records = append(records, Record{
+				Username:  username,
+				MessageID: msg.ID,
+				Room:      msg.Room,
+				From:      msg.From,
+				Kind:      targetKind(msg.Mentions, username),
+				Text:      msg.Text,
+			})
+		}
+		if err := n.Store.Add(records); err != nil {
+			log.Printf("mention: failed to save mentions: %v", err)
+		}
+	}
+
+	for _, username := range targets {
+		n.Hub.SendToUser(username, chat.ChatMessage{
+			ID:        msg.ID,
+			Type:      "mention",
+			From:      msg.From,
+			Room:      msg.Room,
+			Text:      msg.Text,
+			HTML:      msg.HTML,
+			Timestamp: msg.Timestamp,
+			Mentions:  msg.Mentions,
+		})
+
+		if n.Inbox != nil {
+			err := n.Inbox.Notify(username, chat.Notification{
+				Kind:      "mention",
+				From:      msg.From,
+				Room:      msg.Room,
+				MessageID: msg.ID,
+				Text:      msg.Text,
+			})
+			if err != nil {
+				log.Printf("mention: %v", err)
+			}
+		}
+	}
+}
+
+// targets возвращает упомянутых пользователей без отправителя, по алфавиту
+func (n *Notifier) targets(msg chat.ChatMessage) []string {
+	set := make(map[string]bool)
+	for _, m := range msg.Mentions {
+		switch m.Kind {
+		case KindUser:
+			set[m.Username] = true
+		case KindHere:
+			for _, u := range n.Hub.GetRoom(msg.Room).OnlineUsers() {
+				set[u] = true
+			}
+		case KindRoom:
+			members, err := n.members(msg.Room)
+			if err != nil {
+				log.Printf("mention: %v", err)
+			}
+			for _, u := range members {
+				set[u] = true
+			}
+		}
+	}
+	delete(set, msg.From)
+
+	targets := make([]string, 0, len(set))
+	for u := range set {
+		targets = append(targets, u)
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// members возвращает участников комнаты для @room
+func (n *Notifier) members(roomName string) ([]string, error) {
+	if n.Rooms == nil {
+		return n.Hub.GetRoom(roomName).OnlineUsers(), nil
+	}
+	return n.Rooms.Members(roomName)
+}
+
+// targetKind определяет, как именно упомянут пользователь: лично или через @here/@room
+func targetKind(mentions []chat.Mention, username string) string {
+	kind := KindHere
+	for _, m := range mentions {
+		if m.Kind == KindUser && m.Username == username {
+			return KindUser
+		}
+		if m.Kind == KindRoom {
+			kind = KindRoom
+		}
+	}
+	return kind
+}
+
+// mentionable сообщает, может ли сообщение содержать упоминания
+func mentionable(msg *chat.ChatMessage) bool {
+	switch msg.Type {
+	case "", "message", "private", "attachment":
+		return msg.Text != ""
+	}
+	return false
+}
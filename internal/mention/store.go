@@ -0,0 +1,79 @@
+package mention
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Record — сохранённое упоминание пользователя
+type Record struct {
+	ID        int64     `json:"id"`
+	Username  string    `json:"-"`
+	MessageID string    `json:"message_id"`
+	Room      string    `json:"room"`
+	From      string    `json:"from"`
+	Kind      string    `json:"kind"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MentionStore хранит упоминания пользователей
+type MentionStore interface {
+	Add(records []Record) error
+	// List возвращает упоминания пользователя от новых к старым;
+	// before > 0 — только с ID меньше before (постраничная выдача)
+	List(username string, before int64, limit int) ([]Record, error)
+}
+
+type Store struct {
+	Db *sql.DB
+}
+
+var _ MentionStore = (*Store)(nil)
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{Db: db}
+}
+
+// Add сохраняет упоминания в одной транзакции; повторное упоминание
+// того же пользователя в том же сообщении игнорируется
+func (s *Store) Add(records []Record) error {
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, r := range records {
+		_, err := tx.Exec(
+			`INSERT INTO mentions (username, message_id, room, sender, kind, text)
+			 VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (username, message_id) DO NOTHING`,
+			r.Username, r.MessageID, r.Room, r.From, r.Kind, r.Text,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert mention: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *Store) List(username string, before int64, limit int) ([]Record, error) {
+	query := `SELECT id, username, message_id, room, sender, kind, text, created_at
+		FROM mentions WHERE username=$1 AND ($2 = 0 OR id < $2) ORDER BY id DESC LIMIT $3`
+	rows, err := s.Db.Query(query, username, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mentions: %w", err)
+	}
+	defer rows.Close()
+
+	records := []Record{}
+	for rows.Next() {
+		var r Record
+		if err := rows.Scan(&r.ID, &r.Username, &r.MessageID, &r.Room, &r.From, &r.Kind, &r.Text, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan mention: %w", err)
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
@@ -0,0 +1,241 @@
+package mention_test
+
+import (
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-portfolio/websocket-chat/internal/chat"
+	"github.com/go-portfolio/websocket-chat/internal/mention"
+	"github.com/stretchr/testify/assert"
+)
+
+// --- Parse ---
+
+func TestParse(t *testing.T) {
+	got := mention.Parse("привет @bob и @Алиса. @here, а почта bob@example.com и `@code` — нет")
+	assert.Equal(t, []chat.Mention{
+		{Kind: mention.KindUser, Username: "bob", Offset: 7, Length: 4},
+		{Kind: mention.KindUser, Username: "Алиса", Offset: 14, Length: 6},
+		{Kind: mention.KindHere, Offset: 22, Length: 5},
+	}, got)
+
+	assert.Equal(t, mention.KindRoom, mention.Parse("@room внимание")[0].Kind)
+	assert.Empty(t, mention.Parse("@ @@bob @this_name_is_far_too_long_for_a_user"))
+}
+
+// --- Notifier ---
+
+// fakeUsers реализует user.UserStore; важен только Exists
+type fakeUsers struct{ names map[string]bool }
+
+func (f *fakeUsers) Register(username, password, avatar string) error   { return nil }
+func (f *fakeUsers) Authenticate(username, password string) bool        { return false }
+func (f *fakeUsers) GetAvatar(username string) string                   { return "" }
+func (f *fakeUsers) SetAvatar(username, avatar string) error            { return nil }
+func (f *fakeUsers) Exists(username string) (bool, error)               { return f.names[username], nil }
+func (f *fakeUsers) Touch(username string) error                        { return nil }
+func (f *fakeUsers) GetEmail(username string) (string, bool, error)     { return "", false, nil }
+func (f *fakeUsers) SetEmail(username, email string, digest bool) error { return nil }
+func (f *fakeUsers) IsAdmin(username string) (bool, error)              { return false, nil }
+func (f *fakeUsers) IsBanned(username string) (bool, error)             { return false, nil }
+func (f *fakeUsers) CheckCredentials(username, password string) error   { return nil }
+func (f *fakeUsers) Close() error                                       { return nil }
+
+// fakeRooms реализует room.RoomStore
+type fakeRooms struct{ members map[string][]string }
+
+func (f *fakeRooms) Join(room, username string) error             { return nil }
+func (f *fakeRooms) IsMember(room, username string) (bool, error) { return false, nil }
+func (f *fakeRooms) Members(room string) ([]string, error)        { return f.members[room], nil }
+func (f *fakeRooms) Role(room, username string) (string, error)   { return "", nil }
+func (f *fakeRooms) SetRole(room, username, role string) error    { return nil }
+
+// memStore — MentionStore в памяти
+type memStore struct {
+	mu      sync.Mutex
+	records []mention.Record
+}
+
+func (m *memStore) Add(records []mention.Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = append(m.records, records...)
+	return nil
+}
+
+func (m *memStore) List(username string, before int64, limit int) ([]mention.Record, error) {
+	return nil, nil
+}
+
+// memInbox запоминает, кому отправлены уведомления
+type memInbox struct {
+	mu    sync.Mutex
+	notes map[string][]chat.Notification
+}
+
+func (m *memInbox) Notify(username string, n chat.Notification) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.notes[username] = append(m.notes[username], n)
+	return nil
+}
+
+// mockClient — клиент, складывающий полученные сообщения в канал
+type mockClient struct {
+	name, room string
+	ch         chan chat.ChatMessage
+}
+
+func newClient(name, room string) *mockClient {
+	return &mockClient{name: name, room: room, ch: make(chan chat.ChatMessage, 32)}
+}
+
+func (m *mockClient) GetUsername() string                         { return m.name }
+func (m *mockClient) GetRoomName() string                         { return m.room }
+func (m *mockClient) SendMessage(msg chat.ChatMessage) error      { m.ch <- msg; return nil }
+func (m *mockClient) ReceivePrivateChan() <-chan chat.ChatMessage { return m.ch }
+func (m *mockClient) Close() error                                { return nil }
+func (m *mockClient) PrivateChan() chan chat.ChatMessage          { return m.ch }
+
+// mentions возвращает события "mention", пришедшие клиенту за короткое время
+func (m *mockClient) mentions() []chat.ChatMessage {
+	var got []chat.ChatMessage
+	timeout := time.After(100 * time.Millisecond)
+	for {
+		select {
+		case msg := <-m.ch:
+			if msg.Type == "mention" {
+				got = append(got, msg)
+			}
+		case <-timeout:
+			return got
+		}
+	}
+}
+
+func setup(clients ...*mockClient) (*mention.Notifier, *memStore, *memInbox) {
+	hub := chat.NewHub()
+	for _, c := range clients {
+		hub.RegisterClient(c)
+	}
+	store := &memStore{}
+	inbox := &memInbox{notes: map[string][]chat.Notification{}}
+	n := &mention.Notifier{
+		Inbox: inbox,
+		Hub:   hub,
+		Users: &fakeUsers{names: map[string]bool{"alice": true, "bob": true, "carol": true, "dave": true}},
+		Rooms: &fakeRooms{members: map[string][]string{"tech": {"alice", "bob", "dave"}}},
+		Store: store,
+	}
+	return n, store, inbox
+}
+
+// send прогоняет сообщение через Notifier так же, как это делает клиент
+func send(n *mention.Notifier, msg chat.ChatMessage) chat.ChatMessage {
+	_ = n.ProcessMessage(&msg)
+	n.MessageSent(msg)
+	return msg
+}
+
+// Упоминания несуществующих пользователей отбрасываются
+func TestProcessMessage_ValidatesUsers(t *testing.T) {
+	n, _, _ := setup()
+	msg := chat.ChatMessage{Type: "message", From: "alice", Room: "tech", Text: "@bob @ghost @bob"}
+	assert.NoError(t, n.ProcessMessage(&msg))
+	assert.Len(t, msg.Mentions, 2)
+	for _, m := range msg.Mentions {
+		assert.Equal(t, "bob", m.Username)
+	}
+
+	// В личных сообщениях @here и @room не действуют
+	msg = chat.ChatMessage{Type: "private", From: "alice", To: "bob", Text: "@here @room"}
+	assert.NoError(t, n.ProcessMessage(&msg))
+	assert.Empty(t, msg.Mentions)
+}
+
+// Упомянутый получает событие mention, даже находясь в другой комнате
+func TestMessageSent_NotifiesAcrossRooms(t *testing.T) {
+	bob := newClient("bob", "random")
+	carol := newClient("carol", "tech")
+	n, store, inbox := setup(bob, carol)
+
+	send(n, chat.ChatMessage{ID: "m1", Type: "message", From: "alice", Room: "tech", Text: "@bob глянь"})
+
+	got := bob.mentions()
+	if assert.Len(t, got, 1) {
+		assert.Equal(t, "m1", got[0].ID)
+		assert.Equal(t, "tech", got[0].Room)
+		assert.Equal(t, "alice", got[0].From)
+	}
+	assert.Empty(t, carol.mentions())
+
+	if assert.Len(t, store.records, 1) {
+		assert.Equal(t, mention.Record{Username: "bob", MessageID: "m1", Room: "tech", From: "alice", Kind: mention.KindUser, Text: "@bob глянь"}, store.records[0])
+	}
+	// Упоминание попадает и во входящие
+	if assert.Len(t, inbox.notes["bob"], 1) {
+		assert.Equal(t, "mention", inbox.notes["bob"][0].Kind)
+		assert.Equal(t, "tech", inbox.notes["bob"][0].Room)
+	}
+}
+
+// @here — все, кто сейчас в комнате, @room — все участники; отправитель не уведомляется
+func TestMessageSent_HereAndRoom(t *testing.T) {
+	alice := newClient("alice", "tech")
+	bob := newClient("bob", "tech")
+	carol := newClient("carol", "random")
+	n, store, _ := setup(alice, bob, carol)
+
+	send(n, chat.ChatMessage{ID: "m1", Type: "message", From: "alice", Room: "tech", Text: "@here релиз"})
+	assert.Len(t, bob.mentions(), 1)
+	assert.Empty(t, alice.mentions())
+	assert.Empty(t, carol.mentions())
+
+	send(n, chat.ChatMessage{ID: "m2", Type: "message", From: "alice", Room: "tech", Text: "@room релиз"})
+	var users []string
+	for _, r := range store.records[1:] {
+		users = append(users, r.Username)
+		assert.Equal(t, mention.KindRoom, r.Kind)
+	}
+	assert.Equal(t, []string{"bob", "dave"}, users)
+}
+
+// --- Store ---
+
+func TestStore_Add(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := mention.NewStore(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO mentions`).
+		WithArgs("bob", "m1", "tech", "alice", "user", "@bob").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err := store.Add([]mention.Record{{Username: "bob", MessageID: "m1", Room: "tech", From: "alice", Kind: "user", Text: "@bob"}})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStore_List(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := mention.NewStore(db)
+
+	now := time.Now()
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM mentions WHERE username=$1 AND ($2 = 0 OR id < $2) ORDER BY id DESC LIMIT $3`)).
+		WithArgs("bob", int64(10), 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "message_id", "room", "sender", "kind", "text", "created_at"}).
+			AddRow(9, "bob", "m9", "tech", "alice", "user", "@bob", now).
+			AddRow(4, "bob", "m4", "tech", "carol", "room", "@room", now))
+
+	records, err := store.List("bob", 10, 2)
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, int64(9), records[0].ID)
+	assert.Equal(t, "carol", records[1].From)
+}
@@ -0,0 +1,186 @@
+package message
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/attachment"
+)
+
+// Форматы выгрузки истории
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+	FormatHTML = "html"
+)
+
+// ExportItem — сообщение в выгрузке вместе со ссылкой на вложение
+type ExportItem struct {
+	ID             string    `json:"id"`
+	From           string    `json:"from"`
+	Type           string    `json:"type"`
+	Text           string    `json:"text"`
+	CreatedAt      time.Time `json:"created_at"`
+	AttachmentName string    `json:"attachment_name,omitempty"`
+	AttachmentURL  string    `json:"attachment_url,omitempty"`
+}
+
+// Export передаёт в fn сообщения комнаты от старых к новым, не загружая историю
+// в память целиком. Нулевые from и to — без ограничения; to не включается.
+func (s *Store) Export(room string, from, to time.Time, fn func(ExportItem) error) error {
+	var fromArg, toArg sql.NullTime
+	if !from.IsZero() {
+		fromArg = sql.NullTime{Time: from, Valid: true}
+	}
+	if !to.IsZero() {
+		toArg = sql.NullTime{Time: to, Valid: true}
+	}
+
+	rows, err := s.Db.Query(`SELECT m.id, m.sender, m.type, m.text, m.created_at,
+			COALESCE(m.attachment_id, ''), COALESCE(a.name, '')
+		FROM messages m LEFT JOIN attachments a ON a.id = m.attachment_id
+		WHERE m.room=$1
+		  AND ($2::timestamptz IS NULL OR m.created_at >= $2)
+		  AND ($3::timestamptz IS NULL OR m.created_at < $3)
+		ORDER BY m.created_at, m.id`, room, fromArg, toArg)
+	if err != nil {
+		return fmt.Errorf("failed to export messages: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			item         ExportItem
+			attachmentID string
+		)
+		err := rows.Scan(&item.ID, &item.From, &item.Type, &item.Text, &item.CreatedAt, &attachmentID, &item.AttachmentName)
+		if err != nil {
+			return fmt.Errorf("failed to scan message: %w", err)
+		}
+		if attachmentID != "" {
+			item.AttachmentURL = attachment.URL(attachmentID)
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ExportWriter записывает выгрузку в одном из форматов
+type ExportWriter interface {
+	Write(item ExportItem) error
+	// Close дописывает окончание документа
+	Close() error
+}
+
+// NewExportWriter создаёт запись выгрузки комнаты room в формате format
+func NewExportWriter(format string, w io.Writer, room string) (ExportWriter, error) {
+	switch format {
+	case FormatJSON:
+		return newJSONWriter(w, room)
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatHTML:
+		return newHTMLWriter(w, room)
+	}
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
+
+// jsonWriter пишет {"room": ..., "messages": [...]} по одному сообщению
+type jsonWriter struct {
+	w     io.Writer
+	enc   *json.Encoder
+	count int
+}
+
+func newJSONWriter(w io.Writer, room string) (*jsonWriter, error) {
+	name, _ := json.Marshal(room)
+	if _, err := fmt.Fprintf(w, "{\"room\":%s,\"messages\":[\n", name); err != nil {
+		return nil, err
+	}
+	return &jsonWriter{w: w, enc: json.NewEncoder(w)}, nil
+}
+
+func (j *jsonWriter) Write(item ExportItem) error {
+	if j.count > 0 {
+		if _, err := io.WriteString(j.w, ","); err != nil {
+			return err
+		}
+	}
+	j.count++
+	return j.enc.Encode(item)
+}
+
+func (j *jsonWriter) Close() error {
+	_, err := io.WriteString(j.w, "]}\n")
+	return err
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	c := &csvWriter{w: csv.NewWriter(w)}
+	err := c.w.Write([]string{"id", "created_at", "from", "type", "text", "attachment_name", "attachment_url"})
+	return c, err
+}
+
+func (c *csvWriter) Write(item ExportItem) error {
+	return c.w.Write([]string{
+		item.ID, item.CreatedAt.UTC().Format(time.RFC3339), csvCell(item.From), item.Type,
+		csvCell(item.Text), csvCell(item.AttachmentName), item.AttachmentURL,
+	})
+}
+
+// csvCell защищает от подстановки формул: Excel и LibreOffice выполняют ячейку,
+// начинающуюся с =, +, -, @ (а также табуляции или перевода строки перед ними),
+// поэтому такой текст выводится с апострофом в начале
+func csvCell(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+var (
+	htmlHead = template.Must(template.New("head").Parse(`<!DOCTYPE html>
+<html lang="ru"><head><meta charset="utf-8"><title>{{.}}</title>
+<style>body{font-family:sans-serif;max-width:50em;margin:auto}.msg{margin:.5em 0}.meta{color:#666;font-size:.85em}.text{white-space:pre-wrap}</style>
+</head><body><h1>{{.}}</h1>
+`))
+	htmlItem = template.Must(template.New("item").Parse(`<div class="msg" id="m-{{.ID}}"><div class="meta"><b>{{.From}}</b> <time datetime="{{.CreatedAt.UTC.Format "2006-01-02T15:04:05Z07:00"}}">{{.CreatedAt.UTC.Format "2006-01-02 15:04:05"}} UTC</time></div>
+{{- if .Text}}<div class="text">{{.Text}}</div>{{end}}
+{{- if .AttachmentURL}}<div class="attachment">📎 <a href="{{.AttachmentURL}}">{{or .AttachmentName "вложение"}}</a></div>{{end}}</div>
+`))
+)
+
+// htmlWriter пишет самодостаточную страницу; весь текст экранируется шаблоном
+type htmlWriter struct {
+	w io.Writer
+}
+
+func newHTMLWriter(w io.Writer, room string) (*htmlWriter, error) {
+	return &htmlWriter{w: w}, htmlHead.Execute(w, room)
+}
+
+func (h *htmlWriter) Write(item ExportItem) error {
+	return htmlItem.Execute(h.w, item)
+}
+
+func (h *htmlWriter) Close() error {
+	_, err := io.WriteString(h.w, "</body></html>\n")
+	return err
+}
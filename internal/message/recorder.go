@@ -0,0 +1,30 @@
+package message
+
+import (
+	"log"
+
+	"github.com/go-portfolio/websocket-chat/internal/chat"
+)
+
+// Recorder сохраняет разосланные сообщения в историю (наблюдатель Hub)
+type Recorder struct {
+	Store MessageStore
+}
+
+var _ chat.MessageObserver = (*Recorder)(nil)
+
+// MessageSent сохраняет обычные, личные сообщения и вложения;
+// системные и служебные события в историю не попадают
+func (r *Recorder) MessageSent(msg chat.ChatMessage) {
+	switch msg.Type {
+	case "", "message", "private", "attachment":
+	default:
+		return
+	}
+	if msg.ID == "" {
+		return
+	}
+	if err := r.Store.Save(FromChat(msg)); err != nil {
+		log.Printf("message: %v", err)
+	}
+}
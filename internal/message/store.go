@@ -0,0 +1,259 @@
+package message
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/chat"
+)
+
+// Message — сохранённое сообщение чата
+type Message struct {
+	ID           string    `json:"id"`
+	Room         string    `json:"room,omitempty"` // пусто у личных сообщений
+	From         string    `json:"from"`
+	To           string    `json:"to,omitempty"` // адресат личного сообщения
+	Type         string    `json:"type"`
+	Text         string    `json:"text"`
+	AttachmentID string    `json:"attachment_id,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// SearchQuery — параметры полнотекстового поиска
+type SearchQuery struct {
+	Text   string    // запрос в синтаксисе websearch_to_tsquery: слова, "фраза", -исключение, or
+	User   string    // кто ищет: видны только его комнаты и личные сообщения
+	Room   string    // пусто — во всех доступных комнатах
+	Author string    // автор сообщений; пусто — любой
+	Before time.Time // нулевое — без ограничения
+	Limit  int
+	Offset int
+}
+
+// SearchResult — найденное сообщение с фрагментом, где совпадения обёрнуты в <mark>
+type SearchResult struct {
+	Message
+	Snippet string `json:"snippet"`
+}
+
+// MessageStore хранит историю сообщений
+type MessageStore interface {
+	Save(m *Message) error
+	Search(q SearchQuery) ([]SearchResult, error)
+	Export(room string, from, to time.Time, fn func(ExportItem) error) error
+}
+
+type Store struct {
+	Db *sql.DB
+}
+
+var _ MessageStore = (*Store)(nil)
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{Db: db}
+}
+
+func (s *Store) Save(m *Message) error {
+	var attachmentID sql.NullString
+	if m.AttachmentID != "" {
+		attachmentID = sql.NullString{String: m.AttachmentID, Valid: true}
+	}
+	_, err := s.Db.Exec(
+		`INSERT INTO messages (id, room, sender, recipient, type, text, attachment_id, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT (id) DO NOTHING`,
+		m.ID, m.Room, m.From, m.To, m.Type, m.Text, attachmentID, m.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save message: %w", err)
+	}
+	return nil
+}
+
+// Маркеры совпадений в ts_headline. FromChat вырезает их из текста сообщений,
+// поэтому в выдаче они встречаются только вокруг совпадений.
+const (
+	markStart = "\x02"
+	markStop  = "\x03"
+)
+
+// searchQuery ищет по tsvector с учётом доступа: комнаты, где пользователь участник,
+// и личные сообщения, где он отправитель или адресат
+const searchQuery = `SELECT m.id, m.room, m.sender, m.recipient, m.type, m.text,
+		COALESCE(m.attachment_id, ''), m.created_at,
+		ts_headline('russian', m.text, q, 'StartSel=` + markStart + `, StopSel=` + markStop + `, MaxFragments=2, MaxWords=20, MinWords=5')
+	FROM messages m, websearch_to_tsquery('russian', $1) q
+	WHERE m.tsv @@ q
+	  AND (
+		(m.recipient = '' AND m.room IN (SELECT room FROM room_members WHERE username = $2))
+		OR (m.recipient <> '' AND (m.sender = $2 OR m.recipient = $2))
+	  )
+	  AND ($3 = '' OR (m.room = $3 AND m.recipient = ''))
+	  AND ($4 = '' OR m.sender = $4)
+	  AND ($5::timestamptz IS NULL OR m.created_at < $5)
+	ORDER BY m.created_at DESC, m.id DESC
+	LIMIT $6 OFFSET $7`
+
+func (s *Store) Search(q SearchQuery) ([]SearchResult, error) {
+	var before sql.NullTime
+	if !q.Before.IsZero() {
+		before = sql.NullTime{Time: q.Before, Valid: true}
+	}
+
+	rows, err := s.Db.Query(searchQuery, q.Text, q.User, q.Room, q.Author, before, q.Limit, q.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search messages: %w", err)
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		var (
+			r        SearchResult
+			headline string
+		)
+		err := rows.Scan(&r.ID, &r.Room, &r.From, &r.To, &r.Type, &r.Text, &r.AttachmentID, &r.CreatedAt, &headline)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		r.Snippet = Highlight(headline)
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// Highlight экранирует фрагмент из ts_headline и превращает маркеры совпадений в <mark>,
+// так что результат можно вставлять в страницу как HTML
+func Highlight(headline string) string {
+	return marks.Replace(html.EscapeString(headline))
+}
+
+var marks = strings.NewReplacer(markStart, "<mark>", markStop, "</mark>")
+
+// FromChat превращает разосланное сообщение чата в запись истории
+func FromChat(msg chat.ChatMessage) *Message {
+	m := &Message{
+		ID:        msg.ID,
+		Room:      msg.Room,
+		From:      msg.From,
+		To:        msg.To,
+		Type:      msg.Type,
+		Text:      strings.NewReplacer(markStart, "", markStop, "").Replace(msg.Text),
+		CreatedAt: time.Unix(msg.Timestamp, 0),
+	}
+	if m.Type == "" {
+		m.Type = "message"
+	}
+	if m.To != "" {
+		m.Room = ""
+	}
+	if msg.Attachment != nil {
+		m.AttachmentID = msg.Attachment.ID
+	}
+	return m
+}
+
+// Purged — результат удаления пачки сообщений
+type Purged struct {
+	Messages    int
+	IDs         []string // ID удалённых сообщений
+	Attachments []string // ID вложений из удалённых сообщений
+}
+
+// Rooms возвращает комнаты, в которых есть сообщения (личные сообщения не входят)
+func (s *Store) Rooms() ([]string, error) {
+	rows, err := s.Db.Query(`SELECT DISTINCT room FROM messages WHERE room <> ''`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rooms: %w", err)
+	}
+	defer rows.Close()
+
+	rooms := []string{}
+	for rows.Next() {
+		var room string
+		if err := rows.Scan(&room); err != nil {
+			return nil, fmt.Errorf("failed to scan room: %w", err)
+		}
+		rooms = append(rooms, room)
+	}
+	return rooms, rows.Err()
+}
+
+// DeleteBefore удаляет до limit самых старых сообщений комнаты, созданных раньше before.
+// Пустая room — личные сообщения.
+func (s *Store) DeleteBefore(room string, before time.Time, limit int) (Purged, error) {
+	return s.purge(`SELECT id FROM messages WHERE room=$1 AND created_at < $2 ORDER BY created_at LIMIT $3`,
+		room, before, limit)
+}
+
+// DeleteBeyond удаляет до limit сообщений комнаты сверх keep последних
+func (s *Store) DeleteBeyond(room string, keep, limit int) (Purged, error) {
+	return s.purge(`SELECT id FROM messages WHERE room=$1 ORDER BY created_at DESC, id DESC OFFSET $2 LIMIT $3`,
+		room, keep, limit)
+}
+
+// purge удаляет сообщения, выбранные запросом selectIDs, а тем же запросом —
+// и копии их текста в упоминаниях и уведомлениях
+func (s *Store) purge(selectIDs string, args ...any) (Purged, error) {
+	var p Purged
+	query := `WITH deleted AS (
+			DELETE FROM messages WHERE id IN (` + selectIDs + `)
+			RETURNING id, COALESCE(attachment_id, '') AS attachment_id
+		), mentions_deleted AS (
+			DELETE FROM mentions WHERE message_id IN (SELECT id FROM deleted)
+		), notifications_deleted AS (
+			DELETE FROM notifications WHERE message_id IN (SELECT id FROM deleted)
+		)
+		SELECT id, attachment_id FROM deleted`
+	rows, err := s.Db.Query(query, args...)
+	if err != nil {
+		return p, fmt.Errorf("failed to delete messages: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, attachmentID string
+		if err := rows.Scan(&id, &attachmentID); err != nil {
+			return p, fmt.Errorf("failed to scan deleted message: %w", err)
+		}
+		p.Messages++
+		p.IDs = append(p.IDs, id)
+		if attachmentID != "" {
+			p.Attachments = append(p.Attachments, attachmentID)
+		}
+	}
+	return p, rows.Err()
+}
+
+// SaveBatch сохраняет сообщения одним запросом (например, при импорте истории)
+// и возвращает число добавленных; уже существующие ID пропускаются
+func (s *Store) SaveBatch(msgs []*Message) (int, error) {
+	if len(msgs) == 0 {
+		return 0, nil
+	}
+
+	var (
+		query strings.Builder
+		args  = make([]any, 0, len(msgs)*8)
+	)
+	query.WriteString(`INSERT INTO messages (id, room, sender, recipient, type, text, attachment_id, created_at) VALUES `)
+	for i, m := range msgs {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		n := len(args)
+		fmt.Fprintf(&query, "($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8)
+		attachmentID := sql.NullString{String: m.AttachmentID, Valid: m.AttachmentID != ""}
+		args = append(args, m.ID, m.Room, m.From, m.To, m.Type, m.Text, attachmentID, m.CreatedAt)
+	}
+	query.WriteString(" ON CONFLICT (id) DO NOTHING")
+
+	res, err := s.Db.Exec(query.String(), args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save messages: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
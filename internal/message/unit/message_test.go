@@ -0,0 +1,250 @@
+package unit
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-portfolio/websocket-chat/internal/chat"
+	"github.com/go-portfolio/websocket-chat/internal/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// --- FromChat / Recorder ---
+
+func TestFromChat(t *testing.T) {
+	m := message.FromChat(chat.ChatMessage{ID: "m1", From: "alice", Room: "tech", Text: "при\x02вет", Timestamp: 100})
+	assert.Equal(t, "message", m.Type)
+	assert.Equal(t, "tech", m.Room)
+	assert.Equal(t, "привет", m.Text)
+	assert.Equal(t, time.Unix(100, 0), m.CreatedAt)
+
+	// Личное сообщение не относится к комнате отправителя
+	m = message.FromChat(chat.ChatMessage{
+		ID: "m2", Type: "attachment", From: "alice", To: "bob", Room: "tech",
+		Attachment: &chat.Attachment{ID: "a1"},
+	})
+	assert.Equal(t, "", m.Room)
+	assert.Equal(t, "bob", m.To)
+	assert.Equal(t, "a1", m.AttachmentID)
+}
+
+type fakeStore struct{ saved []*message.Message }
+
+func (f *fakeStore) Save(m *message.Message) error {
+	f.saved = append(f.saved, m)
+	return nil
+}
+
+func (f *fakeStore) Search(q message.SearchQuery) ([]message.SearchResult, error) {
+	return nil, nil
+}
+
+func (f *fakeStore) Export(room string, from, to time.Time, fn func(message.ExportItem) error) error {
+	return nil
+}
+
+func TestRecorder_SkipsServiceMessages(t *testing.T) {
+	store := &fakeStore{}
+	r := &message.Recorder{Store: store}
+
+	r.MessageSent(chat.ChatMessage{ID: "m1", Type: "message", Text: "hi"})
+	r.MessageSent(chat.ChatMessage{ID: "m2", Type: "private", To: "bob", Text: "hi"})
+	r.MessageSent(chat.ChatMessage{ID: "m3", Type: "system", Text: "alice joined"})
+	r.MessageSent(chat.ChatMessage{ID: "m4", Type: "message_unfurled"})
+	r.MessageSent(chat.ChatMessage{Type: "message", Text: "без ID"})
+
+	assert.Len(t, store.saved, 2)
+	assert.Equal(t, "m1", store.saved[0].ID)
+	assert.Equal(t, "m2", store.saved[1].ID)
+}
+
+// --- Highlight ---
+
+func TestHighlight_EscapesText(t *testing.T) {
+	got := message.Highlight("<b>x</b> и \x02привет\x03 & \x02мир\x03")
+	assert.Equal(t, "&lt;b&gt;x&lt;/b&gt; и <mark>привет</mark> &amp; <mark>мир</mark>", got)
+}
+
+// --- Store ---
+
+func TestStore_Save(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := message.NewStore(db)
+
+	at := time.Unix(100, 0)
+	mock.ExpectExec(`INSERT INTO messages`).
+		WithArgs("m1", "", "alice", "bob", "private", "hi", sql.NullString{String: "a1", Valid: true}, at).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := store.Save(&message.Message{ID: "m1", From: "alice", To: "bob", Type: "private", Text: "hi", AttachmentID: "a1", CreatedAt: at})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStore_Search(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := message.NewStore(db)
+
+	before := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Now()
+	mock.ExpectQuery(`FROM messages m, websearch_to_tsquery`).
+		WithArgs("привет", "bob", "tech", "alice", sql.NullTime{Time: before, Valid: true}, 20, 40).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "room", "sender", "recipient", "type", "text", "attachment_id", "created_at", "headline"}).
+			AddRow("m1", "tech", "alice", "", "message", "<i>привет</i>", "", now, "<i>\x02привет\x03</i>"))
+
+	results, err := store.Search(message.SearchQuery{
+		Text: "привет", User: "bob", Room: "tech", Author: "alice", Before: before, Limit: 20, Offset: 40,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, "m1", results[0].ID)
+	assert.Equal(t, "<i>привет</i>", results[0].Text)
+	assert.Equal(t, "&lt;i&gt;<mark>привет</mark>&lt;/i&gt;", results[0].Snippet)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStore_DeleteBefore(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := message.NewStore(db)
+
+	before := time.Now()
+	// Вместе с сообщениями удаляются копии текста в упоминаниях и уведомлениях
+	mock.ExpectQuery(`DELETE FROM messages WHERE id IN \(SELECT id FROM messages WHERE room=\$1 AND created_at < \$2.*`+
+		`DELETE FROM mentions WHERE message_id IN \(SELECT id FROM deleted\).*`+
+		`DELETE FROM notifications WHERE message_id IN \(SELECT id FROM deleted\)`).
+		WithArgs("legal", before, 500).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "attachment_id"}).AddRow("m1", "").AddRow("m2", "a1").AddRow("m3", ""))
+
+	p, err := store.DeleteBefore("legal", before, 500)
+	assert.NoError(t, err)
+	assert.Equal(t, message.Purged{Messages: 3, IDs: []string{"m1", "m2", "m3"}, Attachments: []string{"a1"}}, p)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// --- Export ---
+
+func TestStore_Export(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := message.NewStore(db)
+
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	at := from.Add(time.Hour)
+	mock.ExpectQuery(`FROM messages m LEFT JOIN attachments a`).
+		WithArgs("tech", sql.NullTime{Time: from, Valid: true}, sql.NullTime{}).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "sender", "type", "text", "created_at", "attachment_id", "name"}).
+			AddRow("m1", "alice", "message", "hi", at, "", "").
+			AddRow("m2", "bob", "attachment", "", at, "a1", "plan.pdf"))
+
+	var items []message.ExportItem
+	err := store.Export("tech", from, time.Time{}, func(item message.ExportItem) error {
+		items = append(items, item)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+	assert.Equal(t, "", items[0].AttachmentURL)
+	assert.Equal(t, "/api/attachments/a1", items[1].AttachmentURL)
+	assert.Equal(t, "plan.pdf", items[1].AttachmentName)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+var exportItems = []message.ExportItem{
+	{ID: "m1", From: "alice", Type: "message", Text: "<script>alert(1)</script>", CreatedAt: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)},
+	{ID: "m2", From: "bob", Type: "attachment", Text: "план, \"v2\"", CreatedAt: time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC),
+		AttachmentName: "plan.pdf", AttachmentURL: "/api/attachments/a1"},
+}
+
+func export(t *testing.T, format string, items []message.ExportItem) string {
+	var buf bytes.Buffer
+	w, err := message.NewExportWriter(format, &buf, "tech")
+	require.NoError(t, err)
+	for _, item := range items {
+		require.NoError(t, w.Write(item))
+	}
+	require.NoError(t, w.Close())
+	return buf.String()
+}
+
+func TestExportWriter_JSON(t *testing.T) {
+	for _, items := range [][]message.ExportItem{nil, exportItems} {
+		var doc struct {
+			Room     string               `json:"room"`
+			Messages []message.ExportItem `json:"messages"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(export(t, message.FormatJSON, items)), &doc))
+		assert.Equal(t, "tech", doc.Room)
+		assert.Len(t, doc.Messages, len(items))
+	}
+}
+
+func TestExportWriter_CSV(t *testing.T) {
+	records, err := csv.NewReader(bytes.NewBufferString(export(t, message.FormatCSV, exportItems))).ReadAll()
+	require.NoError(t, err)
+	assert.Len(t, records, 3)
+	assert.Equal(t, "from", records[0][2])
+	assert.Equal(t, []string{"m2", "2024-05-01T11:00:00Z", "bob", "attachment", "план, \"v2\"", "plan.pdf", "/api/attachments/a1"}, records[2])
+}
+
+// Текст, который табличный редактор принял бы за формулу, выводится с апострофом
+func TestExportWriter_CSVFormulas(t *testing.T) {
+	items := []message.ExportItem{
+		{ID: "m1", From: "alice", Type: "message", Text: `=HYPERLINK("http://evil","x")`},
+		{ID: "m2", From: "alice", Type: "message", Text: "+1", AttachmentName: "@cmd.csv"},
+		{ID: "m3", From: "alice", Type: "message", Text: "-2"},
+		{ID: "m4", From: "alice", Type: "message", Text: "\t=1"},
+		{ID: "m5", From: "alice", Type: "message", Text: "a=b"},
+	}
+	records, err := csv.NewReader(bytes.NewBufferString(export(t, message.FormatCSV, items))).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 6)
+	assert.Equal(t, `'=HYPERLINK("http://evil","x")`, records[1][4])
+	assert.Equal(t, "'+1", records[2][4])
+	assert.Equal(t, "'@cmd.csv", records[2][5])
+	assert.Equal(t, "'-2", records[3][4])
+	assert.Equal(t, "'\t=1", records[4][4])
+	assert.Equal(t, "a=b", records[5][4])
+}
+
+func TestExportWriter_HTMLEscapes(t *testing.T) {
+	out := export(t, message.FormatHTML, exportItems)
+	assert.NotContains(t, out, "<script>")
+	assert.Contains(t, out, "&lt;script&gt;alert(1)&lt;/script&gt;")
+	assert.Contains(t, out, `<a href="/api/attachments/a1">plan.pdf</a>`)
+	assert.Contains(t, out, "2024-05-01 11:00:00 UTC")
+}
+
+func TestNewExportWriter_UnknownFormat(t *testing.T) {
+	_, err := message.NewExportWriter("xml", &bytes.Buffer{}, "tech")
+	assert.Error(t, err)
+}
+
+func TestStore_SaveBatch(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := message.NewStore(db)
+
+	at := time.Unix(100, 0)
+	mock.ExpectExec(regexp.QuoteMeta(`VALUES ($1, $2, $3, $4, $5, $6, $7, $8), ($9, $10, $11, $12, $13, $14, $15, $16) ON CONFLICT (id) DO NOTHING`)).
+		WithArgs("m1", "tech", "alice", "", "message", "a", sql.NullString{}, at,
+			"m2", "tech", "bob", "", "message", "b", sql.NullString{}, at).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	n, err := store.SaveBatch([]*message.Message{
+		{ID: "m1", Room: "tech", From: "alice", Type: "message", Text: "a", CreatedAt: at},
+		{ID: "m2", Room: "tech", From: "bob", Type: "message", Text: "b", CreatedAt: at},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
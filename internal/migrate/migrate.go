@@ -0,0 +1,269 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// Migration — пара скриптов NNN_name.up.sql / NNN_name.down.sql
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Load читает миграции из fsys и упорядочивает их по версии.
+// У каждой миграции должны быть оба скрипта.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		m := fileName.FindStringSubmatch(e.Name())
+		if e.IsDir() || m == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(m[1])
+		data, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", e.Name(), err)
+		}
+
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(data)
+		} else {
+			mig.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migration %03d_%s must have both up and down scripts", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// ErrDirty — предыдущая миграция прервалась; схему нужно поправить вручную
+var ErrDirty = errors.New("database is dirty")
+
+// lockKey — ключ advisory-блокировки, чтобы два экземпляра не мигрировали одновременно
+const lockKey = 4_206_270_001
+
+// Status — состояние схемы
+type Status struct {
+	Version int // 0 — миграции не применялись
+	Dirty   bool
+	Applied []Migration
+	Pending []Migration
+}
+
+// Migrator применяет и откатывает миграции. Версия схемы хранится в таблице
+// schema_migrations (version, dirty) в том же формате, что у golang-migrate,
+// поэтому базы, размеченные через make migrate-up, подхватываются как есть.
+// Каждая миграция выполняется в своей транзакции вместе с обновлением версии.
+type Migrator struct {
+	Db         *sql.DB
+	Migrations []Migration
+	Logf       func(format string, args ...any) // nil — log.Printf
+}
+
+// Up применяет все неприменённые миграции
+func (m *Migrator) Up(ctx context.Context) error {
+	if len(m.Migrations) == 0 {
+		return nil
+	}
+	return m.To(ctx, m.Migrations[len(m.Migrations)-1].Version)
+}
+
+// Down откатывает последнюю применённую миграцию
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.locked(ctx, func(conn *sql.Conn, current int) error {
+		i := m.index(current)
+		if i < 0 {
+			return fmt.Errorf("nothing to roll back")
+		}
+		return m.down(ctx, conn, i)
+	})
+}
+
+// To приводит схему к версии target (0 — откатить всё)
+func (m *Migrator) To(ctx context.Context, target int) error {
+	if target != 0 && m.index(target) < 0 {
+		return fmt.Errorf("unknown migration version %d", target)
+	}
+	return m.locked(ctx, func(conn *sql.Conn, current int) error {
+		for i := range m.Migrations {
+			mig := m.Migrations[i]
+			if mig.Version > current && mig.Version <= target {
+				if err := m.apply(ctx, conn, mig.Version, mig.Up, fmt.Sprintf("%03d_%s up", mig.Version, mig.Name)); err != nil {
+					return err
+				}
+			}
+		}
+		for i := len(m.Migrations) - 1; i >= 0; i-- {
+			mig := m.Migrations[i]
+			if mig.Version <= current && mig.Version > target {
+				if err := m.down(ctx, conn, i); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Status возвращает текущую версию и списки применённых и ожидающих миграций
+func (m *Migrator) Status(ctx context.Context) (Status, error) {
+	var st Status
+	conn, err := m.Db.Conn(ctx)
+	if err != nil {
+		return st, err
+	}
+	defer conn.Close()
+
+	if err := ensureTable(ctx, conn); err != nil {
+		return st, err
+	}
+	if st.Version, st.Dirty, err = version(ctx, conn); err != nil {
+		return st, err
+	}
+	for _, mig := range m.Migrations {
+		if mig.Version <= st.Version {
+			st.Applied = append(st.Applied, mig)
+		} else {
+			st.Pending = append(st.Pending, mig)
+		}
+	}
+	return st, nil
+}
+
+// locked выполняет fn под advisory-блокировкой на выделенном соединении
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn, current int) error) error {
+	conn, err := m.Db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey); err != nil {
+			m.logf("migrate: failed to release lock: %v", err)
+		}
+	}()
+
+	if err := ensureTable(ctx, conn); err != nil {
+		return err
+	}
+	current, dirty, err := version(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("%w at version %d: fix the schema and update schema_migrations manually", ErrDirty, current)
+	}
+	return fn(conn, current)
+}
+
+// down откатывает миграцию с индексом i; версией становится предыдущая миграция
+func (m *Migrator) down(ctx context.Context, conn *sql.Conn, i int) error {
+	mig := m.Migrations[i]
+	prev := 0
+	if i > 0 {
+		prev = m.Migrations[i-1].Version
+	}
+	return m.apply(ctx, conn, prev, mig.Down, fmt.Sprintf("%03d_%s down", mig.Version, mig.Name))
+}
+
+// apply выполняет скрипт и записывает новую версию в одной транзакции
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, newVersion int, script, label string) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migration %s failed: %w", label, err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations`); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+	if newVersion > 0 {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, FALSE)`, newVersion); err != nil {
+			return fmt.Errorf("failed to update schema version: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("migration %s failed: %w", label, err)
+	}
+	m.logf("migrate: applied %s", label)
+	return nil
+}
+
+func (m *Migrator) index(version int) int {
+	for i, mig := range m.Migrations {
+		if mig.Version == version {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *Migrator) logf(format string, args ...any) {
+	if m.Logf != nil {
+		m.Logf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+func ensureTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+func version(ctx context.Context, conn *sql.Conn) (int, bool, error) {
+	var (
+		v     int
+		dirty bool
+	)
+	err := conn.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&v, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return v, dirty, nil
+}
@@ -0,0 +1,164 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"testing/fstest"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-portfolio/websocket-chat/internal/migrate"
+	"github.com/go-portfolio/websocket-chat/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	list, err := migrate.Load(fstest.MapFS{
+		"002_rooms.up.sql":   {Data: []byte("CREATE TABLE rooms ();")},
+		"002_rooms.down.sql": {Data: []byte("DROP TABLE rooms;")},
+		"001_users.up.sql":   {Data: []byte("CREATE TABLE users ();")},
+		"001_users.down.sql": {Data: []byte("DROP TABLE users;")},
+		"embed.go":           {Data: []byte("package migrations")},
+	})
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, 1, list[0].Version)
+	assert.Equal(t, "users", list[0].Name)
+	assert.Equal(t, "DROP TABLE rooms;", list[1].Down)
+
+	_, err = migrate.Load(fstest.MapFS{"001_users.up.sql": {Data: []byte("CREATE TABLE users ();")}})
+	assert.Error(t, err, "нет down-скрипта")
+}
+
+// Встроенные в бинарник миграции должны загружаться без ошибок
+func TestLoadEmbedded(t *testing.T) {
+	list, err := migrate.Load(migrations.FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, list)
+	for i, m := range list {
+		assert.Equal(t, i+1, m.Version, "версии идут подряд")
+	}
+}
+
+func newMigrator(t *testing.T) (*migrate.Migrator, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return &migrate.Migrator{
+		Db: db,
+		Migrations: []migrate.Migration{
+			{Version: 1, Name: "users", Up: "CREATE users", Down: "DROP users"},
+			{Version: 2, Name: "rooms", Up: "CREATE rooms", Down: "DROP rooms"},
+			{Version: 3, Name: "messages", Up: "CREATE messages", Down: "DROP messages"},
+		},
+		Logf: func(string, ...any) {},
+	}, mock
+}
+
+func expectLocked(mock sqlmock.Sqlmock, version int, dirty bool) {
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_lock($1)`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	rows := sqlmock.NewRows([]string{"version", "dirty"})
+	if version > 0 {
+		rows.AddRow(version, dirty)
+	}
+	mock.ExpectQuery("SELECT version, dirty FROM schema_migrations").WillReturnRows(rows)
+}
+
+func expectApply(mock sqlmock.Sqlmock, script string, newVersion int) {
+	mock.ExpectBegin()
+	mock.ExpectExec(script).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM schema_migrations").WillReturnResult(sqlmock.NewResult(0, 1))
+	if newVersion > 0 {
+		mock.ExpectExec("INSERT INTO schema_migrations").WithArgs(newVersion).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+}
+
+func expectUnlock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_unlock($1)`)).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func TestUpAppliesPending(t *testing.T) {
+	m, mock := newMigrator(t)
+
+	expectLocked(mock, 1, false)
+	expectApply(mock, "CREATE rooms", 2)
+	expectApply(mock, "CREATE messages", 3)
+	expectUnlock(mock)
+
+	require.NoError(t, m.Up(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpStopsOnFailure(t *testing.T) {
+	m, mock := newMigrator(t)
+
+	expectLocked(mock, 0, false)
+	expectApply(mock, "CREATE users", 1)
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE rooms").WillReturnError(errors.New("syntax error"))
+	mock.ExpectRollback()
+	expectUnlock(mock)
+
+	err := m.Up(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "002_rooms up")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDirtyDatabaseRefused(t *testing.T) {
+	m, mock := newMigrator(t)
+
+	expectLocked(mock, 2, true)
+	expectUnlock(mock)
+
+	err := m.Up(context.Background())
+	assert.ErrorIs(t, err, migrate.ErrDirty)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDownRollsBackOne(t *testing.T) {
+	m, mock := newMigrator(t)
+
+	expectLocked(mock, 3, false)
+	expectApply(mock, "DROP messages", 2)
+	expectUnlock(mock)
+
+	require.NoError(t, m.Down(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestToDownwardsToZero(t *testing.T) {
+	m, mock := newMigrator(t)
+
+	expectLocked(mock, 2, false)
+	expectApply(mock, "DROP rooms", 1)
+	expectApply(mock, "DROP users", 0)
+	expectUnlock(mock)
+
+	require.NoError(t, m.To(context.Background(), 0))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Error(t, m.To(context.Background(), 7), "неизвестная версия")
+}
+
+func TestStatus(t *testing.T) {
+	m, mock := newMigrator(t)
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, dirty FROM schema_migrations").WillReturnRows(
+		sqlmock.NewRows([]string{"version", "dirty"}).AddRow(1, false))
+
+	st, err := m.Status(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, st.Version)
+	assert.False(t, st.Dirty)
+	assert.Len(t, st.Applied, 1)
+	require.Len(t, st.Pending, 2)
+	assert.Equal(t, "rooms", st.Pending[0].Name)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,112 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/chat"
+	"github.com/go-portfolio/websocket-chat/internal/mail"
+)
+
+// Digester периодически отправляет e-mail дайджесты непрочитанных упоминаний
+// и личных сообщений тем, кто дольше Threshold не появлялся в сети и включил дайджесты.
+// Каждое уведомление попадает в дайджест не больше одного раза.
+type Digester struct {
+	Store     DigestStore
+	Mailer    mail.Mailer
+	Hub       *chat.Hub     // пользователи в сети пропускаются; nil — не проверять
+	Threshold time.Duration // сколько пользователь должен быть не в сети
+	MaxItems  int           // сколько записей показывать в письме
+}
+
+// Run отправляет дайджесты каждые interval до отмены ctx
+func (d *Digester) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := d.RunOnce(now); err != nil {
+				log.Printf("digest: %v", err)
+			}
+		}
+	}
+}
+
+// RunOnce отправляет все накопившиеся дайджесты и возвращает число отправленных писем.
+// Ошибка отправки одному пользователю не мешает остальным.
+func (d *Digester) RunOnce(now time.Time) (int, error) {
+	recipients, err := d.Store.DigestRecipients(now.Add(-d.Threshold))
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, r := range recipients {
+		if d.Hub != nil && d.Hub.IsOnline(r.Username) {
+			continue
+		}
+
+		// Берём на одну запись больше, чтобы узнать, что показано не всё
+		items, err := d.Store.Pending(r.Username, r.Since, d.maxItems()+1)
+		if err != nil {
+			log.Printf("digest: %v", err)
+			continue
+		}
+		if len(items) == 0 {
+			continue
+		}
+
+		if err := d.Mailer.Send(d.compose(r, items)); err != nil {
+			log.Printf("digest: failed to send to %s: %v", r.Username, err)
+			continue
+		}
+		if err := d.Store.MarkDigestSent(r.Username, now); err != nil {
+			log.Printf("digest: %v", err)
+		}
+		log.Printf("digest: sent %d item(s) to %s", min(len(items), d.maxItems()), r.Username)
+		sent++
+	}
+	return sent, nil
+}
+
+func (d *Digester) maxItems() int {
+	if d.MaxItems <= 0 {
+		return 20
+	}
+	return d.MaxItems
+}
+
+// compose формирует текст письма
+func (d *Digester) compose(r Recipient, items []chat.Notification) mail.Message {
+	more := len(items) > d.maxItems()
+	if more {
+		items = items[:d.maxItems()]
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Здравствуйте, %s!\n\nПока вас не было в чате:\n\n", r.Username)
+	for _, n := range items {
+		switch n.Kind {
+		case KindMention:
+			fmt.Fprintf(&b, "• %s упомянул(а) вас в комнате %s: %s\n", n.From, n.Room, n.Text)
+		case KindDM:
+			fmt.Fprintf(&b, "• Личное сообщение от %s: %s\n", n.From, n.Text)
+		}
+	}
+	if more {
+		b.WriteString("\n…и другие непрочитанные уведомления.\n")
+	}
+	b.WriteString("\nОтключить эти письма можно в настройках профиля.\n")
+
+	subject := fmt.Sprintf("Непрочитанное в чате: %d", len(items))
+	if more {
+		subject += "+"
+	}
+	return mail.Message{To: r.Email, Subject: subject, Body: b.String()}
+}
@@ -0,0 +1,64 @@
+package notify
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/go-portfolio/websocket-chat/internal/chat"
+)
+
+// Виды уведомлений
+const (
+	KindMention    = "mention"    // пользователя упомянули в комнате
+	KindDM         = "dm"         // личное сообщение, пришедшее, пока пользователь был не в сети
+	KindModeration = "moderation" // действие администратора: отключение или блокировка
+)
+
+// Inbox сохраняет уведомления и сразу отправляет их событием "notification"
+// во все подключения пользователя. Как наблюдатель сообщений Hub он создаёт
+// уведомления о личных сообщениях для адресатов не в сети.
+type Inbox struct {
+	Store NotificationStore
+	Hub   *chat.Hub
+}
+
+var _ chat.MessageObserver = (*Inbox)(nil)
+
+// Notify сохраняет уведомление для username и доставляет его, если пользователь в сети
+func (i *Inbox) Notify(username string, n chat.Notification) error {
+	if err := i.Store.Add(username, &n); err != nil {
+		return err
+	}
+	if i.Hub != nil {
+		i.Hub.SendToUser(username, chat.ChatMessage{
+			Type:         "notification",
+			Timestamp:    n.CreatedAt,
+			Notification: &n,
+		})
+	}
+	return nil
+}
+
+// MessageSent создаёт уведомление о личном сообщении, если адресата нет в сети
+func (i *Inbox) MessageSent(msg chat.ChatMessage) {
+	if msg.To == "" || msg.To == msg.From || (msg.Type != "private" && msg.Type != "attachment") {
+		return
+	}
+	if i.Hub.IsOnline(msg.To) {
+		return
+	}
+
+	text := msg.Text
+	if msg.Attachment != nil {
+		text = fmt.Sprintf("📎 %s %s", msg.Attachment.Name, text)
+	}
+	err := i.Notify(msg.To, chat.Notification{
+		Kind:      KindDM,
+		From:      msg.From,
+		MessageID: msg.ID,
+		Text:      text,
+	})
+	if err != nil {
+		log.Printf("notify: %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+package notify
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/chat"
+)
+
+// ErrNotFound — уведомления нет или оно принадлежит другому пользователю
+var ErrNotFound = errors.New("notification not found")
+
+// NotificationStore хранит входящие уведомления пользователей
+type NotificationStore interface {
+	// Add сохраняет уведомление и заполняет его ID и CreatedAt
+	Add(username string, n *chat.Notification) error
+	// List возвращает уведомления от новых к старым; before > 0 — только с ID меньше before
+	List(username string, unreadOnly bool, before int64, limit int) ([]chat.Notification, error)
+	MarkRead(username string, id int64) error
+	// MarkAllRead отмечает прочитанными все уведомления и возвращает их число
+	MarkAllRead(username string) (int64, error)
+	UnreadCount(username string) (int, error)
+}
+
+type Store struct {
+	Db *sql.DB
+}
+
+var _ NotificationStore = (*Store)(nil)
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{Db: db}
+}
+
+func (s *Store) Add(username string, n *chat.Notification) error {
+	var createdAt time.Time
+	err := s.Db.QueryRow(
+		`INSERT INTO notifications (username, kind, actor, room, message_id, text)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`,
+		username, n.Kind, n.From, n.Room, n.MessageID, n.Text,
+	).Scan(&n.ID, &createdAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert notification: %w", err)
+	}
+	n.CreatedAt = createdAt.Unix()
+	n.Read = false
+	return nil
+}
+
+func (s *Store) List(username string, unreadOnly bool, before int64, limit int) ([]chat.Notification, error) {
+	query := `SELECT id, kind, actor, room, message_id, text, read_at IS NOT NULL, created_at
+		FROM notifications
+		WHERE username=$1 AND ($2 = 0 OR id < $2) AND (NOT $3 OR read_at IS NULL)
+		ORDER BY id DESC LIMIT $4`
+	rows, err := s.Db.Query(query, username, before, unreadOnly, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notifications: %w", err)
+	}
+	defer rows.Close()
+
+	list := []chat.Notification{}
+	for rows.Next() {
+		var (
+			n         chat.Notification
+			createdAt time.Time
+		)
+		if err := rows.Scan(&n.ID, &n.Kind, &n.From, &n.Room, &n.MessageID, &n.Text, &n.Read, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %w", err)
+		}
+		n.CreatedAt = createdAt.Unix()
+		list = append(list, n)
+	}
+	return list, rows.Err()
+}
+
+func (s *Store) MarkRead(username string, id int64) error {
+	res, err := s.Db.Exec(
+		`UPDATE notifications SET read_at = COALESCE(read_at, NOW()) WHERE id=$1 AND username=$2`,
+		id, username,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to mark notification: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) MarkAllRead(username string) (int64, error) {
+	res, err := s.Db.Exec(`UPDATE notifications SET read_at = NOW() WHERE username=$1 AND read_at IS NULL`, username)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark notifications: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n, nil
+}
+
+func (s *Store) UnreadCount(username string) (int, error) {
+	var count int
+	err := s.Db.QueryRow(`SELECT COUNT(*) FROM notifications WHERE username=$1 AND read_at IS NULL`, username).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count notifications: %w", err)
+	}
+	return count, nil
+}
+
+// Recipient — пользователь, которому пора отправить дайджест
+type Recipient struct {
+	Username string
+	Email    string
+	Since    time.Time // время предыдущего дайджеста (нулевое — дайджестов ещё не было)
+}
+
+// DigestStore выбирает получателей и содержимое e-mail дайджестов
+type DigestStore interface {
+	// DigestRecipients возвращает пользователей с включёнными дайджестами, которые не были
+	// в сети с offlineSince и у которых есть новые непрочитанные упоминания или личные сообщения
+	DigestRecipients(offlineSince time.Time) ([]Recipient, error)
+	// Pending возвращает непрочитанные упоминания и личные сообщения, созданные после since
+	Pending(username string, since time.Time, limit int) ([]chat.Notification, error)
+	MarkDigestSent(username string, at time.Time) error
+}
+
+var _ DigestStore = (*Store)(nil)
+
+func (s *Store) DigestRecipients(offlineSince time.Time) ([]Recipient, error) {
+	query := `SELECT u.username, u.email, COALESCE(u.digest_sent_at, 'epoch'::timestamptz)
+		FROM users u
+		WHERE u.email_digest AND u.email IS NOT NULL
+		  AND COALESCE(u.last_seen_at, u.created_at) < $1
+		  AND EXISTS (
+			SELECT 1 FROM notifications n
+			WHERE n.username = u.username AND n.read_at IS NULL AND n.kind IN ('mention', 'dm')
+			  AND n.created_at > COALESCE(u.digest_sent_at, 'epoch'::timestamptz)
+		  )
+		ORDER BY u.username`
+	rows, err := s.Db.Query(query, offlineSince)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list digest recipients: %w", err)
+	}
+	defer rows.Close()
+
+	var list []Recipient
+	for rows.Next() {
+		var r Recipient
+		if err := rows.Scan(&r.Username, &r.Email, &r.Since); err != nil {
+			return nil, fmt.Errorf("failed to scan digest recipient: %w", err)
+		}
+		list = append(list, r)
+	}
+	return list, rows.Err()
+}
+
+func (s *Store) Pending(username string, since time.Time, limit int) ([]chat.Notification, error) {
+	query := `SELECT id, kind, actor, room, message_id, text, created_at
+		FROM notifications
+		WHERE username=$1 AND read_at IS NULL AND kind IN ('mention', 'dm') AND created_at > $2
+		ORDER BY id LIMIT $3`
+	rows, err := s.Db.Query(query, username, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var list []chat.Notification
+	for rows.Next() {
+		var (
+			n         chat.Notification
+			createdAt time.Time
+		)
+		if err := rows.Scan(&n.ID, &n.Kind, &n.From, &n.Room, &n.MessageID, &n.Text, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %w", err)
+		}
+		n.CreatedAt = createdAt.Unix()
+		list = append(list, n)
+	}
+	return list, rows.Err()
+}
+
+func (s *Store) MarkDigestSent(username string, at time.Time) error {
+	if _, err := s.Db.Exec(`UPDATE users SET digest_sent_at=$1 WHERE username=$2`, at, username); err != nil {
+		return fmt.Errorf("failed to mark digest: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package notify_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-portfolio/websocket-chat/internal/chat"
+	"github.com/go-portfolio/websocket-chat/internal/mail"
+	"github.com/go-portfolio/websocket-chat/internal/notify"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDigestStore отдаёт заданных получателей и их уведомления
+type fakeDigestStore struct {
+	offlineSince time.Time
+	recipients   []notify.Recipient
+	pending      map[string][]chat.Notification
+	sent         map[string]time.Time
+}
+
+func (f *fakeDigestStore) DigestRecipients(offlineSince time.Time) ([]notify.Recipient, error) {
+	f.offlineSince = offlineSince
+	return f.recipients, nil
+}
+
+func (f *fakeDigestStore) Pending(username string, since time.Time, limit int) ([]chat.Notification, error) {
+	items := f.pending[username]
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items, nil
+}
+
+func (f *fakeDigestStore) MarkDigestSent(username string, at time.Time) error {
+	f.sent[username] = at
+	return nil
+}
+
+// fakeMailer запоминает отправленные письма
+type fakeMailer struct{ sent []mail.Message }
+
+func (f *fakeMailer) Send(msg mail.Message) error {
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+func TestDigester_RunOnce(t *testing.T) {
+	hub := chat.NewHub()
+	carol := &mockClient{name: "carol", room: "tech", ch: make(chan chat.ChatMessage, 4)}
+	hub.Clients[carol] = true
+
+	store := &fakeDigestStore{
+		recipients: []notify.Recipient{
+			{Username: "bob", Email: "bob@example.com"},
+			{Username: "carol", Email: "carol@example.com"}, // снова в сети — письмо не нужно
+		},
+		pending: map[string][]chat.Notification{
+			"bob": {
+				{Kind: notify.KindMention, From: "alice", Room: "tech", Text: "@bob релиз в пятницу"},
+				{Kind: notify.KindDM, From: "dave", Text: "позвони мне"},
+				{Kind: notify.KindDM, From: "dave", Text: "срочно"},
+			},
+			"carol": {{Kind: notify.KindDM, From: "alice", Text: "привет"}},
+		},
+		sent: map[string]time.Time{},
+	}
+	mailer := &fakeMailer{}
+	d := &notify.Digester{Store: store, Mailer: mailer, Hub: hub, Threshold: time.Hour, MaxItems: 2}
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	sent, err := d.RunOnce(now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, now.Add(-time.Hour), store.offlineSince)
+
+	if assert.Len(t, mailer.sent, 1) {
+		msg := mailer.sent[0]
+		assert.Equal(t, "bob@example.com", msg.To)
+		assert.Equal(t, "Непрочитанное в чате: 2+", msg.Subject)
+		assert.Contains(t, msg.Body, "alice упомянул(а) вас в комнате tech: @bob релиз в пятницу")
+		assert.Contains(t, msg.Body, "Личное сообщение от dave: позвони мне")
+		assert.NotContains(t, msg.Body, "срочно")
+		assert.True(t, strings.Contains(msg.Body, "и другие непрочитанные"))
+	}
+	assert.Equal(t, map[string]time.Time{"bob": now}, store.sent)
+}
+
+func TestStore_DigestRecipients(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := notify.NewStore(db)
+
+	cutoff := time.Now().Add(-time.Hour)
+	mock.ExpectQuery(`FROM users u\s+WHERE u.email_digest AND u.email IS NOT NULL`).
+		WithArgs(cutoff).
+		WillReturnRows(sqlmock.NewRows([]string{"username", "email", "since"}).
+			AddRow("bob", "bob@example.com", time.Unix(0, 0)))
+
+	list, err := store.DigestRecipients(cutoff)
+	assert.NoError(t, err)
+	assert.Equal(t, []notify.Recipient{{Username: "bob", Email: "bob@example.com", Since: time.Unix(0, 0)}}, list)
+}
+
+func TestStore_MarkDigestSent(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := notify.NewStore(db)
+
+	at := time.Now()
+	mock.ExpectExec(`UPDATE users SET digest_sent_at=\$1 WHERE username=\$2`).
+		WithArgs(at, "bob").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, store.MarkDigestSent("bob", at))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
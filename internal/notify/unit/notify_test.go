@@ -0,0 +1,164 @@
+package notify_test
+
+import (
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-portfolio/websocket-chat/internal/chat"
+	"github.com/go-portfolio/websocket-chat/internal/notify"
+	"github.com/stretchr/testify/assert"
+)
+
+// memStore — NotificationStore в памяти
+type memStore struct {
+	mu    sync.Mutex
+	items map[string][]chat.Notification
+}
+
+func newMemStore() *memStore { return &memStore{items: map[string][]chat.Notification{}} }
+
+func (m *memStore) Add(username string, n *chat.Notification) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n.ID = int64(len(m.items[username]) + 1)
+	n.CreatedAt = time.Now().Unix()
+	m.items[username] = append(m.items[username], *n)
+	return nil
+}
+
+func (m *memStore) List(username string, unreadOnly bool, before int64, limit int) ([]chat.Notification, error) {
+	return m.items[username], nil
+}
+func (m *memStore) MarkRead(username string, id int64) error   { return nil }
+func (m *memStore) MarkAllRead(username string) (int64, error) { return 0, nil }
+func (m *memStore) UnreadCount(username string) (int, error)   { return len(m.items[username]), nil }
+
+// mockClient — клиент, складывающий полученные сообщения в канал
+type mockClient struct {
+	name, room string
+	ch         chan chat.ChatMessage
+}
+
+func (m *mockClient) GetUsername() string                         { return m.name }
+func (m *mockClient) GetRoomName() string                         { return m.room }
+func (m *mockClient) SendMessage(msg chat.ChatMessage) error      { m.ch <- msg; return nil }
+func (m *mockClient) ReceivePrivateChan() <-chan chat.ChatMessage { return m.ch }
+func (m *mockClient) Close() error                                { return nil }
+func (m *mockClient) PrivateChan() chan chat.ChatMessage          { return m.ch }
+
+// Уведомление сохраняется и сразу приходит во все подключения пользователя
+func TestInbox_NotifyPushesFrame(t *testing.T) {
+	hub := chat.NewHub()
+	bob := &mockClient{name: "bob", room: "tech", ch: make(chan chat.ChatMessage, 16)}
+	hub.Clients[bob] = true
+
+	store := newMemStore()
+	inbox := &notify.Inbox{Store: store, Hub: hub}
+	assert.NoError(t, inbox.Notify("bob", chat.Notification{Kind: notify.KindModeration, Text: "вы получили предупреждение"}))
+
+	got := <-bob.ch
+	assert.Equal(t, "notification", got.Type)
+	if assert.NotNil(t, got.Notification) {
+		assert.Equal(t, int64(1), got.Notification.ID)
+		assert.Equal(t, notify.KindModeration, got.Notification.Kind)
+		assert.False(t, got.Notification.Read)
+	}
+	assert.Len(t, store.items["bob"], 1)
+}
+
+// Личное сообщение порождает уведомление, только если адресат не в сети
+func TestInbox_OfflineDM(t *testing.T) {
+	hub := chat.NewHub()
+	carol := &mockClient{name: "carol", room: "tech", ch: make(chan chat.ChatMessage, 16)}
+	hub.Clients[carol] = true
+
+	store := newMemStore()
+	inbox := &notify.Inbox{Store: store, Hub: hub}
+
+	inbox.MessageSent(chat.ChatMessage{ID: "m1", Type: "private", From: "alice", To: "bob", Text: "ты тут?"})
+	inbox.MessageSent(chat.ChatMessage{ID: "m2", Type: "private", From: "alice", To: "carol", Text: "привет"})
+	inbox.MessageSent(chat.ChatMessage{ID: "m3", Type: "message", From: "alice", Room: "tech", Text: "всем"})
+
+	if assert.Len(t, store.items["bob"], 1) {
+		n := store.items["bob"][0]
+		assert.Equal(t, notify.KindDM, n.Kind)
+		assert.Equal(t, "alice", n.From)
+		assert.Equal(t, "m1", n.MessageID)
+		assert.Equal(t, "ты тут?", n.Text)
+	}
+	assert.Empty(t, store.items["carol"])
+}
+
+// --- Store ---
+
+func TestStore_Add(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := notify.NewStore(db)
+
+	created := time.Unix(1700000000, 0)
+	mock.ExpectQuery(`INSERT INTO notifications`).
+		WithArgs("bob", "dm", "alice", "", "m1", "hi").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(7, created))
+
+	n := &chat.Notification{Kind: "dm", From: "alice", MessageID: "m1", Text: "hi"}
+	assert.NoError(t, store.Add("bob", n))
+	assert.Equal(t, int64(7), n.ID)
+	assert.Equal(t, created.Unix(), n.CreatedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStore_List(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := notify.NewStore(db)
+
+	mock.ExpectQuery(`FROM notifications`).
+		WithArgs("bob", int64(0), true, 50).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "kind", "actor", "room", "message_id", "text", "read", "created_at"}).
+			AddRow(3, "mention", "alice", "tech", "m3", "@bob", false, time.Now()))
+
+	list, err := store.List("bob", true, 0, 50)
+	assert.NoError(t, err)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, "tech", list[0].Room)
+		assert.False(t, list[0].Read)
+	}
+}
+
+// Чужое или несуществующее уведомление не отмечается
+func TestStore_MarkReadNotFound(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := notify.NewStore(db)
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE notifications SET read_at = COALESCE(read_at, NOW()) WHERE id=$1 AND username=$2`)).
+		WithArgs(int64(5), "bob").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.ErrorIs(t, store.MarkRead("bob", 5), notify.ErrNotFound)
+}
+
+func TestStore_MarkAllReadAndCount(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := notify.NewStore(db)
+
+	mock.ExpectExec(`UPDATE notifications SET read_at = NOW\(\) WHERE username=\$1 AND read_at IS NULL`).
+		WithArgs("bob").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM notifications`).
+		WithArgs("bob").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	n, err := store.MarkAllRead("bob")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	count, err := store.UnreadCount("bob")
+	assert.NoError(t, err)
+	assert.Zero(t, count)
+}
@@ -0,0 +1,405 @@
+// Package oidc реализует вход через OpenID Connect: authorization code flow
+// с PKCE (S256) и проверку ID-токена по JWKS провайдера.
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrUnknownState — вход не начинался, уже завершён или истёк
+var ErrUnknownState = errors.New("unknown or expired login state")
+
+// Config — параметры клиента OIDC
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string // пусто — публичный клиент, защищённый только PKCE
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Identity — проверенные данные пользователя из ID-токена
+type Identity struct {
+	Issuer            string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+	Name              string
+}
+
+// Flow — начатый вход, ожидающий возврата пользователя от провайдера
+type Flow struct {
+	State    string
+	Nonce    string
+	Verifier string // PKCE code_verifier
+	LinkTo   string // не пусто — привязать внешний аккаунт к этому пользователю
+	expires  time.Time
+}
+
+// metadata — нужная часть /.well-known/openid-configuration
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider — клиент одного провайдера OIDC. Метаданные и ключи загружаются
+// при первом входе и кэшируются; при неизвестном kid ключи перечитываются.
+type Provider struct {
+	Config  Config
+	Client  *http.Client
+	FlowTTL time.Duration // сколько ждать возврата пользователя от провайдера
+
+	mu          sync.Mutex
+	meta        *metadata
+	keys        map[string]any
+	keysFetched time.Time
+	flows       map[string]Flow
+}
+
+// keysRefreshInterval — не чаще этого ключи перечитываются из-за неизвестного kid
+const keysRefreshInterval = time.Minute
+
+func New(cfg Config) *Provider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "profile", "email"}
+	}
+	return &Provider{
+		Config:  cfg,
+		Client:  &http.Client{Timeout: 10 * time.Second},
+		FlowTTL: 10 * time.Minute,
+		flows:   make(map[string]Flow),
+	}
+}
+
+// Begin начинает вход и возвращает его вместе с адресом страницы входа провайдера
+func (p *Provider) Begin(ctx context.Context, linkTo string) (Flow, string, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return Flow{}, "", err
+	}
+
+	flow := Flow{LinkTo: linkTo, expires: time.Now().Add(p.FlowTTL)}
+	for _, v := range []*string{&flow.State, &flow.Nonce, &flow.Verifier} {
+		if *v, err = randomString(); err != nil {
+			return Flow{}, "", err
+		}
+	}
+
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.Config.ClientID},
+		"redirect_uri":          {p.Config.RedirectURL},
+		"scope":                 {strings.Join(p.Config.Scopes, " ")},
+		"state":                 {flow.State},
+		"nonce":                 {flow.Nonce},
+		"code_challenge":        {Challenge(flow.Verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+
+	p.mu.Lock()
+	now := time.Now()
+	for k, f := range p.flows {
+		if now.After(f.expires) {
+			delete(p.flows, k)
+		}
+	}
+	p.flows[flow.State] = flow
+	p.mu.Unlock()
+
+	return flow, meta.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Finish завершает вход: погашает state, обменивает code на токены
+// и проверяет ID-токен
+func (p *Provider) Finish(ctx context.Context, state, code string) (Flow, Identity, error) {
+	p.mu.Lock()
+	flow, ok := p.flows[state]
+	delete(p.flows, state)
+	p.mu.Unlock()
+	if !ok || time.Now().After(flow.expires) {
+		return Flow{}, Identity{}, ErrUnknownState
+	}
+
+	rawIDToken, err := p.exchange(ctx, code, flow.Verifier)
+	if err != nil {
+		return flow, Identity{}, err
+	}
+	id, err := p.verify(ctx, rawIDToken, flow.Nonce)
+	return flow, id, err
+}
+
+// exchange обменивает code на ID-токен (token endpoint)
+func (p *Provider) exchange(ctx context.Context, code, verifier string) (string, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.Config.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	if p.Config.ClientSecret == "" {
+		form.Set("client_id", p.Config.ClientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.Config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.Config.ClientID), url.QueryEscape(p.Config.ClientSecret))
+	}
+
+	var resp struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.doJSON(req, &resp)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	if status != http.StatusOK || resp.IDToken == "" {
+		if resp.Error != "" {
+			return "", fmt.Errorf("token request failed: %s %s", resp.Error, resp.ErrorDescription)
+		}
+		return "", fmt.Errorf("token request failed: status %d", status)
+	}
+	return resp.IDToken, nil
+}
+
+// verify проверяет подпись, издателя, аудиторию, срок и nonce ID-токена
+func (p *Provider) verify(ctx context.Context, raw, nonce string) (Identity, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	var claims struct {
+		jwt.RegisteredClaims
+		Nonce             string `json:"nonce"`
+		AuthorizedParty   string `json:"azp"`
+		Email             string `json:"email"`
+		EmailVerified     any    `json:"email_verified"` // у некоторых провайдеров — строка "true"
+		PreferredUsername string `json:"preferred_username"`
+		Name              string `json:"name"`
+	}
+	_, err = jwt.ParseWithClaims(raw, &claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "EdDSA"}),
+		jwt.WithIssuer(meta.Issuer),
+		jwt.WithAudience(p.Config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return Identity{}, fmt.Errorf("invalid id token: %w", err)
+	}
+	if claims.Nonce != nonce {
+		return Identity{}, errors.New("invalid id token: nonce mismatch")
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.Config.ClientID {
+		return Identity{}, errors.New("invalid id token: azp mismatch")
+	}
+	if claims.Subject == "" {
+		return Identity{}, errors.New("invalid id token: missing subject")
+	}
+
+	verified := claims.EmailVerified == true || claims.EmailVerified == "true"
+	return Identity{
+		Issuer:            meta.Issuer,
+		Subject:           claims.Subject,
+		Email:             claims.Email,
+		EmailVerified:     verified,
+		PreferredUsername: claims.PreferredUsername,
+		Name:              claims.Name,
+	}, nil
+}
+
+// metadata загружает документ discovery провайдера
+func (p *Provider) metadata(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	meta := p.meta
+	p.mu.Unlock()
+	if meta != nil {
+		return meta, nil
+	}
+
+	issuer := strings.TrimSuffix(p.Config.Issuer, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	meta = &metadata{}
+	status, err := p.doJSON(req, meta)
+	if err != nil || status != http.StatusOK {
+		return nil, fmt.Errorf("oidc discovery failed: status %d: %v", status, err)
+	}
+	if strings.TrimSuffix(meta.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer %q does not match %q", meta.Issuer, p.Config.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, errors.New("oidc discovery: incomplete provider metadata")
+	}
+
+	p.mu.Lock()
+	p.meta = meta
+	p.mu.Unlock()
+	return meta, nil
+}
+
+// key возвращает открытый ключ провайдера по kid, при необходимости перечитывая JWKS
+func (p *Provider) key(ctx context.Context, kid string) (any, error) {
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	stale := time.Since(p.keysFetched) > keysRefreshInterval
+	p.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+	if !stale && p.keys != nil {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, meta.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	status, err := p.doJSON(req, &set)
+	if err != nil || status != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch jwks: status %d: %v", status, err)
+	}
+
+	keys := make(map[string]any, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if public, err := k.publicKey(); err == nil {
+			keys[k.Kid] = public
+		}
+	}
+	p.mu.Lock()
+	p.keys = keys
+	p.keysFetched = time.Now()
+	p.mu.Unlock()
+
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+func (p *Provider) doJSON(req *http.Request, v any) (int, error) {
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v); err != nil {
+		return resp.StatusCode, err
+	}
+	return resp.StatusCode, nil
+}
+
+// jwk — открытый ключ из JWKS провайдера
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (any, error) {
+	b64 := base64.RawURLEncoding
+	switch k.Kty {
+	case "RSA":
+		n, err1 := b64.DecodeString(k.N)
+		e, err2 := b64.DecodeString(k.E)
+		if err1 != nil || err2 != nil || len(e) > 4 {
+			return nil, errors.New("invalid RSA key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err1 := b64.DecodeString(k.X)
+		y, err2 := b64.DecodeString(k.Y)
+		if err1 != nil || err2 != nil {
+			return nil, errors.New("invalid EC key")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		x, err := b64.DecodeString(k.X)
+		if err != nil || k.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid OKP key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// Challenge вычисляет PKCE code_challenge (S256) для verifier
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random value: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,160 @@
+package oidc_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-portfolio/websocket-chat/internal/oidc"
+	"github.com/go-portfolio/websocket-chat/internal/oidc/oidctest"
+)
+
+const redirectURL = "http://chat.test/api/oidc/callback"
+
+func newProvider(t *testing.T) (*oidctest.Provider, *oidc.Provider) {
+	mock := oidctest.New("chat")
+	t.Cleanup(mock.Close)
+	return mock, oidc.New(oidc.Config{Issuer: mock.Issuer(), ClientID: "chat", RedirectURL: redirectURL})
+}
+
+// authorize проходит страницу входа провайдера и возвращает параметры возврата
+func authorize(t *testing.T, authURL string) url.Values {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	return location.Query()
+}
+
+func TestProvider_Flow(t *testing.T) {
+	mock, p := newProvider(t)
+	ctx := context.Background()
+
+	flow, authURL, err := p.Begin(ctx, "")
+	require.NoError(t, err)
+	q, _ := url.Parse(authURL)
+	assert.Equal(t, oidc.Challenge(flow.Verifier), q.Query().Get("code_challenge"))
+	assert.Equal(t, "S256", q.Query().Get("code_challenge_method"))
+	assert.Equal(t, "openid profile email", q.Query().Get("scope"))
+
+	back := authorize(t, authURL)
+	assert.Equal(t, flow.State, back.Get("state"))
+
+	_, id, err := p.Finish(ctx, back.Get("state"), back.Get("code"))
+	require.NoError(t, err)
+	assert.Equal(t, oidc.Identity{
+		Issuer:            mock.Issuer(),
+		Subject:           "user-1",
+		Email:             "alice@example.com",
+		EmailVerified:     true,
+		PreferredUsername: "alice",
+		Name:              "Alice",
+	}, id)
+
+	// state одноразовый
+	_, _, err = p.Finish(ctx, back.Get("state"), back.Get("code"))
+	assert.ErrorIs(t, err, oidc.ErrUnknownState)
+}
+
+func TestProvider_LinkTo(t *testing.T) {
+	_, p := newProvider(t)
+	flow, authURL, err := p.Begin(context.Background(), "bob")
+	require.NoError(t, err)
+	back := authorize(t, authURL)
+
+	got, _, err := p.Finish(context.Background(), back.Get("state"), back.Get("code"))
+	require.NoError(t, err)
+	assert.Equal(t, "bob", got.LinkTo)
+	assert.Equal(t, flow.State, got.State)
+}
+
+func TestProvider_UnknownState(t *testing.T) {
+	_, p := newProvider(t)
+	_, _, err := p.Finish(context.Background(), "forged", "code")
+	assert.ErrorIs(t, err, oidc.ErrUnknownState)
+}
+
+func TestProvider_ExpiredFlow(t *testing.T) {
+	_, p := newProvider(t)
+	p.FlowTTL = -time.Second
+	_, authURL, err := p.Begin(context.Background(), "")
+	require.NoError(t, err)
+	back := authorize(t, authURL)
+
+	_, _, err = p.Finish(context.Background(), back.Get("state"), back.Get("code"))
+	assert.ErrorIs(t, err, oidc.ErrUnknownState)
+}
+
+// Провайдер отвергает обмен кода, если code_verifier не совпадает с challenge
+func TestProvider_CodeFromAnotherFlow(t *testing.T) {
+	_, p := newProvider(t)
+	ctx := context.Background()
+	_, first, err := p.Begin(ctx, "")
+	require.NoError(t, err)
+	_, second, err := p.Begin(ctx, "")
+	require.NoError(t, err)
+
+	a, b := authorize(t, first), authorize(t, second)
+	_, _, err = p.Finish(ctx, a.Get("state"), b.Get("code"))
+	assert.ErrorContains(t, err, "invalid_grant")
+}
+
+func TestProvider_RejectsInvalidIDToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(jwt.MapClaims)
+		want   string
+	}{
+		{"nonce", func(c jwt.MapClaims) { c["nonce"] = "other" }, "nonce mismatch"},
+		{"audience", func(c jwt.MapClaims) { c["aud"] = "another-client" }, "audience"},
+		{"issuer", func(c jwt.MapClaims) { c["iss"] = "https://evil.test" }, "issuer"},
+		{"expired", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, "expired"},
+		{"azp", func(c jwt.MapClaims) { c["aud"] = []string{"chat", "other"} }, "azp mismatch"},
+		{"subject", func(c jwt.MapClaims) { delete(c, "sub") }, "missing subject"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock, p := newProvider(t)
+			mock.Tamper = tt.tamper
+			_, authURL, err := p.Begin(context.Background(), "")
+			require.NoError(t, err)
+			back := authorize(t, authURL)
+
+			_, _, err = p.Finish(context.Background(), back.Get("state"), back.Get("code"))
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
+
+// Подпись ключом, которого нет в JWKS провайдера, не принимается
+func TestProvider_UnknownKey(t *testing.T) {
+	mock, p := newProvider(t)
+	_, authURL, err := p.Begin(context.Background(), "")
+	require.NoError(t, err)
+	back := authorize(t, authURL)
+	mock.TokenKeyID = "rotated"
+
+	_, _, err = p.Finish(context.Background(), back.Get("state"), back.Get("code"))
+	assert.ErrorContains(t, err, "unknown key")
+}
+
+func TestProvider_DiscoveryIssuerMismatch(t *testing.T) {
+	mock := oidctest.New("chat")
+	defer mock.Close()
+	p := oidc.New(oidc.Config{Issuer: mock.Issuer() + "/tenant", ClientID: "chat", RedirectURL: redirectURL})
+
+	_, _, err := p.Begin(context.Background(), "")
+	assert.Error(t, err)
+}
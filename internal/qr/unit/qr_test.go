@@ -0,0 +1,141 @@
+package unit
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/go-portfolio/websocket-chat/internal/qr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Ёмкость байтового режима по таблице 7 стандарта
+func TestCapacity(t *testing.T) {
+	tests := []struct {
+		version int
+		level   qr.Level
+		want    int
+	}{
+		{1, qr.Low, 17}, {1, qr.Medium, 14}, {1, qr.Quartile, 11}, {1, qr.High, 7},
+		{7, qr.Medium, 122}, {10, qr.Low, 271}, {10, qr.Medium, 213}, {10, qr.High, 119},
+		{25, qr.Quartile, 715}, {40, qr.Low, 2953}, {40, qr.Medium, 2331}, {40, qr.High, 1273},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, qr.Capacity(tt.version, tt.level), "version %d level %d", tt.version, tt.level)
+	}
+}
+
+func TestEncode_Version(t *testing.T) {
+	code, err := qr.Encode([]byte(strings.Repeat("a", 14)), qr.Medium)
+	require.NoError(t, err)
+	assert.Equal(t, 1, code.Version)
+	assert.Equal(t, 21, code.Size)
+
+	code, err = qr.Encode([]byte(strings.Repeat("a", 15)), qr.Medium)
+	require.NoError(t, err)
+	assert.Equal(t, 2, code.Version)
+
+	_, err = qr.Encode(make([]byte, 2332), qr.Medium)
+	assert.ErrorIs(t, err, qr.ErrTooLong)
+}
+
+// Поисковые узоры в трёх углах и разделители вокруг них
+func TestEncode_FinderPatterns(t *testing.T) {
+	code, err := qr.Encode([]byte("otpauth://totp/Chat:alice?secret=JBSWY3DPEHPK3PXP"), qr.Medium)
+	require.NoError(t, err)
+
+	for _, corner := range [][2]int{{0, 0}, {code.Size - 7, 0}, {0, code.Size - 7}} {
+		for dy := -1; dy <= 7; dy++ {
+			for dx := -1; dx <= 7; dx++ {
+				x, y := corner[0]+dx, corner[1]+dy
+				if x < 0 || y < 0 || x >= code.Size || y >= code.Size {
+					continue
+				}
+				ring := max(abs(dx-3), abs(dy-3))
+				assert.Equal(t, ring != 2 && ring != 4, code.Module(x, y), "module (%d, %d)", x, y)
+			}
+		}
+	}
+}
+
+// Биты формата для уровня M (таблица C.1 стандарта)
+func TestEncode_FormatBits(t *testing.T) {
+	formats := [8]int{0x5412, 0x5125, 0x5E7C, 0x5B4B, 0x45F9, 0x40CE, 0x4F97, 0x4AA0}
+	code, err := qr.Encode([]byte("hello"), qr.Medium)
+	require.NoError(t, err)
+
+	read := func(at func(i int) (int, int)) int {
+		bits := 0
+		for i := 0; i < 15; i++ {
+			if x, y := at(i); code.Module(x, y) {
+				bits |= 1 << i
+			}
+		}
+		return bits
+	}
+	second := read(func(i int) (int, int) {
+		if i < 8 {
+			return code.Size - 1 - i, 8
+		}
+		return 8, code.Size - 15 + i
+	})
+	first := read(func(i int) (int, int) {
+		switch {
+		case i <= 5:
+			return 8, i
+		case i == 6:
+			return 8, 7
+		case i == 7:
+			return 8, 8
+		case i == 8:
+			return 7, 8
+		default:
+			return 14 - i, 8
+		}
+	})
+	assert.Equal(t, formats[code.Mask], second)
+	assert.Equal(t, formats[code.Mask], first)
+	assert.True(t, code.Module(8, code.Size-8), "тёмный модуль")
+}
+
+// Информация о версии для версии 7 (таблица D.1 стандарта)
+func TestEncode_VersionInfo(t *testing.T) {
+	code, err := qr.Encode(make([]byte, 110), qr.Medium)
+	require.NoError(t, err)
+	require.Equal(t, 7, code.Version)
+
+	bits := 0
+	for i := 0; i < 18; i++ {
+		if code.Module(code.Size-11+i%3, i/3) {
+			bits |= 1 << i
+		}
+	}
+	assert.Equal(t, 0x07C94, bits)
+}
+
+func TestCode_PNG(t *testing.T) {
+	code, err := qr.Encode([]byte("hello"), qr.Low)
+	require.NoError(t, err)
+	data, err := code.PNG(4)
+	require.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	side := (code.Size + 8) * 4
+	assert.Equal(t, side, img.Bounds().Dx())
+
+	// Рамка светлая, левый верхний модуль поискового узора тёмный
+	r, _, _, _ := img.At(0, 0).RGBA()
+	assert.Equal(t, uint32(0xFFFF), r)
+	r, _, _, _ = img.At(16, 16).RGBA()
+	assert.Equal(t, uint32(0), r)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
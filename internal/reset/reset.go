@@ -0,0 +1,75 @@
+// Package reset доставляет пользователям ссылки для сброса пароля и подтверждения e-mail
+package reset
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/mail"
+)
+
+// Kind — назначение ссылки
+type Kind int
+
+const (
+	KindReset       Kind = iota // сброс пароля
+	KindVerifyEmail             // подтверждение e-mail
+)
+
+// Notice — ссылка сброса пароля или подтверждения e-mail для пользователя
+type Notice struct {
+	Kind      Kind
+	Username  string
+	Email     string
+	Link      string // содержит токен
+	ExpiresAt time.Time
+}
+
+// Sender доставляет ссылку (письмом, в лог, во внешний сервис)
+type Sender interface {
+	Send(n Notice) error
+}
+
+// MailSender отправляет ссылку письмом на e-mail пользователя
+type MailSender struct {
+	Mailer mail.Mailer
+}
+
+var _ Sender = (*MailSender)(nil)
+
+func (s *MailSender) Send(n Notice) error {
+	return s.Mailer.Send(Compose(n))
+}
+
+// Compose формирует письмо со ссылкой
+func Compose(n Notice) mail.Message {
+	if n.Kind == KindVerifyEmail {
+		return composeVerification(n)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Здравствуйте, %s!\n\n", n.Username)
+	b.WriteString("Кто-то запросил сброс пароля для вашего аккаунта в чате.\n")
+	b.WriteString("Чтобы задать новый пароль, откройте ссылку:\n\n")
+	fmt.Fprintf(&b, "%s\n\n", n.Link)
+	fmt.Fprintf(&b, "Ссылка действует до %s и только один раз.\n", n.ExpiresAt.Format("02.01.2006 15:04 MST"))
+	b.WriteString("Если вы не запрашивали сброс, просто проигнорируйте письмо — пароль останется прежним.\n")
+	return mail.Message{To: n.Email, Subject: "Сброс пароля", Body: b.String()}
+}
+
+func composeVerification(n Notice) mail.Message {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Здравствуйте, %s!\n\n", n.Username)
+	b.WriteString("Этот адрес указан в профиле вашего аккаунта в чате.\n")
+	b.WriteString("Чтобы подтвердить его, откройте ссылку:\n\n")
+	fmt.Fprintf(&b, "%s\n\n", n.Link)
+	fmt.Fprintf(&b, "Ссылка действует до %s.\n", n.ExpiresAt.Format("02.01.2006 15:04 MST"))
+	b.WriteString("Ссылки сброса пароля отправляются только на подтверждённый адрес.\n")
+	b.WriteString("Если вы не указывали этот адрес, просто проигнорируйте письмо.\n")
+	return mail.Message{To: n.Email, Subject: "Подтверждение e-mail", Body: b.String()}
+}
+
+// Func позволяет использовать функцию как Sender
+type Func func(n Notice) error
+
+func (f Func) Send(n Notice) error { return f(n) }
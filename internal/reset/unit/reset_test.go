@@ -0,0 +1,67 @@
+package reset_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/mail"
+	"github.com/go-portfolio/websocket-chat/internal/reset"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type captureMailer struct{ sent []mail.Message }
+
+func (m *captureMailer) Send(msg mail.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func TestMailSender(t *testing.T) {
+	mailer := &captureMailer{}
+	sender := &reset.MailSender{Mailer: mailer}
+
+	expires := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	err := sender.Send(reset.Notice{
+		Username:  "alice",
+		Email:     "alice@example.com",
+		Link:      "https://chat.example.com/#reset=abc",
+		ExpiresAt: expires,
+	})
+	require.NoError(t, err)
+	require.Len(t, mailer.sent, 1)
+
+	msg := mailer.sent[0]
+	assert.Equal(t, "alice@example.com", msg.To)
+	assert.Equal(t, "Сброс пароля", msg.Subject)
+	assert.Contains(t, msg.Body, "alice")
+	assert.Contains(t, msg.Body, "https://chat.example.com/#reset=abc")
+	assert.Contains(t, msg.Body, "01.03.2026 12:30 UTC")
+}
+
+func TestMailSender_Verification(t *testing.T) {
+	mailer := &captureMailer{}
+	sender := &reset.MailSender{Mailer: mailer}
+
+	err := sender.Send(reset.Notice{
+		Kind:      reset.KindVerifyEmail,
+		Username:  "alice",
+		Email:     "alice@example.com",
+		Link:      "https://chat.example.com/#verify=abc",
+		ExpiresAt: time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC),
+	})
+	require.NoError(t, err)
+	require.Len(t, mailer.sent, 1)
+	assert.Equal(t, "Подтверждение e-mail", mailer.sent[0].Subject)
+	assert.Contains(t, mailer.sent[0].Body, "https://chat.example.com/#verify=abc")
+}
+
+func TestFunc(t *testing.T) {
+	var got reset.Notice
+	var sender reset.Sender = reset.Func(func(n reset.Notice) error {
+		got = n
+		return nil
+	})
+	require.NoError(t, sender.Send(reset.Notice{Username: "bob"}))
+	assert.Equal(t, "bob", got.Username)
+}
@@ -0,0 +1,262 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/attachment"
+	"github.com/go-portfolio/websocket-chat/internal/message"
+	"github.com/go-portfolio/websocket-chat/internal/storage"
+)
+
+// Policy ограничивает историю комнаты; нулевые поля — без ограничения
+type Policy struct {
+	MaxAge   time.Duration
+	MaxCount int
+}
+
+// IsZero сообщает, что политика ничего не удаляет
+func (p Policy) IsZero() bool {
+	return p.MaxAge <= 0 && p.MaxCount <= 0
+}
+
+// ParseRules разбирает правила комнат вида "legal=720h,flood=/1000,ops=168h/5000,archive=".
+// Правило комнаты целиком заменяет глобальное: "archive=" хранит всё.
+func ParseRules(v string) (map[string]Policy, error) {
+	rules := make(map[string]Policy)
+	for _, item := range strings.Split(v, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		room, spec, ok := strings.Cut(item, "=")
+		room = strings.TrimSpace(room)
+		if !ok || room == "" {
+			return nil, fmt.Errorf("retention rule %q: expected room=age/count", item)
+		}
+
+		var p Policy
+		age, count, _ := strings.Cut(strings.TrimSpace(spec), "/")
+		if age != "" {
+			d, err := time.ParseDuration(age)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("retention rule %q: invalid age", item)
+			}
+			p.MaxAge = d
+		}
+		if count != "" {
+			n, err := strconv.Atoi(count)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("retention rule %q: invalid count", item)
+			}
+			p.MaxCount = n
+		}
+		rules[room] = p
+	}
+	return rules, nil
+}
+
+// MessageStore — удаление истории (реализуется message.Store)
+type MessageStore interface {
+	Rooms() ([]string, error)
+	DeleteBefore(room string, before time.Time, limit int) (message.Purged, error)
+	DeleteBeyond(room string, keep, limit int) (message.Purged, error)
+}
+
+// AttachmentStore — удаление вложений, на которые больше нет ссылок (реализуется attachment.Store).
+// Файлы удаляются через remove, пока удаление вложения не завершено; ключи файлов,
+// которые ещё нужны другим вложениям, не удаляются и возвращаются пустыми
+type AttachmentStore interface {
+	DeleteUnused(id string, remove func(key string) error) (*attachment.Attachment, error)
+	DeleteUnsent(before time.Time, limit int, remove func(key string) error) ([]*attachment.Attachment, error)
+}
+
+// History — история комнат в памяти сервера, которую получают вошедшие (реализуется chat.Hub)
+type History interface {
+	PruneHistory(room string, ids []string, before time.Time) int
+}
+
+// Purger периодически удаляет сообщения, вышедшие за пределы политики хранения,
+// вместе с их вложениями и копиями текста: упоминания и уведомления удаляет MessageStore,
+// историю в памяти — History. Удаление идёт пачками по BatchSize, чтобы не держать
+// долгие блокировки на больших комнатах.
+type Purger struct {
+	Messages    MessageStore
+	Attachments AttachmentStore   // nil — вложения не удаляются
+	Blobs       storage.BlobStore // файлы удалённых вложений
+	History     History           // nil — истории в памяти нет
+	Global      Policy            // для комнат без своего правила и для личных сообщений
+	Rooms       map[string]Policy // правила комнат
+	Unsent      time.Duration     // сколько хранить загруженные, но не отправленные вложения; 0 — бессрочно
+	BatchSize   int
+}
+
+// Run запускает очистку каждые interval до отмены ctx
+func (p *Purger) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := p.RunOnce(now); err != nil {
+				log.Printf("retention: %v", err)
+			}
+		}
+	}
+}
+
+// RunOnce удаляет всё устаревшее на момент now и возвращает число удалённых сообщений.
+// Ошибка в одной комнате не мешает остальным.
+func (p *Purger) RunOnce(now time.Time) (int, error) {
+	rooms, err := p.Messages.Rooms()
+	if err != nil {
+		return 0, err
+	}
+	// Комнаты с правилами проверяются, даже если сообщений в них пока нет
+	for room := range p.Rooms {
+		if !slices.Contains(rooms, room) {
+			rooms = append(rooms, room)
+		}
+	}
+
+	total := 0
+	for _, room := range rooms {
+		total += p.purgeRoom(room, p.policy(room), now)
+	}
+	// Для личных сообщений действует только срок: лимит по количеству
+	// относится к комнате, а не ко всем перепискам сразу
+	total += p.purgeRoom("", Policy{MaxAge: p.Global.MaxAge}, now)
+	p.purgeUnsent(now)
+	return total, nil
+}
+
+func (p *Purger) policy(room string) Policy {
+	if rule, ok := p.Rooms[room]; ok {
+		return rule
+	}
+	return p.Global
+}
+
+// purgeRoom удаляет сообщения комнаты по политике и пишет в лог, что удалено
+func (p *Purger) purgeRoom(room string, policy Policy, now time.Time) int {
+	if policy.IsZero() {
+		return 0
+	}
+
+	var (
+		removed message.Purged
+		before  time.Time
+	)
+	if policy.MaxAge > 0 {
+		before = now.Add(-policy.MaxAge)
+		p.drain(room, &removed, func(limit int) (message.Purged, error) {
+			return p.Messages.DeleteBefore(room, before, limit)
+		})
+	}
+	if policy.MaxCount > 0 {
+		p.drain(room, &removed, func(limit int) (message.Purged, error) {
+			return p.Messages.DeleteBeyond(room, policy.MaxCount, limit)
+		})
+	}
+	// В памяти могут быть и сообщения, которых нет в базе (например, системные)
+	if p.History != nil && room != "" {
+		p.History.PruneHistory(room, removed.IDs, before)
+	}
+	if removed.Messages == 0 {
+		return 0
+	}
+
+	files := p.deleteAttachments(removed.Attachments)
+	log.Printf("retention: %s: removed %d message(s) and %d attachment(s)", label(room), removed.Messages, files)
+	return removed.Messages
+}
+
+// drain повторяет удаление пачками, пока очередная пачка не окажется неполной
+func (p *Purger) drain(room string, removed *message.Purged, batch func(limit int) (message.Purged, error)) {
+	limit := p.batchSize()
+	for {
+		res, err := batch(limit)
+		if err != nil {
+			log.Printf("retention: %s: %v", label(room), err)
+			return
+		}
+		removed.Messages += res.Messages
+		removed.IDs = append(removed.IDs, res.IDs...)
+		removed.Attachments = append(removed.Attachments, res.Attachments...)
+		if res.Messages < limit {
+			return
+		}
+	}
+}
+
+// deleteAttachments удаляет вложения, на которые больше не ссылаются сообщения, и их файлы
+func (p *Purger) deleteAttachments(ids []string) int {
+	if p.Attachments == nil {
+		return 0
+	}
+	deleted := 0
+	for _, id := range ids {
+		if _, err := p.Attachments.DeleteUnused(id, p.removeBlob); err != nil {
+			if !errors.Is(err, attachment.ErrNotFound) {
+				log.Printf("retention: attachment %s: %v", id, err)
+			}
+			continue
+		}
+		deleted++
+	}
+	return deleted
+}
+
+// purgeUnsent удаляет вложения, которые загрузили, но так и не отправили в чат:
+// на них не ссылается ни одно сообщение, и очистка истории до них не доходит
+func (p *Purger) purgeUnsent(now time.Time) {
+	if p.Attachments == nil || p.Unsent <= 0 {
+		return
+	}
+	limit := p.batchSize()
+	deleted := 0
+	for {
+		batch, err := p.Attachments.DeleteUnsent(now.Add(-p.Unsent), limit, p.removeBlob)
+		if err != nil {
+			log.Printf("retention: unsent attachments: %v", err)
+			break
+		}
+		deleted += len(batch)
+		if len(batch) < limit {
+			break
+		}
+	}
+	if deleted > 0 {
+		log.Printf("retention: removed %d unsent attachment(s)", deleted)
+	}
+}
+
+// removeBlob удаляет файл вложения из хранилища
+func (p *Purger) removeBlob(key string) error {
+	if p.Blobs == nil {
+		return nil
+	}
+	return p.Blobs.Delete(key)
+}
+
+func (p *Purger) batchSize() int {
+	if p.BatchSize <= 0 {
+		return 500
+	}
+	return p.BatchSize
+}
+
+func label(room string) string {
+	if room == "" {
+		return "direct messages"
+	}
+	return "room " + strconv.Quote(room)
+}
@@ -0,0 +1,326 @@
+package unit
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/attachment"
+	"github.com/go-portfolio/websocket-chat/internal/message"
+	"github.com/go-portfolio/websocket-chat/internal/retention"
+	"github.com/go-portfolio/websocket-chat/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRules(t *testing.T) {
+	rules, err := retention.ParseRules("legal=720h, flood=/1000,ops=168h/5000,archive=")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]retention.Policy{
+		"legal":   {MaxAge: 720 * time.Hour},
+		"flood":   {MaxCount: 1000},
+		"ops":     {MaxAge: 168 * time.Hour, MaxCount: 5000},
+		"archive": {},
+	}, rules)
+
+	for _, bad := range []string{"legal", "=1h", "legal=month", "flood=/-1", "ops=1h/many"} {
+		_, err := retention.ParseRules(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+// fakeMessages — сообщения в памяти, упорядоченные от старых к новым
+type fakeMessages struct {
+	msgs    []message.Message
+	batches int
+}
+
+func (f *fakeMessages) Rooms() ([]string, error) {
+	set := map[string]bool{}
+	for _, m := range f.msgs {
+		if m.Room != "" {
+			set[m.Room] = true
+		}
+	}
+	rooms := []string{}
+	for r := range set {
+		rooms = append(rooms, r)
+	}
+	sort.Strings(rooms)
+	return rooms, nil
+}
+
+func (f *fakeMessages) DeleteBefore(room string, before time.Time, limit int) (message.Purged, error) {
+	return f.delete(limit, func(m message.Message) bool {
+		return m.Room == room && m.CreatedAt.Before(before)
+	}), nil
+}
+
+func (f *fakeMessages) DeleteBeyond(room string, keep, limit int) (message.Purged, error) {
+	count := 0
+	for _, m := range f.msgs {
+		if m.Room == room {
+			count++
+		}
+	}
+	excess := count - keep
+	return f.delete(limit, func(m message.Message) bool {
+		if m.Room != room || excess <= 0 {
+			return false
+		}
+		excess--
+		return true
+	}), nil
+}
+
+func (f *fakeMessages) delete(limit int, match func(message.Message) bool) message.Purged {
+	f.batches++
+	var (
+		p    message.Purged
+		kept []message.Message
+	)
+	for _, m := range f.msgs {
+		if p.Messages < limit && match(m) {
+			p.Messages++
+			p.IDs = append(p.IDs, m.ID)
+			if m.AttachmentID != "" {
+				p.Attachments = append(p.Attachments, m.AttachmentID)
+			}
+			continue
+		}
+		kept = append(kept, m)
+	}
+	f.msgs = kept
+	return p
+}
+
+func (f *fakeMessages) ids() []string {
+	ids := []string{}
+	for _, m := range f.msgs {
+		ids = append(ids, m.ID)
+	}
+	return ids
+}
+
+type fakeAttachments struct {
+	items map[string]*attachment.Attachment
+}
+
+// DeleteUnused, как и attachment.Store, не удаляет файлы, которые ещё есть у других вложений
+func (f *fakeAttachments) DeleteUnused(id string, remove func(key string) error) (*attachment.Attachment, error) {
+	a, ok := f.items[id]
+	if !ok {
+		return nil, attachment.ErrNotFound
+	}
+	delete(f.items, id)
+	cp := *a
+	for _, o := range f.items {
+		if o.BlobKey == cp.BlobKey || o.ThumbKey == cp.BlobKey {
+			cp.BlobKey = ""
+		}
+		if o.BlobKey == cp.ThumbKey || o.ThumbKey == cp.ThumbKey {
+			cp.ThumbKey = ""
+		}
+	}
+	for _, key := range []string{cp.BlobKey, cp.ThumbKey} {
+		if key == "" {
+			continue
+		}
+		if err := remove(key); err != nil {
+			f.items[id] = a
+			return nil, err
+		}
+	}
+	return &cp, nil
+}
+
+func (f *fakeAttachments) DeleteUnsent(before time.Time, limit int, remove func(key string) error) ([]*attachment.Attachment, error) {
+	var deleted []*attachment.Attachment
+	for id, a := range f.items {
+		if len(deleted) == limit {
+			break
+		}
+		if a.Room != "" || a.Peer != "" || !a.CreatedAt.Before(before) {
+			continue
+		}
+		d, err := f.DeleteUnused(id, remove)
+		if err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, d)
+	}
+	return deleted, nil
+}
+
+func TestPurger_RunOnce(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	msgs := &fakeMessages{}
+	add := func(id, room, to string, age time.Duration, attachmentID string) {
+		msgs.msgs = append(msgs.msgs, message.Message{ID: id, Room: room, To: to, CreatedAt: now.Add(-age), AttachmentID: attachmentID})
+	}
+	// legal: хранить 30 дней
+	add("l1", "legal", "", 40*day, "a1")
+	add("l2", "legal", "", 31*day, "")
+	add("l3", "legal", "", 2*day, "")
+	// general: глобальная политика — не больше 2 сообщений и не старше 90 дней
+	add("g1", "general", "", 100*day, "")
+	add("g2", "general", "", 3*day, "")
+	add("g3", "general", "", 2*day, "")
+	add("g4", "general", "", day, "")
+	// archive: хранить всё
+	add("x1", "archive", "", 400*day, "")
+	// личные сообщения: только срок
+	add("d1", "", "bob", 100*day, "")
+	add("d2", "", "bob", day, "")
+
+	blobs := storage.NewMemoryStore()
+	_ = blobs.Put("attachments/a1", []byte("data"), "text/plain")
+	attachments := &fakeAttachments{items: map[string]*attachment.Attachment{
+		"a1": {ID: "a1", BlobKey: "attachments/a1"},
+	}}
+
+	p := &retention.Purger{
+		Messages:    msgs,
+		Attachments: attachments,
+		Blobs:       blobs,
+		Global:      retention.Policy{MaxAge: 90 * day, MaxCount: 2},
+		Rooms: map[string]retention.Policy{
+			"legal":   {MaxAge: 30 * day},
+			"archive": {},
+		},
+		BatchSize: 1,
+	}
+
+	removed, err := p.RunOnce(now)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, removed)
+	assert.ElementsMatch(t, []string{"l3", "g3", "g4", "x1", "d2"}, msgs.ids())
+	assert.Empty(t, attachments.items)
+	_, _, err = blobs.Open("attachments/a1")
+	assert.Error(t, err)
+
+	// При BatchSize=1 каждое сообщение удаляется отдельным запросом
+	assert.Greater(t, msgs.batches, removed)
+}
+
+// fakeHistory запоминает, что убрано из истории в памяти
+type fakeHistory struct {
+	ids    map[string][]string
+	before map[string]time.Time
+}
+
+func (f *fakeHistory) PruneHistory(room string, ids []string, before time.Time) int {
+	f.ids[room] = append(f.ids[room], ids...)
+	f.before[room] = before
+	return len(ids)
+}
+
+// Копии удалённых сообщений в истории комнат в памяти убираются по тем же правилам
+func TestPurger_History(t *testing.T) {
+	now := time.Now()
+	day := 24 * time.Hour
+	msgs := &fakeMessages{msgs: []message.Message{
+		{ID: "l1", Room: "legal", CreatedAt: now.Add(-40 * day)},
+		{ID: "l2", Room: "legal", CreatedAt: now},
+		{ID: "f1", Room: "flood", CreatedAt: now.Add(-time.Minute)},
+		{ID: "f2", Room: "flood", CreatedAt: now},
+		{ID: "d1", To: "bob", CreatedAt: now.Add(-40 * day)},
+	}}
+	history := &fakeHistory{ids: map[string][]string{}, before: map[string]time.Time{}}
+
+	p := &retention.Purger{
+		Messages: msgs,
+		History:  history,
+		Global:   retention.Policy{MaxAge: 30 * day},
+		Rooms: map[string]retention.Policy{
+			"legal": {MaxAge: 30 * day},
+			"flood": {MaxCount: 1},
+		},
+	}
+	_, err := p.RunOnce(now)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"l1"}, history.ids["legal"])
+	assert.Equal(t, now.Add(-30*day), history.before["legal"])
+	assert.Equal(t, []string{"f1"}, history.ids["flood"])
+	assert.True(t, history.before["flood"].IsZero(), "для правила по количеству срок не задан")
+	assert.NotContains(t, history.ids, "", "личные сообщения в истории комнат не хранятся")
+}
+
+// Одинаковые файлы двух вложений лежат под одним ключом: удаление устаревшего
+// вложения не трогает файл живого
+func TestPurger_SharedBlob(t *testing.T) {
+	now := time.Now()
+	msgs := &fakeMessages{msgs: []message.Message{
+		{ID: "l1", Room: "legal", CreatedAt: now.Add(-40 * 24 * time.Hour), AttachmentID: "a1"},
+		{ID: "g1", Room: "general", CreatedAt: now, AttachmentID: "a2"},
+	}}
+	blobs := storage.NewMemoryStore()
+	_ = blobs.Put("attachments/same.png", []byte("data"), "image/png")
+	_ = blobs.Put("thumbnails/same.png", []byte("thumb"), "image/png")
+	attachments := &fakeAttachments{items: map[string]*attachment.Attachment{
+		"a1": {ID: "a1", BlobKey: "attachments/same.png", ThumbKey: "thumbnails/same.png"},
+		"a2": {ID: "a2", BlobKey: "attachments/same.png", ThumbKey: "thumbnails/same.png"},
+	}}
+
+	p := &retention.Purger{
+		Messages:    msgs,
+		Attachments: attachments,
+		Blobs:       blobs,
+		Rooms:       map[string]retention.Policy{"legal": {MaxAge: 30 * 24 * time.Hour}},
+	}
+	removed, err := p.RunOnce(now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.Contains(t, attachments.items, "a2")
+	for _, key := range []string{"attachments/same.png", "thumbnails/same.png"} {
+		_, _, err = blobs.Open(key)
+		assert.NoError(t, err, key)
+	}
+}
+
+func TestPurger_NoPolicy(t *testing.T) {
+	msgs := &fakeMessages{msgs: []message.Message{{ID: "m1", Room: "general", CreatedAt: time.Unix(0, 0)}}}
+	p := &retention.Purger{Messages: msgs}
+
+	removed, err := p.RunOnce(time.Now())
+	assert.NoError(t, err)
+	assert.Zero(t, removed)
+	assert.Zero(t, msgs.batches)
+}
+
+// Вложения, которые загрузили и не отправили, удаляются через Unsent после загрузки
+func TestPurger_Unsent(t *testing.T) {
+	now := time.Now()
+	blobs := storage.NewMemoryStore()
+	_ = blobs.Put("attachments/old", []byte("old"), "text/plain")
+	_ = blobs.Put("attachments/new", []byte("new"), "text/plain")
+	_ = blobs.Put("attachments/sent", []byte("sent"), "text/plain")
+	attachments := &fakeAttachments{items: map[string]*attachment.Attachment{
+		"old":  {ID: "old", BlobKey: "attachments/old", CreatedAt: now.Add(-48 * time.Hour)},
+		"new":  {ID: "new", BlobKey: "attachments/new", CreatedAt: now.Add(-time.Hour)},
+		"sent": {ID: "sent", Room: "general", BlobKey: "attachments/sent", CreatedAt: now.Add(-48 * time.Hour)},
+	}}
+
+	p := &retention.Purger{
+		Messages:    &fakeMessages{},
+		Attachments: attachments,
+		Blobs:       blobs,
+		Unsent:      24 * time.Hour,
+		BatchSize:   1,
+	}
+	_, err := p.RunOnce(now)
+	assert.NoError(t, err)
+	assert.NotContains(t, attachments.items, "old")
+	assert.Contains(t, attachments.items, "new")
+	assert.Contains(t, attachments.items, "sent")
+	_, _, err = blobs.Open("attachments/old")
+	assert.Error(t, err)
+	_, _, err = blobs.Open("attachments/new")
+	assert.NoError(t, err)
+
+	// Без Unsent неотправленные вложения хранятся бессрочно
+	p.Unsent = 0
+	_, err = p.RunOnce(now.Add(30 * 24 * time.Hour))
+	assert.NoError(t, err)
+	assert.Contains(t, attachments.items, "new")
+}
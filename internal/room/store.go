@@ -0,0 +1,151 @@
+package room
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Роли участников комнаты
+const (
+	RoleOwner  = "owner"  // создатель комнаты
+	RoleAdmin  = "admin"  // назначается владельцем
+	RoleMember = "member" // все остальные
+)
+
+// ErrNotMember — пользователь не участник комнаты
+var ErrNotMember = errors.New("user is not a member of the room")
+
+// RoomStore хранит комнаты и их участников.
+// Участником считается пользователь, хотя бы раз подключившийся к комнате.
+type RoomStore interface {
+	Join(room, username string) error
+	IsMember(room, username string) (bool, error)
+	Members(room string) ([]string, error)
+	// Role возвращает роль участника; пусто — пользователь не участник
+	Role(room, username string) (string, error)
+	// SetRole меняет роль участника; ErrNotMember — пользователь не участник
+	SetRole(room, username, role string) error
+}
+
+// CanManage сообщает, может ли участник с ролью role управлять комнатой
+func CanManage(role string) bool {
+	return role == RoleOwner || role == RoleAdmin
+}
+
+type Store struct {
+	Db *sql.DB
+}
+
+var _ RoomStore = (*Store)(nil)
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{Db: db}
+}
+
+// Join создаёт комнату при необходимости и добавляет в неё пользователя.
+// Создатель комнаты становится её владельцем.
+func (s *Store) Join(room, username string) error {
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO rooms (name) VALUES ($1) ON CONFLICT DO NOTHING`, room)
+	if err != nil {
+		return fmt.Errorf("failed to create room: %w", err)
+	}
+	role := RoleMember
+	if n, _ := res.RowsAffected(); n == 1 {
+		role = RoleOwner
+	}
+	if _, err := tx.Exec(`INSERT INTO room_members (room, username, role) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`, room, username, role); err != nil {
+		return fmt.Errorf("failed to add member: %w", err)
+	}
+	return tx.Commit()
+}
+
+// IsMember сообщает, состоит ли пользователь в комнате
+func (s *Store) IsMember(room, username string) (bool, error) {
+	var exists bool
+	err := s.Db.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM room_members WHERE room=$1 AND username=$2)`,
+		room, username,
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check membership: %w", err)
+	}
+	return exists, nil
+}
+
+// Members возвращает имена всех участников комнаты
+func (s *Store) Members(room string) ([]string, error) {
+	rows, err := s.Db.Query(`SELECT username FROM room_members WHERE room=$1 ORDER BY username`, room)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []string
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			return nil, fmt.Errorf("failed to scan member: %w", err)
+		}
+		members = append(members, username)
+	}
+	return members, rows.Err()
+}
+
+func (s *Store) Role(room, username string) (string, error) {
+	var role string
+	err := s.Db.QueryRow(`SELECT role FROM room_members WHERE room=$1 AND username=$2`, room, username).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get role: %w", err)
+	}
+	return role, nil
+}
+
+func (s *Store) SetRole(room, username, role string) error {
+	res, err := s.Db.Exec(`UPDATE room_members SET role=$3 WHERE room=$1 AND username=$2`, room, username, role)
+	if err != nil {
+		return fmt.Errorf("failed to set role: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotMember
+	}
+	return nil
+}
+
+// Info — комната и число её участников
+type Info struct {
+	Name      string    `json:"name"`
+	Members   int       `json:"members"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// List возвращает все комнаты по алфавиту
+func (s *Store) List() ([]Info, error) {
+	rows, err := s.Db.Query(`SELECT r.name, COUNT(m.username), COALESCE(r.created_at, NOW())
+		FROM rooms r LEFT JOIN room_members m ON m.room = r.name
+		GROUP BY r.name, r.created_at ORDER BY r.name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rooms: %w", err)
+	}
+	defer rows.Close()
+
+	rooms := []Info{}
+	for rows.Next() {
+		var r Info
+		if err := rows.Scan(&r.Name, &r.Members, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan room: %w", err)
+		}
+		rooms = append(rooms, r)
+	}
+	return rooms, rows.Err()
+}
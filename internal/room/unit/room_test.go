@@ -0,0 +1,157 @@
+package room_test
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-portfolio/websocket-chat/internal/room"
+	"github.com/stretchr/testify/assert"
+)
+
+// --- ТЕСТЫ ДЛЯ Join ---
+
+func TestJoin_Success(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := room.NewStore(db)
+
+	// Комната и участник добавляются в одной транзакции, повторы игнорируются;
+	// создатель комнаты становится владельцем
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO rooms (name) VALUES ($1) ON CONFLICT DO NOTHING`)).
+		WithArgs("tech").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO room_members (room, username, role) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`)).
+		WithArgs("tech", "alice", room.RoleOwner).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, store.Join("tech", "alice"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestJoin_ExistingRoom(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := room.NewStore(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO rooms`).WithArgs("tech").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO room_members`).
+		WithArgs("tech", "bob", room.RoleMember).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, store.Join("tech", "bob"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestJoin_RollbackOnError(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := room.NewStore(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO rooms`).WillReturnError(errors.New("db down"))
+	mock.ExpectRollback()
+
+	err := store.Join("tech", "alice")
+	assert.ErrorContains(t, err, "failed to create room")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// --- ТЕСТЫ ДЛЯ IsMember ---
+
+func TestIsMember(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := room.NewStore(db)
+
+	mock.ExpectQuery(`SELECT EXISTS`).
+		WithArgs("tech", "alice").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	ok, err := store.IsMember("tech", "alice")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+// --- ТЕСТЫ ДЛЯ Members ---
+
+func TestMembers(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := room.NewStore(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT username FROM room_members WHERE room=$1 ORDER BY username`)).
+		WithArgs("tech").
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alice").AddRow("bob"))
+
+	members, err := store.Members("tech")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"alice", "bob"}, members)
+}
+
+// --- ТЕСТЫ ДЛЯ Role ---
+
+func TestRole(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := room.NewStore(db)
+
+	mock.ExpectQuery(`SELECT role FROM room_members`).
+		WithArgs("tech", "alice").
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(room.RoleAdmin))
+	mock.ExpectQuery(`SELECT role FROM room_members`).
+		WithArgs("tech", "eve").
+		WillReturnRows(sqlmock.NewRows([]string{"role"}))
+
+	role, err := store.Role("tech", "alice")
+	assert.NoError(t, err)
+	assert.True(t, room.CanManage(role))
+
+	role, err = store.Role("tech", "eve")
+	assert.NoError(t, err)
+	assert.Empty(t, role)
+	assert.False(t, room.CanManage(role))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// --- ТЕСТЫ ДЛЯ List ---
+
+func TestList(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := room.NewStore(db)
+
+	now := time.Now()
+	mock.ExpectQuery(`FROM rooms r LEFT JOIN room_members m`).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "count", "created_at"}).
+			AddRow("empty", 0, now).
+			AddRow("tech", 3, now))
+
+	rooms, err := store.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []room.Info{{Name: "empty", CreatedAt: now}, {Name: "tech", Members: 3, CreatedAt: now}}, rooms)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetRole(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := room.NewStore(db)
+
+	mock.ExpectExec(`UPDATE room_members SET role`).
+		WithArgs("tech", "bob", room.RoleAdmin).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE room_members SET role`).
+		WithArgs("tech", "eve", room.RoleAdmin).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, store.SetRole("tech", "bob", room.RoleAdmin))
+	assert.ErrorIs(t, store.SetRole("tech", "eve", room.RoleAdmin), room.ErrNotMember)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,287 @@
+package session
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrNotFound — сессии нет или она принадлежит другому пользователю
+	ErrNotFound = errors.New("session not found")
+	// ErrInvalid — refresh-токен неизвестен, сессия отозвана или истекла
+	ErrInvalid = errors.New("invalid session")
+	// ErrReused — предъявлен уже использованный refresh-токен: вероятна кража,
+	// поэтому сессия отзывается целиком
+	ErrReused = errors.New("refresh token reused")
+)
+
+// Session — вход пользователя с одного устройства
+type Session struct {
+	ID         string
+	Username   string
+	UserAgent  string
+	IP         string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	ExpiresAt  time.Time
+}
+
+// SessionStore хранит сессии и их refresh-токены. В базе лежат только
+// SHA-256 хэши токенов; при каждом обновлении токен заменяется новым.
+type SessionStore interface {
+	// Create открывает сессию и возвращает её вместе с refresh-токеном
+	Create(username, userAgent, ip string, ttl time.Duration) (Session, string, error)
+	// Rotate обменивает refresh-токен на новый и продлевает сессию на ttl;
+	// ErrInvalid — сессия отозвана или истекла, пользователь заблокирован или удалён
+	Rotate(refreshToken string, ttl time.Duration) (Session, string, error)
+	// Active сообщает, что сессия не отозвана и не истекла
+	Active(id string) (bool, error)
+	// Revoke отзывает сессию по ID
+	Revoke(id string) error
+	// RevokeToken отзывает сессию, которой принадлежит refresh-токен
+	RevokeToken(refreshToken string) (Session, error)
+	// List возвращает действующие сессии пользователя, последние использованные — первыми
+	List(username string) ([]Session, error)
+	// RevokeOwn отзывает сессию пользователя; чужая или неизвестная — ErrNotFound
+	RevokeOwn(username, id string) error
+	// RevokeAll отзывает все сессии пользователя, кроме except, и возвращает их ID
+	RevokeAll(username, except string) ([]string, error)
+}
+
+// touchInterval — last_used_at обновляется не чаще, чем раз в этот интервал,
+// чтобы проверка сессии на каждом запросе не превращалась в запись
+const touchInterval = time.Minute
+
+type Store struct {
+	Db *sql.DB
+}
+
+var _ SessionStore = (*Store)(nil)
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{Db: db}
+}
+
+func (s *Store) Create(username, userAgent, ip string, ttl time.Duration) (Session, string, error) {
+	id, err := randomBytes(16)
+	if err != nil {
+		return Session{}, "", err
+	}
+	token, err := NewToken()
+	if err != nil {
+		return Session{}, "", err
+	}
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+
+	now := time.Now()
+	sess := Session{
+		ID:         hex.EncodeToString(id),
+		Username:   username,
+		UserAgent:  userAgent,
+		IP:         ip,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(ttl),
+	}
+	_, err = s.Db.Exec(
+		`INSERT INTO sessions (id, username, refresh_hash, user_agent, ip, created_at, last_used_at, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $6, $7)`,
+		sess.ID, username, HashToken(token), userAgent, ip, now, sess.ExpiresAt,
+	)
+	if err != nil {
+		return Session{}, "", fmt.Errorf("failed to create session: %w", err)
+	}
+	return sess, token, nil
+}
+
+func (s *Store) Rotate(refreshToken string, ttl time.Duration) (Session, string, error) {
+	hash := HashToken(refreshToken)
+
+	var (
+		sess    Session
+		current bool
+		active  bool
+	)
+	// Заблокированные и удалённые пользователи токены не обновляют
+	err := s.Db.QueryRow(
+		`SELECT s.id, s.username, s.user_agent, s.ip, s.created_at, s.refresh_hash = $1,
+			s.revoked_at IS NULL AND s.expires_at > NOW() AND u.banned_at IS NULL
+		 FROM sessions s JOIN users u ON u.username = s.username
+		 WHERE s.refresh_hash = $1 OR s.prev_refresh_hash = $1`,
+		hash,
+	).Scan(&sess.ID, &sess.Username, &sess.UserAgent, &sess.IP, &sess.CreatedAt, &current, &active)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Session{}, "", ErrInvalid
+	}
+	if err != nil {
+		return Session{}, "", fmt.Errorf("failed to find session: %w", err)
+	}
+	if !active {
+		return Session{}, "", ErrInvalid
+	}
+	if !current {
+		if err := s.Revoke(sess.ID); err != nil {
+			return Session{}, "", err
+		}
+		return sess, "", ErrReused
+	}
+
+	token, err := NewToken()
+	if err != nil {
+		return Session{}, "", err
+	}
+	now := time.Now()
+	sess.LastUsedAt = now
+	sess.ExpiresAt = now.Add(ttl)
+
+	// Условие на старый хэш не даёт двум параллельным запросам обменять один токен дважды
+	res, err := s.Db.Exec(
+		`UPDATE sessions SET prev_refresh_hash = refresh_hash, refresh_hash = $1, last_used_at = $2, expires_at = $3
+		 WHERE id = $4 AND refresh_hash = $5 AND revoked_at IS NULL`,
+		HashToken(token), now, sess.ExpiresAt, sess.ID, hash,
+	)
+	if err != nil {
+		return Session{}, "", fmt.Errorf("failed to rotate session: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return Session{}, "", ErrInvalid
+	}
+	return sess, token, nil
+}
+
+func (s *Store) Active(id string) (bool, error) {
+	var active, stale bool
+	err := s.Db.QueryRow(
+		`SELECT revoked_at IS NULL AND expires_at > NOW(), last_used_at < $2 FROM sessions WHERE id = $1`,
+		id, time.Now().Add(-touchInterval),
+	).Scan(&active, &stale)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check session: %w", err)
+	}
+	if active && stale {
+		if _, err := s.Db.Exec(`UPDATE sessions SET last_used_at = NOW() WHERE id = $1`, id); err != nil {
+			return false, fmt.Errorf("failed to touch session: %w", err)
+		}
+	}
+	return active, nil
+}
+
+func (s *Store) Revoke(id string) error {
+	if _, err := s.Db.Exec(`UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL`, id); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) RevokeToken(refreshToken string) (Session, error) {
+	var sess Session
+	err := s.Db.QueryRow(
+		`UPDATE sessions SET revoked_at = COALESCE(revoked_at, NOW())
+		 WHERE refresh_hash = $1 OR prev_refresh_hash = $1
+		 RETURNING id, username`,
+		HashToken(refreshToken),
+	).Scan(&sess.ID, &sess.Username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Session{}, ErrInvalid
+	}
+	if err != nil {
+		return Session{}, fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return sess, nil
+}
+
+func (s *Store) List(username string) ([]Session, error) {
+	rows, err := s.Db.Query(
+		`SELECT id, username, user_agent, ip, created_at, last_used_at, expires_at
+		 FROM sessions
+		 WHERE username = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		 ORDER BY last_used_at DESC`,
+		username,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	list := []Session{}
+	for rows.Next() {
+		var sess Session
+		if err := rows.Scan(&sess.ID, &sess.Username, &sess.UserAgent, &sess.IP, &sess.CreatedAt, &sess.LastUsedAt, &sess.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		list = append(list, sess)
+	}
+	return list, rows.Err()
+}
+
+func (s *Store) RevokeOwn(username, id string) error {
+	res, err := s.Db.Exec(
+		`UPDATE sessions SET revoked_at = NOW()
+		 WHERE id = $1 AND username = $2 AND revoked_at IS NULL AND expires_at > NOW()`,
+		id, username,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) RevokeAll(username, except string) ([]string, error) {
+	rows, err := s.Db.Query(
+		`UPDATE sessions SET revoked_at = NOW()
+		 WHERE username = $1 AND id <> $2 AND revoked_at IS NULL AND expires_at > NOW()
+		 RETURNING id`,
+		username, except,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// HashToken возвращает hex SHA-256 токена — так токены хранятся в базе
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewToken генерирует случайный токен (256 бит, base64url)
+func NewToken() (string, error) {
+	b, err := randomBytes(32)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	return b, nil
+}
@@ -0,0 +1,177 @@
+package unit
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-portfolio/websocket-chat/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newStore(t *testing.T) (*session.Store, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return session.NewStore(db), mock
+}
+
+func TestCreate_StoresOnlyHash(t *testing.T) {
+	store, mock := newStore(t)
+
+	mock.ExpectExec("INSERT INTO sessions").
+		WithArgs(sqlmock.AnyArg(), "alice", sqlmock.AnyArg(), "curl/8", "10.0.0.1", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	sess, token, err := store.Create("alice", "curl/8", "10.0.0.1", time.Hour)
+	require.NoError(t, err)
+	assert.Len(t, sess.ID, 32)
+	assert.NotEmpty(t, token)
+	assert.Equal(t, "alice", sess.Username)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), sess.ExpiresAt, time.Second)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func sessionRows(current, active bool) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "username", "user_agent", "ip", "created_at", "current", "active"}).
+		AddRow("s1", "alice", "curl/8", "10.0.0.1", time.Now(), current, active)
+}
+
+func TestRotate(t *testing.T) {
+	store, mock := newStore(t)
+	hash := session.HashToken("old")
+
+	mock.ExpectQuery("SELECT s.id, s.username").WithArgs(hash).WillReturnRows(sessionRows(true, true))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE sessions SET prev_refresh_hash = refresh_hash")).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "s1", hash).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	sess, token, err := store.Rotate("old", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, "s1", sess.ID)
+	assert.NotEqual(t, "old", token)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Повторное предъявление уже обменянного токена отзывает сессию
+func TestRotate_ReuseRevokesSession(t *testing.T) {
+	store, mock := newStore(t)
+
+	mock.ExpectQuery("SELECT s.id, s.username").WillReturnRows(sessionRows(false, true))
+	mock.ExpectExec("UPDATE sessions SET revoked_at").WithArgs("s1").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	sess, _, err := store.Rotate("stolen", time.Hour)
+	assert.ErrorIs(t, err, session.ErrReused)
+	assert.Equal(t, "s1", sess.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRotate_Invalid(t *testing.T) {
+	store, mock := newStore(t)
+
+	// Неизвестный токен
+	mock.ExpectQuery("SELECT s.id, s.username").WillReturnRows(sqlmock.NewRows(nil))
+	_, _, err := store.Rotate("unknown", time.Hour)
+	assert.ErrorIs(t, err, session.ErrInvalid)
+
+	// Отозванная или истёкшая сессия, заблокированный пользователь
+	mock.ExpectQuery(`SELECT s.id, s.username.*u\.banned_at IS NULL.*JOIN users u`).WillReturnRows(sessionRows(true, false))
+	_, _, err = store.Rotate("old", time.Hour)
+	assert.ErrorIs(t, err, session.ErrInvalid)
+
+	// Параллельный запрос успел обменять тот же токен
+	mock.ExpectQuery("SELECT s.id, s.username").WillReturnRows(sessionRows(true, true))
+	mock.ExpectExec("UPDATE sessions SET prev_refresh_hash").WillReturnResult(sqlmock.NewResult(0, 0))
+	_, _, err = store.Rotate("old", time.Hour)
+	assert.ErrorIs(t, err, session.ErrInvalid)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestActive(t *testing.T) {
+	store, mock := newStore(t)
+
+	mock.ExpectQuery("SELECT revoked_at IS NULL").WithArgs("s1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"active", "stale"}).AddRow(true, false))
+	mock.ExpectQuery("SELECT revoked_at IS NULL").WithArgs("gone", sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows(nil))
+
+	active, err := store.Active("s1")
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	active, err = store.Active("gone")
+	assert.NoError(t, err)
+	assert.False(t, active)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Время последнего использования обновляется, только если оно устарело
+func TestActive_TouchesStaleSession(t *testing.T) {
+	store, mock := newStore(t)
+
+	mock.ExpectQuery("SELECT revoked_at IS NULL").
+		WillReturnRows(sqlmock.NewRows([]string{"active", "stale"}).AddRow(true, true))
+	mock.ExpectExec("UPDATE sessions SET last_used_at").WithArgs("s1").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	active, err := store.Active("s1")
+	assert.NoError(t, err)
+	assert.True(t, active)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRevokeToken(t *testing.T) {
+	store, mock := newStore(t)
+
+	mock.ExpectQuery("UPDATE sessions SET revoked_at").WithArgs(session.HashToken("tok")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username"}).AddRow("s1", "alice"))
+	mock.ExpectQuery("UPDATE sessions SET revoked_at").WillReturnRows(sqlmock.NewRows(nil))
+
+	sess, err := store.RevokeToken("tok")
+	assert.NoError(t, err)
+	assert.Equal(t, "s1", sess.ID)
+
+	_, err = store.RevokeToken("unknown")
+	assert.ErrorIs(t, err, session.ErrInvalid)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestList(t *testing.T) {
+	store, mock := newStore(t)
+	now := time.Now()
+
+	mock.ExpectQuery("SELECT id, username, user_agent, ip, created_at, last_used_at, expires_at").WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "user_agent", "ip", "created_at", "last_used_at", "expires_at"}).
+			AddRow("s2", "alice", "phone", "10.0.0.2", now, now, now.Add(time.Hour)).
+			AddRow("s1", "alice", "laptop", "10.0.0.1", now, now.Add(-time.Hour), now.Add(time.Hour)))
+
+	list, err := store.List("alice")
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "phone", list[0].UserAgent)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRevokeOwn(t *testing.T) {
+	store, mock := newStore(t)
+
+	mock.ExpectExec("UPDATE sessions SET revoked_at").WithArgs("s1", "alice").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE sessions SET revoked_at").WithArgs("s1", "bob").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, store.RevokeOwn("alice", "s1"))
+	assert.ErrorIs(t, store.RevokeOwn("bob", "s1"), session.ErrNotFound, "чужую сессию отозвать нельзя")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRevokeAll(t *testing.T) {
+	store, mock := newStore(t)
+
+	mock.ExpectQuery("UPDATE sessions SET revoked_at").WithArgs("alice", "s1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("s2").AddRow("s3"))
+
+	ids, err := store.RevokeAll("alice", "s1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"s2", "s3"}, ids)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,71 @@
+// Package settings хранит настройки сервера, которые администраторы меняют
+// на ходу (admin API, chatctl), без перезапуска и правки конфигурации.
+package settings
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+)
+
+// Ключи настроек
+const (
+	// RequireTwoFactor — "true": вход без 2FA невозможен, пользователи без неё
+	// должны подключить её при следующем входе
+	RequireTwoFactor = "require_2fa"
+)
+
+// SettingsStore — настройки сервера «ключ — значение»
+type SettingsStore interface {
+	// Get возвращает значение; пустая строка — настройка не задана
+	Get(key string) (string, error)
+	Set(key, value string) error
+}
+
+type Store struct {
+	Db *sql.DB
+}
+
+var _ SettingsStore = (*Store)(nil)
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{Db: db}
+}
+
+func (s *Store) Get(key string) (string, error) {
+	var value string
+	err := s.Db.QueryRow(`SELECT value FROM settings WHERE key=$1`, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get setting %s: %w", key, err)
+	}
+	return value, nil
+}
+
+func (s *Store) Set(key, value string) error {
+	_, err := s.Db.Exec(
+		`INSERT INTO settings (key, value, updated_at) VALUES ($1, $2, NOW())
+		ON CONFLICT (key) DO UPDATE SET value=EXCLUDED.value, updated_at=NOW()`,
+		key, value,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save setting %s: %w", key, err)
+	}
+	return nil
+}
+
+// Bool читает логическую настройку; не заданная считается выключенной
+func Bool(s SettingsStore, key string) (bool, error) {
+	value, err := s.Get(key)
+	if err != nil || value == "" {
+		return false, err
+	}
+	return strconv.ParseBool(value)
+}
+
+// SetBool сохраняет логическую настройку
+func SetBool(s SettingsStore, key string, value bool) error {
+	return s.Set(key, strconv.FormatBool(value))
+}
@@ -0,0 +1,35 @@
+package unit
+
+import (
+	"database/sql"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-portfolio/websocket-chat/internal/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_GetSet(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := settings.NewStore(db)
+
+	get := regexp.QuoteMeta(`SELECT value FROM settings WHERE key=$1`)
+	mock.ExpectQuery(get).WithArgs("require_2fa").WillReturnError(sql.ErrNoRows)
+	on, err := settings.Bool(store, settings.RequireTwoFactor)
+	require.NoError(t, err)
+	assert.False(t, on, "не заданная настройка выключена")
+
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO settings (key, value, updated_at) VALUES ($1, $2, NOW())`)).
+		WithArgs("require_2fa", "true").WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, settings.SetBool(store, settings.RequireTwoFactor, true))
+
+	mock.ExpectQuery(get).WithArgs("require_2fa").WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("true"))
+	on, err = settings.Bool(store, settings.RequireTwoFactor)
+	require.NoError(t, err)
+	assert.True(t, on)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
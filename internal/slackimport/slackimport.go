@@ -0,0 +1,381 @@
+package slackimport
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-portfolio/websocket-chat/internal/message"
+	"github.com/go-portfolio/websocket-chat/internal/user"
+)
+
+// maxRoomLen — ограничение длины имени комнаты в схеме БД
+const maxRoomLen = 64
+
+// Issuer — издатель, под которым пользователи Slack привязываются к пользователям чата
+// (user_identities); субъект — Slack ID
+const Issuer = "slack"
+
+// UserStore создаёт авторов сообщений и помнит, кто из них какой пользователь Slack
+// (реализуется user.Store)
+type UserStore interface {
+	ResolveIdentity(id user.Identity) (username string, created bool, err error)
+}
+
+// RoomStore создаёт комнаты и участников (реализуется room.Store)
+type RoomStore interface {
+	Join(room, username string) error
+}
+
+// MessageStore сохраняет сообщения пачками (реализуется message.Store)
+type MessageStore interface {
+	SaveBatch(msgs []*message.Message) (int, error)
+}
+
+// Stats — итог импорта
+type Stats struct {
+	Users    int // созданные пользователи-заглушки
+	Renamed  int // из них получили другое имя: исходное занято
+	Rooms    int
+	Messages int // добавленные сообщения; уже импортированные не считаются
+	Skipped  int // служебные сообщения Slack (вход в канал, смена темы и т.п.)
+}
+
+// Importer переносит историю из архива экспорта Slack: channels.json, users.json и
+// файлы <канал>/<YYYY-MM-DD>.json. Пользователи Slack становятся заглушками без пароля,
+// привязанными к Slack ID: сообщения не приписываются существующему пользователю с тем же
+// именем, а если имя занято, заглушка получает номер (alice2). Каналы становятся
+// комнатами, сообщения сохраняются с исходным временем. ID сообщений выводятся из канала
+// и ts, а авторы — из привязки, поэтому повторный импорт того же архива ничего не дублирует.
+type Importer struct {
+	Users     UserStore
+	Rooms     RoomStore
+	Messages  MessageStore
+	BatchSize int
+}
+
+type slackUser struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Profile struct {
+		DisplayName string `json:"display_name"`
+	} `json:"profile"`
+}
+
+type slackChannel struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Creator string   `json:"creator"`
+	Members []string `json:"members"`
+}
+
+type slackFile struct {
+	Name      string `json:"name"`
+	Title     string `json:"title"`
+	Permalink string `json:"permalink"`
+}
+
+type slackMessage struct {
+	Type     string      `json:"type"`
+	Subtype  string      `json:"subtype"`
+	User     string      `json:"user"`
+	Username string      `json:"username"` // у сообщений ботов
+	Text     string      `json:"text"`
+	TS       string      `json:"ts"`
+	Files    []slackFile `json:"files"`
+}
+
+// Служебные подтипы, которые переносятся как обычные сообщения
+var keptSubtypes = map[string]bool{
+	"":                 true,
+	"bot_message":      true,
+	"me_message":       true,
+	"file_share":       true,
+	"thread_broadcast": true,
+}
+
+// Import читает архив и сохраняет его содержимое
+func (im *Importer) Import(zr *zip.Reader) (Stats, error) {
+	run := &importRun{im: im, zr: zr}
+
+	var users []slackUser
+	if err := readJSON(zr, "users.json", &users); err != nil {
+		return run.stats, err
+	}
+	var channels []slackChannel
+	if err := readJSON(zr, "channels.json", &channels); err != nil {
+		return run.stats, err
+	}
+
+	run.names = make(map[string]string, len(users))
+	for _, u := range users {
+		if _, err := run.ensureUser(u.ID, u.Name); err != nil {
+			return run.stats, err
+		}
+	}
+
+	for _, ch := range channels {
+		roomName := RoomName(ch.Name)
+		if roomName == "" {
+			continue
+		}
+		if err := run.importChannel(ch, roomName); err != nil {
+			return run.stats, err
+		}
+	}
+	return run.stats, nil
+}
+
+// importRun — состояние одного импорта
+type importRun struct {
+	im    *Importer
+	zr    *zip.Reader
+	names map[string]string // Slack ID -> имя в чате
+	stats Stats
+}
+
+// ensureUser находит или создаёт пользователя чата для пользователя Slack с именем name
+func (r *importRun) ensureUser(id, name string) (string, error) {
+	wanted := user.NormalizeUsername(name)
+	if wanted == "" {
+		wanted = user.NormalizeUsername(id)
+	}
+	username, created, err := r.im.Users.ResolveIdentity(user.Identity{
+		Issuer:  Issuer,
+		Subject: id,
+		Names:   []string{wanted},
+	})
+	if err != nil {
+		return "", fmt.Errorf("slack user %s: %w", id, err)
+	}
+	r.names[id] = username
+	if created {
+		r.stats.Users++
+		if username != wanted {
+			r.stats.Renamed++
+			log.Printf("slack import: user %s (%s) imported as %s: name %s is taken", id, name, username, wanted)
+		}
+	}
+	return username, nil
+}
+
+// author возвращает имя автора по Slack ID; для авторов, которых нет в users.json,
+// создаётся заглушка
+func (r *importRun) author(id string) (string, error) {
+	if name, ok := r.names[id]; ok {
+		return name, nil
+	}
+	return r.ensureUser(id, id)
+}
+
+// importChannel создаёт комнату с участниками и сохраняет сообщения канала
+// из файлов по дням, от старых к новым
+func (r *importRun) importChannel(ch slackChannel, roomName string) error {
+	// Создатель канала вступает первым и становится владельцем комнаты
+	for _, id := range append([]string{ch.Creator}, ch.Members...) {
+		if id == "" {
+			continue
+		}
+		name, err := r.author(id)
+		if err != nil {
+			return err
+		}
+		if err := r.im.Rooms.Join(roomName, name); err != nil {
+			return err
+		}
+	}
+	r.stats.Rooms++
+
+	var days []*zip.File
+	for _, f := range r.zr.File {
+		if path.Dir(f.Name) == ch.Name && path.Ext(f.Name) == ".json" {
+			days = append(days, f)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Name < days[j].Name })
+
+	var (
+		batch []*message.Message
+		saved int
+	)
+	flush := func() error {
+		n, err := r.im.Messages.SaveBatch(batch)
+		saved += n
+		batch = batch[:0]
+		return err
+	}
+	for _, f := range days {
+		var msgs []slackMessage
+		if err := decode(f, &msgs); err != nil {
+			return err
+		}
+		for _, sm := range msgs {
+			at, err := parseTS(sm.TS)
+			if sm.Type != "message" || !keptSubtypes[sm.Subtype] || err != nil {
+				r.stats.Skipped++
+				continue
+			}
+			// У сообщений ботов нет user, только отображаемое имя
+			id := sm.User
+			if id == "" {
+				id = "bot-" + sm.Username
+			}
+			from, err := r.author(id)
+			if err != nil {
+				return err
+			}
+
+			batch = append(batch, &message.Message{
+				ID:        MessageID(ch.ID, sm.TS),
+				Room:      roomName,
+				From:      from,
+				Type:      "message",
+				Text:      ConvertText(sm.Text, r.names) + fileLinks(sm.Files),
+				CreatedAt: at,
+			})
+			if len(batch) >= r.im.batchSize() {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+
+	r.stats.Messages += saved
+	log.Printf("slack import: #%s -> %s: %d message(s)", ch.Name, roomName, saved)
+	return nil
+}
+
+func (im *Importer) batchSize() int {
+	if im.BatchSize <= 0 {
+		return 500
+	}
+	return im.BatchSize
+}
+
+// MessageID выводит ID сообщения из канала и ts Slack
+func MessageID(channelID, ts string) string {
+	sum := sha256.Sum256([]byte(channelID + "/" + ts))
+	return hex.EncodeToString(sum[:8])
+}
+
+// RoomName приводит имя канала Slack к ограничениям длины имени комнаты
+func RoomName(name string) string {
+	return truncate(strings.TrimSpace(name), maxRoomLen)
+}
+
+func truncate(s string, n int) string {
+	for utf8.RuneCountInString(s) > n {
+		_, size := utf8.DecodeLastRuneInString(s)
+		s = s[:len(s)-size]
+	}
+	return s
+}
+
+var slackRef = regexp.MustCompile(`<([^<>]+)>`)
+
+// ConvertText переводит разметку Slack в разметку чата: <@U123> -> @имя, <!here> -> @here,
+// <!channel> и <!everyone> -> @room, <#C123|general> -> #general, <url|текст> -> [текст](url).
+// Slack экранирует &, < и >, поэтому сущности раскрываются после разбора ссылок.
+func ConvertText(text string, names map[string]string) string {
+	text = slackRef.ReplaceAllStringFunc(text, func(ref string) string {
+		inner := ref[1 : len(ref)-1]
+		target, label, _ := strings.Cut(inner, "|")
+		switch {
+		case strings.HasPrefix(target, "@"):
+			if name, ok := names[target[1:]]; ok {
+				return "@" + name
+			}
+			if label != "" {
+				return "@" + user.NormalizeUsername(label)
+			}
+			return "@" + target[1:]
+		case target == "!here":
+			return "@here"
+		case target == "!channel", target == "!everyone":
+			return "@room"
+		case strings.HasPrefix(target, "!"):
+			return label
+		case strings.HasPrefix(target, "#"):
+			if label != "" {
+				return "#" + label
+			}
+			return target
+		case label != "":
+			return "[" + label + "](" + target + ")"
+		default:
+			return target
+		}
+	})
+	return html.UnescapeString(text)
+}
+
+// fileLinks добавляет к тексту ссылки на файлы сообщения: сами файлы в экспорт не входят
+func fileLinks(files []slackFile) string {
+	var b strings.Builder
+	for _, f := range files {
+		if f.Permalink == "" {
+			continue
+		}
+		name := f.Title
+		if name == "" {
+			name = f.Name
+		}
+		fmt.Fprintf(&b, "\n📎 [%s](%s)", name, f.Permalink)
+	}
+	return b.String()
+}
+
+// parseTS разбирает ts Slack вида "1700000000.000100"
+func parseTS(ts string) (time.Time, error) {
+	sec, frac, _ := strings.Cut(ts, ".")
+	s, err := strconv.ParseInt(sec, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid ts %q", ts)
+	}
+	var usec int64
+	if frac != "" {
+		frac = (frac + "000000")[:6]
+		if usec, err = strconv.ParseInt(frac, 10, 64); err != nil {
+			return time.Time{}, fmt.Errorf("invalid ts %q", ts)
+		}
+	}
+	return time.Unix(s, usec*1000).UTC(), nil
+}
+
+func readJSON(zr *zip.Reader, name string, v any) error {
+	for _, f := range zr.File {
+		if f.Name == name {
+			return decode(f, v)
+		}
+	}
+	return fmt.Errorf("%s not found in archive", name)
+}
+
+func decode(f *zip.File, v any) error {
+	r, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", f.Name, err)
+	}
+	defer r.Close()
+	if err := json.NewDecoder(r).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", f.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package unit
+
+import (
+	"archive/zip"
+	"bytes"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/message"
+	"github.com/go-portfolio/websocket-chat/internal/slackimport"
+	"github.com/go-portfolio/websocket-chat/internal/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertText(t *testing.T) {
+	names := map[string]string{"U1": "alice"}
+	got := slackimport.ConvertText(
+		"<@U1> <@U9|bob> <!here> <!channel> в <#C1|general>: <https://go.dev|Go> и <https://x.io> &lt;b&gt; &amp;",
+		names,
+	)
+	assert.Equal(t, "@alice @bob @here @room в #general: [Go](https://go.dev) и https://x.io <b> &", got)
+}
+
+// fakeUsers, как user.Store, помнит привязку внешних аккаунтов и не отдаёт занятые имена
+type fakeUsers struct {
+	names      map[string]bool
+	identities map[string]string
+}
+
+func (f *fakeUsers) ResolveIdentity(id user.Identity) (string, bool, error) {
+	key := id.Issuer + "/" + id.Subject
+	if name, ok := f.identities[key]; ok {
+		return name, false, nil
+	}
+	name := id.Names[0]
+	for i := 2; f.names[name]; i++ {
+		name = id.Names[0] + strconv.Itoa(i)
+	}
+	f.names[name] = true
+	f.identities[key] = name
+	return name, true, nil
+}
+
+type fakeRooms struct{ joins []string }
+
+func (f *fakeRooms) Join(room, username string) error {
+	f.joins = append(f.joins, room+"/"+username)
+	return nil
+}
+
+type fakeMessages struct {
+	saved   map[string]*message.Message
+	order   []string
+	batches int
+}
+
+func (f *fakeMessages) SaveBatch(msgs []*message.Message) (int, error) {
+	f.batches++
+	n := 0
+	for _, m := range msgs {
+		if _, ok := f.saved[m.ID]; ok {
+			continue
+		}
+		f.saved[m.ID] = m
+		f.order = append(f.order, m.Text)
+		n++
+	}
+	return n, nil
+}
+
+func slackArchive(t *testing.T) *zip.Reader {
+	files := map[string]string{
+		"users.json": `[{"id":"U1","name":"alice"},{"id":"U2","name":"bob.smith"},{"id":"U4","name":"bob.smith."}]`,
+		"channels.json": `[{"id":"C1","name":"general","creator":"U2","members":["U1","U2"]},
+			{"id":"C2","name":"empty","creator":"U1","members":["U1"]}]`,
+		"general/2024-05-02.json": `[
+			{"type":"message","user":"U1","text":"второй день","ts":"1714640000.000200"}
+		]`,
+		"general/2024-05-01.json": `[
+			{"type":"message","subtype":"channel_join","user":"U1","text":"<@U1> has joined","ts":"1714550000.000100"},
+			{"type":"message","user":"U2","text":"привет <@U1>","ts":"1714553600.123456",
+			 "files":[{"name":"plan.pdf","title":"План","permalink":"https://slack.example/files/plan"}]},
+			{"type":"message","subtype":"bot_message","username":"CI","text":"build ok","ts":"1714553700.000001"},
+			{"type":"message","user":"U3","text":"гость","ts":"1714553800.000001"}
+		]`,
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, _ = w.Write([]byte(body))
+	}
+	require.NoError(t, zw.Close())
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	return zr
+}
+
+func TestImporter_Import(t *testing.T) {
+	users := &fakeUsers{names: map[string]bool{"alice": true}, identities: map[string]string{}} // alice уже зарегистрирована
+	rooms := &fakeRooms{}
+	msgs := &fakeMessages{saved: map[string]*message.Message{}}
+	im := &slackimport.Importer{Users: users, Rooms: rooms, Messages: msgs, BatchSize: 2}
+
+	zr := slackArchive(t)
+	stats, err := im.Import(zr)
+	require.NoError(t, err)
+	assert.Equal(t, slackimport.Stats{Users: 5, Renamed: 2, Rooms: 2, Messages: 4, Skipped: 1}, stats)
+
+	// Имя занято зарегистрированной alice и другим пользователем Slack: заглушки получают номер
+	assert.Equal(t, "alice2", users.identities["slack/U1"])
+	assert.Equal(t, "bob.smith", users.identities["slack/U2"])
+	assert.Equal(t, "bob.smith2", users.identities["slack/U4"])
+
+	// Создатель канала входит первым и становится владельцем
+	assert.Equal(t, "general/bob.smith", rooms.joins[0])
+
+	// Сообщения идут по дням, с исходным временем и конвертированным текстом
+	assert.Equal(t, []string{
+		"привет @alice2\n📎 [План](https://slack.example/files/plan)", "build ok", "гость", "второй день",
+	}, msgs.order)
+	m := msgs.saved[slackimport.MessageID("C1", "1714553600.123456")]
+	require.NotNil(t, m)
+	assert.Equal(t, "bob.smith", m.From)
+	assert.Equal(t, "general", m.Room)
+	assert.Equal(t, time.Unix(1714553600, 123456000).UTC(), m.CreatedAt)
+	assert.Equal(t, "bot-CI", msgs.saved[slackimport.MessageID("C1", "1714553700.000001")].From)
+	assert.Equal(t, "U3", msgs.saved[slackimport.MessageID("C1", "1714553800.000001")].From)
+	assert.Equal(t, 2, msgs.batches)
+
+	// Повторный импорт ничего не дублирует
+	stats, err = im.Import(zr)
+	require.NoError(t, err)
+	assert.Zero(t, stats.Messages)
+	assert.Zero(t, stats.Users)
+	assert.Equal(t, "alice2", users.identities["slack/U1"], "автор тот же, что при первом импорте")
+}
+
+func TestImporter_MissingFiles(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	_ = zw.Close()
+	zr, _ := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+
+	_, err := (&slackimport.Importer{}).Import(zr)
+	assert.ErrorContains(t, err, "users.json")
+}
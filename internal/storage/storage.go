@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+var (
+	// ErrNotFound — объекта с таким ключом нет
+	ErrNotFound = errors.New("blob not found")
+	// ErrInvalidKey — ключ пустой или пытается выйти за пределы хранилища
+	ErrInvalidKey = errors.New("invalid blob key")
+	// ErrTooLarge — файл превышает лимит Policy.MaxSize
+	ErrTooLarge = errors.New("file too large")
+	// ErrTypeNotAllowed — MIME-тип файла не входит в Policy.AllowedTypes
+	ErrTypeNotAllowed = errors.New("file type not allowed")
+)
+
+// BlobStore — хранилище двоичных объектов (аватары, вложения и т.п.)
+type BlobStore interface {
+	// Put сохраняет объект под ключом key, перезаписывая существующий
+	Put(key string, data []byte, contentType string) error
+	// Open открывает объект на чтение и возвращает его MIME-тип
+	Open(key string) (io.ReadCloser, string, error)
+	// Delete удаляет объект; удаление отсутствующего объекта — не ошибка
+	Delete(key string) error
+}
+
+// Object описывает сохранённый объект
+type Object struct {
+	Key         string
+	ContentType string
+	Size        int64
+}
+
+// Policy — ограничения на загружаемые файлы
+type Policy struct {
+	MaxSize      int64    // максимальный размер в байтах
+	AllowedTypes []string // допустимые MIME-типы (по содержимому, а не по имени файла)
+}
+
+// DefaultImageTypes — типы изображений, принимаемые по умолчанию
+var DefaultImageTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp"}
+
+// Allows сообщает, разрешён ли MIME-тип
+func (p Policy) Allows(contentType string) bool {
+	for _, t := range p.AllowedTypes {
+		if strings.EqualFold(t, contentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Read читает не более MaxSize байт из r, определяет MIME-тип по содержимому и проверяет его
+func (p Policy) Read(r io.Reader) ([]byte, string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, p.MaxSize+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read file: %w", err)
+	}
+	if int64(len(data)) > p.MaxSize {
+		return nil, "", ErrTooLarge
+	}
+
+	contentType := Sniff(data)
+	if !p.Allows(contentType) {
+		return nil, "", fmt.Errorf("%w: %s", ErrTypeNotAllowed, contentType)
+	}
+	return data, contentType, nil
+}
+
+// Sniff определяет MIME-тип по первым байтам содержимого (без параметров вроде charset)
+func Sniff(data []byte) string {
+	contentType := http.DetectContentType(data)
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = strings.TrimSpace(contentType[:i])
+	}
+	return contentType
+}
+
+// Save проверяет файл по политике и сохраняет его под ключом, вычисленным из содержимого:
+// prefix/<sha256><ext>. Имя файла от клиента в ключ не попадает.
+func Save(store BlobStore, prefix string, r io.Reader, p Policy) (Object, error) {
+	data, contentType, err := p.Read(r)
+	if err != nil {
+		return Object{}, err
+	}
+	return Put(store, prefix, data, contentType)
+}
+
+// Put сохраняет уже проверенные данные под ключом, вычисленным из содержимого
+func Put(store BlobStore, prefix string, data []byte, contentType string) (Object, error) {
+	key := ContentKey(prefix, data, contentType)
+	if err := store.Put(key, data, contentType); err != nil {
+		return Object{}, fmt.Errorf("failed to store blob: %w", err)
+	}
+	return Object{Key: key, ContentType: contentType, Size: int64(len(data))}, nil
+}
+
+// ContentKey строит ключ prefix/<sha256><ext>
+func ContentKey(prefix string, data []byte, contentType string) string {
+	sum := sha256.Sum256(data)
+	return path.Join(prefix, hex.EncodeToString(sum[:])+Extension(contentType))
+}
+
+// Extension подбирает расширение файла для MIME-типа
+func Extension(contentType string) string {
+	switch contentType {
+	case "image/png":
+		return ".png"
+	case "image/jpeg":
+		return ".jpg"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	case "image/svg+xml":
+		return ".svg"
+	case "application/pdf":
+		return ".pdf"
+	case "text/plain":
+		return ".txt"
+	}
+	if exts, _ := mime.ExtensionsByType(contentType); len(exts) > 0 {
+		return exts[0]
+	}
+	return ".bin"
+}
+
+// ValidateKey проверяет, что ключ относительный, без ".." и лишних разделителей
+func ValidateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") || path.Clean(key) != key {
+		return ErrInvalidKey
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "." || part == ".." {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package storage_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-portfolio/websocket-chat/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// pngHeader — сигнатура PNG, достаточная для определения типа по содержимому
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// readBlob читает объект целиком
+func readBlob(t *testing.T, s storage.BlobStore, key string) ([]byte, string) {
+	rc, ct, err := s.Open(key)
+	if err != nil {
+		t.Fatalf("open %s: %v", key, err)
+	}
+	defer rc.Close()
+	data, _ := io.ReadAll(rc)
+	return data, ct
+}
+
+/* ==========================
+   Save и Policy
+   ========================== */
+
+// Ключ вычисляется из содержимого, тип — по сигнатуре файла
+func TestSave_ContentAddressedKey(t *testing.T) {
+	store := storage.NewMemoryStore()
+	policy := storage.Policy{MaxSize: 1024, AllowedTypes: storage.DefaultImageTypes}
+
+	obj, err := storage.Save(store, "avatars", bytes.NewReader(pngHeader), policy)
+	assert.NoError(t, err)
+
+	sum := sha256.Sum256(pngHeader)
+	assert.Equal(t, "avatars/"+hex.EncodeToString(sum[:])+".png", obj.Key)
+	assert.Equal(t, "image/png", obj.ContentType)
+	assert.Equal(t, int64(len(pngHeader)), obj.Size)
+
+	data, ct := readBlob(t, store, obj.Key)
+	assert.Equal(t, pngHeader, data)
+	assert.Equal(t, "image/png", ct)
+}
+
+// Файл больше лимита отклоняется
+func TestSave_TooLarge(t *testing.T) {
+	store := storage.NewMemoryStore()
+	policy := storage.Policy{MaxSize: 4, AllowedTypes: storage.DefaultImageTypes}
+
+	_, err := storage.Save(store, "avatars", bytes.NewReader(pngHeader), policy)
+	assert.ErrorIs(t, err, storage.ErrTooLarge)
+	assert.Empty(t, store.Keys())
+}
+
+// HTML под видом картинки отклоняется: тип определяется по содержимому
+func TestSave_TypeNotAllowed(t *testing.T) {
+	store := storage.NewMemoryStore()
+	policy := storage.Policy{MaxSize: 1024, AllowedTypes: storage.DefaultImageTypes}
+
+	_, err := storage.Save(store, "avatars", strings.NewReader("<html><script>alert(1)</script>"), policy)
+	assert.ErrorIs(t, err, storage.ErrTypeNotAllowed)
+}
+
+/* ==========================
+   LocalStore
+   ========================== */
+
+func TestLocalStore_PutOpenDelete(t *testing.T) {
+	store, err := storage.NewLocalStore(t.TempDir())
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.Put("avatars/a.png", pngHeader, "image/png"))
+	data, ct := readBlob(t, store, "avatars/a.png")
+	assert.Equal(t, pngHeader, data)
+	assert.Equal(t, "image/png", ct)
+
+	assert.NoError(t, store.Delete("avatars/a.png"))
+	_, _, err = store.Open("avatars/a.png")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	// Повторное удаление — не ошибка
+	assert.NoError(t, store.Delete("avatars/a.png"))
+}
+
+// Ключи с ".." и абсолютные пути не выходят за пределы каталога
+func TestLocalStore_RejectsTraversal(t *testing.T) {
+	root := t.TempDir()
+	store, _ := storage.NewLocalStore(filepath.Join(root, "uploads"))
+
+	for _, key := range []string{"../evil.txt", "a/../../evil.txt", "/etc/passwd", "a//b", ""} {
+		err := store.Put(key, []byte("x"), "text/plain")
+		assert.ErrorIs(t, err, storage.ErrInvalidKey, key)
+	}
+
+	_, err := os.Stat(filepath.Join(root, "evil.txt"))
+	assert.True(t, os.IsNotExist(err), "файл не должен появиться вне каталога хранилища")
+}
+
+/* ==========================
+   S3Store против локального фейка
+   ========================== */
+
+// fakeS3 — минимальный S3: PUT/GET/DELETE объектов в памяти с проверкой подписи
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	authz := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authz, "AWS4-HMAC-SHA256 Credential=AKID/") ||
+		!strings.Contains(authz, "SignedHeaders=") || !strings.Contains(authz, "host") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	sum := sha256.Sum256(body)
+	if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("<Error><Code>XAmzContentSHA256Mismatch</Code></Error>"))
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[r.URL.Path] = body
+		f.types[r.URL.Path] = r.Header.Get("Content-Type")
+	case http.MethodGet:
+		data, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", f.types[r.URL.Path])
+		_, _ = w.Write(data)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3Store_RoundTrip(t *testing.T) {
+	fake := &fakeS3{objects: map[string][]byte{}, types: map[string]string{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	store := storage.NewS3Store(srv.URL, "chat", "us-east-1", "AKID", "secret")
+
+	assert.NoError(t, store.Put("avatars/a.png", pngHeader, "image/png"))
+	assert.Contains(t, fake.objects, "/chat/avatars/a.png", "path-style адресация: /<bucket>/<key>")
+
+	data, ct := readBlob(t, store, "avatars/a.png")
+	assert.Equal(t, pngHeader, data)
+	assert.Equal(t, "image/png", ct)
+
+	assert.NoError(t, store.Delete("avatars/a.png"))
+	_, _, err := store.Open("avatars/a.png")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+// Ошибка сервера возвращается вместе с телом ответа
+func TestS3Store_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte("<Error><Code>AccessDenied</Code></Error>"))
+	}))
+	defer srv.Close()
+
+	store := storage.NewS3Store(srv.URL, "chat", "us-east-1", "AKID", "secret")
+	err := store.Put("a.png", pngHeader, "image/png")
+	assert.ErrorContains(t, err, "AccessDenied")
+}
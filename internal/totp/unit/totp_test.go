@@ -0,0 +1,68 @@
+package unit
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/totp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Секрет из приложения B RFC 6238 ("12345678901234567890") в base32
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode_RFC6238(t *testing.T) {
+	// Последние 6 цифр 8-значных кодов SHA1 из таблицы RFC
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		code, err := totp.Code(rfcSecret, totp.Step(time.Unix(tt.unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, code, "t=%d", tt.unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	now := time.Unix(1_700_000_000, 0)
+	step := totp.Step(now)
+
+	code, _ := totp.Code(secret, step-1)
+	got, ok := totp.Validate(secret, code, now, 1)
+	assert.True(t, ok, "код предыдущего шага принимается")
+	assert.Equal(t, step-1, got)
+
+	code, _ = totp.Code(secret, step+2)
+	_, ok = totp.Validate(secret, code, now, 1)
+	assert.False(t, ok, "код вне окна")
+
+	_, ok = totp.Validate(secret, "12345", now, 1)
+	assert.False(t, ok)
+	_, ok = totp.Validate("not base32!", "123456", now, 1)
+	assert.False(t, ok)
+}
+
+func TestURI(t *testing.T) {
+	uri := totp.URI("Go Chat", "alice", "JBSWY3DPEHPK3PXP")
+	u, err := url.Parse(uri)
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/Go Chat:alice", u.Path)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", u.Query().Get("secret"))
+	assert.Equal(t, "Go Chat", u.Query().Get("issuer"))
+	assert.Equal(t, "6", u.Query().Get("digits"))
+}
@@ -0,0 +1,412 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+
+	"github.com/go-portfolio/websocket-chat/internal/chat"
+)
+
+var (
+	// ErrBlockedAddress — адрес относится к внутренней сети и не разрешён явно
+	ErrBlockedAddress = errors.New("address is not allowed")
+	// ErrNotHTML — по ссылке не HTML-страница
+	ErrNotHTML = errors.New("not an html page")
+)
+
+// Options — параметры получения превью
+type Options struct {
+	Timeout     time.Duration // общий таймаут запроса, включая редиректы
+	MaxBytes    int64         // сколько байт страницы читать максимум
+	MaxURLs     int           // сколько ссылок одного сообщения разворачивать
+	MaxRedirect int           // сколько редиректов допускается
+	CacheTTL    time.Duration // время жизни результата в кэше
+	CacheSize   int           // максимальное число записей в кэше
+	Concurrency int           // сколько сообщений обрабатывается одновременно
+	// Allow — сети (CIDR) или отдельные IP, к которым разрешено обращаться,
+	// даже если они приватные (например, внутренняя вики)
+	Allow []string
+}
+
+// DefaultOptions возвращает безопасные значения по умолчанию
+func DefaultOptions() Options {
+	return Options{
+		Timeout:     5 * time.Second,
+		MaxBytes:    512 << 10,
+		MaxURLs:     3,
+		MaxRedirect: 3,
+		CacheTTL:    time.Hour,
+		CacheSize:   1024,
+		Concurrency: 8,
+	}
+}
+
+// Unfurler разворачивает ссылки из сообщений в карточки (OpenGraph)
+// и рассылает их отдельным событием "message_unfurled"
+type Unfurler struct {
+	Hub     *chat.Hub
+	Options Options
+
+	client *http.Client
+	allow  []*net.IPNet
+	sem    chan struct{}
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	preview *chat.LinkPreview // nil — у страницы нет превью
+	expires time.Time
+}
+
+var _ chat.MessageObserver = (*Unfurler)(nil)
+
+// New создаёт Unfurler; ошибка возвращается при некорректном списке Allow
+func New(hub *chat.Hub, opts Options) (*Unfurler, error) {
+	u := &Unfurler{
+		Hub:     hub,
+		Options: opts,
+		sem:     make(chan struct{}, max(1, opts.Concurrency)),
+		cache:   make(map[string]cacheEntry),
+	}
+
+	for _, item := range opts.Allow {
+		if !strings.Contains(item, "/") {
+			if ip := net.ParseIP(item); ip != nil && ip.To4() != nil {
+				item += "/32"
+			} else {
+				item += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid unfurl allow entry %q: %w", item, err)
+		}
+		u.allow = append(u.allow, network)
+	}
+
+	// Проверка адреса выполняется в момент соединения, уже после DNS-резолва:
+	// так не обойти защиту ни редиректом, ни DNS rebinding
+	dialer := &net.Dialer{
+		Timeout: opts.Timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if !u.allowed(net.ParseIP(host)) {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+			}
+			return nil
+		},
+	}
+
+	u.client = &http.Client{
+		Timeout: opts.Timeout,
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   opts.Timeout,
+			ResponseHeaderTimeout: opts.Timeout,
+			MaxIdleConns:          16,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > opts.MaxRedirect {
+				return fmt.Errorf("too many redirects")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("unsupported redirect scheme %q", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+	return u, nil
+}
+
+// allowed решает, можно ли соединяться с IP
+func (u *Unfurler) allowed(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range u.allow {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return isPublic(ip)
+}
+
+// cgnat — разделяемое адресное пространство провайдеров (RFC 6598)
+var cgnat = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// isPublic сообщает, является ли адрес публичным (не loopback, не приватный и т.п.)
+func isPublic(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		if ip4[0] == 0 || cgnat.Contains(ip4) {
+			return false
+		}
+	}
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
+
+// urlRe находит http(s)-ссылки в тексте
+var urlRe = regexp.MustCompile(`https?://[^\s<>"'` + "`" + `]+`)
+
+// ExtractURLs возвращает уникальные ссылки из текста (не больше limit)
+func ExtractURLs(text string, limit int) []string {
+	var urls []string
+	seen := make(map[string]bool)
+	for _, raw := range urlRe.FindAllString(text, -1) {
+		raw = strings.TrimRight(raw, ".,;:!?)]}")
+		if seen[raw] {
+			continue
+		}
+		if u, err := url.Parse(raw); err != nil || u.Host == "" {
+			continue
+		}
+		seen[raw] = true
+		urls = append(urls, raw)
+		if len(urls) == limit {
+			break
+		}
+	}
+	return urls
+}
+
+// MessageSent запускает асинхронное получение превью для ссылок из сообщения
+func (u *Unfurler) MessageSent(msg chat.ChatMessage) {
+	switch msg.Type {
+	case "", "message", "private", "attachment":
+	default:
+		return
+	}
+	urls := ExtractURLs(msg.Text, u.Options.MaxURLs)
+	if len(urls) == 0 {
+		return
+	}
+
+	// При перегрузке превью пропускаются, а не копятся в очереди
+	select {
+	case u.sem <- struct{}{}:
+	default:
+		return
+	}
+
+	go func() {
+		defer func() { <-u.sem }()
+
+		var previews []chat.LinkPreview
+		for _, link := range urls {
+			if p := u.Preview(link); p != nil {
+				previews = append(previews, *p)
+			}
+		}
+		if len(previews) == 0 {
+			return
+		}
+
+		u.Hub.Deliver(chat.ChatMessage{
+			ID:        msg.ID,
+			Type:      "message_unfurled",
+			From:      msg.From,
+			To:        msg.To,
+			Room:      msg.Room,
+			Timestamp: time.Now().Unix(),
+			Previews:  previews,
+		})
+	}()
+}
+
+// Preview возвращает превью ссылки из кэша или загружает его; nil — превью нет
+func (u *Unfurler) Preview(link string) *chat.LinkPreview {
+	now := time.Now()
+
+	u.mu.Lock()
+	if e, ok := u.cache[link]; ok && now.Before(e.expires) {
+		u.mu.Unlock()
+		return e.preview
+	}
+	u.mu.Unlock()
+
+	p, err := u.Fetch(link)
+	if err != nil {
+		p = nil
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if len(u.cache) >= u.Options.CacheSize {
+		u.evict(now)
+	}
+	u.cache[link] = cacheEntry{preview: p, expires: now.Add(u.Options.CacheTTL)}
+	return p
+}
+
+// evict удаляет просроченные записи, а если их нет — одну произвольную
+func (u *Unfurler) evict(now time.Time) {
+	for k, e := range u.cache {
+		if now.After(e.expires) {
+			delete(u.cache, k)
+		}
+	}
+	for k := range u.cache {
+		if len(u.cache) < u.Options.CacheSize {
+			break
+		}
+		delete(u.cache, k)
+	}
+}
+
+// Fetch загружает страницу и извлекает из неё OpenGraph-метаданные
+func (u *Unfurler) Fetch(link string) (*chat.LinkPreview, error) {
+	target, err := url.Parse(link)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") {
+		return nil, fmt.Errorf("unsupported url %q", link)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), u.Options.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "websocket-chat-unfurler/1.0")
+	req.Header.Set("Accept", "text/html")
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, ErrNotHTML
+	}
+
+	p := parse(io.LimitReader(resp.Body, u.Options.MaxBytes), resp.Request.URL)
+	if p.Title == "" && p.Description == "" && p.Image == "" {
+		return nil, nil
+	}
+	p.URL = link
+	return p, nil
+}
+
+// parse разбирает <head> страницы: og:* с откатом на <title> и meta description
+func parse(r io.Reader, base *url.URL) *chat.LinkPreview {
+	var (
+		p       chat.LinkPreview
+		title   string
+		desc    string
+		inTitle bool
+	)
+
+	z := html.NewTokenizer(r)
+loop:
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			break loop
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch string(name) {
+			case "body":
+				break loop // метаданные бывают только в <head>
+			case "title":
+				inTitle = tt == html.StartTagToken
+			case "meta":
+				if !hasAttr {
+					continue
+				}
+				var key, content string
+				for {
+					k, v, more := z.TagAttr()
+					switch string(k) {
+					case "property", "name":
+						key = strings.ToLower(string(v))
+					case "content":
+						content = string(v)
+					}
+					if !more {
+						break
+					}
+				}
+				switch key {
+				case "og:title":
+					p.Title = content
+				case "og:description":
+					p.Description = content
+				case "og:image":
+					p.Image = resolve(base, content)
+				case "og:site_name":
+					p.SiteName = content
+				case "description":
+					desc = content
+				}
+			}
+		case html.TextToken:
+			if inTitle {
+				title += string(z.Text())
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "title" {
+				inTitle = false
+			} else if string(name) == "head" {
+				break loop
+			}
+		}
+	}
+
+	if p.Title == "" {
+		p.Title = title
+	}
+	if p.Description == "" {
+		p.Description = desc
+	}
+	p.Title = truncate(p.Title, 200)
+	p.Description = truncate(p.Description, 500)
+	p.SiteName = truncate(p.SiteName, 100)
+	return &p
+}
+
+// resolve превращает относительную ссылку в абсолютную; допускаются только http(s)
+func resolve(base *url.URL, ref string) string {
+	u, err := base.Parse(strings.TrimSpace(ref))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return u.String()
+}
+
+// truncate схлопывает пробелы и обрезает строку до n символов
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
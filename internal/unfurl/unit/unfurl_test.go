@@ -0,0 +1,184 @@
+package unfurl_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/chat"
+	"github.com/go-portfolio/websocket-chat/internal/unfurl"
+	"github.com/stretchr/testify/assert"
+)
+
+const ogPage = `<!DOCTYPE html><html><head>
+<title>Fallback title</title>
+<meta property="og:title" content="Go 1.24 released">
+<meta property="og:description" content="  Release   notes  ">
+<meta property="og:image" content="/img/cover.png">
+<meta property="og:site_name" content="The Go Blog">
+</head><body><meta property="og:title" content="ignored"></body></html>`
+
+// newUnfurler создаёт Unfurler, которому разрешён loopback (там живёт httptest-сервер)
+func newUnfurler(t *testing.T, hub *chat.Hub, allowLocal bool) *unfurl.Unfurler {
+	opts := unfurl.DefaultOptions()
+	opts.Timeout = 500 * time.Millisecond
+	if allowLocal {
+		opts.Allow = []string{"127.0.0.0/8", "::1"}
+	}
+	u, err := unfurl.New(hub, opts)
+	if err != nil {
+		t.Fatalf("new unfurler: %v", err)
+	}
+	return u
+}
+
+// htmlServer отдаёт page и считает запросы
+func htmlServer(page string, hits *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits != nil {
+			atomic.AddInt32(hits, 1)
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(page))
+	}))
+}
+
+// OpenGraph-метаданные извлекаются, относительная картинка становится абсолютной
+func TestFetch_OpenGraph(t *testing.T) {
+	srv := htmlServer(ogPage, nil)
+	defer srv.Close()
+
+	p, err := newUnfurler(t, nil, true).Fetch(srv.URL + "/post")
+	assert.NoError(t, err)
+	assert.Equal(t, "Go 1.24 released", p.Title)
+	assert.Equal(t, "Release notes", p.Description)
+	assert.Equal(t, srv.URL+"/img/cover.png", p.Image)
+	assert.Equal(t, "The Go Blog", p.SiteName)
+	assert.Equal(t, srv.URL+"/post", p.URL)
+}
+
+// Без og:* используются <title> и meta description
+func TestFetch_Fallbacks(t *testing.T) {
+	srv := htmlServer(`<html><head><title>Plain</title><meta name="description" content="Desc"></head></html>`, nil)
+	defer srv.Close()
+
+	p, err := newUnfurler(t, nil, true).Fetch(srv.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "Plain", p.Title)
+	assert.Equal(t, "Desc", p.Description)
+}
+
+// Приватные адреса блокируются, если не разрешены явно (SSRF)
+func TestFetch_BlocksPrivateAddresses(t *testing.T) {
+	srv := htmlServer(ogPage, nil)
+	defer srv.Close()
+
+	_, err := newUnfurler(t, nil, false).Fetch(srv.URL)
+	assert.ErrorIs(t, err, unfurl.ErrBlockedAddress)
+
+	for _, link := range []string{"http://10.0.0.1/", "http://169.254.169.254/latest/meta-data/", "http://[::1]/"} {
+		_, err := newUnfurler(t, nil, false).Fetch(link)
+		assert.ErrorIs(t, err, unfurl.ErrBlockedAddress, link)
+	}
+}
+
+// Не-HTML ответы не разворачиваются
+func TestFetch_NotHTML(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write([]byte("binary"))
+	}))
+	defer srv.Close()
+
+	_, err := newUnfurler(t, nil, true).Fetch(srv.URL)
+	assert.ErrorIs(t, err, unfurl.ErrNotHTML)
+}
+
+// Читается не больше MaxBytes: метаданные дальше лимита не видны
+func TestFetch_SizeLimit(t *testing.T) {
+	page := "<html><head>" + strings.Repeat("<!-- padding -->", 2000) + `<meta property="og:title" content="late"></head></html>`
+	srv := htmlServer(page, nil)
+	defer srv.Close()
+
+	opts := unfurl.DefaultOptions()
+	opts.MaxBytes = 1024
+	opts.Allow = []string{"127.0.0.0/8", "::1"}
+	u, _ := unfurl.New(nil, opts)
+
+	p, err := u.Fetch(srv.URL)
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+}
+
+// Медленный сервер обрывается по таймауту
+func TestFetch_Timeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Second)
+	}))
+	defer srv.Close()
+
+	start := time.Now()
+	_, err := newUnfurler(t, nil, true).Fetch(srv.URL)
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 900*time.Millisecond)
+}
+
+// Повторные запросы одной ссылки берутся из кэша
+func TestPreview_Cache(t *testing.T) {
+	var hits int32
+	srv := htmlServer(ogPage, &hits)
+	defer srv.Close()
+
+	u := newUnfurler(t, nil, true)
+	assert.NotNil(t, u.Preview(srv.URL))
+	assert.NotNil(t, u.Preview(srv.URL))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+}
+
+func TestExtractURLs(t *testing.T) {
+	text := "см. https://go.dev/blog, (http://example.com/a?b=1) и снова https://go.dev/blog."
+	assert.Equal(t, []string{"https://go.dev/blog", "http://example.com/a?b=1"}, unfurl.ExtractURLs(text, 5))
+	assert.Len(t, unfurl.ExtractURLs(text, 1), 1)
+	assert.Empty(t, unfurl.ExtractURLs("без ссылок", 5))
+}
+
+// mockClient — минимальный chat.UserClient для проверки рассылки
+type mockClient struct {
+	name, room string
+	ch         chan chat.ChatMessage
+}
+
+func (m *mockClient) GetUsername() string                         { return m.name }
+func (m *mockClient) GetRoomName() string                         { return m.room }
+func (m *mockClient) SendMessage(msg chat.ChatMessage) error      { m.ch <- msg; return nil }
+func (m *mockClient) ReceivePrivateChan() <-chan chat.ChatMessage { return m.ch }
+func (m *mockClient) Close() error                                { return nil }
+func (m *mockClient) PrivateChan() chan chat.ChatMessage          { return m.ch }
+
+// После сообщения со ссылкой комната получает событие message_unfurled с тем же ID
+func TestMessageSent_BroadcastsUnfurled(t *testing.T) {
+	srv := htmlServer(ogPage, nil)
+	defer srv.Close()
+
+	hub := chat.NewHub()
+	client := &mockClient{name: "bob", room: "tech", ch: make(chan chat.ChatMessage, 10)}
+	hub.GetRoom("tech").AddClient(client)
+
+	u := newUnfurler(t, hub, true)
+	u.MessageSent(chat.ChatMessage{ID: "m1", Type: "message", From: "alice", Room: "tech", Text: fmt.Sprintf("look %s/post", srv.URL)})
+
+	select {
+	case got := <-client.ch:
+		assert.Equal(t, "message_unfurled", got.Type)
+		assert.Equal(t, "m1", got.ID)
+		if assert.Len(t, got.Previews, 1) {
+			assert.Equal(t, "Go 1.24 released", got.Previews[0].Title)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("событие message_unfurled не пришло")
+	}
+}
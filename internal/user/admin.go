@@ -0,0 +1,139 @@
+package user
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrNotFound — пользователя нет
+var ErrNotFound = errors.New("user not found")
+
+// Info — сведения о пользователе для администрирования
+type Info struct {
+	Username   string     `json:"username"`
+	Email      string     `json:"email,omitempty"`
+	Admin      bool       `json:"admin"`
+	BannedAt   *time.Time `json:"banned_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+}
+
+// List возвращает всех пользователей по алфавиту
+func (s *Store) List() ([]Info, error) {
+	rows, err := s.Db.Query(`SELECT username, COALESCE(email, ''), is_admin, banned_at,
+		COALESCE(created_at, NOW()), last_seen_at FROM users ORDER BY username`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []Info{}
+	for rows.Next() {
+		var (
+			u                  Info
+			bannedAt, lastSeen sql.NullTime
+		)
+		if err := rows.Scan(&u.Username, &u.Email, &u.Admin, &bannedAt, &u.CreatedAt, &lastSeen); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		if bannedAt.Valid {
+			u.BannedAt = &bannedAt.Time
+		}
+		if lastSeen.Valid {
+			u.LastSeenAt = &lastSeen.Time
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// Delete удаляет пользователя; его участие в комнатах, вложения и уведомления
+// удаляются каскадно, сообщения в истории остаются
+func (s *Store) Delete(username string) error {
+	return s.update(`DELETE FROM users WHERE username=$1`, username)
+}
+
+// SetPassword задаёт новый пароль; он проверяется той же политикой, что и при регистрации.
+// Выданные ссылки сброса пароля перестают действовать
+func (s *Store) SetPassword(username, password string) error {
+	if err := s.Policy.Check(username, password); err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE users SET password_hash=$2 WHERE username=$1`, username, string(hash))
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	if _, err := tx.Exec(`DELETE FROM password_resets WHERE username=$1`, username); err != nil {
+		return fmt.Errorf("failed to delete reset tokens: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+	return nil
+}
+
+// SetAdmin выдаёт или снимает права администратора сервера
+func (s *Store) SetAdmin(username string, admin bool) error {
+	return s.update(`UPDATE users SET is_admin=$2 WHERE username=$1`, username, admin)
+}
+
+// SetBanned блокирует пользователя (войти и подключиться нельзя) или снимает блокировку
+func (s *Store) SetBanned(username string, banned bool) error {
+	return s.update(`UPDATE users SET banned_at=CASE WHEN $2 THEN COALESCE(banned_at, NOW()) END WHERE username=$1`, username, banned)
+}
+
+// IsAdmin сообщает, является ли пользователь администратором сервера
+func (s *Store) IsAdmin(username string) (bool, error) {
+	var admin bool
+	err := s.Db.QueryRow(`SELECT is_admin FROM users WHERE username=$1`, username).Scan(&admin)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check admin: %w", err)
+	}
+	return admin, nil
+}
+
+// IsBanned сообщает, заблокирован ли пользователь
+func (s *Store) IsBanned(username string) (bool, error) {
+	var banned bool
+	err := s.Db.QueryRow(`SELECT banned_at IS NOT NULL FROM users WHERE username=$1`, username).Scan(&banned)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check ban: %w", err)
+	}
+	return banned, nil
+}
+
+// update выполняет изменение одного пользователя; ErrNotFound — пользователя нет
+func (s *Store) update(query string, args ...any) error {
+	res, err := s.Db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
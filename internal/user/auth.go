@@ -2,8 +2,11 @@ package user
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"net/mail"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -11,11 +14,8 @@ import (
 
 func (s *Store) Register(username, password, avatar string) error {
 	username = strings.TrimSpace(username)
-	if username == "" || password == "" {
-		return fmt.Errorf("username and password are required")
-	}
-	if len(username) > 24 {
-		return fmt.Errorf("username too long (max 24)")
+	if err := s.CheckCredentials(username, password); err != nil {
+		return err
 	}
 
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -42,14 +42,53 @@ func (s *Store) Register(username, password, avatar string) error {
 	return nil
 }
 
+func (s *Store) CheckCredentials(username, password string) error {
+	username = strings.TrimSpace(username)
+	if username == "" || password == "" {
+		return fmt.Errorf("username and password are required")
+	}
+	if len(username) > 24 {
+		return fmt.Errorf("username too long (max 24)")
+	}
+	return s.Policy.Check(username, password)
+}
+
+// EnsurePlaceholder создаёт пользователя без пароля (войти под ним нельзя), если его ещё нет.
+// Нужен для импорта истории: авторы старых сообщений могут так и не зарегистрироваться.
+func (s *Store) EnsurePlaceholder(username string) (bool, error) {
+	res, err := s.Db.Exec(
+		`INSERT INTO users (username, password_hash) VALUES ($1, '!') ON CONFLICT (username) DO NOTHING`,
+		username,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to create placeholder user: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+// dummyHash сверяется с паролем, когда сверять не с чем: ответ для несуществующего
+// пользователя занимает столько же времени, сколько для существующего
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("no such user"), bcrypt.DefaultCost)
+	return hash
+})
+
 func (s *Store) Authenticate(username, password string) bool {
 	var hash string
-	query := `SELECT password_hash FROM users WHERE username=$1`
-	err := s.Db.QueryRow(query, username).Scan(&hash)
-	if err != nil {
+	// Заблокированные пользователи войти не могут
+	query := `SELECT password_hash FROM users WHERE username=$1 AND banned_at IS NULL`
+	if err := s.Db.QueryRow(query, username).Scan(&hash); err != nil {
+		_ = bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
 		return false
 	}
-	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if err != nil && !errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		// У пользователей без пароля (заглушки, вход через OIDC) хэш "!" отклоняется
+		// сразу — по времени ответа их не отличить
+		_ = bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+	}
+	return err == nil
 }
 
 func (s *Store) GetAvatar(username string) string {
@@ -58,3 +97,78 @@ func (s *Store) GetAvatar(username string) string {
 	_ = row.Scan(&avatar)
 	return avatar
 }
+
+func (s *Store) SetAvatar(username, avatar string) error {
+	avatarValue := sql.NullString{String: avatar, Valid: avatar != ""}
+
+	res, err := s.Db.Exec(`UPDATE users SET avatar=$1 WHERE username=$2`, avatarValue, username)
+	if err != nil {
+		return fmt.Errorf("failed to update avatar: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
+
+// Exists сообщает, зарегистрирован ли пользователь
+func (s *Store) Exists(username string) (bool, error) {
+	var exists bool
+	err := s.Db.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE username=$1)`, username).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check user: %w", err)
+	}
+	return exists, nil
+}
+
+func (s *Store) Touch(username string) error {
+	if _, err := s.Db.Exec(`UPDATE users SET last_seen_at=NOW() WHERE username=$1`, username); err != nil {
+		return fmt.Errorf("failed to update last seen: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) GetEmail(username string) (string, bool, error) {
+	var (
+		email  sql.NullString
+		digest bool
+	)
+	err := s.Db.QueryRow(`SELECT email, email_digest FROM users WHERE username=$1`, username).Scan(&email, &digest)
+	if err == sql.ErrNoRows {
+		return "", false, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get email: %w", err)
+	}
+	return email.String, digest, nil
+}
+
+// ErrInvalidEmail — адрес не похож на e-mail
+var ErrInvalidEmail = errors.New("invalid email")
+
+// SetEmail сохраняет e-mail и согласие на дайджесты; без адреса дайджесты отключаются.
+// Новый адрес считается неподтверждённым, пока пользователь не перейдёт по ссылке из письма
+func (s *Store) SetEmail(username, email string, digest bool) error {
+	email = strings.TrimSpace(email)
+	if email != "" {
+		addr, err := mail.ParseAddress(email)
+		if err != nil || addr.Address != email || len(email) > 254 {
+			return ErrInvalidEmail
+		}
+	}
+	emailValue := sql.NullString{String: email, Valid: email != ""}
+
+	res, err := s.Db.Exec(
+		`UPDATE users SET email=$1, email_digest=$2,
+			email_verified_at = CASE WHEN email IS NOT DISTINCT FROM $1 THEN email_verified_at END
+		WHERE username=$3`,
+		emailValue, digest && email != "", username,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update email: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
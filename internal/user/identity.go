@@ -0,0 +1,199 @@
+package user
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrIdentityLinked — внешний аккаунт уже привязан к другому пользователю
+var ErrIdentityLinked = errors.New("identity is linked to another user")
+
+// Identity — внешний аккаунт (например, у провайдера OpenID Connect)
+type Identity struct {
+	Issuer  string
+	Subject string
+	// Email попадает в профиль нового пользователя, только если провайдер его подтвердил
+	Email         string
+	EmailVerified bool
+	// Names — варианты имени пользователя в порядке предпочтения
+	Names []string
+}
+
+// IdentityStore связывает внешние аккаунты с пользователями
+type IdentityStore interface {
+	// ResolveIdentity возвращает пользователя, привязанного к внешнему аккаунту,
+	// а если его нет — создаёт нового без пароля и привязывает аккаунт к нему
+	ResolveIdentity(id Identity) (username string, created bool, err error)
+	// LinkIdentity привязывает внешний аккаунт к существующему пользователю
+	LinkIdentity(id Identity, username string) error
+}
+
+var _ IdentityStore = (*Store)(nil)
+
+// maxSuffix — сколько номеров перебирается, если все варианты имени заняты
+const maxSuffix = 50
+
+func (s *Store) ResolveIdentity(id Identity) (string, bool, error) {
+	username, err := s.identityUser(id)
+	if err == nil {
+		return username, false, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", false, err
+	}
+
+	email := sql.NullString{}
+	if addr, err := mail.ParseAddress(id.Email); id.EmailVerified && err == nil && addr.Address == id.Email && len(id.Email) <= 254 {
+		email = sql.NullString{String: id.Email, Valid: true}
+	}
+
+	for _, candidate := range usernameCandidates(id.Names) {
+		created, linked, err := s.provision(id, candidate, email)
+		if err != nil {
+			return "", false, err
+		}
+		if linked {
+			// Аккаунт успели привязать параллельным входом
+			username, err := s.identityUser(id)
+			return username, false, err
+		}
+		if created {
+			return candidate, true, nil
+		}
+	}
+	return "", false, fmt.Errorf("no free username for identity")
+}
+
+// provision создаёт пользователя username и привязывает к нему аккаунт в одной транзакции.
+// created=false — имя занято; linked=true — аккаунт уже привязан к кому-то другому.
+func (s *Store) provision(id Identity, username string, email sql.NullString) (created, linked bool, err error) {
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return false, false, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(
+		`INSERT INTO users (username, password_hash, email, email_verified_at, created_at)
+		VALUES ($1, '!', $2, CASE WHEN $2 IS NOT NULL THEN NOW() END, NOW())
+		ON CONFLICT (username) DO NOTHING`,
+		username, email,
+	)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to create user: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, false, nil
+	}
+
+	res, err = tx.Exec(
+		`INSERT INTO user_identities (issuer, subject, username, email) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (issuer, subject) DO NOTHING`,
+		id.Issuer, id.Subject, username, id.Email,
+	)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to link identity: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, true, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, false, fmt.Errorf("failed to commit: %w", err)
+	}
+	return true, false, nil
+}
+
+func (s *Store) LinkIdentity(id Identity, username string) error {
+	res, err := s.Db.Exec(
+		`INSERT INTO user_identities (issuer, subject, username, email) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (issuer, subject) DO NOTHING`,
+		id.Issuer, id.Subject, username, id.Email,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to link identity: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 1 {
+		return nil
+	}
+
+	linked, err := s.identityUser(id)
+	if err != nil {
+		return err
+	}
+	if linked != username {
+		return ErrIdentityLinked
+	}
+	return nil
+}
+
+func (s *Store) identityUser(id Identity) (string, error) {
+	var username string
+	err := s.Db.QueryRow(
+		`SELECT username FROM user_identities WHERE issuer=$1 AND subject=$2`,
+		id.Issuer, id.Subject,
+	).Scan(&username)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to find identity: %w", err)
+	}
+	return username, err
+}
+
+// usernameCandidates возвращает допустимые имена из names, а за ними —
+// первое из них с номерами 2, 3, ...
+func usernameCandidates(names []string) []string {
+	var bases []string
+	seen := map[string]bool{}
+	for _, name := range names {
+		if at := strings.IndexByte(name, '@'); at > 0 {
+			name = name[:at]
+		}
+		name = NormalizeUsername(name)
+		if name != "" && !seen[name] {
+			seen[name] = true
+			bases = append(bases, name)
+		}
+	}
+	if len(bases) == 0 {
+		bases = []string{"user"}
+	}
+
+	candidates := bases
+	for i := 2; i <= maxSuffix; i++ {
+		suffix := strconv.Itoa(i)
+		candidates = append(candidates, truncateRunes(bases[0], MaxUsernameLen-len(suffix))+suffix)
+	}
+	return candidates
+}
+
+// MaxUsernameLen — предельная длина имени пользователя в символах (как в схеме БД)
+const MaxUsernameLen = 24
+
+// NormalizeUsername приводит внешнее имя (OIDC, импорт Slack) к ограничениям чата:
+// оставляет буквы, цифры и _.-, заменяет пробелы на _ и обрезает до MaxUsernameLen символов
+func NormalizeUsername(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '-' {
+			return r
+		}
+		if unicode.IsSpace(r) {
+			return '_'
+		}
+		return -1
+	}, strings.TrimSpace(name))
+	return truncateRunes(strings.Trim(name, ".-"), MaxUsernameLen)
+}
+
+func truncateRunes(s string, n int) string {
+	for utf8.RuneCountInString(s) > n {
+		_, size := utf8.DecodeLastRuneInString(s)
+		s = s[:len(s)-size]
+	}
+	return s
+}
@@ -0,0 +1,204 @@
+package user
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrPasswordRequired — новый пароль не задан
+	ErrPasswordRequired = errors.New("password is required")
+	// ErrWrongPassword — текущий пароль указан неверно
+	ErrWrongPassword = errors.New("current password is incorrect")
+	// ErrResetToken — токена сброса нет, он истёк или уже использован
+	ErrResetToken = errors.New("invalid or expired reset token")
+)
+
+// PasswordReset — выданный токен сброса пароля. В базе хранится только SHA-256 токена,
+// сам он есть лишь в ссылке, отправленной пользователю
+type PasswordReset struct {
+	Username  string
+	Email     string
+	Token     string
+	ExpiresAt time.Time
+}
+
+// PasswordStore меняет и восстанавливает пароли. Ссылка сброса уходит только на
+// подтверждённый e-mail, поэтому здесь же выдаются токены подтверждения адреса
+type PasswordStore interface {
+	// ChangePassword меняет пароль, если current совпадает с нынешним
+	ChangePassword(username, current, password string) error
+	// CreatePasswordReset выдаёт токен сброса пользователю с таким именем или e-mail;
+	// ErrNotFound — такого пользователя нет, он заблокирован или его e-mail не подтверждён
+	CreatePasswordReset(login string, ttl time.Duration) (PasswordReset, error)
+	// ResetPassword задаёт новый пароль по токену и возвращает имя пользователя;
+	// токен одноразовый, остальные токены пользователя перестают действовать
+	ResetPassword(token, password string) (username string, err error)
+
+	// CreateEmailVerification выдаёт токен подтверждения текущего e-mail пользователя;
+	// ErrNotFound — пользователя нет или e-mail не указан
+	CreateEmailVerification(username string, ttl time.Duration) (EmailVerification, error)
+	// VerifyEmail подтверждает e-mail по токену и возвращает имя пользователя
+	VerifyEmail(token string) (username string, err error)
+	// EmailVerified сообщает, подтверждён ли текущий e-mail пользователя
+	EmailVerified(username string) (bool, error)
+}
+
+var _ PasswordStore = (*Store)(nil)
+
+func (s *Store) ChangePassword(username, current, password string) error {
+	if err := s.Policy.Check(username, password); err != nil {
+		return err
+	}
+	var oldHash string
+	err := s.Db.QueryRow(`SELECT password_hash FROM users WHERE username=$1`, username).Scan(&oldHash)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get password: %w", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(oldHash), []byte(current)) != nil {
+		return ErrWrongPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Хэш сверяется ещё раз: пароль мог смениться параллельно
+	res, err := tx.Exec(
+		`UPDATE users SET password_hash=$3 WHERE username=$1 AND password_hash=$2`,
+		username, oldHash, string(hash),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrWrongPassword
+	}
+	if _, err := tx.Exec(`DELETE FROM password_resets WHERE username=$1`, username); err != nil {
+		return fmt.Errorf("failed to delete reset tokens: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) CreatePasswordReset(login string, ttl time.Duration) (PasswordReset, error) {
+	login = strings.TrimSpace(login)
+	if login == "" {
+		return PasswordReset{}, ErrNotFound
+	}
+
+	var pr PasswordReset
+	// Точное совпадение имени важнее совпадения e-mail
+	err := s.Db.QueryRow(
+		`SELECT username, email FROM users
+		WHERE (username=$1 OR LOWER(email)=LOWER($1)) AND email_verified_at IS NOT NULL AND banned_at IS NULL
+		ORDER BY username=$1 DESC, username LIMIT 1`,
+		login,
+	).Scan(&pr.Username, &pr.Email)
+	if err == sql.ErrNoRows {
+		return pr, ErrNotFound
+	}
+	if err != nil {
+		return pr, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return pr, fmt.Errorf("failed to generate reset token: %w", err)
+	}
+	pr.Token = base64.RawURLEncoding.EncodeToString(b)
+	pr.ExpiresAt = time.Now().Add(ttl)
+
+	// Заодно убираем истёкшие и использованные токены пользователя
+	if _, err := s.Db.Exec(
+		`DELETE FROM password_resets WHERE username=$1 AND (used_at IS NOT NULL OR expires_at <= NOW())`,
+		pr.Username,
+	); err != nil {
+		return pr, fmt.Errorf("failed to delete old reset tokens: %w", err)
+	}
+	if _, err := s.Db.Exec(
+		`INSERT INTO password_resets (token_hash, username, expires_at) VALUES ($1, $2, $3)`,
+		hashCode(pr.Token), pr.Username, pr.ExpiresAt,
+	); err != nil {
+		return pr, fmt.Errorf("failed to save reset token: %w", err)
+	}
+	return pr, nil
+}
+
+func (s *Store) ResetPassword(token, password string) (string, error) {
+	// Имя пользователя станет известно по токену, с ним пароль сверяется ниже
+	if err := s.Policy.Check("", password); err != nil {
+		return "", err
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", ErrResetToken
+	}
+	// Неизвестный токен отклоняется до bcrypt: перебор токенов не должен нагружать сервер
+	var owner string
+	err := s.Db.QueryRow(
+		`SELECT username FROM password_resets WHERE token_hash=$1 AND used_at IS NULL AND expires_at > NOW()`,
+		hashCode(token),
+	).Scan(&owner)
+	if err == sql.ErrNoRows {
+		return "", ErrResetToken
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to find reset token: %w", err)
+	}
+	if err := s.Policy.Check(owner, password); err != nil {
+		return "", err // токен остаётся действительным
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Токен помечается использованным атомарно: параллельный сброс по нему не пройдёт
+	var username string
+	err = tx.QueryRow(
+		`UPDATE password_resets SET used_at=NOW()
+		WHERE token_hash=$1 AND used_at IS NULL AND expires_at > NOW() RETURNING username`,
+		hashCode(token),
+	).Scan(&username)
+	if err == sql.ErrNoRows {
+		return "", ErrResetToken
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to use reset token: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE users SET password_hash=$2 WHERE username=$1`, username, string(hash)); err != nil {
+		return "", fmt.Errorf("failed to update password: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM password_resets WHERE username=$1 AND used_at IS NULL`, username); err != nil {
+		return "", fmt.Errorf("failed to delete reset tokens: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit: %w", err)
+	}
+	return username, nil
+}
@@ -0,0 +1,101 @@
+package user
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrWeakPassword — пароль не соответствует политике паролей
+var ErrWeakPassword = errors.New("weak password")
+
+// maxPasswordBytes — bcrypt учитывает только первые 72 байта пароля
+const maxPasswordBytes = 72
+
+// PasswordPolicy — требования к новым паролям (регистрация, смена и сброс)
+type PasswordPolicy struct {
+	MinLength int          // минимальная длина в символах; 0 — любая непустая
+	Breached  BreachedList // утёкшие пароли; nil — не проверяются
+}
+
+// Check проверяет пароль пользователя username; пустой username — без сравнения с именем
+func (p PasswordPolicy) Check(username, password string) error {
+	if password == "" {
+		return ErrPasswordRequired
+	}
+	if n := utf8.RuneCountInString(password); n < p.MinLength {
+		return fmt.Errorf("%w: must be at least %d characters", ErrWeakPassword, p.MinLength)
+	}
+	if len(password) > maxPasswordBytes {
+		return fmt.Errorf("%w: must not exceed %d bytes", ErrWeakPassword, maxPasswordBytes)
+	}
+	if username != "" && strings.EqualFold(password, username) {
+		return fmt.Errorf("%w: must differ from the username", ErrWeakPassword)
+	}
+	if p.Breached.Contains(password) {
+		return fmt.Errorf("%w: found in a list of breached passwords", ErrWeakPassword)
+	}
+	return nil
+}
+
+// BreachedList — SHA-1 утёкших паролей
+type BreachedList map[[sha1.Size]byte]struct{}
+
+// Contains сообщает, есть ли пароль в списке (как есть или в нижнем регистре)
+func (b BreachedList) Contains(password string) bool {
+	if len(b) == 0 {
+		return false
+	}
+	if _, ok := b[sha1.Sum([]byte(password))]; ok {
+		return true
+	}
+	_, ok := b[sha1.Sum([]byte(strings.ToLower(password)))]
+	return ok
+}
+
+// LoadBreachedList читает список утёкших паролей: по одному в строке, либо сам пароль,
+// либо его SHA-1 в hex (формат выгрузки Have I Been Pwned, "HASH:count", тоже подходит).
+// Пустые строки и строки, начинающиеся с #, пропускаются.
+func LoadBreachedList(path string) (BreachedList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password list: %w", err)
+	}
+	defer f.Close()
+
+	list := BreachedList{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if sum, ok := parseSHA1(line); ok {
+			list[sum] = struct{}{}
+			continue
+		}
+		list[sha1.Sum([]byte(line))] = struct{}{}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read breached password list: %w", err)
+	}
+	return list, nil
+}
+
+// parseSHA1 разбирает строку вида "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8[:count]"
+func parseSHA1(line string) ([sha1.Size]byte, bool) {
+	var sum [sha1.Size]byte
+	digest, _, _ := strings.Cut(line, ":")
+	if len(digest) != hex.EncodedLen(sha1.Size) {
+		return sum, false
+	}
+	if _, err := hex.Decode(sum[:], []byte(digest)); err != nil {
+		return sum, false
+	}
+	return sum, true
+}
@@ -11,12 +11,24 @@ import (
 type UserStore interface {
 	io.Closer
 	Register(username, password, avatar string) error
+	// CheckCredentials проверяет имя и пароль так же, как Register, ничего не сохраняя
+	CheckCredentials(username, password string) error
 	Authenticate(username, password string) bool
 	GetAvatar(username string) string
+	SetAvatar(username, avatar string) error
+	Exists(username string) (bool, error)
+	// Touch запоминает время последней активности пользователя (подключение или отключение)
+	Touch(username string) error
+	// GetEmail возвращает e-mail и согласие на дайджесты; пустой e-mail — адрес не указан
+	GetEmail(username string) (email string, digest bool, err error)
+	SetEmail(username, email string, digest bool) error
+	IsAdmin(username string) (bool, error)
+	IsBanned(username string) (bool, error)
 }
 
 type Store struct {
-	Db *sql.DB
+	Db     *sql.DB
+	Policy PasswordPolicy // требования к паролям при регистрации, смене и сбросе
 }
 
 var _ UserStore = (*Store)(nil)
@@ -0,0 +1,294 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/totp"
+)
+
+var (
+	// ErrTwoFactorEnabled — 2FA уже включена, новый секрет не выдаётся
+	ErrTwoFactorEnabled = errors.New("two-factor authentication is already enabled")
+	// ErrTwoFactorDisabled — 2FA не включена
+	ErrTwoFactorDisabled = errors.New("two-factor authentication is not enabled")
+	// ErrTwoFactorNotStarted — подключение не начато: нет секрета, ожидающего подтверждения
+	ErrTwoFactorNotStarted = errors.New("two-factor setup not started")
+	// ErrInvalidCode — неверный код подтверждения
+	ErrInvalidCode = errors.New("invalid code")
+)
+
+// RecoveryCodeCount — сколько кодов восстановления выдаётся за раз
+const RecoveryCodeCount = 10
+
+// totpSkew — на сколько 30-секундных шагов могут расходиться часы сервера и телефона
+const totpSkew = 1
+
+// TwoFactorStatus — состояние двухфакторной аутентификации пользователя
+type TwoFactorStatus struct {
+	Enabled           bool `json:"enabled"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+// TwoFactorStore хранит TOTP-секреты и коды восстановления
+type TwoFactorStore interface {
+	TwoFactor(username string) (TwoFactorStatus, error)
+	// BeginTOTP создаёт новый секрет, который начнёт действовать после EnableTOTP
+	BeginTOTP(username string) (secret string, err error)
+	// EnableTOTP включает 2FA, если code подходит к секрету из BeginTOTP,
+	// и возвращает коды восстановления (в базе хранятся только их хэши)
+	EnableTOTP(username, code string) (recoveryCodes []string, err error)
+	// VerifyTwoFactor проверяет код из приложения или код восстановления;
+	// оба принимаются только один раз
+	VerifyTwoFactor(username, code string) (bool, error)
+	// NewRecoveryCodes заменяет коды восстановления новыми
+	NewRecoveryCodes(username string) ([]string, error)
+	DisableTOTP(username string) error
+}
+
+var _ TwoFactorStore = (*Store)(nil)
+
+func (s *Store) TwoFactor(username string) (TwoFactorStatus, error) {
+	var st TwoFactorStatus
+	err := s.Db.QueryRow(
+		`SELECT u.totp_enabled, (SELECT COUNT(*) FROM recovery_codes c WHERE c.username=u.username AND c.used_at IS NULL)
+		FROM users u WHERE u.username=$1`,
+		username,
+	).Scan(&st.Enabled, &st.RecoveryCodesLeft)
+	if err == sql.ErrNoRows {
+		return st, ErrNotFound
+	}
+	if err != nil {
+		return st, fmt.Errorf("failed to get two-factor status: %w", err)
+	}
+	return st, nil
+}
+
+func (s *Store) BeginTOTP(username string) (string, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+	res, err := s.Db.Exec(
+		`UPDATE users SET totp_secret=$1 WHERE username=$2 AND NOT totp_enabled`,
+		secret, username,
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to save totp secret: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return "", ErrTwoFactorEnabled
+	}
+	return secret, nil
+}
+
+func (s *Store) EnableTOTP(username, code string) ([]string, error) {
+	var (
+		secret  sql.NullString
+		enabled bool
+	)
+	err := s.Db.QueryRow(`SELECT totp_secret, totp_enabled FROM users WHERE username=$1`, username).Scan(&secret, &enabled)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get totp secret: %w", err)
+	}
+	if enabled {
+		return nil, ErrTwoFactorEnabled
+	}
+	if !secret.Valid {
+		return nil, ErrTwoFactorNotStarted
+	}
+	step, ok := totp.Validate(secret.String, normalizeCode(code), time.Now(), totpSkew)
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Секрет сверяется ещё раз: между проверкой кода и включением его мог заменить BeginTOTP
+	res, err := tx.Exec(
+		`UPDATE users SET totp_enabled=TRUE, totp_last_step=$3 WHERE username=$1 AND totp_secret=$2 AND NOT totp_enabled`,
+		username, secret.String, step,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enable totp: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrInvalidCode
+	}
+	codes, err := replaceRecoveryCodes(tx, username)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit: %w", err)
+	}
+	return codes, nil
+}
+
+func (s *Store) VerifyTwoFactor(username, code string) (bool, error) {
+	code = normalizeCode(code)
+	if len(code) != totp.Digits {
+		res, err := s.Db.Exec(
+			`UPDATE recovery_codes SET used_at=NOW() WHERE username=$1 AND code_hash=$2 AND used_at IS NULL`,
+			username, hashCode(code),
+		)
+		if err != nil {
+			return false, fmt.Errorf("failed to use recovery code: %w", err)
+		}
+		n, _ := res.RowsAffected()
+		return n == 1, nil
+	}
+
+	var (
+		secret   sql.NullString
+		enabled  bool
+		lastStep int64
+	)
+	err := s.Db.QueryRow(
+		`SELECT totp_secret, totp_enabled, totp_last_step FROM users WHERE username=$1`, username,
+	).Scan(&secret, &enabled, &lastStep)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get totp secret: %w", err)
+	}
+	if !enabled || !secret.Valid {
+		return false, nil
+	}
+	step, ok := totp.Validate(secret.String, code, time.Now(), totpSkew)
+	if !ok || step <= lastStep {
+		return false, nil
+	}
+
+	// Запоминаем шаг: перехваченный код нельзя использовать ещё раз
+	res, err := s.Db.Exec(
+		`UPDATE users SET totp_last_step=$2 WHERE username=$1 AND totp_last_step < $2`,
+		username, step,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to save totp step: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+func (s *Store) NewRecoveryCodes(username string) ([]string, error) {
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var enabled bool
+	err = tx.QueryRow(`SELECT totp_enabled FROM users WHERE username=$1 FOR UPDATE`, username).Scan(&enabled)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get two-factor status: %w", err)
+	}
+	if !enabled {
+		return nil, ErrTwoFactorDisabled
+	}
+
+	codes, err := replaceRecoveryCodes(tx, username)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit: %w", err)
+	}
+	return codes, nil
+}
+
+func (s *Store) DisableTOTP(username string) error {
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(
+		`UPDATE users SET totp_secret=NULL, totp_enabled=FALSE, totp_last_step=0 WHERE username=$1`,
+		username,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to disable totp: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE username=$1`, username); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+	return nil
+}
+
+// replaceRecoveryCodes удаляет старые коды восстановления и сохраняет хэши новых
+func replaceRecoveryCodes(tx *sql.Tx, username string) ([]string, error) {
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE username=$1`, username); err != nil {
+		return nil, fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO recovery_codes (username, code_hash) VALUES ($1, $2)`,
+			username, hashCode(normalizeCode(code)),
+		); err != nil {
+			return nil, fmt.Errorf("failed to save recovery code: %w", err)
+		}
+		codes[i] = code
+	}
+	return codes, nil
+}
+
+// recoveryAlphabet — алфавит кодов восстановления (base32 в нижнем регистре)
+const recoveryAlphabet = "abcdefghijklmnopqrstuvwxyz234567"
+
+// newRecoveryCode возвращает код вида "xxxxx-xxxxx" (50 бит)
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate recovery code: %w", err)
+	}
+	for i := range b {
+		b[i] = recoveryAlphabet[b[i]%32]
+	}
+	return string(b[:5]) + "-" + string(b[5:]), nil
+}
+
+// normalizeCode убирает пробелы и дефисы и приводит код к нижнему регистру
+func normalizeCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(code)))
+}
+
+func hashCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
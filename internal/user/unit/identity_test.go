@@ -0,0 +1,139 @@
+package user_test
+
+import (
+	"database/sql"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-portfolio/websocket-chat/internal/user"
+)
+
+var (
+	findIdentity   = regexp.QuoteMeta(`SELECT username FROM user_identities WHERE issuer=$1 AND subject=$2`)
+	insertUser     = regexp.QuoteMeta(`INSERT INTO users (username, password_hash, email, email_verified_at, created_at) VALUES ($1, '!', $2, CASE WHEN $2 IS NOT NULL THEN NOW() END, NOW())`)
+	insertIdentity = regexp.QuoteMeta(`INSERT INTO user_identities (issuer, subject, username, email) VALUES ($1, $2, $3, $4)`)
+)
+
+var identity = user.Identity{
+	Issuer:        "https://idp.test",
+	Subject:       "42",
+	Email:         "alice@example.com",
+	EmailVerified: true,
+	Names:         []string{"", "alice@example.com", "Alice Smith"},
+}
+
+func TestNormalizeUsername(t *testing.T) {
+	assert.Equal(t, "john.doe", user.NormalizeUsername("john.doe"))
+	assert.Equal(t, "Иван_Петров", user.NormalizeUsername(" Иван Петров "))
+	assert.Equal(t, "a_very_long_slack_userna", user.NormalizeUsername("a_very_long_slack_username_indeed"))
+	assert.Equal(t, "bot", user.NormalizeUsername("bot-"))
+	assert.Equal(t, "alice", user.NormalizeUsername("<alice>"))
+}
+
+func TestResolveIdentity_Linked(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := &user.Store{Db: db}
+
+	mock.ExpectQuery(findIdentity).WithArgs("https://idp.test", "42").
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alice"))
+
+	username, created, err := store.ResolveIdentity(identity)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", username)
+	assert.False(t, created)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Новый пользователь получает первое свободное имя; e-mail берётся только подтверждённый
+func TestResolveIdentity_Provisions(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := &user.Store{Db: db}
+	email := sql.NullString{String: "alice@example.com", Valid: true}
+
+	mock.ExpectQuery(findIdentity).WillReturnError(sql.ErrNoRows)
+	// "alice" (из e-mail) занято
+	mock.ExpectBegin()
+	mock.ExpectExec(insertUser).WithArgs("alice", email).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	// "Alice_Smith" (из name) свободно
+	mock.ExpectBegin()
+	mock.ExpectExec(insertUser).WithArgs("Alice_Smith", email).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(insertIdentity).WithArgs("https://idp.test", "42", "Alice_Smith", "alice@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	username, created, err := store.ResolveIdentity(identity)
+	require.NoError(t, err)
+	assert.Equal(t, "Alice_Smith", username)
+	assert.True(t, created)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResolveIdentity_NumberedName(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := &user.Store{Db: db}
+	id := user.Identity{Issuer: "https://idp.test", Subject: "7", Email: "bob@example.com", Names: []string{"bob"}}
+
+	mock.ExpectQuery(findIdentity).WillReturnError(sql.ErrNoRows)
+	mock.ExpectBegin()
+	mock.ExpectExec(insertUser).WithArgs("bob", sql.NullString{}).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec(insertUser).WithArgs("bob2", sql.NullString{}).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(insertIdentity).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	username, created, err := store.ResolveIdentity(id)
+	require.NoError(t, err)
+	assert.Equal(t, "bob2", username)
+	assert.True(t, created)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Параллельный вход успел привязать аккаунт: созданный пользователь откатывается
+func TestResolveIdentity_ConcurrentLink(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := &user.Store{Db: db}
+
+	mock.ExpectQuery(findIdentity).WillReturnError(sql.ErrNoRows)
+	mock.ExpectBegin()
+	mock.ExpectExec(insertUser).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(insertIdentity).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	mock.ExpectQuery(findIdentity).WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alice"))
+
+	username, created, err := store.ResolveIdentity(identity)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", username)
+	assert.False(t, created)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLinkIdentity(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := &user.Store{Db: db}
+
+	mock.ExpectExec(insertIdentity).WithArgs("https://idp.test", "42", "alice", "alice@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, store.LinkIdentity(identity, "alice"))
+
+	// Повторная привязка к тому же пользователю — не ошибка, к другому — ошибка
+	mock.ExpectExec(insertIdentity).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(findIdentity).WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alice"))
+	assert.NoError(t, store.LinkIdentity(identity, "alice"))
+
+	mock.ExpectExec(insertIdentity).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(findIdentity).WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alice"))
+	assert.ErrorIs(t, store.LinkIdentity(identity, "mallory"), user.ErrIdentityLinked)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,180 @@
+package user_test
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/go-portfolio/websocket-chat/internal/user"
+)
+
+var (
+	selectPassword = regexp.QuoteMeta(`SELECT password_hash FROM users WHERE username=$1`)
+	findResetUser  = regexp.QuoteMeta(`SELECT username, email FROM users`)
+	useResetToken  = regexp.QuoteMeta(`UPDATE password_resets SET used_at=NOW()`)
+	findResetToken = regexp.QuoteMeta(`SELECT username FROM password_resets WHERE token_hash=$1`)
+)
+
+func TestChangePassword(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := &user.Store{Db: db}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("old"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	mock.ExpectQuery(selectPassword).WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"password_hash"}).AddRow(string(hash)))
+	assert.ErrorIs(t, store.ChangePassword("alice", "wrong", "new"), user.ErrWrongPassword)
+
+	mock.ExpectQuery(selectPassword).WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"password_hash"}).AddRow(string(hash)))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET password_hash=$3 WHERE username=$1 AND password_hash=$2`)).
+		WithArgs("alice", string(hash), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM password_resets WHERE username=$1`)).
+		WithArgs("alice").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	require.NoError(t, store.ChangePassword("alice", "old", "new"))
+
+	mock.ExpectQuery(selectPassword).WithArgs("ghost").WillReturnError(sql.ErrNoRows)
+	assert.ErrorIs(t, store.ChangePassword("ghost", "old", "new"), user.ErrNotFound)
+
+	assert.ErrorIs(t, store.ChangePassword("alice", "old", ""), user.ErrPasswordRequired)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreatePasswordReset(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := &user.Store{Db: db}
+
+	mock.ExpectQuery(findResetUser).WithArgs("Alice@Example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"username", "email"}).AddRow("alice", "alice@example.com"))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM password_resets WHERE username=$1 AND (used_at IS NOT NULL OR expires_at <= NOW())`)).
+		WithArgs("alice").WillReturnResult(sqlmock.NewResult(0, 0))
+	var savedHash string
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO password_resets (token_hash, username, expires_at) VALUES ($1, $2, $3)`)).
+		WithArgs(hashArg{&savedHash}, "alice", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	pr, err := store.CreatePasswordReset(" Alice@Example.com ", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", pr.Username)
+	assert.Equal(t, "alice@example.com", pr.Email)
+	assert.Len(t, pr.Token, 43)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), pr.ExpiresAt, time.Minute)
+
+	sum := sha256.Sum256([]byte(pr.Token))
+	assert.Equal(t, hex.EncodeToString(sum[:]), savedHash, "в базе только хэш токена")
+
+	mock.ExpectQuery(findResetUser).WithArgs("nobody").WillReturnError(sql.ErrNoRows)
+	_, err = store.CreatePasswordReset("nobody", time.Hour)
+	assert.ErrorIs(t, err, user.ErrNotFound)
+
+	_, err = store.CreatePasswordReset("  ", time.Hour)
+	assert.ErrorIs(t, err, user.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// hashArg запоминает переданный в запрос аргумент
+type hashArg struct{ v *string }
+
+func (a hashArg) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	*a.v = s
+	return ok
+}
+
+func TestResetPassword(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := &user.Store{Db: db}
+
+	sum := sha256.Sum256([]byte("token"))
+	tokenHash := hex.EncodeToString(sum[:])
+
+	mock.ExpectQuery(findResetToken).WithArgs(tokenHash).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alice"))
+	mock.ExpectBegin()
+	mock.ExpectQuery(useResetToken).WithArgs(tokenHash).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alice"))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET password_hash=$2 WHERE username=$1`)).
+		WithArgs("alice", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM password_resets WHERE username=$1 AND used_at IS NULL`)).
+		WithArgs("alice").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	username, err := store.ResetPassword("token", "new")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", username)
+
+	// Использованный или истёкший токен отклоняется до хэширования пароля
+	mock.ExpectQuery(findResetToken).WithArgs(tokenHash).WillReturnError(sql.ErrNoRows)
+	_, err = store.ResetPassword("token", "new")
+	assert.ErrorIs(t, err, user.ErrResetToken)
+
+	// Токен успели использовать параллельно
+	mock.ExpectQuery(findResetToken).WithArgs(tokenHash).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alice"))
+	mock.ExpectBegin()
+	mock.ExpectQuery(useResetToken).WithArgs(tokenHash).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+	_, err = store.ResetPassword("token", "new")
+	assert.ErrorIs(t, err, user.ErrResetToken)
+
+	_, err = store.ResetPassword("token", "")
+	assert.ErrorIs(t, err, user.ErrPasswordRequired)
+	_, err = store.ResetPassword("", "new")
+	assert.ErrorIs(t, err, user.ErrResetToken)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEmailVerification(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := &user.Store{Db: db}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT email FROM users WHERE username=$1`)).WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("alice@example.com"))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM email_verifications WHERE username=$1`)).
+		WithArgs("alice").WillReturnResult(sqlmock.NewResult(0, 1))
+	var savedHash string
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO email_verifications (token_hash, username, email, expires_at)`)).
+		WithArgs(hashArg{&savedHash}, "alice", "alice@example.com", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ev, err := store.CreateEmailVerification("alice", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", ev.Email)
+	sum := sha256.Sum256([]byte(ev.Token))
+	assert.Equal(t, hex.EncodeToString(sum[:]), savedHash)
+
+	// Адрес подтверждается, только если он совпадает с тем, на который ушла ссылка
+	verify := regexp.QuoteMeta(`UPDATE users u SET email_verified_at=NOW() FROM v WHERE u.username=v.username AND u.email=v.email`)
+	mock.ExpectQuery(verify).WithArgs(savedHash).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alice"))
+	username, err := store.VerifyEmail(ev.Token)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", username)
+
+	mock.ExpectQuery(verify).WithArgs(savedHash).WillReturnError(sql.ErrNoRows)
+	_, err = store.VerifyEmail(ev.Token)
+	assert.ErrorIs(t, err, user.ErrVerifyToken)
+	_, err = store.VerifyEmail(" ")
+	assert.ErrorIs(t, err, user.ErrVerifyToken)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT email FROM users WHERE username=$1`)).WithArgs("bob").
+		WillReturnError(sql.ErrNoRows)
+	_, err = store.CreateEmailVerification("bob", time.Hour)
+	assert.ErrorIs(t, err, user.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,108 @@
+package user_test
+
+import (
+	"crypto/sha1"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-portfolio/websocket-chat/internal/user"
+)
+
+func TestPasswordPolicy_Check(t *testing.T) {
+	policy := user.PasswordPolicy{MinLength: 8, Breached: user.BreachedList{}}
+	policy.Breached[sha1.Sum([]byte("password1"))] = struct{}{}
+
+	assert.ErrorIs(t, policy.Check("alice", ""), user.ErrPasswordRequired)
+	assert.ErrorIs(t, policy.Check("alice", "short"), user.ErrWeakPassword)
+	assert.ErrorIs(t, policy.Check("alice", "пароль"), user.ErrWeakPassword, "длина считается в символах")
+	assert.ErrorIs(t, policy.Check("alice", strings.Repeat("x", 73)), user.ErrWeakPassword)
+	assert.ErrorIs(t, policy.Check("alice_smith", "Alice_Smith"), user.ErrWeakPassword)
+	assert.ErrorIs(t, policy.Check("alice", "Password1"), user.ErrWeakPassword)
+
+	assert.NoError(t, policy.Check("alice", "длинныйпароль"))
+	assert.NoError(t, policy.Check("", "correct horse"))
+	assert.NoError(t, user.PasswordPolicy{}.Check("alice", "1"), "без настроек подходит любой непустой пароль")
+}
+
+func TestLoadBreachedList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	content := "# top passwords\n123456\r\n\nqwerty\n" +
+		"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:3861493\n" // SHA-1 от "password"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	list, err := user.LoadBreachedList(path)
+	require.NoError(t, err)
+	assert.Len(t, list, 3)
+	assert.True(t, list.Contains("123456"))
+	assert.True(t, list.Contains("QWERTY"))
+	assert.True(t, list.Contains("password"))
+	assert.False(t, list.Contains("# top passwords"))
+	assert.False(t, list.Contains("correct horse"))
+
+	_, err = user.LoadBreachedList(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.Error(t, err)
+}
+
+func TestRegister_WeakPassword(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := &user.Store{Db: db, Policy: user.PasswordPolicy{MinLength: 8}}
+
+	assert.ErrorIs(t, store.Register("alice", "12345", ""), user.ErrWeakPassword)
+	assert.ErrorIs(t, store.Register("alice", "ALICE", ""), user.ErrWeakPassword)
+
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO users`)).
+		WithArgs("alice", sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	require.NoError(t, store.Register("alice", "correct horse", ""))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthenticate_UnknownUser(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := &user.Store{Db: db}
+
+	query := regexp.QuoteMeta(`SELECT password_hash FROM users WHERE username=$1 AND banned_at IS NULL`)
+	mock.ExpectQuery(query).WithArgs("ghost").WillReturnError(sql.ErrNoRows)
+	assert.False(t, store.Authenticate("ghost", "secret"))
+
+	// Пользователь без пароля (заглушка импорта)
+	mock.ExpectQuery(query).WithArgs("old").
+		WillReturnRows(sqlmock.NewRows([]string{"password_hash"}).AddRow("!"))
+	assert.False(t, store.Authenticate("old", "!"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetPassword_Policy(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := &user.Store{Db: db, Policy: user.PasswordPolicy{MinLength: 8}}
+
+	assert.ErrorIs(t, store.SetPassword("alice", ""), user.ErrPasswordRequired)
+	assert.ErrorIs(t, store.SetPassword("alice", "short"), user.ErrWeakPassword)
+
+	// Вместе с паролем удаляются выданные ссылки сброса
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET password_hash=$2 WHERE username=$1`)).
+		WithArgs("alice", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM password_resets WHERE username=$1`)).
+		WithArgs("alice").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	require.NoError(t, store.SetPassword("alice", "correct horse"))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET password_hash=$2`)).
+		WithArgs("ghost", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	assert.ErrorIs(t, store.SetPassword("ghost", "correct horse"), user.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,159 @@
+package user_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-portfolio/websocket-chat/internal/totp"
+	"github.com/go-portfolio/websocket-chat/internal/user"
+)
+
+const totpSecret = "JBSWY3DPEHPK3PXP"
+
+var (
+	selectTOTP    = regexp.QuoteMeta(`SELECT totp_secret, totp_enabled, totp_last_step FROM users WHERE username=$1`)
+	useRecovery   = regexp.QuoteMeta(`UPDATE recovery_codes SET used_at=NOW() WHERE username=$1 AND code_hash=$2 AND used_at IS NULL`)
+	insertRecover = regexp.QuoteMeta(`INSERT INTO recovery_codes (username, code_hash) VALUES ($1, $2)`)
+)
+
+func TestBeginTOTP(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := &user.Store{Db: db}
+
+	query := regexp.QuoteMeta(`UPDATE users SET totp_secret=$1 WHERE username=$2 AND NOT totp_enabled`)
+	mock.ExpectExec(query).WithArgs(sqlmock.AnyArg(), "alice").WillReturnResult(sqlmock.NewResult(0, 1))
+	secret, err := store.BeginTOTP("alice")
+	require.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	mock.ExpectExec(query).WithArgs(sqlmock.AnyArg(), "bob").WillReturnResult(sqlmock.NewResult(0, 0))
+	_, err = store.BeginTOTP("bob")
+	assert.ErrorIs(t, err, user.ErrTwoFactorEnabled)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEnableTOTP(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := &user.Store{Db: db}
+
+	step := totp.Step(time.Now())
+	code, err := totp.Code(totpSecret, step)
+	require.NoError(t, err)
+	selectSecret := regexp.QuoteMeta(`SELECT totp_secret, totp_enabled FROM users WHERE username=$1`)
+
+	mock.ExpectQuery(selectSecret).WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"totp_secret", "totp_enabled"}).AddRow(totpSecret, false))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET totp_enabled=TRUE, totp_last_step=$3 WHERE username=$1 AND totp_secret=$2 AND NOT totp_enabled`)).
+		WithArgs("alice", totpSecret, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM recovery_codes WHERE username=$1`)).
+		WithArgs("alice").WillReturnResult(sqlmock.NewResult(0, 0))
+	for i := 0; i < user.RecoveryCodeCount; i++ {
+		mock.ExpectExec(insertRecover).WithArgs("alice", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+
+	codes, err := store.EnableTOTP("alice", code)
+	require.NoError(t, err)
+	require.Len(t, codes, user.RecoveryCodeCount)
+	assert.Regexp(t, `^[a-z2-7]{5}-[a-z2-7]{5}$`, codes[0])
+
+	mock.ExpectQuery(selectSecret).WithArgs("bob").
+		WillReturnRows(sqlmock.NewRows([]string{"totp_secret", "totp_enabled"}).AddRow(totpSecret, false))
+	wrong, _ := totp.Code(totpSecret, step+10)
+	_, err = store.EnableTOTP("bob", wrong)
+	assert.ErrorIs(t, err, user.ErrInvalidCode)
+
+	mock.ExpectQuery(selectSecret).WithArgs("carol").
+		WillReturnRows(sqlmock.NewRows([]string{"totp_secret", "totp_enabled"}).AddRow(nil, false))
+	_, err = store.EnableTOTP("carol", code)
+	assert.ErrorIs(t, err, user.ErrTwoFactorNotStarted)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestVerifyTwoFactor_TOTP(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := &user.Store{Db: db}
+
+	step := totp.Step(time.Now())
+	code, err := totp.Code(totpSecret, step)
+	require.NoError(t, err)
+	saveStep := regexp.QuoteMeta(`UPDATE users SET totp_last_step=$2 WHERE username=$1 AND totp_last_step < $2`)
+
+	mock.ExpectQuery(selectTOTP).WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"totp_secret", "totp_enabled", "totp_last_step"}).AddRow(totpSecret, true, 0))
+	mock.ExpectExec(saveStep).WithArgs("alice", step).WillReturnResult(sqlmock.NewResult(0, 1))
+	ok, err := store.VerifyTwoFactor("alice", code)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// Тот же код повторно не принимается: шаг уже использован
+	mock.ExpectQuery(selectTOTP).WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"totp_secret", "totp_enabled", "totp_last_step"}).AddRow(totpSecret, true, step))
+	ok, err = store.VerifyTwoFactor("alice", code)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// Без включённой 2FA код не принимается
+	mock.ExpectQuery(selectTOTP).WithArgs("bob").
+		WillReturnRows(sqlmock.NewRows([]string{"totp_secret", "totp_enabled", "totp_last_step"}).AddRow(totpSecret, false, 0))
+	ok, err = store.VerifyTwoFactor("bob", code)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestVerifyTwoFactor_RecoveryCode(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := &user.Store{Db: db}
+
+	// Код нормализуется: пробелы, дефисы и регистр не важны
+	sum := sha256.Sum256([]byte("abcdeabcde"))
+	hash := hex.EncodeToString(sum[:])
+	mock.ExpectExec(useRecovery).WithArgs("alice", hash).WillReturnResult(sqlmock.NewResult(0, 1))
+	ok, err := store.VerifyTwoFactor("alice", " ABCDE-abcde ")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	mock.ExpectExec(useRecovery).WithArgs("alice", hash).WillReturnResult(sqlmock.NewResult(0, 0))
+	ok, err = store.VerifyTwoFactor("alice", "abcde-abcde")
+	require.NoError(t, err)
+	assert.False(t, ok, "использованный код не принимается")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDisableTOTP(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := &user.Store{Db: db}
+
+	disable := regexp.QuoteMeta(`UPDATE users SET totp_secret=NULL, totp_enabled=FALSE, totp_last_step=0 WHERE username=$1`)
+	mock.ExpectBegin()
+	mock.ExpectExec(disable).WithArgs("alice").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM recovery_codes WHERE username=$1`)).
+		WithArgs("alice").WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+	require.NoError(t, store.DisableTOTP("alice"))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(disable).WithArgs("ghost").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	assert.ErrorIs(t, store.DisableTOTP("ghost"), user.ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
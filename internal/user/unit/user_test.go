@@ -5,6 +5,7 @@ import (
 	"errors"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock" // библиотека для моков SQL-запросов
 	"github.com/go-portfolio/websocket-chat/internal/user"
@@ -174,3 +175,191 @@ func TestGetAvatar_NoAvatar(t *testing.T) {
 	// Если аватарки нет, метод всегда возвращает ""
 	assert.Equal(t, "", avatar)
 }
+
+// --- ТЕСТЫ ДЛЯ SetAvatar ---
+// SetAvatar — заменяет аватар существующего пользователя
+
+func TestSetAvatar_Success(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := &user.Store{Db: db}
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET avatar=$1 WHERE username=$2`)).
+		WithArgs(sql.NullString{String: "/uploads/avatars/x/256.png", Valid: true}, "alice").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := store.SetAvatar("alice", "/uploads/avatars/x/256.png")
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetAvatar_UserNotFound(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := &user.Store{Db: db}
+
+	// UPDATE не затронул ни одной строки
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET avatar=$1 WHERE username=$2`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := store.SetAvatar("ghost", "/uploads/a.png")
+
+	assert.EqualError(t, err, "user not found")
+}
+
+// --- ТЕСТЫ ДЛЯ Exists ---
+
+func TestExists(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := &user.Store{Db: db}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS (SELECT 1 FROM users WHERE username=$1)`)).
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	ok, err := store.Exists("alice")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// --- ТЕСТЫ ДЛЯ SetEmail / GetEmail ---
+
+func TestSetEmail_Success(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := &user.Store{Db: db}
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET email=$1, email_digest=$2, email_verified_at = CASE WHEN email IS NOT DISTINCT FROM $1 THEN email_verified_at END WHERE username=$3`)).
+		WithArgs(sql.NullString{String: "alice@example.com", Valid: true}, true, "alice").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, store.SetEmail("alice", " alice@example.com ", true))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Без адреса дайджесты отключаются
+func TestSetEmail_ClearDisablesDigest(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := &user.Store{Db: db}
+
+	mock.ExpectExec(`UPDATE users SET email`).
+		WithArgs(sql.NullString{}, false, "alice").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, store.SetEmail("alice", "", true))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetEmail_Invalid(t *testing.T) {
+	db, _, _ := sqlmock.New()
+	defer db.Close()
+	store := &user.Store{Db: db}
+
+	for _, email := range []string{"not-an-email", "Alice <alice@example.com>", "a@b.c\r\nBcc: x@y.z"} {
+		assert.ErrorIs(t, store.SetEmail("alice", email, true), user.ErrInvalidEmail, email)
+	}
+}
+
+func TestGetEmail(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := &user.Store{Db: db}
+
+	mock.ExpectQuery(`SELECT email, email_digest FROM users WHERE username=`).
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"email", "email_digest"}).AddRow(nil, false))
+
+	email, digest, err := store.GetEmail("alice")
+	assert.NoError(t, err)
+	assert.Empty(t, email)
+	assert.False(t, digest)
+}
+
+// --- ТЕСТЫ ДЛЯ EnsurePlaceholder ---
+
+func TestEnsurePlaceholder(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := &user.Store{Db: db}
+
+	// Новый пользователь создаётся, существующий не меняется
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO users (username, password_hash) VALUES ($1, '!') ON CONFLICT (username) DO NOTHING`)).
+		WithArgs("alice").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO users`).
+		WithArgs("bob").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	created, err := store.EnsurePlaceholder("alice")
+	assert.NoError(t, err)
+	assert.True(t, created)
+
+	created, err = store.EnsurePlaceholder("bob")
+	assert.NoError(t, err)
+	assert.False(t, created)
+
+	// Под заглушкой войти нельзя: '!' не является bcrypt-хешем
+	mock.ExpectQuery(`SELECT password_hash FROM users`).
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"password_hash"}).AddRow("!"))
+	assert.False(t, store.Authenticate("alice", ""))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// --- ТЕСТЫ ДЛЯ администрирования ---
+
+func TestAdminFlags(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := &user.Store{Db: db}
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET is_admin=$2 WHERE username=$1`)).
+		WithArgs("alice", true).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE users SET banned_at`).
+		WithArgs("ghost", true).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT is_admin FROM users`).
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"is_admin"}).AddRow(true))
+	mock.ExpectQuery(`SELECT banned_at IS NOT NULL FROM users`).
+		WithArgs("ghost").
+		WillReturnRows(sqlmock.NewRows([]string{"banned"}))
+
+	assert.NoError(t, store.SetAdmin("alice", true))
+	assert.ErrorIs(t, store.SetBanned("ghost", true), user.ErrNotFound)
+
+	admin, err := store.IsAdmin("alice")
+	assert.NoError(t, err)
+	assert.True(t, admin)
+
+	banned, err := store.IsBanned("ghost")
+	assert.NoError(t, err)
+	assert.False(t, banned)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestList(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	store := &user.Store{Db: db}
+
+	now := time.Now()
+	mock.ExpectQuery(`FROM users ORDER BY username`).
+		WillReturnRows(sqlmock.NewRows([]string{"username", "email", "is_admin", "banned_at", "created_at", "last_seen_at"}).
+			AddRow("alice", "a@example.com", true, nil, now, now).
+			AddRow("bob", "", false, now, now, nil))
+
+	users, err := store.List()
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+	assert.True(t, users[0].Admin)
+	assert.Nil(t, users[0].BannedAt)
+	assert.NotNil(t, users[1].BannedAt)
+	assert.Nil(t, users[1].LastSeenAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,95 @@
+package user
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrVerifyToken — токена подтверждения нет, он истёк или e-mail с тех пор сменился
+var ErrVerifyToken = errors.New("invalid or expired verification token")
+
+// EmailVerification — выданный токен подтверждения e-mail; как и для сброса пароля,
+// в базе хранится только SHA-256 токена
+type EmailVerification struct {
+	Username  string
+	Email     string
+	Token     string
+	ExpiresAt time.Time
+}
+
+func (s *Store) CreateEmailVerification(username string, ttl time.Duration) (EmailVerification, error) {
+	ev := EmailVerification{Username: username}
+	err := s.Db.QueryRow(
+		`SELECT email FROM users WHERE username=$1 AND email IS NOT NULL AND banned_at IS NULL`, username,
+	).Scan(&ev.Email)
+	if err == sql.ErrNoRows {
+		return ev, ErrNotFound
+	}
+	if err != nil {
+		return ev, fmt.Errorf("failed to get email: %w", err)
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return ev, fmt.Errorf("failed to generate verification token: %w", err)
+	}
+	ev.Token = base64.RawURLEncoding.EncodeToString(b)
+	ev.ExpiresAt = time.Now().Add(ttl)
+
+	// Действует только последняя ссылка: прежние могли уйти на другой адрес
+	if _, err := s.Db.Exec(`DELETE FROM email_verifications WHERE username=$1`, username); err != nil {
+		return ev, fmt.Errorf("failed to delete old verification tokens: %w", err)
+	}
+	if _, err := s.Db.Exec(
+		`INSERT INTO email_verifications (token_hash, username, email, expires_at) VALUES ($1, $2, $3, $4)`,
+		hashCode(ev.Token), username, ev.Email, ev.ExpiresAt,
+	); err != nil {
+		return ev, fmt.Errorf("failed to save verification token: %w", err)
+	}
+	return ev, nil
+}
+
+func (s *Store) VerifyEmail(token string) (string, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", ErrVerifyToken
+	}
+	// Адрес подтверждается, только если он не менялся после выдачи токена
+	var username string
+	err := s.Db.QueryRow(
+		`WITH v AS (
+			DELETE FROM email_verifications WHERE token_hash=$1 AND expires_at > NOW()
+			RETURNING username, email
+		)
+		UPDATE users u SET email_verified_at=NOW()
+		FROM v WHERE u.username=v.username AND u.email=v.email
+		RETURNING u.username`,
+		hashCode(token),
+	).Scan(&username)
+	if err == sql.ErrNoRows {
+		return "", ErrVerifyToken
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to verify email: %w", err)
+	}
+	return username, nil
+}
+
+func (s *Store) EmailVerified(username string) (bool, error) {
+	var verified bool
+	err := s.Db.QueryRow(
+		`SELECT email IS NOT NULL AND email_verified_at IS NOT NULL FROM users WHERE username=$1`, username,
+	).Scan(&verified)
+	if err == sql.ErrNoRows {
+		return false, ErrNotFound
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get email status: %w", err)
+	}
+	return verified, nil
+}
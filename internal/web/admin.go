@@ -0,0 +1,195 @@
+package web
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-portfolio/websocket-chat/internal/chat"
+	"github.com/go-portfolio/websocket-chat/internal/notify"
+	"github.com/go-portfolio/websocket-chat/internal/settings"
+	"github.com/go-portfolio/websocket-chat/internal/user"
+)
+
+// =========================
+// AdminMiddleware пускает к admin API по токену AdminToken
+// (заголовок Authorization: Bearer, так работает chatctl) или пользователей
+// с правами администратора сервера (по cookie или Bearer JWT)
+// =========================
+func AdminMiddleware(next http.Handler) http.Handler {
+	asAdmin := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(CtxUserKey).(string)
+		admin, err := Users.IsAdmin(username)
+		if err != nil {
+			log.Printf("admin: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to check permissions")
+			return
+		}
+		if !admin {
+			writeError(w, http.StatusForbidden, "admin only")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+	withUser := AuthMiddleware(asAdmin)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if token != "" && AdminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(AdminToken)) == 1 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		withUser.ServeHTTP(w, r)
+	})
+}
+
+// RoomOnline — комната и число пользователей в ней
+type RoomOnline struct {
+	Room   string `json:"room"`
+	Online int    `json:"online"`
+}
+
+// =========================
+// AdminRoomsHandler отдаёт комнаты, где кто-то в сети, и число пользователей в них
+// =========================
+func AdminRoomsHandler(w http.ResponseWriter, r *http.Request) {
+	rooms := []RoomOnline{}
+	for name, n := range ChatHub.OnlineCounts() {
+		rooms = append(rooms, RoomOnline{Room: name, Online: n})
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].Room < rooms[j].Room })
+	writeJSON(w, http.StatusOK, map[string]any{"rooms": rooms})
+}
+
+// =========================
+// AdminKickHandler отключает все подключения пользователя и оставляет ему
+// уведомление о модерации. Тело (необязательно): {"reason": "...", "silent": true};
+// silent — без уведомления (отключение после удаления аккаунта, сброса пароля и т.п.)
+// =========================
+func AdminKickHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Reason string `json:"reason"`
+		Silent bool   `json:"silent"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+	}
+	if req.Reason == "" {
+		req.Reason = "disconnected by administrator"
+	}
+
+	username := r.PathValue("username")
+	if !req.Silent {
+		admin, _ := r.Context().Value(CtxUserKey).(string)
+		notifyModeration(username, admin, "Администратор отключил вас от чата: "+req.Reason)
+	}
+	n := ChatHub.Disconnect(username, req.Reason)
+	writeJSON(w, http.StatusOK, map[string]int{"disconnected": n})
+}
+
+// notifyModeration сохраняет уведомление о действии администратора; actor пустой,
+// если действие выполнено по токену admin API
+func notifyModeration(username, actor, text string) {
+	if Inbox == nil {
+		return
+	}
+	err := Inbox.Notify(username, chat.Notification{Kind: notify.KindModeration, From: actor, Text: text})
+	if err != nil {
+		log.Printf("admin: notify %s: %v", username, err)
+	}
+}
+
+// =========================
+// AdminAnnounceHandler рассылает системное сообщение во все комнаты: {"text": "..."}
+// =========================
+func AdminAnnounceHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	req.Text = strings.TrimSpace(req.Text)
+	if req.Text == "" {
+		writeError(w, http.StatusBadRequest, "text is required")
+		return
+	}
+
+	n := ChatHub.Announce(req.Text)
+	log.Printf("admin: announcement sent to %d room(s)", n)
+	writeJSON(w, http.StatusOK, map[string]int{"rooms": n})
+}
+
+// =========================
+// AdminSettingsHandler отдаёт настройки сервера
+// GET /api/admin/settings
+// =========================
+func AdminSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	required, err := twoFactorRequired()
+	if err != nil {
+		log.Printf("admin settings: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to get settings")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"require_2fa": required})
+}
+
+// =========================
+// AdminUpdateSettingsHandler меняет настройки сервера: {"require_2fa": true}
+// PUT /api/admin/settings
+// =========================
+func AdminUpdateSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RequireTwoFactor *bool `json:"require_2fa"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if Settings == nil || TwoFactor == nil {
+		writeError(w, http.StatusNotFound, "settings are not available")
+		return
+	}
+	if req.RequireTwoFactor != nil {
+		if err := settings.SetBool(Settings, settings.RequireTwoFactor, *req.RequireTwoFactor); err != nil {
+			log.Printf("admin settings: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to save settings")
+			return
+		}
+		log.Printf("admin: require_2fa set to %v", *req.RequireTwoFactor)
+	}
+	AdminSettingsHandler(w, r)
+}
+
+// =========================
+// AdminResetTwoFactorHandler отключает 2FA пользователя, потерявшего телефон
+// и коды восстановления
+// DELETE /api/admin/users/{username}/2fa
+// =========================
+func AdminResetTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	if TwoFactor == nil {
+		writeError(w, http.StatusNotFound, "two-factor authentication is disabled")
+		return
+	}
+	username := r.PathValue("username")
+	err := TwoFactor.DisableTOTP(username)
+	if errors.Is(err, user.ErrNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("admin 2fa reset: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to reset two-factor authentication")
+		return
+	}
+	log.Printf("admin: 2fa reset for %s", username)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "reset"})
+}
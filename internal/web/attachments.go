@@ -0,0 +1,190 @@
+package web
+
+import (
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/attachment"
+	"github.com/go-portfolio/websocket-chat/internal/storage"
+)
+
+// =========================
+// Загрузка вложения
+// POST /api/attachments (multipart/form-data, поле "file")
+// Возвращает ID, который клиент затем отправляет в сообщении типа "attachment"
+// =========================
+func UploadAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	username, _ := r.Context().Value(CtxUserKey).(string)
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxUploadSize)
+	if err := r.ParseMultipartForm(MaxUploadSize); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid form data")
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "file is required")
+		return
+	}
+	defer file.Close()
+
+	data, contentType, err := AttachmentPolicy.Read(file)
+	if errors.Is(err, storage.ErrTooLarge) || errors.Is(err, storage.ErrTypeNotAllowed) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "cannot read file")
+		return
+	}
+
+	a := &attachment.Attachment{
+		ID:        attachment.NewID(),
+		Owner:     username,
+		Name:      attachment.CleanName(header.Filename),
+		Size:      int64(len(data)),
+		MimeType:  contentType,
+		BlobKey:   storage.ContentKey("attachments", data, contentType),
+		CreatedAt: time.Now(),
+	}
+
+	// Для изображений строим миниатюру; если картинка не декодируется, вложение остаётся без неё
+	var thumb []byte
+	if a.IsImage() {
+		if t, err := attachment.Thumbnail(data, attachment.ThumbnailSize); err == nil {
+			thumb = t
+			a.ThumbKey = storage.ContentKey("thumbnails", thumb, "image/png")
+		}
+	}
+
+	// Файлы кладутся после метаданных: иначе очистка истории может удалить только что
+	// записанный файл, если у него тот же ключ, что у удаляемого вложения.
+	// Если файл не записался, вложение без файла удалит очистка неотправленных.
+	if err := Attachments.Create(a); err != nil {
+		log.Printf("attachments: %v", err)
+		writeError(w, http.StatusInternalServerError, "cannot save attachment")
+		return
+	}
+	if _, err := storage.Put(Blobs, "attachments", data, contentType); err != nil {
+		log.Printf("attachments: %v", err)
+		writeError(w, http.StatusInternalServerError, "cannot save file")
+		return
+	}
+	if thumb != nil {
+		if _, err := storage.Put(Blobs, "thumbnails", thumb, "image/png"); err != nil {
+			log.Printf("attachments: %v", err)
+			writeError(w, http.StatusInternalServerError, "cannot save file")
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusCreated, a.Info())
+}
+
+// =========================
+// canAccessAttachment проверяет право пользователя скачать вложение:
+// владелец — всегда; после отправки — участники комнаты или адресат личного сообщения.
+// Комнаты открытые: участником становится любой вошедший пользователь, подключившийся
+// к /ws?room=... (ChatConnectionHandler вызывает Rooms.Join), и он же получает историю
+// комнаты. Поэтому вложение в комнате доступно так же, как её история, — любому, кто
+// в неё зайдёт; закрыты только вложения личных сообщений и ещё не отправленные
+// =========================
+func canAccessAttachment(a *attachment.Attachment, username string) (bool, error) {
+	switch {
+	case a.Owner == username:
+		return true, nil
+	case a.Peer != "":
+		return a.Peer == username, nil
+	case a.Room != "":
+		return Rooms.IsMember(a.Room, username)
+	default:
+		return false, nil
+	}
+}
+
+// =========================
+// loadAttachment находит вложение и проверяет доступ; при отказе сам пишет ответ
+// =========================
+func loadAttachment(w http.ResponseWriter, r *http.Request) (*attachment.Attachment, bool) {
+	username, _ := r.Context().Value(CtxUserKey).(string)
+
+	a, err := Attachments.Get(r.PathValue("id"))
+	if errors.Is(err, attachment.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "attachment not found")
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("attachments: %v", err)
+		writeError(w, http.StatusInternalServerError, "storage error")
+		return nil, false
+	}
+
+	ok, err := canAccessAttachment(a, username)
+	if err != nil {
+		log.Printf("attachments: %v", err)
+		writeError(w, http.StatusInternalServerError, "storage error")
+		return nil, false
+	}
+	if !ok {
+		// Не раскрываем существование вложения посторонним
+		writeError(w, http.StatusNotFound, "attachment not found")
+		return nil, false
+	}
+	return a, true
+}
+
+// =========================
+// Скачивание вложения
+// GET /api/attachments/{id}
+// =========================
+func AttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	a, ok := loadAttachment(w, r)
+	if !ok {
+		return
+	}
+
+	disposition := "attachment"
+	if a.IsImage() {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": a.Name}))
+	serveBlob(w, a.BlobKey, a.MimeType)
+}
+
+// =========================
+// Миниатюра изображения
+// GET /api/attachments/{id}/thumbnail
+// =========================
+func AttachmentThumbnailHandler(w http.ResponseWriter, r *http.Request) {
+	a, ok := loadAttachment(w, r)
+	if !ok {
+		return
+	}
+	if a.ThumbKey == "" {
+		writeError(w, http.StatusNotFound, "thumbnail not found")
+		return
+	}
+	serveBlob(w, a.ThumbKey, "image/png")
+}
+
+// =========================
+// serveBlob отдаёт объект из хранилища приватно (доступ уже проверен)
+// =========================
+func serveBlob(w http.ResponseWriter, key, contentType string) {
+	rc, _, err := Blobs.Open(key)
+	if err != nil {
+		log.Printf("attachments: open %q: %v", key, err)
+		writeError(w, http.StatusNotFound, "file not found")
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	_, _ = io.Copy(w, rc)
+}
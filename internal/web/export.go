@@ -0,0 +1,105 @@
+package web
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/message"
+	"github.com/go-portfolio/websocket-chat/internal/room"
+)
+
+// Типы содержимого выгрузки
+var exportTypes = map[string]string{
+	message.FormatJSON: "application/json; charset=utf-8",
+	message.FormatCSV:  "text/csv; charset=utf-8",
+	message.FormatHTML: "text/html; charset=utf-8",
+}
+
+// =========================
+// ExportRoomHandler отдаёт историю комнаты файлом в формате json, csv или html.
+// Доступен владельцу и администраторам комнаты, а также администраторам сервера.
+// Параметры from и to — дата (YYYY-MM-DD) или время (RFC 3339); дата в to
+// включается целиком.
+// =========================
+func ExportRoomHandler(w http.ResponseWriter, r *http.Request) {
+	username, _ := r.Context().Value(CtxUserKey).(string)
+	roomName := r.PathValue("room")
+	params := r.URL.Query()
+
+	format := params.Get("format")
+	if format == "" {
+		format = message.FormatJSON
+	}
+	contentType, ok := exportTypes[format]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "format must be json, csv or html")
+		return
+	}
+
+	var from, to time.Time
+	if v := params.Get("from"); v != "" {
+		t, err := parseTime(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid from")
+			return
+		}
+		from = t
+	}
+	if v := params.Get("to"); v != "" {
+		t, err := parseTime(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid to")
+			return
+		}
+		if len(v) == len(time.DateOnly) {
+			t = t.AddDate(0, 0, 1)
+		}
+		to = t
+	}
+
+	allowed, err := canExport(roomName, username)
+	if err != nil {
+		log.Printf("export: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to check permissions")
+		return
+	}
+	if !allowed {
+		writeError(w, http.StatusForbidden, "only room owners and admins can export history")
+		return
+	}
+
+	filename := fmt.Sprintf("%s-%s.%s", roomName, time.Now().UTC().Format("20060102"), format)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "no-store")
+
+	// Дальше ответ уже начат: ошибки можно только записать в лог
+	out, err := message.NewExportWriter(format, w, roomName)
+	if err != nil {
+		log.Printf("export %s: %v", roomName, err)
+		return
+	}
+	err = Messages.Export(roomName, from, to, out.Write)
+	if err == nil {
+		err = out.Close()
+	}
+	if err != nil {
+		log.Printf("export %s: %v", roomName, err)
+	}
+}
+
+// canExport — может ли пользователь выгрузить историю комнаты
+func canExport(roomName, username string) (bool, error) {
+	role, err := Rooms.Role(roomName, username)
+	if err != nil {
+		return false, err
+	}
+	if room.CanManage(role) {
+		return true, nil
+	}
+	return Users.IsAdmin(username)
+}
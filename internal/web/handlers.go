@@ -2,14 +2,9 @@ package web
 
 import (
 	"encoding/json"
-	"fmt"
-	"io"
+	"log"
 	"net/http"
-	"os"
-	"path/filepath"
-	"time"
 
-	"github.com/go-portfolio/websocket-chat/internal/auth"
 	"github.com/go-portfolio/websocket-chat/internal/user"
 )
 
@@ -18,8 +13,13 @@ import (
 // POST /api/register
 // =========================
 func RegisterHandler(w http.ResponseWriter, r *http.Request) {
-	// Парсим multipart/form-data
-	err := r.ParseMultipartForm(10 << 20) // 10MB лимит
+	if signupThrottled(w, r) {
+		return
+	}
+
+	// Парсим multipart/form-data (тело запроса жёстко ограничено MaxUploadSize)
+	r.Body = http.MaxBytesReader(w, r.Body, MaxUploadSize)
+	err := r.ParseMultipartForm(MaxUploadSize)
 	if err != nil {
 		http.Error(w, "invalid form data", http.StatusBadRequest)
 		return
@@ -29,32 +29,29 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	cred.Username = r.FormValue("username")
 	cred.Password = r.FormValue("password")
 
-	file, handler, err := r.FormFile("avatar")
+	// Данные проверяются до сохранения аватара, иначе отклонённые регистрации
+	// оставляли бы в хранилище файлы без владельца
+	if err := Users.CheckCredentials(cred.Username, cred.Password); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	file, _, err := r.FormFile("avatar")
 	var avatarURL string
 
 	if err == nil { // файл передан
 		defer file.Close()
 
-		// создаём папку, если нет
-		os.MkdirAll("../../uploads", os.ModePerm)
-
-		// уникальное имя
-		filename := fmt.Sprintf("../../uploads/%d_%s", time.Now().Unix(), handler.Filename)
-		dst, err := os.Create(filename)
+		// Имя файла от клиента не используется: ключи вычисляются из содержимого,
+		// тип проверяется по сигнатуре, изображение перекодируется в набор размеров
+		url, status, err := saveAvatar(file)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(map[string]string{"error": "cannot save file"})
-			return
-		}
-		defer dst.Close()
-
-		if _, err = io.Copy(dst, file); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(map[string]string{"error": "cannot write file"})
+			w.WriteHeader(status)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 			return
 		}
-
-		avatarURL = fmt.Sprintf("/uploads/%d_%s", time.Now().Unix(), handler.Filename)
+		avatarURL = url
 	}
 
 	// Регистрируем пользователя
@@ -68,57 +65,70 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // =========================
-// Логин пользователя
+// Логин пользователя. С включённой 2FA вход двухшаговый: после пароля
+// возвращается {"status": "2fa_required", "challenge": "..."}, а сессия выдаётся
+// на повторный запрос {"challenge": "...", "code": "123456"}
 // POST /api/login
 // =========================
 func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	withJSON(w)
 
-	var cred user.Credentials
+	var cred struct {
+		user.Credentials
+		Tokens    bool   `json:"tokens"`    // вернуть токены в теле ответа (для клиентов без cookie)
+		Challenge string `json:"challenge"` // второй шаг: вызов из ответа первого
+		Code      string `json:"code"`      // код из приложения-аутентификатора или код восстановления
+	}
 	if err := json.NewDecoder(r.Body).Decode(&cred); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid json"})
 		return
 	}
 
+	if cred.Challenge != "" {
+		loginWithCode(w, r, cred.Challenge, cred.Code, cred.Tokens)
+		return
+	}
+
+	if loginThrottled(w, r, cred.Username) {
+		return
+	}
 	if !Users.Authenticate(cred.Username, cred.Password) {
+		loginFailed(r, cred.Username)
 		w.WriteHeader(http.StatusUnauthorized)
 		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid credentials"})
 		return
 	}
+	loginReleased(r, cred.Username)
+
+	if issueChallenge(w, cred.Username) {
+		return
+	}
+	completeLogin(w, r, cred.Username, cred.Tokens, nil)
+}
 
-	token, err := auth.IssueJWT(cred.Username)
+// completeLogin создаёт сессию и отвечает как успешный вход; extra дописывается в ответ
+func completeLogin(w http.ResponseWriter, r *http.Request, username string, withTokens bool, extra map[string]any) {
+	loginSucceeded(username)
+	tokens, err := startSession(w, r, username)
 	if err != nil {
+		log.Printf("login error: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		_ = json.NewEncoder(w).Encode(map[string]string{"error": "failed to issue token"})
 		return
 	}
 
-	cookie := http.Cookie{
-		Name:     CookieName,
-		Value:    token,
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   false,
-		SameSite: http.SameSiteLaxMode,
-		MaxAge:   24 * 60 * 60,
+	var avatar = avatarOf(username)
+	resp := map[string]any{
+		"status":  "ok",
+		"avatar":  avatar,
+		"avatars": avatarURLs(avatar),
 	}
-	http.SetCookie(w, &cookie)
-
-	var avatar = Users.GetAvatar(cred.Username)
-	_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok", "avatar": avatar})
-}
-
-// =========================
-// IndexHandler
-// =========================
-func IndexHandler(w http.ResponseWriter, r *http.Request) {
-	path := filepath.Join("..", "..", "internal", "web", "index.html")
-	data, err := os.ReadFile(path)
-	if err != nil {
-		http.Error(w, "index.html not found", http.StatusInternalServerError)
-		return
+	if withTokens {
+		resp["tokens"] = tokens
+	}
+	for k, v := range extra {
+		resp[k] = v
 	}
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.Write(data)
+	_ = json.NewEncoder(w).Encode(resp)
 }
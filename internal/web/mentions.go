@@ -0,0 +1,63 @@
+package web
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// Размер страницы для списков (упоминания и т.п.)
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// =========================
+// MentionsHandler отдаёт упоминания текущего пользователя, от новых к старым.
+// Параметры: limit — размер страницы, before — ID, с которого продолжить.
+// =========================
+func MentionsHandler(w http.ResponseWriter, r *http.Request) {
+	username, _ := r.Context().Value(CtxUserKey).(string)
+
+	limit, before, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+
+	records, err := Mentions.List(username, before, limit)
+	if err != nil {
+		log.Printf("mentions: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to load mentions")
+		return
+	}
+
+	resp := map[string]any{"mentions": records}
+	if len(records) == limit {
+		resp["next_before"] = records[len(records)-1].ID
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// =========================
+// pageParams разбирает limit и before из строки запроса; при ошибке отвечает 400
+// =========================
+func pageParams(w http.ResponseWriter, r *http.Request) (limit int, before int64, ok bool) {
+	limit = defaultPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return 0, 0, false
+		}
+		limit = min(n, maxPageSize)
+	}
+	if v := r.URL.Query().Get("before"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid before")
+			return 0, 0, false
+		}
+		before = n
+	}
+	return limit, before, true
+}
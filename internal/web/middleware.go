@@ -3,35 +3,79 @@ package web
 import (
 	"context"
 	"encoding/json"
+	"log"
 	"net/http"
+	"strings"
 
 	"github.com/go-portfolio/websocket-chat/internal/auth"
 )
 
 type ctxKey string
 
-const CtxUserKey ctxKey = "user"
+const (
+	CtxUserKey    ctxKey = "user"
+	CtxSessionKey ctxKey = "session" // ID сессии входа, если токен к ней привязан
+)
 
 // =========================
-// AuthMiddleware проверяет cookie JWT
+// AuthMiddleware проверяет JWT из заголовка Authorization: Bearer
+// (CLI, мобильные клиенты) или из cookie авторизации (браузер)
 // =========================
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		c, err := r.Cookie(CookieName)
+		token := bearerToken(r)
+		if token == "" {
+			c, err := r.Cookie(CookieName)
+			if err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				_ = json.NewEncoder(w).Encode(map[string]string{"error": "missing auth cookie"})
+				return
+			}
+			token = c.Value
+		}
+
+		claims, err := auth.ParseClaims(token)
 		if err != nil {
 			w.WriteHeader(http.StatusUnauthorized)
-			_ = json.NewEncoder(w).Encode(map[string]string{"error": "missing auth cookie"})
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid token"})
 			return
 		}
+		serveAuthenticated(next, w, r, claims)
+	})
+}
 
-		userName, err := auth.ParseJWT(c.Value)
-		if err != nil {
+// serveAuthenticated проверяет, что сессия claims жива, и передаёт запрос дальше
+// с пользователем и сессией в контексте
+func serveAuthenticated(next http.Handler, w http.ResponseWriter, r *http.Request, claims auth.Claims) {
+	// Токен действует, только пока жива его сессия: после выхода или отзыва
+	// он отклоняется сразу, не дожидаясь истечения
+	if Sessions != nil {
+		active := false
+		if claims.SessionID != "" {
+			var err error
+			if active, err = Sessions.Active(claims.SessionID); err != nil {
+				log.Printf("session check error: %v", err)
+				writeError(w, http.StatusInternalServerError, "failed to check session")
+				return
+			}
+		}
+		if !active {
 			w.WriteHeader(http.StatusUnauthorized)
-			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid token"})
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "session revoked"})
 			return
 		}
+	}
 
-		ctx := context.WithValue(r.Context(), CtxUserKey, userName)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+	ctx := context.WithValue(r.Context(), CtxUserKey, claims.Username)
+	ctx = context.WithValue(ctx, CtxSessionKey, claims.SessionID)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// bearerToken возвращает токен из заголовка Authorization: Bearer или ""
+func bearerToken(r *http.Request) string {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(token)
 }
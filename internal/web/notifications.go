@@ -0,0 +1,96 @@
+package web
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-portfolio/websocket-chat/internal/notify"
+)
+
+// =========================
+// NotificationsHandler отдаёт входящие уведомления текущего пользователя.
+// Параметры: unread=1 — только непрочитанные, limit и before — постраничная выдача.
+// =========================
+func NotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	username, _ := r.Context().Value(CtxUserKey).(string)
+
+	limit, before, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+	unreadOnly, _ := strconv.ParseBool(r.URL.Query().Get("unread"))
+
+	list, err := Notifications.List(username, unreadOnly, before, limit)
+	if err != nil {
+		log.Printf("notifications: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to load notifications")
+		return
+	}
+	unread, err := Notifications.UnreadCount(username)
+	if err != nil {
+		log.Printf("notifications: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to load notifications")
+		return
+	}
+
+	resp := map[string]any{"notifications": list, "unread": unread}
+	if len(list) == limit {
+		resp["next_before"] = list[len(list)-1].ID
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// =========================
+// MarkNotificationReadHandler отмечает прочитанным одно уведомление
+// =========================
+func MarkNotificationReadHandler(w http.ResponseWriter, r *http.Request) {
+	username, _ := r.Context().Value(CtxUserKey).(string)
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid notification id")
+		return
+	}
+
+	err = Notifications.MarkRead(username, id)
+	if errors.Is(err, notify.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "notification not found")
+		return
+	}
+	if err != nil {
+		log.Printf("notifications: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to update notification")
+		return
+	}
+	writeUnread(w, username, 1)
+}
+
+// =========================
+// MarkAllNotificationsReadHandler отмечает прочитанными все уведомления
+// =========================
+func MarkAllNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	username, _ := r.Context().Value(CtxUserKey).(string)
+
+	n, err := Notifications.MarkAllRead(username)
+	if err != nil {
+		log.Printf("notifications: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to update notifications")
+		return
+	}
+	writeUnread(w, username, n)
+}
+
+// =========================
+// writeUnread отвечает числом обновлённых и оставшихся непрочитанных уведомлений
+// =========================
+func writeUnread(w http.ResponseWriter, username string, updated int64) {
+	unread, err := Notifications.UnreadCount(username)
+	if err != nil {
+		log.Printf("notifications: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to count notifications")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"updated": updated, "unread": unread})
+}
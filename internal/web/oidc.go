@@ -0,0 +1,191 @@
+package web
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/go-portfolio/websocket-chat/internal/oidc"
+	"github.com/go-portfolio/websocket-chat/internal/user"
+)
+
+// =========================
+// Вход через OpenID Connect (nil — отключён)
+// =========================
+var (
+	OIDC       *oidc.Provider     // Провайдер OpenID Connect
+	OIDCName   = "OIDC"           // Название провайдера на кнопке входа
+	Identities user.IdentityStore // Привязка внешних аккаунтов к пользователям
+)
+
+// oidcStateCookie — cookie со state начатого входа: callback принимается
+// только в том браузере, где вход начинался
+func oidcStateCookie() string {
+	return CookieName + "_oidc"
+}
+
+// oidcCookiePath — cookie state нужна только обработчикам /api/oidc/
+const oidcCookiePath = "/api/oidc/"
+
+// =========================
+// Включён ли вход через OIDC (для страницы входа)
+// GET /api/oidc
+// =========================
+func OIDCInfoHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"enabled": OIDC != nil && Identities != nil,
+		"name":    OIDCName,
+	})
+}
+
+// =========================
+// Начало входа: перенаправление на страницу входа провайдера
+// GET /api/oidc/login
+// =========================
+func OIDCLoginHandler(w http.ResponseWriter, r *http.Request) {
+	beginOIDC(w, r, "")
+}
+
+// =========================
+// Привязка внешнего аккаунта к текущему пользователю
+// GET /api/oidc/link
+// =========================
+func OIDCLinkHandler(w http.ResponseWriter, r *http.Request) {
+	username, _ := r.Context().Value(CtxUserKey).(string)
+	beginOIDC(w, r, username)
+}
+
+func beginOIDC(w http.ResponseWriter, r *http.Request, linkTo string) {
+	if OIDC == nil || Identities == nil {
+		writeError(w, http.StatusNotFound, "oidc login is disabled")
+		return
+	}
+
+	flow, authURL, err := OIDC.Begin(r.Context(), linkTo)
+	if err != nil {
+		log.Printf("oidc begin: %v", err)
+		writeError(w, http.StatusBadGateway, "identity provider is unavailable")
+		return
+	}
+
+	// Lax, а не Strict: возврат от провайдера — переход с чужого сайта
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie(),
+		Value:    flow.State,
+		Path:     oidcCookiePath,
+		HttpOnly: true,
+		Secure:   CookieSecure,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(OIDC.FlowTTL.Seconds()),
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// =========================
+// Возврат от провайдера: проверка ID-токена, поиск или создание пользователя
+// и обычная сессия входа
+// GET /api/oidc/callback
+// =========================
+func OIDCCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if OIDC == nil || Identities == nil {
+		writeError(w, http.StatusNotFound, "oidc login is disabled")
+		return
+	}
+
+	q := r.URL.Query()
+	state := q.Get("state")
+	c, err := r.Cookie(oidcStateCookie())
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie(),
+		Path:     oidcCookiePath,
+		HttpOnly: true,
+		Secure:   CookieSecure,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   -1,
+	})
+	if err != nil || state == "" || c.Value != state {
+		redirectLoginError(w, r, "login state mismatch")
+		return
+	}
+	if e := q.Get("error"); e != "" {
+		redirectLoginError(w, r, "identity provider: "+e)
+		return
+	}
+
+	flow, ident, err := OIDC.Finish(r.Context(), state, q.Get("code"))
+	if err != nil {
+		log.Printf("oidc callback: %v", err)
+		if errors.Is(err, oidc.ErrUnknownState) {
+			redirectLoginError(w, r, "login expired, try again")
+		} else {
+			redirectLoginError(w, r, "identity provider login failed")
+		}
+		return
+	}
+	id := toUserIdentity(ident)
+
+	if flow.LinkTo != "" {
+		if err := Identities.LinkIdentity(id, flow.LinkTo); err != nil {
+			log.Printf("oidc link: %v", err)
+			redirectLoginError(w, r, "failed to link account")
+			return
+		}
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+
+	username, created, err := Identities.ResolveIdentity(id)
+	if err != nil {
+		log.Printf("oidc resolve: %v", err)
+		redirectLoginError(w, r, "failed to sign in")
+		return
+	}
+	if created {
+		log.Printf("oidc: created user %s for %s", username, ident.Subject)
+	}
+	if banned, err := Users.IsBanned(username); err != nil || banned {
+		redirectLoginError(w, r, "user is banned")
+		return
+	}
+
+	// Вход через провайдера не отменяет 2FA: второй шаг завершает страница входа
+	// (вызов передаётся во фрагменте адреса, чтобы не попасть в логи)
+	step, err := secondFactor(username)
+	if err == nil && step != "" {
+		var challenge string
+		if challenge, err = LoginChallenges.Issue(username); err == nil {
+			http.Redirect(w, r, "/#"+url.Values{"status": {step}, "challenge": {challenge}}.Encode(), http.StatusFound)
+			return
+		}
+	}
+	if err != nil {
+		log.Printf("oidc 2fa: %v", err)
+		redirectLoginError(w, r, "failed to check two-factor authentication")
+		return
+	}
+
+	if _, err := startSession(w, r, username); err != nil {
+		log.Printf("oidc login error: %v", err)
+		redirectLoginError(w, r, "failed to issue token")
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// redirectLoginError возвращает браузер на страницу входа с текстом ошибки
+func redirectLoginError(w http.ResponseWriter, r *http.Request, msg string) {
+	http.Redirect(w, r, "/?login_error="+url.QueryEscape(msg), http.StatusFound)
+}
+
+// toUserIdentity переводит данные ID-токена во внешний аккаунт хранилища;
+// имя нового пользователя берётся из preferred_username, e-mail или name
+func toUserIdentity(id oidc.Identity) user.Identity {
+	return user.Identity{
+		Issuer:        id.Issuer,
+		Subject:       id.Subject,
+		Email:         id.Email,
+		EmailVerified: id.EmailVerified,
+		Names:         []string{id.PreferredUsername, id.Email, id.Name},
+	}
+}
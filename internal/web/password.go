@@ -0,0 +1,274 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/reset"
+	"github.com/go-portfolio/websocket-chat/internal/user"
+)
+
+// =========================
+// Смена и сброс пароля
+// =========================
+var (
+	Passwords   user.PasswordStore // смена и сброс пароля (nil — отключены)
+	ResetSender reset.Sender       // доставка ссылок сброса (nil — сброс отключён)
+	ResetTTL    = time.Hour        // срок действия ссылки сброса
+	VerifyTTL   = 24 * time.Hour   // срок действия ссылки подтверждения e-mail
+	PublicURL   = "http://localhost:8080"
+)
+
+// resetLink — ссылка на страницу чата с токеном сброса во фрагменте: фрагмент
+// не уходит на сервер и не попадает в логи и заголовок Referer
+func resetLink(token string) string {
+	return strings.TrimRight(PublicURL, "/") + "/#reset=" + url.QueryEscape(token)
+}
+
+// verifyLink — ссылка подтверждения e-mail, токен тоже во фрагменте
+func verifyLink(token string) string {
+	return strings.TrimRight(PublicURL, "/") + "/#verify=" + url.QueryEscape(token)
+}
+
+// =========================
+// Смена пароля: {"current_password": "...", "new_password": "..."}.
+// Остальные сессии пользователя отзываются, текущая остаётся
+// POST /api/password
+// =========================
+func ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	username, _ := r.Context().Value(CtxUserKey).(string)
+	current, _ := r.Context().Value(CtxSessionKey).(string)
+	if Passwords == nil {
+		writeError(w, http.StatusNotFound, "password change is disabled")
+		return
+	}
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+
+	// Украденной сессией нельзя перебирать текущий пароль
+	if loginThrottled(w, r, username) {
+		return
+	}
+	err := Passwords.ChangePassword(username, req.CurrentPassword, req.NewPassword)
+	if errors.Is(err, user.ErrWrongPassword) {
+		loginFailed(r, username)
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	loginReleased(r, username)
+	switch {
+	case err == nil:
+		loginSucceeded(username)
+	case errors.Is(err, user.ErrPasswordRequired), errors.Is(err, user.ErrWeakPassword):
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, user.ErrNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	default:
+		log.Printf("change password: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to change password")
+		return
+	}
+
+	log.Printf("password: changed by %s", username)
+	writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "revoked": revokeAfterPasswordChange(username, current, "password changed")})
+}
+
+// =========================
+// Запрос сброса пароля: {"login": "имя или e-mail"}. Ответ всегда одинаковый,
+// чтобы по нему нельзя было узнать, есть ли такой пользователь
+// POST /api/password/forgot
+// =========================
+func ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if Passwords == nil || ResetSender == nil {
+		writeError(w, http.StatusNotFound, "password reset is disabled")
+		return
+	}
+	var req struct {
+		Login string `json:"login"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if signupThrottled(w, r) {
+		return
+	}
+
+	pr, err := Passwords.CreatePasswordReset(req.Login, ResetTTL)
+	switch {
+	case err == nil:
+		// Письмо уходит в фоне: время ответа не выдаёт, нашёлся ли пользователь
+		go func() {
+			notice := reset.Notice{Username: pr.Username, Email: pr.Email, Link: resetLink(pr.Token), ExpiresAt: pr.ExpiresAt}
+			if err := ResetSender.Send(notice); err != nil {
+				log.Printf("password reset: send to %s: %v", pr.Username, err)
+				return
+			}
+			log.Printf("password reset: link sent to %s", pr.Username)
+		}()
+	case errors.Is(err, user.ErrNotFound):
+	default:
+		log.Printf("password reset: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to start password reset")
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "sent"})
+}
+
+// =========================
+// Новый пароль по ссылке из письма: {"token": "...", "password": "..."}.
+// Все сессии пользователя отзываются — войти нужно заново
+// POST /api/password/reset
+// =========================
+func ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if Passwords == nil {
+		writeError(w, http.StatusNotFound, "password reset is disabled")
+		return
+	}
+	var req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+
+	// Неверные токены считаются вместе с регистрациями и запросами сброса с этого IP
+	ip := clientIP(r)
+	if wait := SignupLimiter.Reserve(ip); wait > 0 {
+		tooManyAttempts(w, wait)
+		return
+	}
+	username, err := Passwords.ResetPassword(req.Token, req.Password)
+	if !errors.Is(err, user.ErrResetToken) {
+		SignupLimiter.Release(ip)
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, user.ErrResetToken), errors.Is(err, user.ErrPasswordRequired), errors.Is(err, user.ErrWeakPassword):
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	default:
+		log.Printf("password reset: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to reset password")
+		return
+	}
+
+	log.Printf("password: reset for %s", username)
+	revokeAfterPasswordChange(username, "", "password reset")
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok", "username": username})
+}
+
+// =========================
+// Подтверждение e-mail по ссылке из письма: {"token": "..."}.
+// Только на подтверждённый адрес уходят ссылки сброса пароля
+// POST /api/email/verify
+// =========================
+func VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	if Passwords == nil {
+		writeError(w, http.StatusNotFound, "email verification is disabled")
+		return
+	}
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+
+	username, err := Passwords.VerifyEmail(req.Token)
+	switch {
+	case err == nil:
+	case errors.Is(err, user.ErrVerifyToken):
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	default:
+		log.Printf("email verify: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to verify email")
+		return
+	}
+	log.Printf("email: verified by %s", username)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok", "username": username})
+}
+
+// sendEmailVerification отправляет ссылку подтверждения на текущий e-mail пользователя.
+// Письмо уходит в фоне, ошибки только логируются
+func sendEmailVerification(username string) {
+	if Passwords == nil || ResetSender == nil {
+		return
+	}
+	ev, err := Passwords.CreateEmailVerification(username, VerifyTTL)
+	if err != nil {
+		if !errors.Is(err, user.ErrNotFound) {
+			log.Printf("email verify: %v", err)
+		}
+		return
+	}
+	go func() {
+		notice := reset.Notice{Kind: reset.KindVerifyEmail, Username: ev.Username, Email: ev.Email, Link: verifyLink(ev.Token), ExpiresAt: ev.ExpiresAt}
+		if err := ResetSender.Send(notice); err != nil {
+			log.Printf("email verify: send to %s: %v", ev.Username, err)
+		}
+	}()
+}
+
+// reauthenticated проверяет текущий пароль или код второго фактора перед изменением,
+// которое даёт контроль над аккаунтом: одной украденной сессии для него мало.
+// Попытки считаются вместе с попытками входа
+func reauthenticated(w http.ResponseWriter, r *http.Request, username, password, code string) bool {
+	if password == "" && code == "" {
+		writeError(w, http.StatusForbidden, "current password or two-factor code required")
+		return false
+	}
+	if loginThrottled(w, r, username) {
+		return false
+	}
+	var ok bool
+	switch {
+	case password != "":
+		ok = Users.Authenticate(username, password)
+	case TwoFactor != nil:
+		var err error
+		if ok, err = TwoFactor.VerifyTwoFactor(username, code); err != nil {
+			loginReleased(r, username)
+			log.Printf("reauth 2fa: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to verify code")
+			return false
+		}
+	}
+	if !ok {
+		loginFailed(r, username)
+		writeError(w, http.StatusForbidden, "invalid password or code")
+		return false
+	}
+	loginReleased(r, username)
+	return true
+}
+
+// revokeAfterPasswordChange отзывает сессии пользователя, кроме except, и возвращает их число.
+// Пароль к этому моменту уже сменён, поэтому ошибка только логируется
+func revokeAfterPasswordChange(username, except, reason string) int {
+	if Sessions == nil {
+		return 0
+	}
+	n, err := RevokeUserSessions(username, except, reason)
+	if err != nil {
+		log.Printf("password: revoke sessions of %s: %v", username, err)
+	}
+	return n
+}
@@ -0,0 +1,225 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/avatar"
+	"github.com/go-portfolio/websocket-chat/internal/storage"
+	"github.com/go-portfolio/websocket-chat/internal/user"
+)
+
+// DefaultAvatars — кэш сгенерированных аватаров по умолчанию
+var DefaultAvatars = avatar.NewCache(1024)
+
+// =========================
+// DefaultAvatarURL возвращает стабильный URL сгенерированного аватара пользователя
+// =========================
+func DefaultAvatarURL(username string) string {
+	return "/avatars/" + url.PathEscape(username) + ".svg"
+}
+
+// =========================
+// avatarOf возвращает аватар пользователя или сгенерированный, если он не загружен
+// =========================
+func avatarOf(username string) string {
+	if stored := Users.GetAvatar(username); stored != "" {
+		return stored
+	}
+	return DefaultAvatarURL(username)
+}
+
+// =========================
+// avatarURLs возвращает URL вариантов аватара по сохранённому значению
+// Для аватаров, загруженных до появления вариантов, и сгенерированных SVG
+// все размеры указывают на один и тот же файл
+// =========================
+func avatarURLs(stored string) map[string]string {
+	urls := make(map[string]string, len(avatar.Sizes))
+
+	keys, ok := avatar.Variants(strings.TrimPrefix(stored, UploadsURL("")))
+	for _, size := range avatar.Sizes {
+		if ok {
+			urls[strconv.Itoa(size)] = UploadsURL(keys[size])
+		} else {
+			urls[strconv.Itoa(size)] = stored
+		}
+	}
+	return urls
+}
+
+// =========================
+// saveAvatar проверяет загруженный файл, строит варианты и возвращает URL основного
+// При ошибке возвращает HTTP-статус и текст для клиента
+// =========================
+func saveAvatar(file io.Reader) (string, int, error) {
+	data, _, err := AvatarPolicy.Read(file)
+	if err == nil {
+		var key string
+		if key, err = avatar.Save(Blobs, data); err == nil {
+			return UploadsURL(key), http.StatusOK, nil
+		}
+	}
+
+	switch {
+	case errors.Is(err, storage.ErrTooLarge), errors.Is(err, storage.ErrTypeNotAllowed),
+		errors.Is(err, avatar.ErrNotImage), errors.Is(err, avatar.ErrTooManyPixels):
+		return "", http.StatusBadRequest, err
+	default:
+		log.Printf("avatar: %v", err)
+		return "", http.StatusInternalServerError, errors.New("cannot save file")
+	}
+}
+
+// =========================
+// Профиль текущего пользователя
+// GET /api/profile
+// =========================
+func ProfileHandler(w http.ResponseWriter, r *http.Request) {
+	username, _ := r.Context().Value(CtxUserKey).(string)
+
+	current := avatarOf(username)
+	email, digest, err := Users.GetEmail(username)
+	if err != nil {
+		log.Printf("profile: %v", err)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"username":       username,
+		"avatar":         current,
+		"avatars":        avatarURLs(current),
+		"email":          email,
+		"email_digest":   digest,
+		"email_verified": emailVerified(username),
+	})
+}
+
+// =========================
+// E-mail и согласие на дайджесты непрочитанного
+// PUT /api/profile/email {"email": "...", "email_digest": true, "current_password": "..."}
+// Сменить адрес можно только с текущим паролем или кодом второго фактора ("code"):
+// иначе украденной сессией можно было бы направить ссылку сброса пароля себе.
+// Пока адрес не подтверждён, каждый запрос отправляет на него новую ссылку подтверждения
+// =========================
+func EmailSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	username, _ := r.Context().Value(CtxUserKey).(string)
+
+	var req struct {
+		Email           string `json:"email"`
+		Digest          bool   `json:"email_digest"`
+		CurrentPassword string `json:"current_password"`
+		Code            string `json:"code"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+
+	old, _, err := Users.GetEmail(username)
+	if err != nil {
+		log.Printf("email settings: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to update email")
+		return
+	}
+	changed := strings.TrimSpace(req.Email) != old
+	if changed && !reauthenticated(w, r, username, req.CurrentPassword, req.Code) {
+		return
+	}
+
+	if err := Users.SetEmail(username, req.Email, req.Digest); err != nil {
+		if errors.Is(err, user.ErrInvalidEmail) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		log.Printf("email settings: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to update email")
+		return
+	}
+
+	email, digest, _ := Users.GetEmail(username)
+	verified := emailVerified(username)
+	if email != "" && !verified {
+		sendEmailVerification(username)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"email": email, "email_digest": digest, "email_verified": verified})
+}
+
+// emailVerified сообщает, подтверждён ли e-mail пользователя; без хранилища паролей — нет
+func emailVerified(username string) bool {
+	if Passwords == nil {
+		return false
+	}
+	verified, err := Passwords.EmailVerified(username)
+	if err != nil {
+		log.Printf("email status: %v", err)
+	}
+	return verified
+}
+
+// =========================
+// Сгенерированный аватар
+// GET /avatars/{name}.svg
+// =========================
+func DefaultAvatarHandler(w http.ResponseWriter, r *http.Request) {
+	username, ok := strings.CutSuffix(r.PathValue("name"), ".svg")
+	if !ok || username == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := DefaultAvatars.Get(username)
+	sum := sha256.Sum256(data)
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:8])+`"`)
+	http.ServeContent(w, r, "avatar.svg", time.Time{}, bytes.NewReader(data))
+}
+
+// =========================
+// Замена аватара
+// PUT /api/profile/avatar (multipart/form-data, поле "avatar")
+// =========================
+func AvatarUploadHandler(w http.ResponseWriter, r *http.Request) {
+	username, _ := r.Context().Value(CtxUserKey).(string)
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxUploadSize)
+	if err := r.ParseMultipartForm(MaxUploadSize); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid form data")
+		return
+	}
+	file, _, err := r.FormFile("avatar")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "avatar file is required")
+		return
+	}
+	defer file.Close()
+
+	url, status, err := saveAvatar(file)
+	if err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+
+	if err := Users.SetAvatar(username, url); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update avatar")
+		return
+	}
+
+	// Старые варианты не удаляются: ключи адресуются содержимым и могут быть общими у разных пользователей
+	writeJSON(w, http.StatusOK, map[string]any{
+		"avatar":  url,
+		"avatars": avatarURLs(url),
+	})
+}
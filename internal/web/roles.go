@@ -0,0 +1,60 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-portfolio/websocket-chat/internal/room"
+)
+
+// =========================
+// RoomRoleHandler — владелец комнаты назначает администраторов или снимает их:
+// {"role": "admin"} или {"role": "member"}. Администраторы комнаты, как и владелец,
+// могут выгружать её историю
+// PUT /api/rooms/{room}/members/{username}/role
+// =========================
+func RoomRoleHandler(w http.ResponseWriter, r *http.Request) {
+	username, _ := r.Context().Value(CtxUserKey).(string)
+	roomName, target := r.PathValue("room"), r.PathValue("username")
+
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if req.Role != room.RoleAdmin && req.Role != room.RoleMember {
+		writeError(w, http.StatusBadRequest, "role must be admin or member")
+		return
+	}
+
+	role, err := Rooms.Role(roomName, username)
+	if err != nil {
+		log.Printf("room role: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to check permissions")
+		return
+	}
+	if role != room.RoleOwner {
+		writeError(w, http.StatusForbidden, "only the room owner can change roles")
+		return
+	}
+	if target == username {
+		writeError(w, http.StatusBadRequest, "the owner's role cannot be changed")
+		return
+	}
+
+	if err := Rooms.SetRole(roomName, target, req.Role); err != nil {
+		if errors.Is(err, room.ErrNotMember) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		log.Printf("room role: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to change role")
+		return
+	}
+	log.Printf("room %s: %s is now %s (by %s)", roomName, target, req.Role, username)
+	writeJSON(w, http.StatusOK, map[string]string{"room": roomName, "username": target, "role": req.Role})
+}
@@ -0,0 +1,99 @@
+package web
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/message"
+)
+
+// Размер страницы поиска
+const (
+	defaultSearchSize = 20
+	maxSearchSize     = 100
+)
+
+// =========================
+// SearchHandler ищет по истории сообщений, доступной текущему пользователю.
+// Параметры: q — запрос, room — комната, author — автор, before — дата (YYYY-MM-DD)
+// или время (RFC 3339), limit и offset — постраничная выдача.
+// =========================
+func SearchHandler(w http.ResponseWriter, r *http.Request) {
+	username, _ := r.Context().Value(CtxUserKey).(string)
+	params := r.URL.Query()
+
+	q := message.SearchQuery{
+		Text:   strings.TrimSpace(params.Get("q")),
+		User:   username,
+		Room:   params.Get("room"),
+		Author: params.Get("author"),
+		Limit:  defaultSearchSize,
+	}
+	if q.Text == "" {
+		writeError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		q.Limit = min(n, maxSearchSize)
+	}
+	if v := params.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "invalid offset")
+			return
+		}
+		q.Offset = n
+	}
+	if v := params.Get("before"); v != "" {
+		t, err := parseTime(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid before")
+			return
+		}
+		q.Before = t
+	}
+
+	// Поиск по конкретной комнате — только для её участников
+	if q.Room != "" {
+		member, err := Rooms.IsMember(q.Room, username)
+		if err != nil {
+			log.Printf("search: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to check membership")
+			return
+		}
+		if !member {
+			writeError(w, http.StatusForbidden, "not a member of this room")
+			return
+		}
+	}
+
+	results, err := Messages.Search(q)
+	if err != nil {
+		log.Printf("search: %v", err)
+		writeError(w, http.StatusInternalServerError, "search failed")
+		return
+	}
+
+	resp := map[string]any{"results": results}
+	if len(results) == q.Limit {
+		resp["next_offset"] = q.Offset + q.Limit
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// parseTime принимает дату (YYYY-MM-DD, UTC) или время в RFC 3339
+func parseTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
@@ -0,0 +1,344 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/go-portfolio/websocket-chat/internal/auth"
+	"github.com/go-portfolio/websocket-chat/internal/session"
+)
+
+// refreshPath — refresh-cookie уходит только на /api/*, а не с каждым запросом статики
+const refreshPath = "/api/"
+
+// refreshCookieName — имя cookie с refresh-токеном
+func refreshCookieName() string {
+	return CookieName + "_refresh"
+}
+
+// tokenResponse — токены в теле ответа для клиентов без cookie (CLI, мобильные приложения)
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+func newTokenResponse(access, refresh string) tokenResponse {
+	return tokenResponse{AccessToken: access, RefreshToken: refresh, TokenType: "Bearer", ExpiresIn: int(auth.TokenTTL.Seconds())}
+}
+
+// =========================
+// startSession открывает сессию и выставляет cookie access- и refresh-токенов.
+// Без хранилища сессий выдаётся только access-токен.
+// =========================
+func startSession(w http.ResponseWriter, r *http.Request, username string) (tokenResponse, error) {
+	if Sessions == nil {
+		token, err := auth.IssueJWT(username)
+		if err != nil {
+			return tokenResponse{}, err
+		}
+		setAuthCookies(w, token, "")
+		return newTokenResponse(token, ""), nil
+	}
+
+	sess, refresh, err := Sessions.Create(username, r.UserAgent(), clientIP(r), RefreshTTL)
+	if err != nil {
+		return tokenResponse{}, err
+	}
+	token, err := auth.IssueSessionJWT(username, sess.ID)
+	if err != nil {
+		return tokenResponse{}, err
+	}
+	setAuthCookies(w, token, refresh)
+	return newTokenResponse(token, refresh), nil
+}
+
+// =========================
+// Обновление access-токена по refresh-cookie или, для клиентов без cookie,
+// по {"refresh_token": "..."} в теле — тогда новые токены возвращаются в ответе
+// POST /api/refresh
+// =========================
+func RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	refreshToken := ""
+	if c, err := r.Cookie(refreshCookieName()); err == nil {
+		refreshToken = c.Value
+	} else if err := json.NewDecoder(r.Body).Decode(&body); err == nil {
+		refreshToken = body.RefreshToken
+	}
+	if refreshToken == "" || Sessions == nil {
+		writeError(w, http.StatusUnauthorized, "missing refresh cookie")
+		return
+	}
+
+	sess, refresh, err := Sessions.Rotate(refreshToken, RefreshTTL)
+	if errors.Is(err, session.ErrReused) {
+		// Старый токен предъявлен повторно — сессия отозвана, отключаем её сокеты
+		log.Printf("refresh token reuse detected for %s, session %s revoked", sess.Username, sess.ID)
+		ChatHub.DisconnectSession(sess.ID, "session revoked")
+	}
+	if err != nil {
+		if !errors.Is(err, session.ErrInvalid) && !errors.Is(err, session.ErrReused) {
+			log.Printf("refresh error: %v", err)
+		}
+		clearAuthCookies(w)
+		writeError(w, http.StatusUnauthorized, "invalid session")
+		return
+	}
+
+	token, err := auth.IssueSessionJWT(sess.Username, sess.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to issue token")
+		return
+	}
+	if body.RefreshToken != "" {
+		writeJSON(w, http.StatusOK, newTokenResponse(token, refresh))
+		return
+	}
+	setAuthCookies(w, token, refresh)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// =========================
+// Выход: отзыв текущей сессии и отключение её сокетов
+// POST /api/logout
+// =========================
+func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	if Sessions != nil {
+		sessionID, err := revokeCurrentSession(r)
+		if err != nil {
+			log.Printf("logout error: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to revoke session")
+			return
+		}
+		if sessionID != "" {
+			ChatHub.DisconnectSession(sessionID, "logged out")
+		}
+	}
+
+	clearAuthCookies(w)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "logged out"})
+}
+
+// revokeCurrentSession отзывает сессию запроса: по refresh-cookie (она живёт дольше)
+// или по ещё действующему access-токену из Authorization: Bearer или cookie. Возвращает ID отозванной сессии или "".
+func revokeCurrentSession(r *http.Request) (string, error) {
+	if c, err := r.Cookie(refreshCookieName()); err == nil {
+		sess, err := Sessions.RevokeToken(c.Value)
+		if err == nil {
+			return sess.ID, nil
+		}
+		if !errors.Is(err, session.ErrInvalid) {
+			return "", err
+		}
+	}
+	token := bearerToken(r)
+	if c, err := r.Cookie(CookieName); err == nil && token == "" {
+		token = c.Value
+	}
+	if claims, err := auth.ParseClaims(token); err == nil && claims.SessionID != "" {
+		return claims.SessionID, Sessions.Revoke(claims.SessionID)
+	}
+	return "", nil
+}
+
+// setAuthCookies выставляет cookie access-токена и, если он есть, refresh-токена
+func setAuthCookies(w http.ResponseWriter, token, refresh string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   CookieSecure,
+		SameSite: CookieSameSite,
+		MaxAge:   int(auth.TokenTTL.Seconds()),
+	})
+	if refresh == "" {
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshCookieName(),
+		Value:    refresh,
+		Path:     refreshPath,
+		HttpOnly: true,
+		Secure:   CookieSecure,
+		SameSite: CookieSameSite,
+		MaxAge:   int(RefreshTTL.Seconds()),
+	})
+}
+
+// clearAuthCookies удаляет обе cookie авторизации
+func clearAuthCookies(w http.ResponseWriter) {
+	for name, path := range map[string]string{CookieName: "/", refreshCookieName(): refreshPath} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Path:     path,
+			HttpOnly: true,
+			Secure:   CookieSecure,
+			SameSite: CookieSameSite,
+			MaxAge:   -1,
+		})
+	}
+}
+
+// TrustedProxies — обратные прокси перед сервером; X-Forwarded-For принимается только
+// от них. Пусто — адрес клиента берётся из соединения
+var TrustedProxies []*net.IPNet
+
+// clientIP возвращает адрес клиента без порта. За доверенным прокси это самый правый
+// адрес X-Forwarded-For, не принадлежащий доверенным прокси: всё левее него мог
+// дописать сам клиент
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !trustedProxy(host) {
+		return host
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		host = hop
+		if !trustedProxy(hop) {
+			break
+		}
+	}
+	return host
+}
+
+func trustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range TrustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// sessionInfo — сессия в ответе GET /api/sessions
+type sessionInfo struct {
+	ID          string `json:"id"`
+	UserAgent   string `json:"user_agent"`
+	IP          string `json:"ip"`
+	CreatedAt   int64  `json:"created_at"`
+	LastUsedAt  int64  `json:"last_used_at"`
+	ExpiresAt   int64  `json:"expires_at"`
+	Current     bool   `json:"current"`     // сессия, из которой сделан запрос
+	Connections int    `json:"connections"` // открытые WebSocket-подключения
+}
+
+// =========================
+// SessionsHandler отдаёт действующие сессии текущего пользователя
+// GET /api/sessions
+// =========================
+func SessionsHandler(w http.ResponseWriter, r *http.Request) {
+	username, _ := r.Context().Value(CtxUserKey).(string)
+	current, _ := r.Context().Value(CtxSessionKey).(string)
+
+	list, err := Sessions.List(username)
+	if err != nil {
+		log.Printf("sessions: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to load sessions")
+		return
+	}
+
+	connections := ChatHub.SessionConnections(username)
+	resp := make([]sessionInfo, 0, len(list))
+	for _, s := range list {
+		resp = append(resp, sessionInfo{
+			ID:          s.ID,
+			UserAgent:   s.UserAgent,
+			IP:          s.IP,
+			CreatedAt:   s.CreatedAt.Unix(),
+			LastUsedAt:  s.LastUsedAt.Unix(),
+			ExpiresAt:   s.ExpiresAt.Unix(),
+			Current:     s.ID == current,
+			Connections: connections[s.ID],
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"sessions": resp})
+}
+
+// =========================
+// RevokeSessionHandler отзывает одну сессию текущего пользователя
+// и закрывает её WebSocket-подключения
+// DELETE /api/sessions/{id}
+// =========================
+func RevokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	username, _ := r.Context().Value(CtxUserKey).(string)
+	current, _ := r.Context().Value(CtxSessionKey).(string)
+	id := r.PathValue("id")
+
+	err := Sessions.RevokeOwn(username, id)
+	if errors.Is(err, session.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	if err != nil {
+		log.Printf("sessions: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to revoke session")
+		return
+	}
+
+	ChatHub.DisconnectSession(id, "session revoked")
+	if id == current {
+		clearAuthCookies(w)
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "revoked"})
+}
+
+// =========================
+// RevokeOtherSessionsHandler — «выйти на всех устройствах»: отзывает все сессии
+// пользователя, кроме текущей
+// DELETE /api/sessions
+// =========================
+func RevokeOtherSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	username, _ := r.Context().Value(CtxUserKey).(string)
+	current, _ := r.Context().Value(CtxSessionKey).(string)
+
+	n, err := RevokeUserSessions(username, current, "session revoked")
+	if err != nil {
+		log.Printf("sessions: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to revoke sessions")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"revoked": n})
+}
+
+// RevokeUserSessions отзывает все сессии пользователя, кроме except, закрывает их
+// WebSocket-подключения и возвращает число отозванных сессий
+func RevokeUserSessions(username, except, reason string) (int, error) {
+	ids, err := Sessions.RevokeAll(username, except)
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		ChatHub.DisconnectSession(id, reason)
+	}
+	return len(ids), nil
+}
+
+// =========================
+// JWKSHandler публикует открытые ключи проверки JWT (RFC 7517)
+// GET /.well-known/jwks.json
+// =========================
+func JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	_ = json.NewEncoder(w).Encode(map[string]any{"keys": auth.Keys.JWKS()})
+}
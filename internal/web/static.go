@@ -0,0 +1,108 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// =========================
+// Встроенный фронтенд
+// =========================
+
+//go:embed static
+var embeddedStatic embed.FS
+
+// StaticDir — каталог, из которого UI отдаётся вместо встроенного (удобно при разработке:
+// правки index.html видны без пересборки). Пустое значение — использовать встроенные файлы.
+var StaticDir string
+
+// staticFile — содержимое файла вместе с ETag
+type staticFile struct {
+	data []byte
+	etag string
+}
+
+// кэш встроенных файлов: они не меняются за время жизни процесса
+var (
+	embeddedMu    sync.Mutex
+	embeddedCache = map[string]staticFile{}
+)
+
+// staticFS возвращает текущий источник статических файлов
+func staticFS() fs.FS {
+	if StaticDir != "" {
+		return os.DirFS(StaticDir)
+	}
+	sub, _ := fs.Sub(embeddedStatic, "static")
+	return sub
+}
+
+// loadStatic читает файл и вычисляет его ETag
+func loadStatic(name string) (staticFile, error) {
+	if StaticDir == "" {
+		embeddedMu.Lock()
+		defer embeddedMu.Unlock()
+		if f, ok := embeddedCache[name]; ok {
+			return f, nil
+		}
+	}
+
+	data, err := fs.ReadFile(staticFS(), name)
+	if err != nil {
+		return staticFile{}, err
+	}
+	sum := sha256.Sum256(data)
+	f := staticFile{data: data, etag: `"` + hex.EncodeToString(sum[:8]) + `"`}
+
+	if StaticDir == "" {
+		embeddedCache[name] = f
+	}
+	return f, nil
+}
+
+// =========================
+// IndexHandler
+// GET /, GET /<asset>
+// Отдаёт статические файлы UI; неизвестные пути получают index.html
+// =========================
+func IndexHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" {
+		name = "index.html"
+	}
+
+	f, err := loadStatic(name)
+	if err != nil {
+		name = "index.html"
+		if f, err = loadStatic(name); err != nil {
+			http.Error(w, "index.html not found", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	ctype := mime.TypeByExtension(path.Ext(name))
+	if ctype == "" {
+		ctype = http.DetectContentType(f.data)
+	}
+	w.Header().Set("Content-Type", ctype)
+	w.Header().Set("ETag", f.etag)
+	if strings.HasSuffix(name, ".html") {
+		// HTML всегда перепроверяем по ETag, чтобы новая версия UI подхватывалась сразу
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+	}
+
+	// ServeContent сам обработает If-None-Match (304) и Range-запросы
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(f.data))
+}
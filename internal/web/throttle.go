@@ -0,0 +1,89 @@
+package web
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/auth"
+)
+
+// =========================
+// Защита от перебора паролей и массовой регистрации. Значения по умолчанию совпадают
+// с настройками в config; сервер заменяет счётчики при запуске
+// =========================
+var (
+	// LoginIPLimiter — неудачные входы с одного IP (перебор по многим аккаунтам)
+	LoginIPLimiter = auth.NewLimiter(10, time.Second, 5*time.Minute, 15*time.Minute)
+	// LoginUserLimiter — неудачные входы в один аккаунт: пауза растёт экспоненциально
+	LoginUserLimiter = auth.NewLimiter(3, time.Second, 5*time.Minute, 15*time.Minute)
+	// Lockouts — временная блокировка аккаунта после 10 неудач подряд
+	Lockouts = auth.NewLimiter(9, 15*time.Minute, 15*time.Minute, 15*time.Minute)
+	// SignupLimiter — регистрации и запросы сброса пароля с одного IP
+	SignupLimiter = auth.NewLimiter(5, time.Minute, time.Hour, time.Hour)
+)
+
+// loginThrottled отвечает 429, если вход с этого IP или в этот аккаунт пока запрещён,
+// а иначе заранее учитывает попытку как неудачную: проверка и учёт идут под одной
+// блокировкой, поэтому параллельные запросы не проходят все разом до первой неудачи.
+// Если пароль или код окажется верным, попытку снимает loginReleased.
+// Ответ не зависит от того, есть ли такой пользователь
+func loginThrottled(w http.ResponseWriter, r *http.Request, username string) bool {
+	ip := clientIP(r)
+	wait := LoginIPLimiter.Reserve(ip)
+	if wait <= 0 {
+		if wait = LoginUserLimiter.Reserve(username); wait > 0 {
+			LoginIPLimiter.Release(ip)
+		}
+	}
+	if wait <= 0 {
+		if wait = Lockouts.Reserve(username); wait > 0 {
+			LoginIPLimiter.Release(ip)
+			LoginUserLimiter.Release(username)
+		}
+	}
+	if wait <= 0 {
+		return false
+	}
+	tooManyAttempts(w, wait)
+	return true
+}
+
+// loginFailed вызывается при неверном пароле или коде второго фактора; сама попытка
+// уже учтена loginThrottled
+func loginFailed(r *http.Request, username string) {
+	if lock := Lockouts.Wait(username); lock > 0 {
+		log.Printf("login: %s locked for %s after repeated failures (last from %s)", username, lock.Round(time.Second), clientIP(r))
+	}
+}
+
+// loginReleased снимает попытку, учтённую loginThrottled: пароль верен или не проверялся
+func loginReleased(r *http.Request, username string) {
+	LoginIPLimiter.Release(clientIP(r))
+	LoginUserLimiter.Release(username)
+	Lockouts.Release(username)
+}
+
+// loginSucceeded сбрасывает счётчики аккаунта; счётчик IP остаётся, иначе перебор
+// можно было бы «обнулять» входом в свой аккаунт
+func loginSucceeded(username string) {
+	LoginUserLimiter.Reset(username)
+	Lockouts.Reset(username)
+}
+
+// signupThrottled учитывает запрос регистрации или сброса пароля с IP клиента
+// и отвечает 429, если их было слишком много
+func signupThrottled(w http.ResponseWriter, r *http.Request) bool {
+	if wait := SignupLimiter.Reserve(clientIP(r)); wait > 0 {
+		tooManyAttempts(w, wait)
+		return true
+	}
+	return false
+}
+
+func tooManyAttempts(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	writeError(w, http.StatusTooManyRequests, "too many attempts, try again later")
+}
@@ -0,0 +1,103 @@
+package web
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/auth"
+)
+
+// Tickets — одноразовые билеты для /ws (query-параметр ticket или подпротокол)
+var Tickets = auth.NewTicketStore(30 * time.Second)
+
+// ticketProtocol — префикс подпротокола с билетом: Sec-WebSocket-Protocol: chat, ticket.<билет>
+const ticketProtocol = "ticket."
+
+// chatProtocol — подпротокол, который сервер подтверждает клиенту, передавшему билет в заголовке
+const chatProtocol = "chat"
+
+// =========================
+// Выдача билета на подключение к WebSocket
+// POST /api/ws-ticket
+// =========================
+func WSTicketHandler(w http.ResponseWriter, r *http.Request) {
+	username, _ := r.Context().Value(CtxUserKey).(string)
+	sessionID, _ := r.Context().Value(CtxSessionKey).(string)
+
+	ticket, err := Tickets.Issue(auth.Claims{Username: username, SessionID: sessionID})
+	if err != nil {
+		log.Printf("ws ticket: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to issue ticket")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"ticket":     ticket,
+		"expires_in": int(Tickets.TTL.Seconds()),
+	})
+}
+
+// =========================
+// WebSocketAuthMiddleware пускает на /ws по билету из ?ticket= или подпротокола
+// ticket.<билет>; без билета — как AuthMiddleware (cookie или Bearer)
+// =========================
+func WebSocketAuthMiddleware(next http.Handler) http.Handler {
+	withToken := AuthMiddleware(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ticket := r.URL.Query().Get("ticket")
+		if ticket == "" {
+			ticket = protocolTicket(r)
+		}
+		if ticket == "" {
+			withToken.ServeHTTP(w, r)
+			return
+		}
+
+		claims, ok := Tickets.Redeem(ticket)
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "invalid ticket")
+			return
+		}
+		serveAuthenticated(next, w, r, claims)
+	})
+}
+
+// protocolTicket достаёт билет из Sec-WebSocket-Protocol
+func protocolTicket(r *http.Request) string {
+	for _, p := range websocketProtocols(r) {
+		if t, ok := strings.CutPrefix(p, ticketProtocol); ok {
+			return t
+		}
+	}
+	return ""
+}
+
+// responseProtocol выбирает подпротокол для ответа: браузер разрывает соединение,
+// если предложил подпротоколы, а сервер не подтвердил ни один
+func responseProtocol(r *http.Request) string {
+	offered := websocketProtocols(r)
+	for _, p := range offered {
+		if p == chatProtocol {
+			return p
+		}
+	}
+	for _, p := range offered {
+		if strings.HasPrefix(p, ticketProtocol) {
+			return p
+		}
+	}
+	return ""
+}
+
+func websocketProtocols(r *http.Request) []string {
+	var protocols []string
+	for _, h := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(h, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				protocols = append(protocols, p)
+			}
+		}
+	}
+	return protocols
+}
@@ -0,0 +1,386 @@
+package web
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/auth"
+	"github.com/go-portfolio/websocket-chat/internal/qr"
+	"github.com/go-portfolio/websocket-chat/internal/settings"
+	"github.com/go-portfolio/websocket-chat/internal/totp"
+	"github.com/go-portfolio/websocket-chat/internal/user"
+)
+
+// =========================
+// Двухфакторная аутентификация (TOTP)
+// =========================
+var (
+	TwoFactor  user.TwoFactorStore    // TOTP-секреты и коды восстановления (nil — 2FA отключена)
+	Settings   settings.SettingsStore // Настройки сервера, в т.ч. обязательная 2FA
+	TOTPIssuer = "GoChat"             // Название сервиса в приложении-аутентификаторе
+
+	// LoginChallenges — входы, ожидающие второго фактора: 5 минут и 5 попыток на код
+	LoginChallenges = auth.NewChallengeStore(5*time.Minute, 5)
+)
+
+// Статусы первого шага входа, когда нужен второй фактор
+const (
+	statusTwoFactorRequired = "2fa_required"       // ввести код
+	statusTwoFactorSetup    = "2fa_setup_required" // 2FA обязательна: подключить её и ввести код
+)
+
+// ChallengeHeader — заголовок с вызовом входа для подключения 2FA до выдачи сессии
+const ChallengeHeader = "X-Login-Challenge"
+
+// ctxChallengeKey — вызов входа, по которому пришёл запрос к /api/2fa
+const ctxChallengeKey ctxKey = "login-challenge"
+
+// twoFactorRequired сообщает, требует ли администратор 2FA от всех
+func twoFactorRequired() (bool, error) {
+	if Settings == nil || TwoFactor == nil {
+		return false, nil
+	}
+	return settings.Bool(Settings, settings.RequireTwoFactor)
+}
+
+// secondFactor возвращает, какой второй шаг нужен пользователю после пароля; "" — не нужен
+func secondFactor(username string) (string, error) {
+	if TwoFactor == nil {
+		return "", nil
+	}
+	status, err := TwoFactor.TwoFactor(username)
+	if err != nil {
+		return "", err
+	}
+	if status.Enabled {
+		return statusTwoFactorRequired, nil
+	}
+	required, err := twoFactorRequired()
+	if err != nil || !required {
+		return "", err
+	}
+	return statusTwoFactorSetup, nil
+}
+
+// issueChallenge отвечает вызовом, если после пароля нужен второй фактор;
+// false — не нужен, вход можно завершать
+func issueChallenge(w http.ResponseWriter, username string) bool {
+	step, err := secondFactor(username)
+	if err == nil && step == "" {
+		return false
+	}
+	var challenge string
+	if err == nil {
+		challenge, err = LoginChallenges.Issue(username)
+	}
+	if err != nil {
+		log.Printf("login 2fa: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to check two-factor authentication")
+		return true
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"status":     step,
+		"challenge":  challenge,
+		"expires_in": int(LoginChallenges.TTL.Seconds()),
+	})
+	return true
+}
+
+// loginWithCode — второй шаг входа: код из приложения или код восстановления
+func loginWithCode(w http.ResponseWriter, r *http.Request, challenge, code string, withTokens bool) {
+	username, ok := LoginChallenges.Peek(challenge)
+	if !ok || TwoFactor == nil {
+		writeError(w, http.StatusUnauthorized, auth.ErrChallenge.Error())
+		return
+	}
+	status, err := TwoFactor.TwoFactor(username)
+	if err != nil {
+		log.Printf("login 2fa: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to check two-factor authentication")
+		return
+	}
+	if !status.Enabled {
+		writeError(w, http.StatusForbidden, "two-factor setup required")
+		return
+	}
+	// Неверные коды учитываются вместе с неверными паролями: иначе, зная пароль,
+	// можно было бы перебирать коды, каждый раз получая новый вызов
+	if loginThrottled(w, r, username) {
+		return
+	}
+
+	_, err = LoginChallenges.Attempt(challenge, func(username string) (bool, error) {
+		return TwoFactor.VerifyTwoFactor(username, code)
+	})
+	if errors.Is(err, auth.ErrChallengeFailed) {
+		loginFailed(r, username)
+	} else {
+		loginReleased(r, username)
+	}
+	if !writeChallengeError(w, err) {
+		return
+	}
+	completeLogin(w, r, username, withTokens, nil)
+}
+
+// writeChallengeError отвечает ошибкой проверки кода; true — ошибки нет
+func writeChallengeError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, auth.ErrChallenge), errors.Is(err, auth.ErrChallengeFailed):
+		writeError(w, http.StatusUnauthorized, err.Error())
+	default:
+		log.Printf("login 2fa: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to verify code")
+	}
+	return false
+}
+
+// =========================
+// TwoFactorSetupMiddleware пускает к подключению 2FA вошедших пользователей,
+// а также по вызову входа из заголовка X-Login-Challenge — когда 2FA обязательна,
+// сессия выдаётся только после её подключения
+// =========================
+func TwoFactorSetupMiddleware(next http.Handler) http.Handler {
+	withToken := AuthMiddleware(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		challenge := r.Header.Get(ChallengeHeader)
+		if challenge == "" {
+			withToken.ServeHTTP(w, r)
+			return
+		}
+		username, ok := LoginChallenges.Peek(challenge)
+		if !ok {
+			writeError(w, http.StatusUnauthorized, auth.ErrChallenge.Error())
+			return
+		}
+		ctx := context.WithValue(r.Context(), CtxUserKey, username)
+		ctx = context.WithValue(ctx, ctxChallengeKey, challenge)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// =========================
+// Состояние 2FA текущего пользователя
+// GET /api/2fa
+// =========================
+func TwoFactorStatusHandler(w http.ResponseWriter, r *http.Request) {
+	username, _ := r.Context().Value(CtxUserKey).(string)
+	if TwoFactor == nil {
+		writeError(w, http.StatusNotFound, "two-factor authentication is disabled")
+		return
+	}
+	status, err := TwoFactor.TwoFactor(username)
+	if err != nil {
+		log.Printf("2fa status: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to get two-factor status")
+		return
+	}
+	required, err := twoFactorRequired()
+	if err != nil {
+		log.Printf("2fa status: %v", err)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"enabled":             status.Enabled,
+		"recovery_codes_left": status.RecoveryCodesLeft,
+		"required":            required,
+	})
+}
+
+// =========================
+// Начало подключения: новый секрет, ссылка otpauth:// и QR-код (PNG в base64)
+// POST /api/2fa/setup
+// =========================
+func TwoFactorSetupHandler(w http.ResponseWriter, r *http.Request) {
+	username, _ := r.Context().Value(CtxUserKey).(string)
+	if TwoFactor == nil {
+		writeError(w, http.StatusNotFound, "two-factor authentication is disabled")
+		return
+	}
+
+	secret, err := TwoFactor.BeginTOTP(username)
+	if errors.Is(err, user.ErrTwoFactorEnabled) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("2fa setup: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to start two-factor setup")
+		return
+	}
+
+	uri := totp.URI(TOTPIssuer, username, secret)
+	code, err := qr.Encode([]byte(uri), qr.Medium)
+	var image []byte
+	if err == nil {
+		image, err = code.PNG(6)
+	}
+	if err != nil {
+		log.Printf("2fa setup qr: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to render qr code")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"secret":      secret,
+		"otpauth_uri": uri,
+		"qr_png":      base64.StdEncoding.EncodeToString(image),
+	})
+}
+
+// =========================
+// Включение 2FA после проверки кода из приложения: {"code": "123456"}.
+// Возвращает коды восстановления; при подключении по вызову входа ещё и завершает вход
+// POST /api/2fa/enable
+// =========================
+func TwoFactorEnableHandler(w http.ResponseWriter, r *http.Request) {
+	username, _ := r.Context().Value(CtxUserKey).(string)
+	challenge, _ := r.Context().Value(ctxChallengeKey).(string)
+	if TwoFactor == nil {
+		writeError(w, http.StatusNotFound, "two-factor authentication is disabled")
+		return
+	}
+	var req struct {
+		Code   string `json:"code"`
+		Tokens bool   `json:"tokens"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+
+	var (
+		codes []string
+		err   error
+	)
+	if challenge != "" {
+		// Подбор кода ограничен попытками вызова
+		_, err = LoginChallenges.Attempt(challenge, func(username string) (bool, error) {
+			var enableErr error
+			codes, enableErr = TwoFactor.EnableTOTP(username, req.Code)
+			if errors.Is(enableErr, user.ErrInvalidCode) {
+				return false, nil
+			}
+			return enableErr == nil, enableErr
+		})
+	} else {
+		codes, err = TwoFactor.EnableTOTP(username, req.Code)
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, user.ErrInvalidCode), errors.Is(err, auth.ErrChallengeFailed):
+		writeError(w, http.StatusBadRequest, user.ErrInvalidCode.Error())
+		return
+	case errors.Is(err, user.ErrTwoFactorEnabled), errors.Is(err, user.ErrTwoFactorNotStarted):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case errors.Is(err, auth.ErrChallenge):
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	default:
+		log.Printf("2fa enable: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to enable two-factor authentication")
+		return
+	}
+
+	log.Printf("2fa: enabled for %s", username)
+	if challenge != "" {
+		withJSON(w)
+		completeLogin(w, r, username, req.Tokens, map[string]any{"recovery_codes": codes})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"recovery_codes": codes})
+}
+
+// =========================
+// Отключение 2FA: {"code": "..."} — код из приложения или код восстановления
+// POST /api/2fa/disable
+// =========================
+func TwoFactorDisableHandler(w http.ResponseWriter, r *http.Request) {
+	username, ok := verifiedTwoFactorUser(w, r)
+	if !ok {
+		return
+	}
+	if required, err := twoFactorRequired(); err != nil || required {
+		writeError(w, http.StatusForbidden, "two-factor authentication is required by the administrator")
+		return
+	}
+	if err := TwoFactor.DisableTOTP(username); err != nil {
+		log.Printf("2fa disable: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to disable two-factor authentication")
+		return
+	}
+	log.Printf("2fa: disabled for %s", username)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "disabled"})
+}
+
+// =========================
+// Новые коды восстановления взамен старых: {"code": "..."}
+// POST /api/2fa/recovery-codes
+// =========================
+func TwoFactorRecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	username, ok := verifiedTwoFactorUser(w, r)
+	if !ok {
+		return
+	}
+	codes, err := TwoFactor.NewRecoveryCodes(username)
+	if err != nil {
+		log.Printf("2fa recovery codes: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to generate recovery codes")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"recovery_codes": codes})
+}
+
+// verifiedTwoFactorUser проверяет код второго фактора из тела {"code": "..."}:
+// отключать 2FA и менять коды восстановления по одной украденной cookie нельзя.
+// Неверные коды учитываются ограничителями входа (429 при переборе)
+func verifiedTwoFactorUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	username, _ := r.Context().Value(CtxUserKey).(string)
+	if TwoFactor == nil {
+		writeError(w, http.StatusNotFound, "two-factor authentication is disabled")
+		return "", false
+	}
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return "", false
+	}
+
+	status, err := TwoFactor.TwoFactor(username)
+	if err != nil {
+		log.Printf("2fa: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to get two-factor status")
+		return "", false
+	}
+	if !status.Enabled {
+		writeError(w, http.StatusConflict, user.ErrTwoFactorDisabled.Error())
+		return "", false
+	}
+	// Попытки считаются вместе с попытками входа: перебор кода по cookie так же медленный
+	if loginThrottled(w, r, username) {
+		return "", false
+	}
+	ok, err := TwoFactor.VerifyTwoFactor(username, req.Code)
+	if err != nil {
+		loginReleased(r, username)
+		log.Printf("2fa: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to verify code")
+		return "", false
+	}
+	if !ok {
+		loginFailed(r, username)
+		writeError(w, http.StatusBadRequest, user.ErrInvalidCode.Error())
+		return "", false
+	}
+	loginReleased(r, username)
+	loginSucceeded(username)
+	return username, true
+}
@@ -0,0 +1,180 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/auth"
+	"github.com/go-portfolio/websocket-chat/internal/chat"
+	"github.com/go-portfolio/websocket-chat/internal/notify"
+	"github.com/go-portfolio/websocket-chat/internal/web"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeConn — chat.WebSocketConn без сети, запоминает закрытие
+type fakeConn struct {
+	mu     sync.Mutex
+	closed bool
+}
+
+func (c *fakeConn) ReadJSON(v any) error                         { return nil }
+func (c *fakeConn) WriteJSON(v any) error                        { return nil }
+func (c *fakeConn) SetReadLimit(limit int64)                     {}
+func (c *fakeConn) SetReadDeadline(t time.Time) error            { return nil }
+func (c *fakeConn) SetWriteDeadline(t time.Time) error           { return nil }
+func (c *fakeConn) SetPongHandler(h func(string) error)          {}
+func (c *fakeConn) WriteMessage(messageType int, b []byte) error { return nil }
+
+func (c *fakeConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *fakeConn) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+// adminMux — маршруты admin API, как в app.New
+func adminMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/admin/rooms", web.AdminMiddleware(http.HandlerFunc(web.AdminRoomsHandler)))
+	mux.Handle("POST /api/admin/users/{username}/kick", web.AdminMiddleware(http.HandlerFunc(web.AdminKickHandler)))
+	mux.Handle("POST /api/admin/announcements", web.AdminMiddleware(http.HandlerFunc(web.AdminAnnounceHandler)))
+	return mux
+}
+
+func setupAdmin() (*mockUserStore, *chat.Hub) {
+	users := newMockUserStore()
+	_ = users.Register("root", "secret", "")
+	_ = users.Register("alice", "secret", "")
+	users.setFlags("root", true, false)
+	web.Users = users
+	web.AdminToken = "admin-secret"
+
+	hub := chat.NewHub()
+	web.ChatHub = hub
+	return users, hub
+}
+
+func adminRequest(method, target, body string, setup func(*http.Request)) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if setup != nil {
+		setup(req)
+	}
+	rr := httptest.NewRecorder()
+	adminMux().ServeHTTP(rr, req)
+	return rr
+}
+
+func bearer(token string) func(*http.Request) {
+	return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+}
+
+func cookieOf(username string) func(*http.Request) {
+	return func(r *http.Request) {
+		token, _ := auth.IssueJWT(username)
+		r.AddCookie(&http.Cookie{Name: web.CookieName, Value: token})
+	}
+}
+
+func TestAdminMiddleware_Access(t *testing.T) {
+	setupAdmin()
+	defer func() { web.AdminToken = "" }()
+
+	assert.Equal(t, http.StatusOK, adminRequest(http.MethodGet, "/api/admin/rooms", "", bearer("admin-secret")).Code)
+	assert.Equal(t, http.StatusOK, adminRequest(http.MethodGet, "/api/admin/rooms", "", cookieOf("root")).Code)
+	assert.Equal(t, http.StatusForbidden, adminRequest(http.MethodGet, "/api/admin/rooms", "", cookieOf("alice")).Code)
+	assert.Equal(t, http.StatusUnauthorized, adminRequest(http.MethodGet, "/api/admin/rooms", "", bearer("wrong")).Code)
+	assert.Equal(t, http.StatusUnauthorized, adminRequest(http.MethodGet, "/api/admin/rooms", "", nil).Code)
+
+	// Без настроенного токена пустой Bearer не пропускается
+	web.AdminToken = ""
+	assert.Equal(t, http.StatusUnauthorized, adminRequest(http.MethodGet, "/api/admin/rooms", "", bearer("")).Code)
+}
+
+func TestAdminRoomsAndKick(t *testing.T) {
+	_, hub := setupAdmin()
+	defer func() { web.AdminToken = "" }()
+
+	alice := chat.NewClient(hub, hub.GetRoom("tech"), &fakeConn{}, "alice")
+	hub.Clients[alice] = true
+	hub.Clients[chat.NewClient(hub, hub.GetRoom("tech"), &fakeConn{}, "bob")] = true
+
+	rr := adminRequest(http.MethodGet, "/api/admin/rooms", "", bearer("admin-secret"))
+	assert.JSONEq(t, `{"rooms":[{"room":"tech","online":2}]}`, rr.Body.String())
+
+	rr = adminRequest(http.MethodPost, "/api/admin/users/alice/kick", `{"reason":"spam"}`, bearer("admin-secret"))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"disconnected":1}`, rr.Body.String())
+
+	conn := &fakeConn{}
+	kicked := chat.NewClient(hub, hub.GetRoom("tech"), conn, "carol")
+	hub.Clients[kicked] = true
+	rr = adminRequest(http.MethodPost, "/api/admin/users/carol/kick", "", bearer("admin-secret"))
+	assert.JSONEq(t, `{"disconnected":1}`, rr.Body.String())
+
+	done := make(chan struct{})
+	go func() { kicked.WriteSocket(); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("клиент не отключён")
+	}
+	assert.True(t, conn.isClosed())
+}
+
+// Отключённый пользователь получает уведомление о модерации, если отключение не тихое
+func TestAdminKick_Notifies(t *testing.T) {
+	_, hub := setupAdmin()
+	store := newMockNotificationStore()
+	web.Inbox = &notify.Inbox{Store: store, Hub: hub}
+	defer func() { web.AdminToken, web.Inbox = "", nil }()
+
+	rr := adminRequest(http.MethodPost, "/api/admin/users/alice/kick", `{"reason":"spam"}`, cookieOf("root"))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	if assert.Len(t, store.items["alice"], 1) {
+		n := store.items["alice"][0]
+		assert.Equal(t, notify.KindModeration, n.Kind)
+		assert.Equal(t, "root", n.From)
+		assert.Contains(t, n.Text, "spam")
+	}
+
+	rr = adminRequest(http.MethodPost, "/api/admin/users/alice/kick", `{"reason":"account deleted","silent":true}`, bearer("admin-secret"))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Len(t, store.items["alice"], 1)
+}
+
+func TestAdminAnnounceHandler(t *testing.T) {
+	_, hub := setupAdmin()
+	defer func() { web.AdminToken = "" }()
+
+	rr := adminRequest(http.MethodPost, "/api/admin/announcements", `{"text":"  "}`, bearer("admin-secret"))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	hub.Clients[chat.NewClient(hub, hub.GetRoom("tech"), &fakeConn{}, "alice")] = true
+	rr = adminRequest(http.MethodPost, "/api/admin/announcements", `{"text":"перезапуск в 22:00"}`, bearer("admin-secret"))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var resp map[string]int
+	_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+	assert.Equal(t, 1, resp["rooms"])
+}
+
+// Заблокированный пользователь не может подключиться к чату даже с действующим токеном
+func TestChatConnectionHandler_Banned(t *testing.T) {
+	users, _ := setupAdmin()
+	users.setFlags("alice", false, true)
+
+	req := httptest.NewRequest(http.MethodGet, "/ws?room=tech", nil)
+	rr := httptest.NewRecorder()
+	web.ChatConnectionHandler(rr, withUser(req, "alice"))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+}
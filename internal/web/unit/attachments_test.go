@@ -0,0 +1,226 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/go-portfolio/websocket-chat/internal/attachment"
+	roompkg "github.com/go-portfolio/websocket-chat/internal/room"
+	"github.com/go-portfolio/websocket-chat/internal/storage"
+	"github.com/go-portfolio/websocket-chat/internal/web"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockRoomStore — in-memory room.RoomStore
+type mockRoomStore struct {
+	mu      sync.Mutex
+	members map[string]map[string]string // room -> username -> role
+}
+
+func newMockRoomStore() *mockRoomStore {
+	return &mockRoomStore{members: map[string]map[string]string{}}
+}
+
+func (m *mockRoomStore) Join(room, username string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.members[room] == nil {
+		m.members[room] = map[string]string{username: "owner"}
+	}
+	if m.members[room][username] == "" {
+		m.members[room][username] = "member"
+	}
+	return nil
+}
+
+func (m *mockRoomStore) IsMember(room, username string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.members[room][username] != "", nil
+}
+
+func (m *mockRoomStore) Role(room, username string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.members[room][username], nil
+}
+
+func (m *mockRoomStore) SetRole(room, username, role string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.members[room][username] == "" {
+		return roompkg.ErrNotMember
+	}
+	m.members[room][username] = role
+	return nil
+}
+
+func (m *mockRoomStore) Members(room string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var members []string
+	for username := range m.members[room] {
+		members = append(members, username)
+	}
+	sort.Strings(members)
+	return members, nil
+}
+
+// mockAttachmentStore — in-memory attachment.AttachmentStore
+type mockAttachmentStore struct {
+	mu    sync.Mutex
+	items map[string]*attachment.Attachment
+}
+
+func newMockAttachmentStore() *mockAttachmentStore {
+	return &mockAttachmentStore{items: map[string]*attachment.Attachment{}}
+}
+
+func (m *mockAttachmentStore) Create(a *attachment.Attachment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items[a.ID] = a
+	return nil
+}
+
+func (m *mockAttachmentStore) Get(id string) (*attachment.Attachment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.items[id]
+	if !ok {
+		return nil, attachment.ErrNotFound
+	}
+	cp := *a
+	return &cp, nil
+}
+
+func (m *mockAttachmentStore) Bind(id, room, peer string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a := m.items[id]
+	a.Room, a.Peer = room, peer
+	return nil
+}
+
+// withUser кладёт имя пользователя в контекст, как это делает AuthMiddleware
+func withUser(r *http.Request, username string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), web.CtxUserKey, username))
+}
+
+// attachmentMux — маршруты вложений без AuthMiddleware (пользователь задаётся через withUser)
+func attachmentMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/attachments", web.UploadAttachmentHandler)
+	mux.HandleFunc("GET /api/attachments/{id}", web.AttachmentHandler)
+	mux.HandleFunc("GET /api/attachments/{id}/thumbnail", web.AttachmentThumbnailHandler)
+	return mux
+}
+
+// uploadAttachment загружает файл от имени username и возвращает ответ
+func uploadAttachment(t *testing.T, username, name string, content []byte) (*httptest.ResponseRecorder, map[string]any) {
+	body, contentType := createMultipartForm(t, nil, "file", name, content)
+	req := httptest.NewRequest(http.MethodPost, "/api/attachments", body)
+	req.Header.Set("Content-Type", contentType)
+	rr := httptest.NewRecorder()
+	attachmentMux().ServeHTTP(rr, withUser(req, username))
+
+	var resp map[string]any
+	_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+	return rr, resp
+}
+
+// get выполняет GET от имени username
+func get(username, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	rr := httptest.NewRecorder()
+	attachmentMux().ServeHTTP(rr, withUser(req, username))
+	return rr
+}
+
+func setupAttachments() (*mockRoomStore, *mockAttachmentStore) {
+	rooms, attachments := newMockRoomStore(), newMockAttachmentStore()
+	web.Rooms = rooms
+	web.Attachments = attachments
+	web.Blobs = storage.NewMemoryStore()
+	return rooms, attachments
+}
+
+/* ==========================
+   ТЕСТЫ загрузки вложений
+   ========================== */
+
+// Изображение получает миниатюру, имя файла очищается от пути
+func TestUploadAttachment_ImageWithThumbnail(t *testing.T) {
+	setupAttachments()
+
+	rr, resp := uploadAttachment(t, "alice", "../photos/cat.png", testPNG(t, 600, 300))
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, "cat.png", resp["name"])
+	assert.Equal(t, "image/png", resp["mime_type"])
+	assert.NotEmpty(t, resp["id"])
+	assert.NotEmpty(t, resp["thumbnail_url"])
+
+	rr = get("alice", resp["thumbnail_url"].(string))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "image/png", rr.Header().Get("Content-Type"))
+}
+
+// Недопустимый тип файла отклоняется
+func TestUploadAttachment_TypeNotAllowed(t *testing.T) {
+	setupAttachments()
+
+	rr, _ := uploadAttachment(t, "alice", "evil.html", []byte("<html><script>alert(1)</script>"))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "file type not allowed")
+}
+
+/* ==========================
+   ТЕСТЫ доступа к вложениям
+   ========================== */
+
+// До отправки вложение видит только владелец
+func TestAttachment_OwnerOnlyBeforeSend(t *testing.T) {
+	setupAttachments()
+	_, resp := uploadAttachment(t, "alice", "notes.txt", []byte("hello world"))
+	url := resp["url"].(string)
+
+	rr := get("alice", url)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "hello world", rr.Body.String())
+	assert.Contains(t, rr.Header().Get("Content-Disposition"), `attachment; filename=notes.txt`)
+
+	assert.Equal(t, http.StatusNotFound, get("bob", url).Code)
+}
+
+// Вложение в комнате доступно её участникам и недоступно остальным
+func TestAttachment_RoomMembersOnly(t *testing.T) {
+	rooms, attachments := setupAttachments()
+	_, resp := uploadAttachment(t, "alice", "notes.txt", []byte("hello world"))
+	id, url := resp["id"].(string), resp["url"].(string)
+
+	_ = attachments.Bind(id, "tech", "")
+	_ = rooms.Join("tech", "bob")
+
+	assert.Equal(t, http.StatusOK, get("bob", url).Code, "участник комнаты")
+	assert.Equal(t, http.StatusNotFound, get("carol", url).Code, "посторонний")
+}
+
+// Вложение в личном сообщении доступно только отправителю и адресату
+func TestAttachment_DirectMessage(t *testing.T) {
+	rooms, attachments := setupAttachments()
+	_, resp := uploadAttachment(t, "alice", "notes.txt", []byte("hello world"))
+	id, url := resp["id"].(string), resp["url"].(string)
+
+	_ = attachments.Bind(id, "", "bob")
+	_ = rooms.Join("tech", "carol") // членство в комнатах не даёт доступа к личным вложениям
+
+	assert.Equal(t, http.StatusOK, get("bob", url).Code)
+	assert.Equal(t, http.StatusNotFound, get("carol", url).Code)
+}
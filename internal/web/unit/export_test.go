@@ -0,0 +1,108 @@
+package unit
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/message"
+	"github.com/go-portfolio/websocket-chat/internal/web"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupExport() {
+	rooms := newMockRoomStore()
+	_ = rooms.Join("tech", "alice") // владелец
+	_ = rooms.Join("tech", "bob")
+	web.Rooms = rooms
+	web.Users = newMockUserStore()
+
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	web.Messages = &mockMessageStore{saved: []*message.Message{
+		{ID: "m1", Room: "tech", From: "alice", Type: "message", Text: "старое", CreatedAt: day.Add(-time.Hour)},
+		{ID: "m2", Room: "tech", From: "bob", Type: "message", Text: "утро", CreatedAt: day.Add(9 * time.Hour)},
+		{ID: "m3", Room: "tech", From: "alice", Type: "message", Text: "вечер", CreatedAt: day.Add(23 * time.Hour)},
+		{ID: "m4", Room: "other", From: "carol", Type: "message", Text: "чужое", CreatedAt: day.Add(time.Hour)},
+	}}
+}
+
+func exportRoom(username, room, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/rooms/"+room+"/export"+query, nil)
+	req.SetPathValue("room", room)
+	rr := httptest.NewRecorder()
+	web.ExportRoomHandler(rr, withUser(req, username))
+	return rr
+}
+
+// Выгрузка за день: дата в to включается целиком
+func TestExportRoomHandler_CSV(t *testing.T) {
+	setupExport()
+
+	rr := exportRoom("alice", "tech", "?format=csv&from=2024-05-01&to=2024-05-01")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Header().Get("Content-Disposition"), `attachment; filename=tech-`)
+
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, "m2", records[1][0])
+	assert.Equal(t, "m3", records[2][0])
+}
+
+func TestExportRoomHandler_OwnersOnly(t *testing.T) {
+	setupExport()
+	assert.Equal(t, http.StatusForbidden, exportRoom("bob", "tech", "").Code)
+	assert.Equal(t, http.StatusForbidden, exportRoom("alice", "other", "").Code)
+	assert.Equal(t, http.StatusOK, exportRoom("alice", "tech", "").Code)
+}
+
+func TestExportRoomHandler_InvalidParams(t *testing.T) {
+	setupExport()
+	for _, query := range []string{"?format=xml", "?from=yesterday", "?to=2024-13-01"} {
+		assert.Equal(t, http.StatusBadRequest, exportRoom("alice", "tech", query).Code, query)
+	}
+}
+
+func TestExportRoomHandler_ServerAdmin(t *testing.T) {
+	setupExport()
+	users := web.Users.(*mockUserStore)
+	_ = users.Register("root", "secret", "")
+	users.setFlags("root", true, false)
+
+	assert.Equal(t, http.StatusOK, exportRoom("root", "tech", "").Code, "администратор сервера не обязан быть участником")
+	assert.Equal(t, http.StatusForbidden, exportRoom("bob", "tech", "").Code)
+}
+
+func setRole(username, room, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPut, "/api/rooms/"+room+"/members/"+target+"/role", strings.NewReader(body))
+	req.SetPathValue("room", room)
+	req.SetPathValue("username", target)
+	rr := httptest.NewRecorder()
+	web.RoomRoleHandler(rr, withUser(req, username))
+	return rr
+}
+
+// Владелец назначает администратора, и тот получает доступ к выгрузке
+func TestRoomRoleHandler(t *testing.T) {
+	setupExport()
+	_ = web.Rooms.Join("tech", "carol")
+
+	assert.Equal(t, http.StatusForbidden, setRole("bob", "tech", "carol", `{"role":"admin"}`).Code, "только владелец")
+	assert.Equal(t, http.StatusBadRequest, setRole("alice", "tech", "bob", `{"role":"owner"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, setRole("alice", "tech", "alice", `{"role":"member"}`).Code)
+	assert.Equal(t, http.StatusNotFound, setRole("alice", "tech", "dave", `{"role":"admin"}`).Code)
+
+	rr := setRole("alice", "tech", "bob", `{"role":"admin"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"room":"tech","username":"bob","role":"admin"}`, rr.Body.String())
+	assert.Equal(t, http.StatusOK, exportRoom("bob", "tech", "").Code)
+	assert.Equal(t, http.StatusForbidden, setRole("bob", "tech", "carol", `{"role":"admin"}`).Code, "администратор не назначает других")
+
+	require.Equal(t, http.StatusOK, setRole("alice", "tech", "bob", `{"role":"member"}`).Code)
+	assert.Equal(t, http.StatusForbidden, exportRoom("bob", "tech", "").Code)
+}
@@ -0,0 +1,79 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-portfolio/websocket-chat/internal/mention"
+	"github.com/go-portfolio/websocket-chat/internal/web"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockMentionStore — упоминания в памяти, ID растут в порядке добавления
+type mockMentionStore struct {
+	records []mention.Record
+}
+
+func (m *mockMentionStore) Add(records []mention.Record) error {
+	for _, r := range records {
+		r.ID = int64(len(m.records) + 1)
+		m.records = append(m.records, r)
+	}
+	return nil
+}
+
+func (m *mockMentionStore) List(username string, before int64, limit int) ([]mention.Record, error) {
+	out := []mention.Record{}
+	for i := len(m.records) - 1; i >= 0 && len(out) < limit; i-- {
+		r := m.records[i]
+		if r.Username == username && (before == 0 || r.ID < before) {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func getMentions(username, query string) (*httptest.ResponseRecorder, map[string]json.RawMessage) {
+	req := httptest.NewRequest(http.MethodGet, "/api/mentions"+query, nil)
+	rr := httptest.NewRecorder()
+	web.MentionsHandler(rr, withUser(req, username))
+
+	var resp map[string]json.RawMessage
+	_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+	return rr, resp
+}
+
+// Пользователь видит только свои упоминания, постранично от новых к старым
+func TestMentionsHandler_Pagination(t *testing.T) {
+	store := &mockMentionStore{}
+	_ = store.Add([]mention.Record{
+		{Username: "bob", MessageID: "m1", Room: "tech", From: "alice"},
+		{Username: "carol", MessageID: "m2", Room: "tech", From: "alice"},
+		{Username: "bob", MessageID: "m3", Room: "tech", From: "alice"},
+		{Username: "bob", MessageID: "m4", Room: "tech", From: "alice"},
+	})
+	web.Mentions = store
+
+	rr, resp := getMentions("bob", "?limit=2")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var page []mention.Record
+	_ = json.Unmarshal(resp["mentions"], &page)
+	assert.Equal(t, []string{"m4", "m3"}, []string{page[0].MessageID, page[1].MessageID})
+	assert.JSONEq(t, "3", string(resp["next_before"]))
+
+	_, resp = getMentions("bob", "?limit=2&before=3")
+	_ = json.Unmarshal(resp["mentions"], &page)
+	assert.Len(t, page, 1)
+	assert.Equal(t, "m1", page[0].MessageID)
+	assert.NotContains(t, resp, "next_before")
+}
+
+func TestMentionsHandler_InvalidParams(t *testing.T) {
+	web.Mentions = &mockMentionStore{}
+	rr, _ := getMentions("bob", "?limit=abc")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	rr, _ = getMentions("bob", "?before=-1")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
@@ -0,0 +1,133 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-portfolio/websocket-chat/internal/chat"
+	"github.com/go-portfolio/websocket-chat/internal/notify"
+	"github.com/go-portfolio/websocket-chat/internal/web"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockNotificationStore — уведомления в памяти
+type mockNotificationStore struct {
+	items map[string][]chat.Notification
+	next  int64
+}
+
+func newMockNotificationStore() *mockNotificationStore {
+	return &mockNotificationStore{items: map[string][]chat.Notification{}}
+}
+
+func (m *mockNotificationStore) Add(username string, n *chat.Notification) error {
+	m.next++
+	n.ID = m.next
+	m.items[username] = append(m.items[username], *n)
+	return nil
+}
+
+func (m *mockNotificationStore) List(username string, unreadOnly bool, before int64, limit int) ([]chat.Notification, error) {
+	out := []chat.Notification{}
+	items := m.items[username]
+	for i := len(items) - 1; i >= 0 && len(out) < limit; i-- {
+		n := items[i]
+		if (before == 0 || n.ID < before) && (!unreadOnly || !n.Read) {
+			out = append(out, n)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockNotificationStore) MarkRead(username string, id int64) error {
+	for i := range m.items[username] {
+		if m.items[username][i].ID == id {
+			m.items[username][i].Read = true
+			return nil
+		}
+	}
+	return notify.ErrNotFound
+}
+
+func (m *mockNotificationStore) MarkAllRead(username string) (int64, error) {
+	var n int64
+	for i := range m.items[username] {
+		if !m.items[username][i].Read {
+			m.items[username][i].Read = true
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *mockNotificationStore) UnreadCount(username string) (int, error) {
+	count := 0
+	for _, n := range m.items[username] {
+		if !n.Read {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// notificationsMux — маршруты уведомлений без AuthMiddleware
+func notificationsMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/notifications", web.NotificationsHandler)
+	mux.HandleFunc("POST /api/notifications/{id}/read", web.MarkNotificationReadHandler)
+	mux.HandleFunc("POST /api/notifications/read", web.MarkAllNotificationsReadHandler)
+	return mux
+}
+
+func doNotifications(method, username, path string) (*httptest.ResponseRecorder, map[string]json.RawMessage) {
+	req := httptest.NewRequest(method, path, nil)
+	rr := httptest.NewRecorder()
+	notificationsMux().ServeHTTP(rr, withUser(req, username))
+
+	var resp map[string]json.RawMessage
+	_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+	return rr, resp
+}
+
+func TestNotifications_ListAndMarkRead(t *testing.T) {
+	store := newMockNotificationStore()
+	_ = store.Add("bob", &chat.Notification{Kind: "mention", Text: "@bob"})
+	_ = store.Add("bob", &chat.Notification{Kind: "dm", Text: "привет"})
+	_ = store.Add("carol", &chat.Notification{Kind: "dm", Text: "не для bob"})
+	web.Notifications = store
+
+	rr, resp := doNotifications(http.MethodGet, "bob", "/api/notifications")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var list []chat.Notification
+	_ = json.Unmarshal(resp["notifications"], &list)
+	assert.Len(t, list, 2)
+	assert.JSONEq(t, "2", string(resp["unread"]))
+
+	// Отметить одно: непрочитанным остаётся второе
+	rr, resp = doNotifications(http.MethodPost, "bob", "/api/notifications/1/read")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, "1", string(resp["unread"]))
+
+	_, resp = doNotifications(http.MethodGet, "bob", "/api/notifications?unread=1")
+	_ = json.Unmarshal(resp["notifications"], &list)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, "dm", list[0].Kind)
+	}
+
+	// Чужое уведомление недоступно
+	rr, _ = doNotifications(http.MethodPost, "bob", "/api/notifications/3/read")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr, resp = doNotifications(http.MethodPost, "bob", "/api/notifications/read")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, "1", string(resp["updated"]))
+	assert.JSONEq(t, "0", string(resp["unread"]))
+}
+
+func TestNotifications_InvalidID(t *testing.T) {
+	web.Notifications = newMockNotificationStore()
+	rr, _ := doNotifications(http.MethodPost, "bob", "/api/notifications/abc/read")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
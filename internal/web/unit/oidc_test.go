@@ -0,0 +1,198 @@
+package unit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/go-portfolio/websocket-chat/internal/oidc"
+	"github.com/go-portfolio/websocket-chat/internal/oidc/oidctest"
+	"github.com/go-portfolio/websocket-chat/internal/user"
+	"github.com/go-portfolio/websocket-chat/internal/web"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockIdentityStore — in-memory привязка внешних аккаунтов; новые пользователи
+// создаются в mockUserStore под первым непустым именем
+type mockIdentityStore struct {
+	mu     sync.Mutex
+	users  *mockUserStore
+	linked map[string]string // issuer + " " + subject -> username
+}
+
+func (m *mockIdentityStore) ResolveIdentity(id user.Identity) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if username, ok := m.linked[id.Issuer+" "+id.Subject]; ok {
+		return username, false, nil
+	}
+	for _, name := range id.Names {
+		if name == "" {
+			continue
+		}
+		if err := m.users.Register(name, "!", ""); err != nil {
+			return "", false, err
+		}
+		m.linked[id.Issuer+" "+id.Subject] = name
+		return name, true, nil
+	}
+	return "", false, nil
+}
+
+func (m *mockIdentityStore) LinkIdentity(id user.Identity, username string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if linked, ok := m.linked[id.Issuer+" "+id.Subject]; ok && linked != username {
+		return user.ErrIdentityLinked
+	}
+	m.linked[id.Issuer+" "+id.Subject] = username
+	return nil
+}
+
+const oidcCallback = "http://chat.test/api/oidc/callback"
+
+// setupOIDC поднимает тестовый провайдер и включает вход через него
+func setupOIDC(t *testing.T) (*oidctest.Provider, *mockIdentityStore) {
+	setupSessions(t)
+	provider := oidctest.New("chat")
+	provider.User = oidctest.User{Subject: "u-1", Email: "carol@example.com", EmailVerified: true, PreferredUsername: "carol"}
+	t.Cleanup(provider.Close)
+
+	identities := &mockIdentityStore{users: web.Users.(*mockUserStore), linked: map[string]string{}}
+	web.OIDC = oidc.New(oidc.Config{Issuer: provider.Issuer(), ClientID: "chat", RedirectURL: oidcCallback})
+	web.Identities = identities
+	t.Cleanup(func() { web.OIDC, web.Identities = nil, nil })
+	return provider, identities
+}
+
+func oidcMux() *http.ServeMux {
+	mux := sessionMux()
+	mux.HandleFunc("GET /api/oidc", web.OIDCInfoHandler)
+	mux.HandleFunc("GET /api/oidc/login", web.OIDCLoginHandler)
+	mux.HandleFunc("GET /api/oidc/callback", web.OIDCCallbackHandler)
+	mux.Handle("GET /api/oidc/link", web.AuthMiddleware(http.HandlerFunc(web.OIDCLinkHandler)))
+	return mux
+}
+
+// oidcRoundTrip начинает вход по start, проходит страницу провайдера и возвращает
+// ответ callback; cookies передаются в оба запроса к чату
+func oidcRoundTrip(t *testing.T, start string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, start, nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	rr := httptest.NewRecorder()
+	oidcMux().ServeHTTP(rr, req)
+	require.Equal(t, http.StatusFound, rr.Code, rr.Body.String())
+	state := cookiesByName(rr)[web.CookieName+"_oidc"]
+	require.NotNil(t, state)
+	assert.True(t, state.HttpOnly)
+	assert.Equal(t, http.SameSiteLaxMode, state.SameSite)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(rr.Header().Get("Location"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+
+	req = httptest.NewRequest(http.MethodGet, callback.RequestURI(), nil)
+	for _, c := range append(cookies, state) {
+		req.AddCookie(c)
+	}
+	rr = httptest.NewRecorder()
+	oidcMux().ServeHTTP(rr, req)
+	return rr
+}
+
+func TestOIDCInfoHandler(t *testing.T) {
+	rr := httptest.NewRecorder()
+	web.OIDCInfoHandler(rr, httptest.NewRequest(http.MethodGet, "/api/oidc", nil))
+	assert.JSONEq(t, `{"enabled":false,"name":"OIDC"}`, rr.Body.String())
+
+	setupOIDC(t)
+	rr = httptest.NewRecorder()
+	web.OIDCInfoHandler(rr, httptest.NewRequest(http.MethodGet, "/api/oidc", nil))
+	assert.JSONEq(t, `{"enabled":true,"name":"OIDC"}`, rr.Body.String())
+}
+
+// Первый вход создаёт пользователя и выдаёт обычную сессию
+func TestOIDCLogin_ProvisionsUser(t *testing.T) {
+	_, identities := setupOIDC(t)
+
+	rr := oidcRoundTrip(t, "/api/oidc/login")
+	require.Equal(t, http.StatusFound, rr.Code)
+	assert.Equal(t, "/", rr.Header().Get("Location"))
+	cookies := cookiesByName(rr)
+	require.NotNil(t, cookies[web.CookieName])
+	require.NotNil(t, cookies[web.CookieName+"_refresh"])
+	assert.Equal(t, -1, cookies[web.CookieName+"_oidc"].MaxAge, "state cookie удаляется")
+	assert.Equal(t, "carol", identities.linked[web.OIDC.Config.Issuer+" u-1"])
+
+	whoami := send(http.MethodGet, "/api/whoami", cookies[web.CookieName])
+	assert.Equal(t, http.StatusOK, whoami.Code)
+
+	// Повторный вход попадает в того же пользователя
+	rr = oidcRoundTrip(t, "/api/oidc/login")
+	assert.Equal(t, "/", rr.Header().Get("Location"))
+	assert.Len(t, identities.linked, 1)
+}
+
+// Callback без cookie со state (вход начат в другом браузере) отвергается
+func TestOIDCCallback_StateMismatch(t *testing.T) {
+	setupOIDC(t)
+
+	rr := httptest.NewRecorder()
+	oidcMux().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/oidc/callback?state=abc&code=def", nil))
+	assert.Equal(t, http.StatusFound, rr.Code)
+	assert.Contains(t, rr.Header().Get("Location"), "login_error=")
+	assert.Nil(t, cookiesByName(rr)[web.CookieName])
+}
+
+func TestOIDCCallback_InvalidIDToken(t *testing.T) {
+	provider, _ := setupOIDC(t)
+	provider.TokenKeyID = "unknown"
+
+	rr := oidcRoundTrip(t, "/api/oidc/login")
+	assert.Contains(t, rr.Header().Get("Location"), "login_error=")
+	assert.Nil(t, cookiesByName(rr)[web.CookieName])
+}
+
+func TestOIDCCallback_BannedUser(t *testing.T) {
+	_, identities := setupOIDC(t)
+	require.NoError(t, identities.LinkIdentity(user.Identity{Issuer: web.OIDC.Config.Issuer, Subject: "u-1"}, "alice"))
+	web.Users.(*mockUserStore).setFlags("alice", false, true)
+
+	rr := oidcRoundTrip(t, "/api/oidc/login")
+	assert.Contains(t, rr.Header().Get("Location"), "login_error=user+is+banned")
+	assert.Nil(t, cookiesByName(rr)[web.CookieName])
+}
+
+// Вошедший пользователь привязывает внешний аккаунт и дальше входит через него
+func TestOIDCLink(t *testing.T) {
+	_, identities := setupOIDC(t)
+	access := login(t)[web.CookieName]
+
+	rr := oidcRoundTrip(t, "/api/oidc/link", access)
+	require.Equal(t, http.StatusFound, rr.Code)
+	assert.Equal(t, "/", rr.Header().Get("Location"))
+	assert.Equal(t, "alice", identities.linked[web.OIDC.Config.Issuer+" u-1"])
+
+	rr = oidcRoundTrip(t, "/api/oidc/login")
+	require.NotNil(t, cookiesByName(rr)[web.CookieName])
+	_, exists := identities.users.users["carol"]
+	assert.False(t, exists, "новый пользователь не создаётся")
+}
+
+func TestOIDCLink_RequiresAuth(t *testing.T) {
+	setupOIDC(t)
+	rr := httptest.NewRecorder()
+	oidcMux().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/oidc/link", nil))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
@@ -0,0 +1,306 @@
+package unit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/auth"
+	"github.com/go-portfolio/websocket-chat/internal/chat"
+	"github.com/go-portfolio/websocket-chat/internal/reset"
+	"github.com/go-portfolio/websocket-chat/internal/user"
+	"github.com/go-portfolio/websocket-chat/internal/web"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// mockPasswordStore меняет пароли в mockUserStore; токены сброса — по порядковому номеру
+type mockPasswordStore struct {
+	mu     sync.Mutex
+	users  *mockUserStore
+	seq    int
+	tokens map[string]string // токен -> username
+}
+
+func (m *mockPasswordStore) setPassword(username, password string) error {
+	if password == "" {
+		return user.ErrPasswordRequired
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		return err
+	}
+	m.users.mu.Lock()
+	defer m.users.mu.Unlock()
+	u, ok := m.users.users[username]
+	if !ok {
+		return user.ErrNotFound
+	}
+	u.hash = hash
+	m.users.users[username] = u
+	return nil
+}
+
+func (m *mockPasswordStore) ChangePassword(username, current, password string) error {
+	if !m.users.Authenticate(username, current) {
+		return user.ErrWrongPassword
+	}
+	return m.setPassword(username, password)
+}
+
+func (m *mockPasswordStore) CreatePasswordReset(login string, ttl time.Duration) (user.PasswordReset, error) {
+	email, _, err := m.users.GetEmail(login)
+	if verified, _ := m.EmailVerified(login); err != nil || !verified {
+		return user.PasswordReset{}, user.ErrNotFound
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	token := fmt.Sprintf("t%d", m.seq)
+	m.tokens[token] = login
+	return user.PasswordReset{Username: login, Email: email, Token: token, ExpiresAt: time.Now().Add(ttl)}, nil
+}
+
+func (m *mockPasswordStore) ResetPassword(token, password string) (string, error) {
+	if password == "" {
+		return "", user.ErrPasswordRequired
+	}
+	m.mu.Lock()
+	username, ok := m.tokens[token]
+	delete(m.tokens, token)
+	m.mu.Unlock()
+	if !ok || !strings.HasPrefix(token, "t") {
+		return "", user.ErrResetToken
+	}
+	return username, m.setPassword(username, password)
+}
+
+func (m *mockPasswordStore) CreateEmailVerification(username string, ttl time.Duration) (user.EmailVerification, error) {
+	email, _, err := m.users.GetEmail(username)
+	if err != nil || email == "" {
+		return user.EmailVerification{}, user.ErrNotFound
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	token := fmt.Sprintf("v%d", m.seq)
+	m.tokens[token] = username + "\n" + email
+	return user.EmailVerification{Username: username, Email: email, Token: token, ExpiresAt: time.Now().Add(ttl)}, nil
+}
+
+func (m *mockPasswordStore) VerifyEmail(token string) (string, error) {
+	m.mu.Lock()
+	entry, ok := m.tokens[token]
+	delete(m.tokens, token)
+	m.mu.Unlock()
+	username, email, _ := strings.Cut(entry, "\n")
+	if !ok || !strings.HasPrefix(token, "v") {
+		return "", user.ErrVerifyToken
+	}
+	m.users.mu.Lock()
+	defer m.users.mu.Unlock()
+	u, found := m.users.users[username]
+	if !found || u.email != email {
+		return "", user.ErrVerifyToken
+	}
+	u.verified = true
+	m.users.users[username] = u
+	return username, nil
+}
+
+func (m *mockPasswordStore) EmailVerified(username string) (bool, error) {
+	m.users.mu.Lock()
+	defer m.users.mu.Unlock()
+	u, ok := m.users.users[username]
+	if !ok {
+		return false, user.ErrNotFound
+	}
+	return u.email != "" && u.verified, nil
+}
+
+// setupPasswords подключает смену и сброс пароля; отправленные ссылки попадают в канал
+func setupPasswords(t *testing.T) (*chat.Hub, <-chan reset.Notice) {
+	_, hub := setupSessions(t)
+	users := web.Users.(*mockUserStore)
+	require.NoError(t, users.SetEmail("alice", "alice@example.com", false))
+	u := users.users["alice"]
+	u.verified = true
+	users.users["alice"] = u
+
+	sent := make(chan reset.Notice, 1)
+	web.Passwords = &mockPasswordStore{users: users, tokens: map[string]string{}}
+	web.ResetSender = reset.Func(func(n reset.Notice) error {
+		sent <- n
+		return nil
+	})
+	web.PublicURL = "https://chat.example.com/"
+	t.Cleanup(func() {
+		web.Passwords, web.ResetSender, web.PublicURL = nil, nil, "http://localhost:8080"
+	})
+	return hub, sent
+}
+
+func passwordRequest(handler http.Handler, target, body string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestChangePasswordHandler(t *testing.T) {
+	hub, _ := setupPasswords(t)
+	laptop := login(t)
+	phone := login(t)
+	phoneWS := connect(hub, "alice", "s2")
+	change := web.AuthMiddleware(http.HandlerFunc(web.ChangePasswordHandler))
+
+	rr := passwordRequest(change, "/api/password", `{"current_password":"wrong","new_password":"next"}`, laptop[web.CookieName])
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = passwordRequest(change, "/api/password", `{"current_password":"secret","new_password":""}`, laptop[web.CookieName])
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = passwordRequest(change, "/api/password", `{"current_password":"secret","new_password":"next"}`, laptop[web.CookieName])
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"status":"ok","revoked":1}`, rr.Body.String())
+	assertKicked(t, phoneWS)
+
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "/api/sessions", laptop[web.CookieName]).Code, "текущая сессия остаётся")
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodGet, "/api/sessions", phone[web.CookieName]).Code)
+	assert.True(t, web.Users.Authenticate("alice", "next"))
+	assert.False(t, web.Users.Authenticate("alice", "secret"))
+}
+
+func TestPasswordReset(t *testing.T) {
+	_, sent := setupPasswords(t)
+	laptop := login(t)
+	forgot := http.HandlerFunc(web.ForgotPasswordHandler)
+
+	rr := passwordRequest(forgot, "/api/password/forgot", `{"login":"alice"}`)
+	require.Equal(t, http.StatusAccepted, rr.Code)
+	var notice reset.Notice
+	select {
+	case notice = <-sent:
+	case <-time.After(time.Second):
+		t.Fatal("ссылка сброса не отправлена")
+	}
+	assert.Equal(t, "alice@example.com", notice.Email)
+	require.True(t, strings.HasPrefix(notice.Link, "https://chat.example.com/#reset="), notice.Link)
+	token := strings.TrimPrefix(notice.Link, "https://chat.example.com/#reset=")
+
+	resetHandler := http.HandlerFunc(web.ResetPasswordHandler)
+	rr = passwordRequest(resetHandler, "/api/password/reset", `{"token":"bogus","password":"next"}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = passwordRequest(resetHandler, "/api/password/reset", `{"token":"`+token+`","password":"next"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp map[string]string
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, "alice", resp["username"])
+	assert.True(t, web.Users.Authenticate("alice", "next"))
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodGet, "/api/sessions", laptop[web.CookieName]).Code, "все сессии отозваны")
+
+	rr = passwordRequest(resetHandler, "/api/password/reset", `{"token":"`+token+`","password":"again"}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "токен одноразовый")
+}
+
+func TestForgotPasswordHandler_UnknownUser(t *testing.T) {
+	_, sent := setupPasswords(t)
+	_ = web.Users.Register("bob", "secret", "") // без e-mail
+
+	for _, login := range []string{"nobody", "bob"} {
+		rr := passwordRequest(http.HandlerFunc(web.ForgotPasswordHandler), "/api/password/forgot", `{"login":"`+login+`"}`)
+		assert.Equal(t, http.StatusAccepted, rr.Code, "ответ не выдаёт, есть ли пользователь")
+		assert.JSONEq(t, `{"status":"sent"}`, rr.Body.String())
+	}
+	select {
+	case n := <-sent:
+		t.Fatalf("неожиданная ссылка для %s", n.Username)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	web.ResetSender = nil
+	rr := passwordRequest(http.HandlerFunc(web.ForgotPasswordHandler), "/api/password/forgot", `{"login":"alice"}`)
+	assert.Equal(t, http.StatusNotFound, rr.Code, "без отправщика сброс отключён")
+}
+
+// Украденной сессией нельзя сменить e-mail и получить на него ссылку сброса пароля
+func TestEmailChange_AccountTakeover(t *testing.T) {
+	_, sent := setupPasswords(t)
+	stolen := login(t)
+	settings := web.AuthMiddleware(http.HandlerFunc(web.EmailSettingsHandler))
+	put := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/profile/email", strings.NewReader(body))
+		req.AddCookie(stolen[web.CookieName])
+		rr := httptest.NewRecorder()
+		settings.ServeHTTP(rr, req)
+		return rr
+	}
+	receive := func() reset.Notice {
+		select {
+		case n := <-sent:
+			return n
+		case <-time.After(time.Second):
+			t.Fatal("письмо не отправлено")
+			return reset.Notice{}
+		}
+	}
+
+	assert.Equal(t, http.StatusForbidden, put(`{"email":"mallory@example.com"}`).Code)
+	assert.Equal(t, http.StatusForbidden, put(`{"email":"mallory@example.com","current_password":"wrong"}`).Code)
+	email, _, _ := web.Users.GetEmail("alice")
+	assert.Equal(t, "alice@example.com", email, "адрес не сменился")
+
+	// Сменённый с паролем адрес не получает ссылок сброса, пока не подтверждён
+	rr := put(`{"email":"alice@new.example.com","current_password":"secret"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"email_verified":false`)
+	verification := receive()
+	assert.Equal(t, reset.KindVerifyEmail, verification.Kind)
+	assert.Equal(t, "alice@new.example.com", verification.Email)
+
+	forgot := http.HandlerFunc(web.ForgotPasswordHandler)
+	require.Equal(t, http.StatusAccepted, passwordRequest(forgot, "/api/password/forgot", `{"login":"alice"}`).Code)
+	select {
+	case n := <-sent:
+		t.Fatalf("ссылка сброса ушла на неподтверждённый адрес %s", n.Email)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	token := strings.TrimPrefix(verification.Link, "https://chat.example.com/#verify=")
+	verify := http.HandlerFunc(web.VerifyEmailHandler)
+	assert.Equal(t, http.StatusBadRequest, passwordRequest(verify, "/api/email/verify", `{"token":"bogus"}`).Code)
+	require.Equal(t, http.StatusOK, passwordRequest(verify, "/api/email/verify", `{"token":"`+token+`"}`).Code)
+
+	require.Equal(t, http.StatusAccepted, passwordRequest(forgot, "/api/password/forgot", `{"login":"alice"}`).Code)
+	n := receive()
+	assert.Equal(t, reset.KindReset, n.Kind)
+	assert.Equal(t, "alice@new.example.com", n.Email)
+}
+
+func TestResetPasswordHandler_Throttled(t *testing.T) {
+	setupPasswords(t)
+	web.SignupLimiter = auth.NewLimiter(2, time.Hour, time.Hour, time.Hour)
+	resetHandler := http.HandlerFunc(web.ResetPasswordHandler)
+
+	// Ошибки в новом пароле не считаются, перебор токенов — считается
+	for i := 0; i < 3; i++ {
+		rr := passwordRequest(resetHandler, "/api/password/reset", `{"token":"t1","password":""}`)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	}
+	for i := 0; i < 3; i++ {
+		rr := passwordRequest(resetHandler, "/api/password/reset", `{"token":"bogus","password":"next"}`)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	}
+	rr := passwordRequest(resetHandler, "/api/password/reset", `{"token":"bogus","password":"next"}`)
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+}
@@ -0,0 +1,98 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/message"
+	"github.com/go-portfolio/websocket-chat/internal/web"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockMessageStore запоминает последний запрос и отдаёт заранее заданные результаты
+type mockMessageStore struct {
+	saved   []*message.Message
+	query   message.SearchQuery
+	results []message.SearchResult
+}
+
+func (m *mockMessageStore) Save(msg *message.Message) error {
+	m.saved = append(m.saved, msg)
+	return nil
+}
+
+func (m *mockMessageStore) Search(q message.SearchQuery) ([]message.SearchResult, error) {
+	m.query = q
+	end := min(q.Offset+q.Limit, len(m.results))
+	if q.Offset >= end {
+		return []message.SearchResult{}, nil
+	}
+	return m.results[q.Offset:end], nil
+}
+
+func (m *mockMessageStore) Export(room string, from, to time.Time, fn func(message.ExportItem) error) error {
+	for _, msg := range m.saved {
+		if msg.Room != room || (!from.IsZero() && msg.CreatedAt.Before(from)) || (!to.IsZero() && !msg.CreatedAt.Before(to)) {
+			continue
+		}
+		err := fn(message.ExportItem{ID: msg.ID, From: msg.From, Type: msg.Type, Text: msg.Text, CreatedAt: msg.CreatedAt})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func search(username, query string) (*httptest.ResponseRecorder, map[string]json.RawMessage) {
+	req := httptest.NewRequest(http.MethodGet, "/api/search"+query, nil)
+	rr := httptest.NewRecorder()
+	web.SearchHandler(rr, withUser(req, username))
+
+	var resp map[string]json.RawMessage
+	_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+	return rr, resp
+}
+
+func TestSearchHandler_Pagination(t *testing.T) {
+	rooms := newMockRoomStore()
+	_ = rooms.Join("tech", "bob")
+	store := &mockMessageStore{results: []message.SearchResult{
+		{Message: message.Message{ID: "m3"}}, {Message: message.Message{ID: "m2"}}, {Message: message.Message{ID: "m1"}},
+	}}
+	web.Rooms, web.Messages = rooms, store
+
+	rr, resp := search("bob", "?q=go+chat&room=tech&author=alice&before=2024-05-01&limit=2")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, message.SearchQuery{
+		Text: "go chat", User: "bob", Room: "tech", Author: "alice",
+		Before: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), Limit: 2,
+	}, store.query)
+	var page []message.SearchResult
+	_ = json.Unmarshal(resp["results"], &page)
+	assert.Len(t, page, 2)
+	assert.JSONEq(t, "2", string(resp["next_offset"]))
+
+	_, resp = search("bob", "?q=go&limit=2&offset=2")
+	_ = json.Unmarshal(resp["results"], &page)
+	assert.Len(t, page, 1)
+	assert.Equal(t, "m1", page[0].ID)
+	assert.NotContains(t, resp, "next_offset")
+}
+
+// Искать в чужой комнате нельзя
+func TestSearchHandler_NotMember(t *testing.T) {
+	web.Rooms, web.Messages = newMockRoomStore(), &mockMessageStore{}
+	rr, _ := search("bob", "?q=secret&room=private")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+}
+
+func TestSearchHandler_InvalidParams(t *testing.T) {
+	web.Rooms, web.Messages = newMockRoomStore(), &mockMessageStore{}
+	for _, query := range []string{"", "?q=+", "?q=go&limit=0", "?q=go&offset=-1", "?q=go&before=yesterday"} {
+		rr, _ := search("bob", query)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
@@ -0,0 +1,400 @@
+package unit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/auth"
+	"github.com/go-portfolio/websocket-chat/internal/chat"
+	"github.com/go-portfolio/websocket-chat/internal/session"
+	"github.com/go-portfolio/websocket-chat/internal/web"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockSessionStore — сессии в памяти с той же семантикой ротации, что у session.Store
+type mockSessionStore struct {
+	mu       sync.Mutex
+	seq      int
+	sessions map[string]*mockSession
+}
+
+type mockSession struct {
+	session.Session
+	refresh string
+	prev    string
+	revoked bool
+}
+
+func newMockSessionStore() *mockSessionStore {
+	return &mockSessionStore{sessions: make(map[string]*mockSession)}
+}
+
+func (m *mockSessionStore) Create(username, userAgent, ip string, ttl time.Duration) (session.Session, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	now := time.Now()
+	s := &mockSession{
+		Session: session.Session{
+			ID: fmt.Sprintf("s%d", m.seq), Username: username, UserAgent: userAgent, IP: ip,
+			CreatedAt: now, LastUsedAt: now, ExpiresAt: now.Add(ttl),
+		},
+		refresh: fmt.Sprintf("r%d-0", m.seq),
+	}
+	m.sessions[s.ID] = s
+	return s.Session, s.refresh, nil
+}
+
+func (m *mockSessionStore) find(token string) *mockSession {
+	for _, s := range m.sessions {
+		if s.refresh == token || s.prev == token {
+			return s
+		}
+	}
+	return nil
+}
+
+func (m *mockSessionStore) Rotate(token string, ttl time.Duration) (session.Session, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.find(token)
+	if s == nil || s.revoked {
+		return session.Session{}, "", session.ErrInvalid
+	}
+	if s.refresh != token {
+		s.revoked = true
+		return s.Session, "", session.ErrReused
+	}
+	s.prev, s.refresh = s.refresh, s.refresh+"+"
+	s.ExpiresAt = time.Now().Add(ttl)
+	return s.Session, s.refresh, nil
+}
+
+func (m *mockSessionStore) Active(id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	return ok && !s.revoked, nil
+}
+
+func (m *mockSessionStore) Revoke(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.sessions[id]; ok {
+		s.revoked = true
+	}
+	return nil
+}
+
+func (m *mockSessionStore) RevokeToken(token string) (session.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.find(token)
+	if s == nil {
+		return session.Session{}, session.ErrInvalid
+	}
+	s.revoked = true
+	return s.Session, nil
+}
+
+func (m *mockSessionStore) List(username string) ([]session.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := []session.Session{}
+	for _, s := range m.sessions {
+		if s.Username == username && !s.revoked {
+			list = append(list, s.Session)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+func (m *mockSessionStore) RevokeOwn(username, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	if !ok || s.Username != username || s.revoked {
+		return session.ErrNotFound
+	}
+	s.revoked = true
+	return nil
+}
+
+func (m *mockSessionStore) RevokeAll(username, except string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ids := []string{}
+	for id, s := range m.sessions {
+		if s.Username == username && id != except && !s.revoked {
+			s.revoked = true
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// setupSessions подключает хранилище сессий и возвращает его; после теста web.Sessions сбрасывается
+func setupSessions(t *testing.T) (*mockSessionStore, *chat.Hub) {
+	freshLimiters(t)
+	sessions := newMockSessionStore()
+	web.Sessions = sessions
+	t.Cleanup(func() { web.Sessions = nil })
+
+	web.Users = newMockUserStore()
+	_ = web.Users.Register("alice", "secret", "")
+	hub := chat.NewHub()
+	web.ChatHub = hub
+	return sessions, hub
+}
+
+// login выполняет вход и возвращает выставленные cookie по имени
+func login(t *testing.T) map[string]*http.Cookie {
+	req := httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(`{"username":"alice","password":"secret"}`))
+	req.Header.Set("User-Agent", "test-agent")
+	rr := httptest.NewRecorder()
+	web.LoginHandler(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	return cookiesByName(rr)
+}
+
+func cookiesByName(rr *httptest.ResponseRecorder) map[string]*http.Cookie {
+	cookies := map[string]*http.Cookie{}
+	for _, c := range rr.Result().Cookies() {
+		cookies[c.Name] = c
+	}
+	return cookies
+}
+
+// sessionMux — маршруты сессий, как в app.New, плюс защищённый эндпоинт
+func sessionMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/refresh", web.RefreshHandler)
+	mux.HandleFunc("POST /api/logout", web.LogoutHandler)
+	mux.Handle("GET /api/sessions", web.AuthMiddleware(http.HandlerFunc(web.SessionsHandler)))
+	mux.Handle("DELETE /api/sessions", web.AuthMiddleware(http.HandlerFunc(web.RevokeOtherSessionsHandler)))
+	mux.Handle("DELETE /api/sessions/{id}", web.AuthMiddleware(http.HandlerFunc(web.RevokeSessionHandler)))
+	mux.Handle("GET /api/whoami", web.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sid, _ := r.Context().Value(web.CtxSessionKey).(string)
+		_, _ = w.Write([]byte(sid))
+	})))
+	return mux
+}
+
+func send(method, target string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for _, c := range cookies {
+		if c != nil {
+			req.AddCookie(c)
+		}
+	}
+	rr := httptest.NewRecorder()
+	sessionMux().ServeHTTP(rr, req)
+	return rr
+}
+
+func TestLogin_IssuesSessionCookies(t *testing.T) {
+	sessions, _ := setupSessions(t)
+
+	cookies := login(t)
+	access, refresh := cookies[web.CookieName], cookies[web.CookieName+"_refresh"]
+	require.NotNil(t, access)
+	require.NotNil(t, refresh)
+	assert.True(t, refresh.HttpOnly)
+	assert.Equal(t, "/api/", refresh.Path)
+	assert.Equal(t, "test-agent", sessions.sessions["s1"].UserAgent)
+
+	rr := send(http.MethodGet, "/api/whoami", access)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "s1", rr.Body.String())
+}
+
+// Токен без сессии или с отозванной сессией не принимается
+func TestAuthMiddleware_RevokedSession(t *testing.T) {
+	sessions, _ := setupSessions(t)
+	access := login(t)[web.CookieName]
+
+	require.NoError(t, sessions.Revoke("s1"))
+	rr := send(http.MethodGet, "/api/whoami", access)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Contains(t, rr.Body.String(), "session revoked")
+
+	rr = send(http.MethodGet, "/api/whoami", cookieFor("alice"))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "токен без sid")
+}
+
+func TestRefreshHandler_Rotates(t *testing.T) {
+	setupSessions(t)
+	old := login(t)[web.CookieName+"_refresh"]
+
+	rr := send(http.MethodPost, "/api/refresh", old)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	fresh := cookiesByName(rr)
+	require.NotNil(t, fresh[web.CookieName])
+	assert.NotEqual(t, old.Value, fresh[web.CookieName+"_refresh"].Value)
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "/api/whoami", fresh[web.CookieName]).Code)
+
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodPost, "/api/refresh").Code)
+}
+
+// Повторное использование старого refresh-токена отзывает сессию и отключает её сокеты
+func TestRefreshHandler_ReuseRevokes(t *testing.T) {
+	_, hub := setupSessions(t)
+	cookies := login(t)
+	old := cookies[web.CookieName+"_refresh"]
+
+	client := chat.NewClient(hub, hub.GetRoom("tech"), &fakeConn{}, "alice")
+	client.SessionID = "s1"
+	hub.Clients[client] = true
+
+	fresh := cookiesByName(send(http.MethodPost, "/api/refresh", old))
+	rr := send(http.MethodPost, "/api/refresh", old)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assertKicked(t, client)
+
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodPost, "/api/refresh", fresh[web.CookieName+"_refresh"]).Code)
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodGet, "/api/whoami", fresh[web.CookieName]).Code)
+}
+
+func TestLogoutHandler(t *testing.T) {
+	_, hub := setupSessions(t)
+	cookies := login(t)
+
+	client := chat.NewClient(hub, hub.GetRoom("tech"), &fakeConn{}, "alice")
+	client.SessionID = "s1"
+	hub.Clients[client] = true
+
+	rr := send(http.MethodPost, "/api/logout", cookies[web.CookieName], cookies[web.CookieName+"_refresh"])
+	assert.Equal(t, http.StatusOK, rr.Code)
+	for _, c := range rr.Result().Cookies() {
+		assert.Equal(t, -1, c.MaxAge, "cookie %s должна удаляться", c.Name)
+	}
+	assertKicked(t, client)
+
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodGet, "/api/whoami", cookies[web.CookieName]).Code)
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodPost, "/api/refresh", cookies[web.CookieName+"_refresh"]).Code)
+
+	// Выход без cookie ничего не ломает
+	assert.Equal(t, http.StatusOK, send(http.MethodPost, "/api/logout").Code)
+}
+
+// cookieFor — access-токен без привязки к сессии
+func cookieFor(username string) *http.Cookie {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	cookieOf(username)(req)
+	c, _ := req.Cookie(web.CookieName)
+	return c
+}
+
+// assertKicked проверяет, что клиент получил команду отключения
+func assertKicked(t *testing.T, client *chat.Client) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() { client.WriteSocket(); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("клиент не отключён")
+	}
+}
+
+// connect имитирует WebSocket-подключение в рамках сессии
+func connect(hub *chat.Hub, username, sessionID string) *chat.Client {
+	client := chat.NewClient(hub, hub.GetRoom("tech"), &fakeConn{}, username)
+	client.SessionID = sessionID
+	hub.Clients[client] = true
+	return client
+}
+
+func TestSessionsHandler(t *testing.T) {
+	_, hub := setupSessions(t)
+	laptop := login(t)
+	login(t)
+	connect(hub, "alice", "s2")
+	connect(hub, "alice", "s2")
+
+	rr := send(http.MethodGet, "/api/sessions", laptop[web.CookieName])
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var resp struct {
+		Sessions []struct {
+			ID          string `json:"id"`
+			UserAgent   string `json:"user_agent"`
+			Current     bool   `json:"current"`
+			Connections int    `json:"connections"`
+		} `json:"sessions"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.Len(t, resp.Sessions, 2)
+	assert.Equal(t, "s1", resp.Sessions[0].ID)
+	assert.True(t, resp.Sessions[0].Current)
+	assert.Equal(t, "test-agent", resp.Sessions[0].UserAgent)
+	assert.False(t, resp.Sessions[1].Current)
+	assert.Equal(t, 2, resp.Sessions[1].Connections)
+}
+
+func TestRevokeSessionHandler(t *testing.T) {
+	sessions, hub := setupSessions(t)
+	laptop := login(t)
+	phone := login(t)
+	client := connect(hub, "alice", "s2")
+
+	// Чужая или неизвестная сессия — 404
+	_ = web.Users.Register("bob", "secret", "")
+	bobSess, _, _ := sessions.Create("bob", "", "", time.Hour)
+	assert.Equal(t, http.StatusNotFound, send(http.MethodDelete, "/api/sessions/"+bobSess.ID, laptop[web.CookieName]).Code)
+
+	rr := send(http.MethodDelete, "/api/sessions/s2", laptop[web.CookieName])
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assertKicked(t, client)
+	assert.Empty(t, rr.Result().Cookies(), "текущая сессия не тронута")
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodGet, "/api/sessions", phone[web.CookieName]).Code)
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "/api/sessions", laptop[web.CookieName]).Code)
+
+	// Отзыв текущей сессии удаляет её cookie
+	rr = send(http.MethodDelete, "/api/sessions/s1", laptop[web.CookieName])
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotEmpty(t, rr.Result().Cookies())
+}
+
+func TestRevokeOtherSessionsHandler(t *testing.T) {
+	_, hub := setupSessions(t)
+	laptop := login(t)
+	phone := login(t)
+	tablet := login(t)
+	phoneWS := connect(hub, "alice", "s2")
+	tabletWS := connect(hub, "alice", "s3")
+
+	rr := send(http.MethodDelete, "/api/sessions", laptop[web.CookieName])
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"revoked":2}`, rr.Body.String())
+	assertKicked(t, phoneWS)
+	assertKicked(t, tabletWS)
+
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "/api/sessions", laptop[web.CookieName]).Code)
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodGet, "/api/sessions", phone[web.CookieName]).Code)
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodPost, "/api/refresh", tablet[web.CookieName+"_refresh"]).Code)
+}
+
+func TestJWKSHandler(t *testing.T) {
+	rr := httptest.NewRecorder()
+	web.JWKSHandler(rr, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Header().Get("Cache-Control"), "max-age")
+	var resp struct {
+		Keys []auth.JWK `json:"keys"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.Len(t, resp.Keys, 1)
+	assert.Equal(t, auth.Keys.Current().ID, resp.Keys[0].Kid)
+}
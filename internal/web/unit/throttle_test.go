@@ -0,0 +1,172 @@
+package unit
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/auth"
+	"github.com/go-portfolio/websocket-chat/internal/web"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// freshLimiters ставит новые счётчики попыток: все тестовые запросы приходят
+// с одного адреса и иначе влияли бы друг на друга
+func freshLimiters(t *testing.T) {
+	ip, user, lock, signup := web.LoginIPLimiter, web.LoginUserLimiter, web.Lockouts, web.SignupLimiter
+	web.LoginIPLimiter = auth.NewLimiter(ip.Free, ip.Base, ip.Max, ip.Window)
+	web.LoginUserLimiter = auth.NewLimiter(user.Free, user.Base, user.Max, user.Window)
+	web.Lockouts = auth.NewLimiter(lock.Free, lock.Base, lock.Max, lock.Window)
+	web.SignupLimiter = auth.NewLimiter(signup.Free, signup.Base, signup.Max, signup.Window)
+	t.Cleanup(func() {
+		web.LoginIPLimiter, web.LoginUserLimiter, web.Lockouts, web.SignupLimiter = ip, user, lock, signup
+	})
+}
+
+// loginFrom выполняет вход с адреса remoteAddr
+func loginFrom(remoteAddr, username, password string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/login",
+		strings.NewReader(`{"username":"`+username+`","password":"`+password+`"}`))
+	req.RemoteAddr = remoteAddr
+	rr := httptest.NewRecorder()
+	web.LoginHandler(rr, req)
+	return rr
+}
+
+func TestLogin_UserBackoff(t *testing.T) {
+	setupSessions(t)
+	web.LoginUserLimiter = auth.NewLimiter(2, time.Hour, 4*time.Hour, time.Hour)
+
+	// Попытки с разных адресов: пауза привязана к аккаунту
+	for _, addr := range []string{"198.51.100.1:1", "198.51.100.2:1", "198.51.100.3:1"} {
+		assert.Equal(t, http.StatusUnauthorized, loginFrom(addr, "alice", "wrong").Code)
+	}
+	rr := loginFrom("198.51.100.4:1", "alice", "secret")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code, "во время паузы не принимается и верный пароль")
+	assert.Equal(t, "3600", rr.Header().Get("Retry-After"))
+
+	// Для несуществующего пользователя ответы те же
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, loginFrom("198.51.100.5:1", "ghost", "wrong").Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, loginFrom("198.51.100.5:1", "ghost", "wrong").Code)
+
+	_ = web.Users.Register("bob", "secret", "")
+	assert.Equal(t, http.StatusOK, loginFrom("198.51.100.6:1", "bob", "secret").Code, "другие аккаунты не затронуты")
+}
+
+func TestLogin_Lockout(t *testing.T) {
+	setupSessions(t)
+	web.Lockouts = auth.NewLimiter(2, time.Hour, time.Hour, time.Hour)
+
+	// Успешный вход обнуляет счётчик неудач
+	loginFrom("198.51.100.1:1", "alice", "wrong")
+	loginFrom("198.51.100.1:1", "alice", "wrong")
+	require.Equal(t, http.StatusOK, loginFrom("198.51.100.1:1", "alice", "secret").Code)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, loginFrom("198.51.100.2:1", "alice", "wrong").Code)
+	}
+	rr := loginFrom("198.51.100.3:1", "alice", "secret")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code, "аккаунт временно заблокирован")
+	assert.Equal(t, "3600", rr.Header().Get("Retry-After"))
+}
+
+func TestLogin_IPLimiter(t *testing.T) {
+	setupSessions(t)
+	web.LoginIPLimiter = auth.NewLimiter(2, time.Hour, time.Hour, time.Hour)
+
+	// Перебор по разным аккаунтам с одного адреса
+	for _, name := range []string{"u1", "u2", "u3"} {
+		assert.Equal(t, http.StatusUnauthorized, loginFrom("203.0.113.9:1", name, "wrong").Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, loginFrom("203.0.113.9:1", "alice", "secret").Code)
+	assert.Equal(t, http.StatusOK, loginFrom("203.0.113.10:1", "alice", "secret").Code)
+}
+
+func TestLogin_ConcurrentGuesses(t *testing.T) {
+	setupSessions(t)
+	web.LoginUserLimiter = auth.NewLimiter(3, time.Hour, time.Hour, time.Hour)
+
+	// Попытка учитывается до проверки пароля, поэтому одновременные запросы
+	// не проходят все разом, пока bcrypt проверяет первые из них
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	codes := map[int]int{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			code := loginFrom("198.51.100.1:1", "alice", "wrong").Code
+			mu.Lock()
+			codes[code]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 4, codes[http.StatusUnauthorized], "Free попыток и ещё одна, назначившая паузу")
+	assert.Equal(t, 16, codes[http.StatusTooManyRequests])
+}
+
+func TestLogin_CorrectPasswordNotCounted(t *testing.T) {
+	setupSessions(t)
+	web.LoginUserLimiter = auth.NewLimiter(1, time.Hour, time.Hour, time.Hour)
+	web.LoginIPLimiter = auth.NewLimiter(1, time.Hour, time.Hour, time.Hour)
+
+	for i := 0; i < 3; i++ {
+		require.Equal(t, http.StatusOK, loginFrom("198.51.100.1:1", "alice", "secret").Code)
+	}
+	assert.Equal(t, http.StatusUnauthorized, loginFrom("198.51.100.1:1", "alice", "wrong").Code)
+}
+
+func TestLogin_IPLimiterBehindProxy(t *testing.T) {
+	setupSessions(t)
+	web.LoginIPLimiter = auth.NewLimiter(1, time.Hour, time.Hour, time.Hour)
+	web.TrustedProxies = []*net.IPNet{{IP: net.IPv4(10, 0, 0, 0), Mask: net.CIDRMask(8, 32)}}
+	t.Cleanup(func() { web.TrustedProxies = nil })
+
+	loginVia := func(remoteAddr, forwarded, username string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/login",
+			strings.NewReader(`{"username":"`+username+`","password":"wrong"}`))
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", forwarded)
+		rr := httptest.NewRecorder()
+		web.LoginHandler(rr, req)
+		return rr.Code
+	}
+
+	// Клиенты за прокси считаются по X-Forwarded-For; подделанный клиентом адрес слева не учитывается
+	assert.Equal(t, http.StatusUnauthorized, loginVia("10.0.0.1:1", "203.0.113.7, 198.51.100.1", "u1"))
+	assert.Equal(t, http.StatusUnauthorized, loginVia("10.0.0.2:1", "203.0.113.8, 198.51.100.1, 10.0.0.5", "u2"))
+	assert.Equal(t, http.StatusTooManyRequests, loginVia("10.0.0.1:1", "198.51.100.1", "u3"))
+	assert.Equal(t, http.StatusUnauthorized, loginVia("10.0.0.1:1", "198.51.100.2", "u4"), "другой клиент за тем же прокси")
+
+	// Не от прокси заголовок игнорируется
+	assert.Equal(t, http.StatusUnauthorized, loginVia("192.0.2.1:1", "198.51.100.3", "u5"))
+	assert.Equal(t, http.StatusUnauthorized, loginVia("192.0.2.1:1", "198.51.100.4", "u6"))
+	assert.Equal(t, http.StatusTooManyRequests, loginVia("192.0.2.1:1", "198.51.100.5", "u7"))
+}
+
+func TestRegisterHandler_Throttled(t *testing.T) {
+	freshLimiters(t)
+	web.Users = newMockUserStore()
+	web.SignupLimiter = auth.NewLimiter(2, time.Hour, time.Hour, time.Hour)
+
+	register := func(name string) int {
+		body, contentType := createMultipartForm(t, map[string]string{"username": name, "password": "12345"}, "", "", nil)
+		req := httptest.NewRequest(http.MethodPost, "/api/register", body)
+		req.Header.Set("Content-Type", contentType)
+		rr := httptest.NewRecorder()
+		web.RegisterHandler(rr, req)
+		return rr.Code
+	}
+	assert.Equal(t, http.StatusOK, register("u1"))
+	assert.Equal(t, http.StatusOK, register("u2"))
+	assert.Equal(t, http.StatusOK, register("u3"))
+	assert.Equal(t, http.StatusTooManyRequests, register("u4"))
+}
@@ -0,0 +1,148 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-portfolio/websocket-chat/internal/web"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loginTokens выполняет вход с "tokens": true и возвращает токены из тела ответа
+func loginTokens(t *testing.T) (access, refresh string) {
+	req := httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(`{"username":"alice","password":"secret","tokens":true}`))
+	rr := httptest.NewRecorder()
+	web.LoginHandler(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var resp struct {
+		Tokens struct {
+			AccessToken  string `json:"access_token"`
+			RefreshToken string `json:"refresh_token"`
+			TokenType    string `json:"token_type"`
+		} `json:"tokens"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.Equal(t, "Bearer", resp.Tokens.TokenType)
+	return resp.Tokens.AccessToken, resp.Tokens.RefreshToken
+}
+
+func withBearer(method, target, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rr := httptest.NewRecorder()
+	sessionMux().ServeHTTP(rr, req)
+	return rr
+}
+
+// Клиент без cookie: Bearer для API, refresh и logout через тело и заголовок
+func TestBearerTokenFlow(t *testing.T) {
+	setupSessions(t)
+	access, refresh := loginTokens(t)
+
+	rr := withBearer(http.MethodGet, "/api/whoami", access, "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "s1", rr.Body.String())
+
+	// Другие схемы авторизации не принимаются за Bearer
+	req := httptest.NewRequest(http.MethodGet, "/api/whoami", nil)
+	req.Header.Set("Authorization", "Basic "+access)
+	rr = httptest.NewRecorder()
+	sessionMux().ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr = withBearer(http.MethodPost, "/api/refresh", "", `{"refresh_token":"`+refresh+`"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, rr.Result().Cookies(), "клиенту без cookie токены приходят в теле")
+	var fresh struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&fresh))
+	assert.NotEqual(t, refresh, fresh.RefreshToken)
+
+	assert.Equal(t, http.StatusOK, withBearer(http.MethodPost, "/api/logout", fresh.AccessToken, "").Code)
+	assert.Equal(t, http.StatusUnauthorized, withBearer(http.MethodGet, "/api/whoami", fresh.AccessToken, "").Code)
+}
+
+// ticketMux — выдача билетов и /ws за WebSocketAuthMiddleware
+func ticketMux(next http.HandlerFunc) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("POST /api/ws-ticket", web.AuthMiddleware(http.HandlerFunc(web.WSTicketHandler)))
+	mux.Handle("/ws", web.WebSocketAuthMiddleware(next))
+	return mux
+}
+
+func issueTicket(t *testing.T, mux http.Handler, access string) string {
+	req := httptest.NewRequest(http.MethodPost, "/api/ws-ticket", nil)
+	req.Header.Set("Authorization", "Bearer "+access)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var resp struct {
+		Ticket    string `json:"ticket"`
+		ExpiresIn int    `json:"expires_in"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, 30, resp.ExpiresIn)
+	return resp.Ticket
+}
+
+func TestWebSocketTicket_SingleUse(t *testing.T) {
+	setupSessions(t)
+	access, _ := loginTokens(t)
+	mux := ticketMux(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := r.Context().Value(web.CtxUserKey).(string)
+		sid, _ := r.Context().Value(web.CtxSessionKey).(string)
+		_, _ = w.Write([]byte(user + "/" + sid))
+	})
+
+	ticket := issueTicket(t, mux, access)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/ws?ticket="+ticket, nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "alice/s1", rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/ws?ticket="+ticket, nil))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "билет одноразовый")
+
+	// Билет в подпротоколе
+	req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	req.Header.Set("Sec-WebSocket-Protocol", "chat, ticket."+issueTicket(t, mux, access))
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// Без билета — обычная проверка токена
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/ws", nil))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+// Настоящее подключение: сервер подтверждает подпротокол chat, иначе браузер разорвёт соединение
+func TestWebSocketTicket_Subprotocol(t *testing.T) {
+	_, hub := setupSessions(t)
+	go hub.Run()
+	access, _ := loginTokens(t)
+
+	mux := ticketMux(web.ChatConnectionHandler)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ticket := issueTicket(t, mux, access)
+	dialer := websocket.Dialer{Subprotocols: []string{"chat", "ticket." + ticket}}
+	conn, resp, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws?room=tech", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	assert.Equal(t, "chat", conn.Subprotocol())
+}
@@ -0,0 +1,380 @@
+package unit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-portfolio/websocket-chat/internal/auth"
+	"github.com/go-portfolio/websocket-chat/internal/settings"
+	"github.com/go-portfolio/websocket-chat/internal/user"
+	"github.com/go-portfolio/websocket-chat/internal/web"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// validCode — единственный код из «приложения», который принимает mockTwoFactorStore
+const validCode = "123456"
+
+type mockTwoFactor struct {
+	pending  bool
+	enabled  bool
+	recovery map[string]bool // код -> использован
+}
+
+// mockTwoFactorStore — in-memory 2FA: код из приложения всегда validCode
+type mockTwoFactorStore struct {
+	mu    sync.Mutex
+	users map[string]*mockTwoFactor
+}
+
+func newMockTwoFactorStore() *mockTwoFactorStore {
+	return &mockTwoFactorStore{users: map[string]*mockTwoFactor{}}
+}
+
+func (m *mockTwoFactorStore) get(username string) *mockTwoFactor {
+	tf, ok := m.users[username]
+	if !ok {
+		tf = &mockTwoFactor{recovery: map[string]bool{}}
+		m.users[username] = tf
+	}
+	return tf
+}
+
+func (m *mockTwoFactorStore) TwoFactor(username string) (user.TwoFactorStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tf := m.get(username)
+	st := user.TwoFactorStatus{Enabled: tf.enabled}
+	for _, used := range tf.recovery {
+		if !used {
+			st.RecoveryCodesLeft++
+		}
+	}
+	return st, nil
+}
+
+func (m *mockTwoFactorStore) BeginTOTP(username string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tf := m.get(username)
+	if tf.enabled {
+		return "", user.ErrTwoFactorEnabled
+	}
+	tf.pending = true
+	return "JBSWY3DPEHPK3PXP", nil
+}
+
+func (m *mockTwoFactorStore) EnableTOTP(username, code string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tf := m.get(username)
+	switch {
+	case tf.enabled:
+		return nil, user.ErrTwoFactorEnabled
+	case !tf.pending:
+		return nil, user.ErrTwoFactorNotStarted
+	case code != validCode:
+		return nil, user.ErrInvalidCode
+	}
+	tf.enabled, tf.pending = true, false
+	return m.newCodes(tf), nil
+}
+
+func (m *mockTwoFactorStore) newCodes(tf *mockTwoFactor) []string {
+	tf.recovery = map[string]bool{}
+	codes := make([]string, 2)
+	for i := range codes {
+		codes[i] = fmt.Sprintf("code%d-%d", len(m.users), i)
+		tf.recovery[codes[i]] = false
+	}
+	return codes
+}
+
+func (m *mockTwoFactorStore) VerifyTwoFactor(username, code string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tf := m.get(username)
+	if !tf.enabled {
+		return false, nil
+	}
+	if used, ok := tf.recovery[code]; ok && !used {
+		tf.recovery[code] = true
+		return true, nil
+	}
+	return code == validCode, nil
+}
+
+func (m *mockTwoFactorStore) NewRecoveryCodes(username string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tf := m.get(username)
+	if !tf.enabled {
+		return nil, user.ErrTwoFactorDisabled
+	}
+	return m.newCodes(tf), nil
+}
+
+func (m *mockTwoFactorStore) DisableTOTP(username string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users[username] = &mockTwoFactor{recovery: map[string]bool{}}
+	return nil
+}
+
+// enable включает 2FA пользователю в обход API
+func (m *mockTwoFactorStore) enable(username string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tf := m.get(username)
+	tf.enabled = true
+	return m.newCodes(tf)
+}
+
+type mockSettings struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func (m *mockSettings) Get(key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.values[key], nil
+}
+
+func (m *mockSettings) Set(key, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[key] = value
+	return nil
+}
+
+// setupTwoFactor подключает 2FA и настройки; после теста они отключаются
+func setupTwoFactor(t *testing.T) (*mockTwoFactorStore, *mockSettings) {
+	setupSessions(t)
+	store, s := newMockTwoFactorStore(), &mockSettings{values: map[string]string{}}
+	web.TwoFactor, web.Settings = store, s
+	t.Cleanup(func() { web.TwoFactor, web.Settings = nil, nil })
+	return store, s
+}
+
+func twoFactorMux() *http.ServeMux {
+	mux := sessionMux()
+	mux.HandleFunc("POST /api/login", web.LoginHandler)
+	mux.Handle("GET /api/2fa", web.AuthMiddleware(http.HandlerFunc(web.TwoFactorStatusHandler)))
+	mux.Handle("POST /api/2fa/setup", web.TwoFactorSetupMiddleware(http.HandlerFunc(web.TwoFactorSetupHandler)))
+	mux.Handle("POST /api/2fa/enable", web.TwoFactorSetupMiddleware(http.HandlerFunc(web.TwoFactorEnableHandler)))
+	mux.Handle("POST /api/2fa/disable", web.AuthMiddleware(http.HandlerFunc(web.TwoFactorDisableHandler)))
+	mux.Handle("POST /api/2fa/recovery-codes", web.AuthMiddleware(http.HandlerFunc(web.TwoFactorRecoveryCodesHandler)))
+	mux.Handle("GET /api/admin/settings", web.AdminMiddleware(http.HandlerFunc(web.AdminSettingsHandler)))
+	mux.Handle("PUT /api/admin/settings", web.AdminMiddleware(http.HandlerFunc(web.AdminUpdateSettingsHandler)))
+	mux.Handle("DELETE /api/admin/users/{username}/2fa", web.AdminMiddleware(http.HandlerFunc(web.AdminResetTwoFactorHandler)))
+	return mux
+}
+
+// call отправляет JSON; setup может добавить cookie или заголовки
+func call(method, target, body string, setup func(*http.Request)) (*httptest.ResponseRecorder, map[string]any) {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if setup != nil {
+		setup(req)
+	}
+	rr := httptest.NewRecorder()
+	twoFactorMux().ServeHTTP(rr, req)
+	var resp map[string]any
+	_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+	return rr, resp
+}
+
+// signedIn входит по паролю (2FA ещё не включена) и добавляет к запросу cookie сессии
+func signedIn(t *testing.T, username string) func(*http.Request) {
+	rr, _ := call(http.MethodPost, "/api/login", `{"username":"`+username+`","password":"secret"}`, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	access := cookiesByName(rr)[web.CookieName]
+	require.NotNil(t, access)
+	return func(r *http.Request) { r.AddCookie(access) }
+}
+
+func withChallenge(challenge string) func(*http.Request) {
+	return func(r *http.Request) { r.Header.Set(web.ChallengeHeader, challenge) }
+}
+
+func TestLogin_TwoFactor(t *testing.T) {
+	store, _ := setupTwoFactor(t)
+	codes := store.enable("alice")
+
+	rr, resp := call(http.MethodPost, "/api/login", `{"username":"alice","password":"secret"}`, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "2fa_required", resp["status"])
+	assert.Empty(t, rr.Result().Cookies(), "сессия выдаётся только после второго фактора")
+	challenge, _ := resp["challenge"].(string)
+	require.NotEmpty(t, challenge)
+
+	rr, _ = call(http.MethodPost, "/api/login", `{"challenge":"`+challenge+`","code":"000000"}`, nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr, resp = call(http.MethodPost, "/api/login", `{"challenge":"`+challenge+`","code":"`+codes[0]+`","tokens":true}`, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "ok", resp["status"])
+	assert.NotNil(t, resp["tokens"])
+	assert.NotNil(t, cookiesByName(rr)[web.CookieName])
+
+	// Вызов одноразовый
+	rr, _ = call(http.MethodPost, "/api/login", `{"challenge":"`+challenge+`","code":"`+validCode+`"}`, nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestLogin_TwoFactorAttemptsLimited(t *testing.T) {
+	store, _ := setupTwoFactor(t)
+	store.enable("alice")
+	// Паузы между попытками проверяются отдельно
+	web.LoginUserLimiter = auth.NewLimiter(100, time.Second, time.Second, time.Hour)
+
+	_, resp := call(http.MethodPost, "/api/login", `{"username":"alice","password":"secret"}`, nil)
+	challenge := resp["challenge"].(string)
+	for i := 0; i < web.LoginChallenges.MaxAttempts; i++ {
+		rr, _ := call(http.MethodPost, "/api/login", `{"challenge":"`+challenge+`","code":"000000"}`, nil)
+		require.Equal(t, http.StatusUnauthorized, rr.Code)
+	}
+	rr, _ := call(http.MethodPost, "/api/login", `{"challenge":"`+challenge+`","code":"`+validCode+`"}`, nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "после исчерпания попыток нужен новый вход по паролю")
+}
+
+func TestLogin_TwoFactorThrottled(t *testing.T) {
+	store, _ := setupTwoFactor(t)
+	store.enable("alice")
+	web.LoginUserLimiter = auth.NewLimiter(1, time.Hour, time.Hour, time.Hour)
+
+	// Неверные коды считаются неудачными входами: перебор TOTP через новые входы не проходит
+	_, resp := call(http.MethodPost, "/api/login", `{"username":"alice","password":"secret"}`, nil)
+	challenge := resp["challenge"].(string)
+	for i := 0; i < 2; i++ {
+		rr, _ := call(http.MethodPost, "/api/login", `{"challenge":"`+challenge+`","code":"000000"}`, nil)
+		require.Equal(t, http.StatusUnauthorized, rr.Code)
+	}
+	rr, _ := call(http.MethodPost, "/api/login", `{"username":"alice","password":"secret"}`, nil)
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+}
+
+func TestLogin_TwoFactorSetupRequired(t *testing.T) {
+	store, s := setupTwoFactor(t)
+	require.NoError(t, settings.SetBool(s, settings.RequireTwoFactor, true))
+
+	rr, resp := call(http.MethodPost, "/api/login", `{"username":"alice","password":"secret"}`, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "2fa_setup_required", resp["status"])
+	challenge := resp["challenge"].(string)
+
+	// Без включённой 2FA по вызову войти нельзя
+	rr, _ = call(http.MethodPost, "/api/login", `{"challenge":"`+challenge+`","code":"`+validCode+`"}`, nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr, resp = call(http.MethodPost, "/api/2fa/setup", "", withChallenge(challenge))
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", resp["secret"])
+	assert.Contains(t, resp["otpauth_uri"], "otpauth://totp/GoChat:alice?")
+	assert.NotEmpty(t, resp["qr_png"])
+
+	rr, _ = call(http.MethodPost, "/api/2fa/enable", `{"code":"000000"}`, withChallenge(challenge))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr, resp = call(http.MethodPost, "/api/2fa/enable", `{"code":"`+validCode+`"}`, withChallenge(challenge))
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "ok", resp["status"])
+	assert.Len(t, resp["recovery_codes"], 2)
+	assert.NotNil(t, cookiesByName(rr)[web.CookieName], "подключение 2FA завершает вход")
+	assert.True(t, store.users["alice"].enabled)
+
+	rr, _ = call(http.MethodPost, "/api/2fa/setup", "", withChallenge(challenge))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "вызов погашен")
+}
+
+func TestTwoFactorSetupMiddleware_RequiresAuth(t *testing.T) {
+	setupTwoFactor(t)
+	rr, _ := call(http.MethodPost, "/api/2fa/setup", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	rr, _ = call(http.MethodPost, "/api/2fa/setup", "", withChallenge("bogus"))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestTwoFactor_EnableAndDisable(t *testing.T) {
+	store, s := setupTwoFactor(t)
+	access := signedIn(t, "alice")
+
+	rr, resp := call(http.MethodPost, "/api/2fa/enable", `{"code":"`+validCode+`"}`, access)
+	assert.Equal(t, http.StatusConflict, rr.Code, "сначала нужен /api/2fa/setup")
+
+	rr, _ = call(http.MethodPost, "/api/2fa/setup", "", access)
+	require.Equal(t, http.StatusOK, rr.Code)
+	rr, resp = call(http.MethodPost, "/api/2fa/enable", `{"code":"`+validCode+`"}`, access)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Len(t, resp["recovery_codes"], 2)
+	assert.Nil(t, resp["status"], "вошедшему пользователю новая сессия не выдаётся")
+
+	_, resp = call(http.MethodGet, "/api/2fa", "", access)
+	assert.Equal(t, true, resp["enabled"])
+	assert.Equal(t, float64(2), resp["recovery_codes_left"])
+
+	rr, resp = call(http.MethodPost, "/api/2fa/recovery-codes", `{"code":"`+validCode+`"}`, access)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Len(t, resp["recovery_codes"], 2)
+
+	rr, _ = call(http.MethodPost, "/api/2fa/disable", `{"code":"000000"}`, access)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	require.NoError(t, settings.SetBool(s, settings.RequireTwoFactor, true))
+	rr, _ = call(http.MethodPost, "/api/2fa/disable", `{"code":"`+validCode+`"}`, access)
+	assert.Equal(t, http.StatusForbidden, rr.Code, "администратор требует 2FA")
+
+	require.NoError(t, settings.SetBool(s, settings.RequireTwoFactor, false))
+	rr, _ = call(http.MethodPost, "/api/2fa/disable", `{"code":"`+validCode+`"}`, access)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.False(t, store.users["alice"].enabled)
+}
+
+// Перебор кода по украденной cookie упирается в те же ограничения, что и вход
+func TestTwoFactor_DisableThrottled(t *testing.T) {
+	_, _ = setupTwoFactor(t)
+	access := signedIn(t, "alice")
+	rr, _ := call(http.MethodPost, "/api/2fa/setup", "", access)
+	require.Equal(t, http.StatusOK, rr.Code)
+	rr, _ = call(http.MethodPost, "/api/2fa/enable", `{"code":"`+validCode+`"}`, access)
+	require.Equal(t, http.StatusOK, rr.Code)
+	web.LoginUserLimiter = auth.NewLimiter(2, time.Hour, time.Hour, time.Hour)
+
+	for _, path := range []string{"/api/2fa/disable", "/api/2fa/recovery-codes", "/api/2fa/disable"} {
+		rr, _ = call(http.MethodPost, path, `{"code":"000000"}`, access)
+		require.Equal(t, http.StatusBadRequest, rr.Code, path)
+	}
+	for _, path := range []string{"/api/2fa/disable", "/api/2fa/recovery-codes"} {
+		rr, _ = call(http.MethodPost, path, `{"code":"`+validCode+`"}`, access)
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code, path)
+		assert.Equal(t, "3600", rr.Header().Get("Retry-After"))
+	}
+}
+
+func TestAdminTwoFactorSettings(t *testing.T) {
+	store, _ := setupTwoFactor(t)
+	users := web.Users.(*mockUserStore)
+	_ = users.Register("root", "secret", "")
+	users.setFlags("root", true, false)
+	root := signedIn(t, "root")
+
+	rr, _ := call(http.MethodGet, "/api/admin/settings", "", signedIn(t, "alice"))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr, resp := call(http.MethodPut, "/api/admin/settings", `{"require_2fa":true}`, root)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, true, resp["require_2fa"])
+	_, resp = call(http.MethodGet, "/api/admin/settings", "", root)
+	assert.Equal(t, true, resp["require_2fa"])
+
+	store.enable("alice")
+	rr, _ = call(http.MethodDelete, "/api/admin/users/alice/2fa", "", root)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.False(t, store.users["alice"].enabled)
+}
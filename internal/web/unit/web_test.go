@@ -2,8 +2,12 @@ package unit
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"image"
+	"image/color"
+	"image/png"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
@@ -13,6 +17,8 @@ import (
 	"testing"
 
 	"github.com/go-portfolio/websocket-chat/internal/auth"
+	"github.com/go-portfolio/websocket-chat/internal/storage"
+	"github.com/go-portfolio/websocket-chat/internal/user"
 	"github.com/go-portfolio/websocket-chat/internal/web"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/crypto/bcrypt"
@@ -35,14 +41,20 @@ import (
 
 // mockUser хранит bcrypt-хэш пароля и имя файла аватара
 type mockUser struct {
-	hash   []byte // bcrypt-хэш пароля
-	avatar string // путь/имя аватара
+	hash     []byte // bcrypt-хэш пароля
+	avatar   string // путь/имя аватара
+	email    string // e-mail для дайджестов
+	verified bool   // e-mail подтверждён
+	digest   bool   // согласие на дайджесты
+	admin    bool   // администратор сервера
+	banned   bool   // заблокирован
 }
 
 // mockUserStore — in-memory хранилище пользователей с защитой от конкурентного доступа
 type mockUserStore struct {
-	mu    sync.Mutex          // защита от параллельного доступа
-	users map[string]mockUser // ключ: username, значение: mockUser
+	mu     sync.Mutex          // защита от параллельного доступа
+	users  map[string]mockUser // ключ: username, значение: mockUser
+	policy user.PasswordPolicy // политика паролей, как у user.Store
 }
 
 // newMockUserStore создаёт новый in-memory store
@@ -58,11 +70,8 @@ func newMockUserStore() *mockUserStore {
 // - хеширует пароль через bcrypt для последующей проверки в Authenticate
 func (m *mockUserStore) Register(username, password, avatar string) error {
 	username = strings.TrimSpace(username) // убираем пробелы с краёв
-	if username == "" || password == "" {
-		return fmt.Errorf("username and password are required")
-	}
-	if len(username) > 24 {
-		return fmt.Errorf("username too long (max 24)")
+	if err := m.CheckCredentials(username, password); err != nil {
+		return err
 	}
 
 	m.mu.Lock()         // блокировка для безопасного доступа к карте users
@@ -88,11 +97,23 @@ func (m *mockUserStore) Register(username, password, avatar string) error {
 
 // Authenticate проверяет соответствие введённого пароля сохранённому bcrypt-хэшу
 // Возвращает true только при корректном username+password
+// CheckCredentials повторяет проверки user.Store: пустые поля, длина имени, политика паролей
+func (m *mockUserStore) CheckCredentials(username, password string) error {
+	username = strings.TrimSpace(username)
+	if username == "" || password == "" {
+		return fmt.Errorf("username and password are required")
+	}
+	if len(username) > 24 {
+		return fmt.Errorf("username too long (max 24)")
+	}
+	return m.policy.Check(username, password)
+}
+
 func (m *mockUserStore) Authenticate(username, password string) bool {
 	m.mu.Lock()
 	u, ok := m.users[username]
 	m.mu.Unlock()
-	if !ok { // пользователь не найден
+	if !ok || u.banned { // пользователь не найден или заблокирован
 		return false
 	}
 	return bcrypt.CompareHashAndPassword(u.hash, []byte(password)) == nil
@@ -108,7 +129,84 @@ func (m *mockUserStore) GetAvatar(username string) string {
 	return ""
 }
 
+// SetAvatar заменяет аватар существующего пользователя
+func (m *mockUserStore) SetAvatar(username, avatar string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[username]
+	if !ok {
+		return fmt.Errorf("user not found")
+	}
+	u.avatar = avatar
+	m.users[username] = u
+	return nil
+}
+
+// Exists — проверка, что пользователь зарегистрирован
+func (m *mockUserStore) Exists(username string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.users[username]
+	return ok, nil
+}
+
+// Touch — время последней активности в моке не хранится
+func (m *mockUserStore) Touch(username string) error { return nil }
+
+// GetEmail — e-mail и согласие на дайджесты
+func (m *mockUserStore) GetEmail(username string) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[username]
+	if !ok {
+		return "", false, fmt.Errorf("user not found")
+	}
+	return u.email, u.digest, nil
+}
+
+// SetEmail — упрощённая проверка адреса: наличие "@"
+func (m *mockUserStore) SetEmail(username, email string, digest bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[username]
+	if !ok {
+		return fmt.Errorf("user not found")
+	}
+	if email != "" && !strings.Contains(email, "@") {
+		return user.ErrInvalidEmail
+	}
+	if u.email != email {
+		u.verified = false
+	}
+	u.email, u.digest = email, digest && email != ""
+	m.users[username] = u
+	return nil
+}
+
 // Close — пустая реализация для совместимости с интерфейсом
+// IsAdmin — права администратора сервера
+func (m *mockUserStore) IsAdmin(username string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.users[username].admin, nil
+}
+
+// IsBanned — блокировка пользователя
+func (m *mockUserStore) IsBanned(username string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.users[username].banned, nil
+}
+
+// setFlags задаёт права и блокировку существующего пользователя
+func (m *mockUserStore) setFlags(username string, admin, banned bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u := m.users[username]
+	u.admin, u.banned = admin, banned
+	m.users[username] = u
+}
+
 func (m *mockUserStore) Close() error { return nil }
 
 /*
@@ -156,6 +254,7 @@ func createMultipartForm(t *testing.T, fields map[string]string, fileField, file
 
 // Успешная регистрация без аватара
 func TestRegisterHandler_Success_NoAvatar(t *testing.T) {
+	freshLimiters(t)
 	web.Users = newMockUserStore() // подставляем мок вместо реальной БД
 
 	// Формируем multipart без файла (только username и password)
@@ -183,6 +282,7 @@ func TestRegisterHandler_Success_NoAvatar(t *testing.T) {
 
 // Некорректный form-data (ошибка ParseMultipartForm)
 func TestRegisterHandler_InvalidForm(t *testing.T) {
+	freshLimiters(t)
 	web.Users = newMockUserStore() // Users не нужен для этого теста, но не должен паниковать
 
 	req := httptest.NewRequest(http.MethodPost, "/api/register", strings.NewReader("not a multipart"))
@@ -196,6 +296,7 @@ func TestRegisterHandler_InvalidForm(t *testing.T) {
 
 // Повторная регистрация (duplicate username)
 func TestRegisterHandler_DuplicateUser(t *testing.T) {
+	freshLimiters(t)
 	web.Users = newMockUserStore()
 	err := web.Users.Register("bob", "pass", "")
 	assert.NoError(t, err) // пользователь успешно зарегистрирован
@@ -216,12 +317,256 @@ func TestRegisterHandler_DuplicateUser(t *testing.T) {
 	assert.Contains(t, rr.Body.String(), "username already exists")
 }
 
+// testPNG создаёт настоящее PNG-изображение заданного размера
+func testPNG(t *testing.T, w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// Регистрация с аватаром: имя файла от клиента не используется, ключ — по содержимому
+func TestRegisterHandler_AvatarSafeName(t *testing.T) {
+	freshLimiters(t)
+	web.Users = newMockUserStore()
+	blobs := storage.NewMemoryStore()
+	web.Blobs = blobs
+
+	body, contentType := createMultipartForm(t,
+		map[string]string{"username": "alice", "password": "12345"},
+		"avatar", "../../etc/passwd.png", testPNG(t, 120, 80),
+	)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/register", body)
+	req.Header.Set("Content-Type", contentType)
+	rr := httptest.NewRecorder()
+
+	web.RegisterHandler(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	keys := blobs.Keys()
+	assert.Len(t, keys, 3, "должны быть сохранены варианты 32, 64 и 256")
+	for _, key := range keys {
+		assert.True(t, strings.HasPrefix(key, "avatars/"))
+		assert.NotContains(t, key, "passwd")
+	}
+
+	// URL в профиле совпадает с реально сохранённым объектом
+	stored := web.Users.GetAvatar("alice")
+	assert.Contains(t, keys, strings.TrimPrefix(stored, "/uploads/"))
+}
+
+// Слабый пароль отклоняется до сохранения аватара: файлов без владельца не остаётся
+func TestRegisterHandler_WeakPasswordKeepsNoAvatar(t *testing.T) {
+	freshLimiters(t)
+	users := newMockUserStore()
+	users.policy = user.PasswordPolicy{MinLength: 8}
+	web.Users = users
+	blobs := storage.NewMemoryStore()
+	web.Blobs = blobs
+
+	body, contentType := createMultipartForm(t,
+		map[string]string{"username": "alice", "password": "12345"},
+		"avatar", "me.png", testPNG(t, 64, 64),
+	)
+	req := httptest.NewRequest(http.MethodPost, "/api/register", body)
+	req.Header.Set("Content-Type", contentType)
+	rr := httptest.NewRecorder()
+
+	web.RegisterHandler(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "weak password")
+	assert.Empty(t, blobs.Keys())
+}
+
+// Файл, не являющийся изображением, отклоняется
+func TestRegisterHandler_AvatarWrongType(t *testing.T) {
+	freshLimiters(t)
+	web.Users = newMockUserStore()
+	web.Blobs = storage.NewMemoryStore()
+
+	body, contentType := createMultipartForm(t,
+		map[string]string{"username": "alice", "password": "12345"},
+		"avatar", "avatar.png", []byte("<html><script>alert(1)</script></html>"),
+	)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/register", body)
+	req.Header.Set("Content-Type", contentType)
+	rr := httptest.NewRecorder()
+
+	web.RegisterHandler(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "file type not allowed")
+	assert.False(t, web.Users.Authenticate("alice", "12345"), "пользователь не должен быть создан")
+}
+
+// Замена аватара: варианты квадратные, URL всех размеров возвращаются клиенту
+func TestAvatarUploadHandler_ReplacesAvatar(t *testing.T) {
+	web.Users = newMockUserStore()
+	_ = web.Users.Register("alice", "12345", "/uploads/old.png")
+	blobs := storage.NewMemoryStore()
+	web.Blobs = blobs
+
+	body, contentType := createMultipartForm(t, nil, "avatar", "me.png", testPNG(t, 300, 100))
+	req := httptest.NewRequest(http.MethodPut, "/api/profile/avatar", body)
+	req.Header.Set("Content-Type", contentType)
+	req = req.WithContext(context.WithValue(req.Context(), web.CtxUserKey, "alice"))
+	rr := httptest.NewRecorder()
+
+	web.AvatarUploadHandler(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var resp struct {
+		Avatar  string            `json:"avatar"`
+		Avatars map[string]string `json:"avatars"`
+	}
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, resp.Avatar, web.Users.GetAvatar("alice"))
+	assert.Equal(t, resp.Avatar, resp.Avatars["256"])
+
+	// Каждый вариант — квадрат нужного размера
+	for size, url := range resp.Avatars {
+		rc, _, err := blobs.Open(strings.TrimPrefix(url, "/uploads/"))
+		if !assert.NoError(t, err) {
+			continue
+		}
+		cfg, err := png.DecodeConfig(rc)
+		rc.Close()
+		assert.NoError(t, err)
+		assert.Equal(t, size, fmt.Sprint(cfg.Width))
+		assert.Equal(t, cfg.Width, cfg.Height)
+	}
+}
+
+// Данные с сигнатурой PNG, но не являющиеся изображением, отклоняются
+func TestAvatarUploadHandler_NotAnImage(t *testing.T) {
+	web.Users = newMockUserStore()
+	_ = web.Users.Register("alice", "12345", "")
+	web.Blobs = storage.NewMemoryStore()
+
+	body, contentType := createMultipartForm(t, nil, "avatar", "me.png", []byte("\x89PNG\r\n\x1a\ngarbage"))
+	req := httptest.NewRequest(http.MethodPut, "/api/profile/avatar", body)
+	req.Header.Set("Content-Type", contentType)
+	req = req.WithContext(context.WithValue(req.Context(), web.CtxUserKey, "alice"))
+	rr := httptest.NewRecorder()
+
+	web.AvatarUploadHandler(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "not a supported image")
+	assert.Equal(t, "", web.Users.GetAvatar("alice"))
+}
+
+// E-mail сохраняется и возвращается в профиле, некорректный адрес отклоняется
+func TestEmailSettingsHandler(t *testing.T) {
+	freshLimiters(t)
+	web.Users = newMockUserStore()
+	_ = web.Users.Register("alice", "12345", "")
+
+	put := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/profile/email", strings.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), web.CtxUserKey, "alice"))
+		rr := httptest.NewRecorder()
+		web.EmailSettingsHandler(rr, req)
+		return rr
+	}
+
+	rr := put(`{"email": "alice@example.com", "email_digest": true, "current_password": "12345"}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"email": "alice@example.com", "email_digest": true, "email_verified": false}`, rr.Body.String())
+
+	assert.Equal(t, http.StatusOK, put(`{"email": "alice@example.com", "email_digest": true}`).Code, "без смены адреса пароль не нужен")
+	assert.Equal(t, http.StatusBadRequest, put(`{"email": "nope", "current_password": "12345"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, put(`not json`).Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/profile", nil)
+	req = req.WithContext(context.WithValue(req.Context(), web.CtxUserKey, "alice"))
+	rr = httptest.NewRecorder()
+	web.ProfileHandler(rr, req)
+	var profile struct {
+		Email  string `json:"email"`
+		Digest bool   `json:"email_digest"`
+	}
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&profile))
+	assert.Equal(t, "alice@example.com", profile.Email)
+	assert.True(t, profile.Digest)
+}
+
+// UploadsHandler отдаёт объект с сохранённым типом и запретом sniffing
+func TestUploadsHandler(t *testing.T) {
+	blobs := storage.NewMemoryStore()
+	_ = blobs.Put("avatars/a.png", []byte("png"), "image/png")
+	web.Blobs = blobs
+
+	req := httptest.NewRequest(http.MethodGet, "/uploads/avatars/a.png", nil)
+	rr := httptest.NewRecorder()
+	web.UploadsHandler(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "image/png", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "nosniff", rr.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "png", rr.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/uploads/avatars/missing.png", nil)
+	rr = httptest.NewRecorder()
+	web.UploadsHandler(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+// Вложения и миниатюры не отдаются в обход проверки доступа к комнате
+func TestUploadsHandler_OnlyAvatars(t *testing.T) {
+	blobs := storage.NewMemoryStore()
+	_ = blobs.Put("attachments/a.pdf", []byte("secret"), "application/pdf")
+	_ = blobs.Put("thumbnails/a.png", []byte("png"), "image/png")
+	web.Blobs = blobs
+
+	for _, path := range []string{"/uploads/attachments/a.pdf", "/uploads/thumbnails/a.png", "/uploads/avatars/../attachments/a.pdf"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rr := httptest.NewRecorder()
+		web.UploadsHandler(rr, req)
+		assert.Equal(t, http.StatusNotFound, rr.Code, path)
+		assert.NotContains(t, rr.Body.String(), "secret", path)
+	}
+}
+
+// Аватары, загруженные до вариантов размеров (/uploads/<время>_<имя>), по-прежнему отдаются,
+// но только если это изображения
+func TestUploadsHandler_LegacyAvatars(t *testing.T) {
+	blobs := storage.NewMemoryStore()
+	_ = blobs.Put("1700000000_me.jpg", []byte("jpeg"), "image/jpeg")
+	_ = blobs.Put("1700000001_page.html", []byte("<script>"), "text/html")
+	web.Blobs = blobs
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		web.UploadsHandler(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr
+	}
+	rr := get("/uploads/1700000000_me.jpg")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "image/jpeg", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "jpeg", rr.Body.String())
+
+	assert.Equal(t, http.StatusNotFound, get("/uploads/1700000001_page.html").Code)
+	assert.Equal(t, http.StatusNotFound, get("/uploads/me.jpg").Code)
+}
+
 /* ==========================
    ТЕСТЫ LoginHandler
    ========================== */
 
 // Успешный логин с корректными данными
 func TestLoginHandler_Success(t *testing.T) {
+	freshLimiters(t)
 	web.Users = newMockUserStore()
 	_ = web.Users.Register("john", "secret", "avatar.png") // создаём тестового пользователя
 
@@ -233,11 +578,16 @@ func TestLoginHandler_Success(t *testing.T) {
 
 	// Проверяем статус и JSON ответ
 	assert.Equal(t, http.StatusOK, rr.Code)
-	var resp map[string]string
+	var resp struct {
+		Status  string            `json:"status"`
+		Avatar  string            `json:"avatar"`
+		Avatars map[string]string `json:"avatars"`
+	}
 	err := json.NewDecoder(rr.Body).Decode(&resp)
 	assert.NoError(t, err)
-	assert.Equal(t, "ok", resp["status"])
-	assert.Equal(t, "avatar.png", resp["avatar"]) // проверяем аватар
+	assert.Equal(t, "ok", resp.Status)
+	assert.Equal(t, "avatar.png", resp.Avatar)        // проверяем аватар
+	assert.Equal(t, "avatar.png", resp.Avatars["64"]) // старый аватар без вариантов
 
 	// Проверяем установку cookie авторизации
 	cookies := rr.Result().Cookies()
@@ -251,8 +601,59 @@ func TestLoginHandler_Success(t *testing.T) {
 	assert.True(t, found, "должна быть установлена cookie авторизации")
 }
 
+// Пользователь без аватара получает сгенерированный
+func TestLoginHandler_DefaultAvatar(t *testing.T) {
+	freshLimiters(t)
+	web.Users = newMockUserStore()
+	_ = web.Users.Register("john", "secret", "")
+
+	body := `{"username":"john","password":"secret"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+
+	web.LoginHandler(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var resp struct {
+		Avatar  string            `json:"avatar"`
+		Avatars map[string]string `json:"avatars"`
+	}
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, web.DefaultAvatarURL("john"), resp.Avatar)
+	assert.Equal(t, "/avatars/john.svg", resp.Avatars["32"])
+}
+
+// Сгенерированный аватар отдаётся как SVG с кэширующими заголовками
+func TestDefaultAvatarHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /avatars/{name}", web.DefaultAvatarHandler)
+
+	req := httptest.NewRequest(http.MethodGet, "/avatars/john.svg", nil)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "image/svg+xml", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Header().Get("Cache-Control"), "max-age")
+	assert.True(t, strings.HasPrefix(rr.Body.String(), "<svg"))
+
+	// Повторный запрос с ETag — 304
+	req = httptest.NewRequest(http.MethodGet, "/avatars/john.svg", nil)
+	req.Header.Set("If-None-Match", rr.Header().Get("ETag"))
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+
+	// Путь без .svg не обслуживается
+	req = httptest.NewRequest(http.MethodGet, "/avatars/john", nil)
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
 // Некорректный JSON
 func TestLoginHandler_InvalidJSON(t *testing.T) {
+	freshLimiters(t)
 	web.Users = newMockUserStore() // неважно
 	req := httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader("bad json"))
 	rr := httptest.NewRecorder()
@@ -265,6 +666,7 @@ func TestLoginHandler_InvalidJSON(t *testing.T) {
 
 // Логин с неверным паролем
 func TestLoginHandler_BadCredentials(t *testing.T) {
+	freshLimiters(t)
 	web.Users = newMockUserStore()
 	_ = web.Users.Register("john", "secret", "")
 
@@ -278,10 +680,49 @@ func TestLoginHandler_BadCredentials(t *testing.T) {
 	assert.Contains(t, rr.Body.String(), "invalid credentials")
 }
 
+// Встроенный index.html отдаётся с ETag, повторный запрос с If-None-Match получает 304
+func TestIndexHandler_EmbeddedWithETag(t *testing.T) {
+	web.StaticDir = ""
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rr := httptest.NewRecorder()
+	web.IndexHandler(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Header().Get("Content-Type"), "text/html")
+	assert.Equal(t, "no-cache", rr.Header().Get("Cache-Control"))
+	assert.Contains(t, rr.Body.String(), "<!DOCTYPE html>")
+
+	etag := rr.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	web.IndexHandler(rr, req)
+
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+}
+
+// Каталог StaticDir перекрывает встроенный UI
+func TestIndexHandler_StaticDirOverride(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(dir+"/index.html", []byte("<p>dev</p>"), 0o644))
+	web.StaticDir = dir
+	defer func() { web.StaticDir = "" }()
+
+	req := httptest.NewRequest(http.MethodGet, "/some/route", nil)
+	rr := httptest.NewRecorder()
+	web.IndexHandler(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "<p>dev</p>", rr.Body.String())
+}
 
 // Если index.html нет — handler возвращает 500 и сообщение об ошибке
 func TestIndexHandler_NotFound(t *testing.T) {
-	_ = os.Remove("../../internal/web/index.html") // удаляем файл, если есть
+	web.StaticDir = t.TempDir() // пустой каталог вместо встроенного UI
+	defer func() { web.StaticDir = "" }()
 
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 	rr := httptest.NewRecorder()
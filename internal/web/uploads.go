@@ -0,0 +1,63 @@
+package web
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/go-portfolio/websocket-chat/internal/storage"
+)
+
+// publicPrefix — префикс ключей аватаров, доступных без проверки прав.
+// Вложения и их миниатюры отдаются только через /api/attachments/{id}
+const publicPrefix = "avatars/"
+
+// legacyAvatarKey — аватары, загруженные до появления вариантов размеров: они лежат
+// в корне хранилища под именами <unix-время>_<имя файла>, и ссылки на них остались
+// в users.avatar. Такие файлы тогда не проверялись, поэтому отдаются только изображения
+var legacyAvatarKey = regexp.MustCompile(`^[0-9]+_[^/]+$`)
+
+// UploadsURL возвращает публичный URL объекта из хранилища
+func UploadsURL(key string) string {
+	return "/uploads/" + key
+}
+
+// =========================
+// UploadsHandler
+// GET /uploads/{key...}
+// Отдаёт аватары из BlobStore (в том числе старые, см. legacyAvatarKey); остальные ключи — 404
+// =========================
+func UploadsHandler(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/uploads/")
+	legacy := legacyAvatarKey.MatchString(key)
+	if !strings.HasPrefix(key, publicPrefix) && !legacy {
+		http.NotFound(w, r)
+		return
+	}
+
+	rc, contentType, err := Blobs.Open(key)
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("uploads: open %q: %v", key, err)
+		http.Error(w, "storage error", http.StatusInternalServerError)
+		return
+	}
+	defer rc.Close()
+	if legacy && !(storage.Policy{AllowedTypes: storage.DefaultImageTypes}).Allows(contentType) {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	// Браузер не должен «угадывать» тип: файл отдаётся строго как сохранённый тип
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// Ключи вычисляются из содержимого, поэтому объект по ключу никогда не меняется
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	_, _ = io.Copy(w, rc)
+}
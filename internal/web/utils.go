@@ -1,6 +1,9 @@
 package web
 
-import "net/http"
+import (
+	"encoding/json"
+	"net/http"
+)
 
 // =========================
 // withJSON задаёт заголовки JSON
@@ -9,3 +12,19 @@ func withJSON(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.Header().Set("Cache-Control", "no-store")
 }
+
+// =========================
+// writeJSON отправляет JSON-ответ с указанным статусом
+// =========================
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	withJSON(w)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// =========================
+// writeError отправляет JSON-ошибку {"error": msg}
+// =========================
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -3,8 +3,16 @@ package web
 import (
 	"log"
 	"net/http"
+	"time"
 
+	"github.com/go-portfolio/websocket-chat/internal/attachment"
 	"github.com/go-portfolio/websocket-chat/internal/chat"
+	"github.com/go-portfolio/websocket-chat/internal/mention"
+	"github.com/go-portfolio/websocket-chat/internal/message"
+	"github.com/go-portfolio/websocket-chat/internal/notify"
+	"github.com/go-portfolio/websocket-chat/internal/room"
+	"github.com/go-portfolio/websocket-chat/internal/session"
+	"github.com/go-portfolio/websocket-chat/internal/storage"
 	"github.com/go-portfolio/websocket-chat/internal/user"
 	"github.com/gorilla/websocket"
 )
@@ -13,11 +21,48 @@ import (
 // Глобальные переменные (можно инжектировать в main.go)
 // =========================
 var (
-	ChatHub    *chat.Hub      // Ссылка на Hub чата
-	Users      user.UserStore // Хранилище пользователей
-	CookieName = "auth"       // Имя cookie для хранения JWT
+	ChatHub       *chat.Hub                  // Ссылка на Hub чата
+	Users         user.UserStore             // Хранилище пользователей
+	Blobs         storage.BlobStore          // Хранилище загруженных файлов
+	Rooms         room.RoomStore             // Комнаты и их участники
+	Attachments   attachment.AttachmentStore // Метаданные вложений
+	Mentions      mention.MentionStore       // Упоминания пользователей
+	Notifications notify.NotificationStore   // Входящие уведомления
+	Inbox         *notify.Inbox              // Создание и доставка уведомлений (nil — о модерации не уведомляем)
+	Messages      message.MessageStore       // История сообщений
+	Sessions      session.SessionStore       // Сессии входа и refresh-токены (nil — токены без сессий)
 )
 
+// =========================
+// Параметры HTTP-слоя (переопределяются из конфига в app.New)
+// =========================
+var (
+	CookieName     = "auth"               // Имя cookie для хранения JWT
+	CookieSecure   = false                // Флаг Secure для cookie
+	CookieSameSite = http.SameSiteLaxMode // Политика SameSite для cookie
+
+	RefreshTTL = 30 * 24 * time.Hour // Время жизни сессии без обновления refresh-токена
+
+	MaxUploadSize int64 = 10 << 20 // Лимит размера multipart-формы
+
+	AdminToken string // Токен admin API (для chatctl); пусто — только администраторы по cookie
+
+	// Ограничения для аватаров: размер и типы по содержимому файла
+	AvatarPolicy = storage.Policy{MaxSize: 2 << 20, AllowedTypes: storage.DefaultImageTypes}
+
+	// Ограничения для вложений в сообщениях
+	AttachmentPolicy = storage.Policy{
+		MaxSize:      10 << 20,
+		AllowedTypes: append([]string{"application/pdf", "text/plain", "application/zip"}, storage.DefaultImageTypes...),
+	}
+)
+
+// SetBufferSizes задаёт размеры буферов WebSocket-апгрейдера
+func SetBufferSizes(read, write int) {
+	upgrader.ReadBufferSize = read
+	upgrader.WriteBufferSize = write
+}
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
@@ -36,22 +81,56 @@ func ChatConnectionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Заблокированные пользователи не подключаются, даже с ещё действующим токеном
+	if banned, err := Users.IsBanned(username); err != nil || banned {
+		if err != nil {
+			log.Printf("ban check error: %v", err)
+		}
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
 	roomName := r.URL.Query().Get("room")
 	if roomName == "" {
 		roomName = "default"
 	}
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+	var header http.Header
+	if p := responseProtocol(r); p != "" {
+		header = http.Header{"Sec-WebSocket-Protocol": {p}}
+	}
+	conn, err := upgrader.Upgrade(w, r, header)
 	if err != nil {
 		log.Printf("WebSocket upgrade error: %v", err)
 		return
 	}
 
-	room := ChatHub.GetRoom(roomName)
-	client := chat.NewClient(ChatHub, room, conn, username)
-	room.AddClient(client)
+	// Запоминаем участника комнаты: от этого зависит доступ к вложениям
+	if Rooms != nil {
+		if err := Rooms.Join(roomName, username); err != nil {
+			log.Printf("room join error: %v", err)
+		}
+	}
+
+	// Время последней активности нужно для e-mail дайджестов
+	touch(username)
+	defer touch(username)
+
+	chatRoom := ChatHub.GetRoom(roomName)
+	client := chat.NewClient(ChatHub, chatRoom, conn, username)
+	client.SessionID, _ = r.Context().Value(CtxSessionKey).(string)
+	chatRoom.AddClient(client)
 	ChatHub.RegisterCh <- client
 
 	go client.WriteSocket()
 	client.ReadSocket()
 }
+
+// =========================
+// touch обновляет время последней активности пользователя
+// =========================
+func touch(username string) {
+	if err := Users.Touch(username); err != nil {
+		log.Printf("last seen update error: %v", err)
+	}
+}
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS attachments;
+DROP TABLE IF EXISTS room_members;
+DROP TABLE IF EXISTS rooms;
@@ -0,0 +1,27 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS rooms (
+    name VARCHAR(64) PRIMARY KEY,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS room_members (
+    room VARCHAR(64) NOT NULL REFERENCES rooms(name) ON DELETE CASCADE,
+    username VARCHAR(24) NOT NULL REFERENCES users(username) ON DELETE CASCADE,
+    joined_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (room, username)
+);
+
+CREATE TABLE IF NOT EXISTS attachments (
+    id VARCHAR(32) PRIMARY KEY,
+    owner VARCHAR(24) NOT NULL REFERENCES users(username) ON DELETE CASCADE,
+    room VARCHAR(64),
+    peer VARCHAR(24),
+    name TEXT NOT NULL,
+    size BIGINT NOT NULL,
+    mime_type TEXT NOT NULL,
+    blob_key TEXT NOT NULL,
+    thumb_key TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS attachments_owner_idx ON attachments (owner);
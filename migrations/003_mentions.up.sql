@@ -0,0 +1,14 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS mentions (
+    id BIGSERIAL PRIMARY KEY,
+    username VARCHAR(24) NOT NULL REFERENCES users(username) ON DELETE CASCADE,
+    message_id VARCHAR(32) NOT NULL,
+    room VARCHAR(64) NOT NULL,
+    sender VARCHAR(24) NOT NULL,
+    kind VARCHAR(8) NOT NULL,
+    text TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (username, message_id)
+);
+
+CREATE INDEX IF NOT EXISTS mentions_username_idx ON mentions (username, id DESC);
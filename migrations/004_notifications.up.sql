@@ -0,0 +1,15 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS notifications (
+    id BIGSERIAL PRIMARY KEY,
+    username VARCHAR(24) NOT NULL REFERENCES users(username) ON DELETE CASCADE,
+    kind VARCHAR(16) NOT NULL,
+    actor VARCHAR(24) NOT NULL DEFAULT '',
+    room VARCHAR(64) NOT NULL DEFAULT '',
+    message_id VARCHAR(32) NOT NULL DEFAULT '',
+    text TEXT NOT NULL,
+    read_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS notifications_username_idx ON notifications (username, id DESC);
+CREATE INDEX IF NOT EXISTS notifications_unread_idx ON notifications (username) WHERE read_at IS NULL;
@@ -0,0 +1,4 @@
+ALTER TABLE users DROP COLUMN IF EXISTS digest_sent_at;
+ALTER TABLE users DROP COLUMN IF EXISTS last_seen_at;
+ALTER TABLE users DROP COLUMN IF EXISTS email_digest;
+ALTER TABLE users DROP COLUMN IF EXISTS email;
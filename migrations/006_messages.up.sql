@@ -0,0 +1,17 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS messages (
+    id VARCHAR(32) PRIMARY KEY,
+    room VARCHAR(64) NOT NULL DEFAULT '',
+    sender VARCHAR(24) NOT NULL,
+    recipient VARCHAR(24) NOT NULL DEFAULT '',
+    type VARCHAR(16) NOT NULL,
+    text TEXT NOT NULL DEFAULT '',
+    attachment_id VARCHAR(32),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    -- Конфигурация russian стеммит и кириллицу, и латиницу (english_stem)
+    tsv TSVECTOR GENERATED ALWAYS AS (to_tsvector('russian', text)) STORED
+);
+
+CREATE INDEX IF NOT EXISTS messages_tsv_idx ON messages USING GIN (tsv);
+CREATE INDEX IF NOT EXISTS messages_room_created_idx ON messages (room, created_at);
+CREATE INDEX IF NOT EXISTS messages_dm_idx ON messages (sender, recipient) WHERE recipient <> '';
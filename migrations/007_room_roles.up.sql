@@ -0,0 +1,9 @@
+-- +migrate Up
+ALTER TABLE room_members ADD COLUMN IF NOT EXISTS role VARCHAR(16) NOT NULL DEFAULT 'member';
+
+-- Владельцем существующей комнаты становится тот, кто вошёл в неё первым
+UPDATE room_members rm SET role = 'owner'
+FROM (
+    SELECT DISTINCT ON (room) room, username FROM room_members ORDER BY room, joined_at, username
+) first
+WHERE rm.room = first.room AND rm.username = first.username;
@@ -0,0 +1,16 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS sessions (
+    id VARCHAR(32) PRIMARY KEY,
+    username VARCHAR(24) NOT NULL REFERENCES users(username) ON DELETE CASCADE,
+    refresh_hash CHAR(64) NOT NULL UNIQUE,
+    prev_refresh_hash CHAR(64),
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS sessions_username_idx ON sessions (username) WHERE revoked_at IS NULL;
+CREATE INDEX IF NOT EXISTS sessions_prev_refresh_idx ON sessions (prev_refresh_hash);
@@ -0,0 +1,16 @@
+-- +migrate Up
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMPTZ;
+
+-- Адрес аккаунта, созданного входом через OIDC, подтвердил провайдер
+UPDATE users SET email_verified_at = COALESCE(created_at, NOW())
+WHERE email IS NOT NULL AND password_hash = '!' AND email_verified_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS email_verifications (
+    token_hash CHAR(64) PRIMARY KEY,
+    username VARCHAR(24) NOT NULL REFERENCES users(username) ON DELETE CASCADE,
+    email VARCHAR(254) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS email_verifications_username_idx ON email_verifications (username);
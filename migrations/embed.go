@@ -0,0 +1,9 @@
+// Package migrations встраивает SQL-миграции в бинарник сервера
+package migrations
+
+import "embed"
+
+// FS содержит файлы NNN_name.up.sql и NNN_name.down.sql
+//
+//go:embed *.sql
+var FS embed.FS